@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"sync"
@@ -16,6 +17,43 @@ var cleanupHandlers struct {
 	ch   chan os.Signal
 }
 
+// shutdown is used to cancel the global context when the first signal is
+// received, such that the running command can stop gracefully.
+var shutdown struct {
+	sync.Mutex
+	cancel    context.CancelFunc
+	requested bool
+}
+
+// setShutdownFunc sets the function which is called to request a graceful
+// shutdown of the currently running command.
+func setShutdownFunc(cancel context.CancelFunc) {
+	shutdown.Lock()
+	defer shutdown.Unlock()
+	shutdown.cancel = cancel
+}
+
+// requestShutdown cancels the global context. It returns false if a shutdown
+// was already requested before or is not possible.
+func requestShutdown() bool {
+	shutdown.Lock()
+	defer shutdown.Unlock()
+
+	if shutdown.requested || shutdown.cancel == nil {
+		return false
+	}
+	shutdown.requested = true
+	shutdown.cancel()
+	return true
+}
+
+// shutdownRequested returns true if a graceful shutdown was requested.
+func shutdownRequested() bool {
+	shutdown.Lock()
+	defer shutdown.Unlock()
+	return shutdown.requested
+}
+
 func init() {
 	cleanupHandlers.ch = make(chan os.Signal, 1)
 	go CleanupHandler(cleanupHandlers.ch)
@@ -56,7 +94,21 @@ func RunCleanupHandlers(code int) int {
 	return code
 }
 
-// CleanupHandler handles the SIGINT signals.
+// gracefulShutdown requests a graceful shutdown after a signal was received. It
+// returns false if restic must exit immediately instead because a shutdown was
+// already requested or a password is read from the terminal.
+func gracefulShutdown() bool {
+	if isReadingPassword.Load() {
+		return false
+	}
+	return requestShutdown()
+}
+
+// CleanupHandler handles the SIGINT signals. The first signal cancels the
+// global context such that the running command can finish its current
+// operation and release its locks, a second signal terminates restic
+// immediately. While a password is read from the terminal, the first signal
+// already terminates restic, as the prompt cannot be interrupted otherwise.
 func CleanupHandler(c <-chan os.Signal) {
 	for s := range c {
 		debug.Log("signal %v received, cleaning up", s)
@@ -76,6 +128,11 @@ func CleanupHandler(c <-chan os.Signal) {
 			code = 1
 		}
 
+		if gracefulShutdown() {
+			Warnf("waiting for the current operation to finish, interrupt again to exit immediately\n")
+			continue
+		}
+
 		Exit(code)
 	}
 }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func resetShutdown(t *testing.T) {
+	t.Cleanup(func() {
+		shutdown.Lock()
+		defer shutdown.Unlock()
+		shutdown.cancel = nil
+		shutdown.requested = false
+		isReadingPassword.Store(false)
+	})
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	resetShutdown(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	setShutdownFunc(cancel)
+
+	// a signal received while reading a password terminates restic
+	isReadingPassword.Store(true)
+	rtest.Assert(t, !gracefulShutdown(), "graceful shutdown requested while reading a password")
+	rtest.OK(t, ctx.Err())
+
+	isReadingPassword.Store(false)
+	rtest.Assert(t, gracefulShutdown(), "graceful shutdown not requested")
+	rtest.Assert(t, ctx.Err() != nil, "context not canceled")
+	rtest.Assert(t, shutdownRequested(), "shutdown not recorded")
+
+	// the second signal terminates restic
+	rtest.Assert(t, !gracefulShutdown(), "second graceful shutdown requested")
+}
+
+func TestRestoreTerminalHandler(t *testing.T) {
+	resetShutdown(t)
+
+	restored := 0
+	handler := restoreTerminalHandler(func() error {
+		restored++
+		return nil
+	})
+
+	// the terminal is left alone unless a password is read
+	code, err := handler(130)
+	rtest.OK(t, err)
+	rtest.Equals(t, 130, code)
+	rtest.Equals(t, 0, restored)
+
+	isReadingPassword.Store(true)
+	code, err = handler(130)
+	rtest.OK(t, err)
+	rtest.Equals(t, 130, code)
+	rtest.Equals(t, 1, restored)
+
+	failing := restoreTerminalHandler(func() error {
+		return errors.New("restore failed")
+	})
+	_, err = failing(1)
+	rtest.Assert(t, err != nil, "restore error not returned")
+}
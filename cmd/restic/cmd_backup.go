@@ -19,6 +19,7 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/backend/location"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/fs"
@@ -43,6 +44,7 @@ EXIT STATUS
 Exit status is 0 if the command was successful.
 Exit status is 1 if there was a fatal error (no snapshot created).
 Exit status is 3 if some source data could not be read (incomplete snapshot created).
+Exit status is 130 if restic was interrupted (no snapshot created).
 `,
 	PreRun: func(cmd *cobra.Command, args []string) {
 		if backupOptions.Host == "" {
@@ -88,29 +90,49 @@ Exit status is 3 if some source data could not be read (incomplete snapshot crea
 type BackupOptions struct {
 	excludePatternOptions
 
-	Parent            string
-	GroupBy           restic.SnapshotGroupByOptions
-	Force             bool
-	ExcludeOtherFS    bool
-	ExcludeIfPresent  []string
-	ExcludeCaches     bool
-	ExcludeLargerThan string
-	Stdin             bool
-	StdinFilename     string
-	StdinCommand      bool
-	Tags              restic.TagLists
-	Host              string
-	FilesFrom         []string
-	FilesFromVerbatim []string
-	FilesFromRaw      []string
-	TimeStamp         string
-	WithAtime         bool
-	IgnoreInode       bool
-	IgnoreCtime       bool
-	UseFsSnapshot     bool
-	DryRun            bool
-	ReadConcurrency   uint
-	NoScan            bool
+	Parent             string
+	GroupBy            restic.SnapshotGroupByOptions
+	Force              bool
+	ExcludeOtherFS     bool
+	ExcludeIfPresent   []string
+	ExcludeCaches      bool
+	ExcludeLargerThan  string
+	SizeLimitsFile     string
+	ExcludeCloudFiles  bool
+	ExcludeMounts      []string
+	ExcludeCommonCache bool
+	CommonCacheNames   []string
+	KeepCommonCaches   []string
+	ChangedSince       string
+	KeepEmptyDirs      bool
+	MaxNewData         string
+	Stdin              bool
+	StdinFilename      string
+	StdinCommand       bool
+	Tags               restic.TagLists
+	TagFromPath        bool
+	SetPaths           []string
+	SnapshotGroups     []string
+	Host               string
+	FilesFrom          []string
+	FilesFromVerbatim  []string
+	FilesFromRaw       []string
+	TimeStamp          string
+	WithAtime          bool
+	WithBtime          bool
+	IgnoreInode        bool
+	IgnoreCtime        bool
+	UseFsSnapshot      bool
+	DryRun             bool
+	ReadConcurrency    uint
+	ReaddirConcurrency uint
+	NoScan             bool
+	SkipIfUnchanged    bool
+
+	secondary                secondaryRepoOptions
+	ContinueOnSecondaryError bool
+
+	webhookOptions
 }
 
 var backupOptions BackupOptions
@@ -130,14 +152,27 @@ func init() {
 	initExcludePatternOptions(f, &backupOptions.excludePatternOptions)
 
 	f.BoolVarP(&backupOptions.ExcludeOtherFS, "one-file-system", "x", false, "exclude other file systems, don't cross filesystem boundaries and subvolumes")
-	f.StringArrayVar(&backupOptions.ExcludeIfPresent, "exclude-if-present", nil, "takes `filename[:header]`, exclude contents of directories containing filename (except filename itself) if header of that file is as provided (can be specified multiple times)")
+	f.StringArrayVar(&backupOptions.ExcludeIfPresent, "exclude-if-present", nil, "takes `filename[:content]`, exclude contents of directories containing filename (except filename itself) if that file starts with content or contains it as a line (can be specified multiple times)")
 	f.BoolVar(&backupOptions.ExcludeCaches, "exclude-caches", false, `excludes cache directories that are marked with a CACHEDIR.TAG file. See https://bford.info/cachedir/ for the Cache Directory Tagging Standard`)
 	f.StringVar(&backupOptions.ExcludeLargerThan, "exclude-larger-than", "", "max `size` of the files to be backed up (allowed suffixes: k/K, m/M, g/G, t/T)")
+	f.StringVar(&backupOptions.SizeLimitsFile, "exclude-larger-than-file", "", "read the max size of the files below specific paths from `file`, overriding --exclude-larger-than")
+	f.BoolVar(&backupOptions.ExcludeCommonCache, "exclude-common-caches", false, "excludes directories with names of well-known caches and build output, such as node_modules or __pycache__ (see the documentation for the full list)")
+	f.StringArrayVar(&backupOptions.CommonCacheNames, "common-cache-name", nil, "add the directory `name` to the list used by --exclude-common-caches (can be specified multiple times)")
+	f.StringArrayVar(&backupOptions.KeepCommonCaches, "keep-common-cache", nil, "remove the directory `name` from the list used by --exclude-common-caches (can be specified multiple times)")
+	f.StringArrayVar(&backupOptions.ExcludeMounts, "exclude-mount", nil, "exclude the directory `path` if a different file system is mounted there (can be specified multiple times)")
+	f.BoolVar(&backupOptions.ExcludeCloudFiles, "exclude-cloud-files", false, "excludes online-only placeholder files of cloud storage providers such as OneDrive or iCloud Drive (Windows and macOS only)")
+	f.StringVar(&backupOptions.ChangedSince, "changed-since", "", "only include files modified after `time` (ex. '2024-01-01' or duration like '1d'), the snapshot is tagged as \""+partialSnapshotTag+"\"")
+	f.BoolVar(&backupOptions.KeepEmptyDirs, "keep-empty-dirs", false, "with --changed-since, keep directories which contain no modified files")
+	f.StringVar(&backupOptions.MaxNewData, "max-new-data", "", "stop adding new or modified files once `size` of new data has been stored, the snapshot is tagged as \""+truncatedSnapshotTag+"\" (allowed suffixes: k/K, m/M, g/G, t/T)")
 	f.BoolVar(&backupOptions.Stdin, "stdin", false, "read backup from stdin")
 	f.StringVar(&backupOptions.StdinFilename, "stdin-filename", "stdin", "`filename` to use when reading from stdin")
 	f.BoolVar(&backupOptions.StdinCommand, "stdin-from-command", false, "execute command and store its stdout")
 	f.Var(&backupOptions.Tags, "tag", "add `tags` for the new snapshot in the format `tag[,tag,...]` (can be specified multiple times)")
+	f.BoolVar(&backupOptions.TagFromPath, "tag-from-path", false, "add the base name of each backed up path as tag")
+	f.StringArrayVar(&backupOptions.SnapshotGroups, "group-snapshots-by-tag", nil, "save the backup targets below `path` in a separate snapshot with the given tag, in the format tag=path (can be specified multiple times)")
+	f.StringArrayVar(&backupOptions.SetPaths, "set-path", nil, "record `path` in the snapshot instead of the backup target (specify once, or once per target in the same order)")
 	f.UintVar(&backupOptions.ReadConcurrency, "read-concurrency", 0, "read `n` files concurrently (default: $RESTIC_READ_CONCURRENCY or 2)")
+	f.UintVar(&backupOptions.ReaddirConcurrency, "readdir-concurrency", 0, "run up to `n` lstat calls concurrently for the entries of a directory (default: 1)")
 	f.StringVarP(&backupOptions.Host, "host", "H", "", "set the `hostname` for the snapshot manually. To prevent an expensive rescan use the \"parent\" flag")
 	f.StringVar(&backupOptions.Host, "hostname", "", "set the `hostname` for the snapshot manually")
 	err := f.MarkDeprecated("hostname", "use --host")
@@ -150,10 +185,15 @@ func init() {
 	f.StringArrayVar(&backupOptions.FilesFromRaw, "files-from-raw", nil, "read the files to backup from `file` (can be combined with file args; can be specified multiple times)")
 	f.StringVar(&backupOptions.TimeStamp, "time", "", "`time` of the backup (ex. '2012-11-01 22:08:41') (default: now)")
 	f.BoolVar(&backupOptions.WithAtime, "with-atime", false, "store the atime for all files and directories")
+	f.BoolVar(&backupOptions.WithBtime, "with-btime", false, "store the birth time (creation time) for all files and directories, if supported by the platform and filesystem")
 	f.BoolVar(&backupOptions.IgnoreInode, "ignore-inode", false, "ignore inode number changes when checking for modified files")
 	f.BoolVar(&backupOptions.IgnoreCtime, "ignore-ctime", false, "ignore ctime changes when checking for modified files")
 	f.BoolVarP(&backupOptions.DryRun, "dry-run", "n", false, "do not upload or write any data, just show what would be done")
 	f.BoolVar(&backupOptions.NoScan, "no-scan", false, "do not run scanner to estimate size of backup")
+	f.BoolVar(&backupOptions.SkipIfUnchanged, "skip-if-unchanged", false, "skip snapshot creation if identical to parent snapshot")
+	initSecondaryTargetRepoOptions(f, &backupOptions.secondary)
+	f.BoolVar(&backupOptions.ContinueOnSecondaryError, "continue-on-secondary-error", false, "do not fail the backup if the snapshot cannot be saved to the secondary repository")
+	initWebhookOptions(f, &backupOptions.webhookOptions)
 	if runtime.GOOS == "windows" {
 		f.BoolVar(&backupOptions.UseFsSnapshot, "use-fs-snapshot", false, "use filesystem snapshot where possible (currently only Windows VSS)")
 	}
@@ -303,11 +343,97 @@ func (opts BackupOptions) Check(gopts GlobalOptions, args []string) error {
 		if len(args) > 0 && !opts.StdinCommand {
 			return errors.Fatal("--stdin was specified and files/dirs were listed as arguments")
 		}
+
+		if opts.MaxNewData != "" {
+			return errors.Fatal("--stdin and --max-new-data cannot be used together")
+		}
+
+		if len(opts.SetPaths) > 0 {
+			return errors.Fatal("--stdin and --set-path cannot be used together")
+		}
+
+		if opts.ChangedSince != "" {
+			return errors.Fatal("--stdin and --changed-since cannot be used together")
+		}
+	}
+
+	if opts.KeepEmptyDirs && opts.ChangedSince == "" {
+		return errors.Fatal("--keep-empty-dirs requires --changed-since")
+	}
+
+	if len(opts.SnapshotGroups) > 0 {
+		if opts.Stdin || opts.StdinCommand {
+			return errors.Fatal("--stdin and --group-snapshots-by-tag cannot be used together")
+		}
+		if len(opts.SetPaths) > 0 {
+			return errors.Fatal("--set-path and --group-snapshots-by-tag cannot be used together")
+		}
+		if opts.Parent != "" && opts.Parent != "latest" {
+			return errors.Fatal("--parent and --group-snapshots-by-tag cannot be used together")
+		}
 	}
 
 	return nil
 }
 
+// truncatedSnapshotTag is added to snapshots which do not contain all files
+// because the limit set by --max-new-data was reached.
+const truncatedSnapshotTag = "truncated"
+
+// partialSnapshotTag is added to snapshots which only contain the files
+// modified after the time passed to --changed-since.
+const partialSnapshotTag = "partial"
+
+// newDataBudget tracks the amount of new data stored during a backup. Only
+// the bytes actually added to the repository after deduplication and
+// compression are counted. While a file is read, its full size is reserved as
+// an upper bound for the data it adds.
+type newDataBudget struct {
+	mu       sync.Mutex
+	limit    uint64
+	added    uint64
+	reserved map[string]uint64
+	// sum of all reservations
+	reservedBytes uint64
+	exceeded      bool
+}
+
+func newNewDataBudget(limit uint64) *newDataBudget {
+	return &newDataBudget{limit: limit, reserved: make(map[string]uint64)}
+}
+
+// complete records size bytes as newly stored in the repository for item and
+// releases the reservation for it.
+func (b *newDataBudget) complete(item string, size uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reservedBytes -= b.reserved[item]
+	delete(b.reserved, item)
+	b.added += size
+}
+
+// reserve returns true if item with the given size can still be saved without
+// exceeding the limit and reserves size bytes for it. Files which do not fit
+// are skipped, but smaller files may still be saved afterwards.
+func (b *newDataBudget) reserve(item string, size uint64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.added+b.reservedBytes+size <= b.limit {
+		b.reserved[item] = size
+		b.reservedBytes += size
+		return true
+	}
+	b.exceeded = true
+	return false
+}
+
+// isExceeded returns true if at least one file was skipped.
+func (b *newDataBudget) isExceeded() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.exceeded
+}
+
 // collectRejectByNameFuncs returns a list of all functions which may reject data
 // from being saved in a snapshot based on path only
 func collectRejectByNameFuncs(opts BackupOptions, repo *repository.Repository) (fs []RejectByNameFunc, err error) {
@@ -328,7 +454,7 @@ func collectRejectByNameFuncs(opts BackupOptions, repo *repository.Repository) (
 	fs = append(fs, fsPatterns...)
 
 	if opts.ExcludeCaches {
-		opts.ExcludeIfPresent = append(opts.ExcludeIfPresent, "CACHEDIR.TAG:Signature: 8a477f597d28d172789f06886806bc55")
+		opts.ExcludeIfPresent = append(opts.ExcludeIfPresent, cacheDirTagFilename+":"+cacheDirTagSignature)
 	}
 
 	for _, spec := range opts.ExcludeIfPresent {
@@ -344,26 +470,64 @@ func collectRejectByNameFuncs(opts BackupOptions, repo *repository.Repository) (
 }
 
 // collectRejectFuncs returns a list of all functions which may reject data
-// from being saved in a snapshot based on path and file info
-func collectRejectFuncs(opts BackupOptions, targets []string) (fs []RejectFunc, err error) {
+// from being saved in a snapshot based on path and file info. If files are
+// excluded by their size, the sizeLimiter is returned as well.
+func collectRejectFuncs(opts BackupOptions, targets []string) (fs []RejectFunc, sizes *sizeLimiter, err error) {
 	// allowed devices
 	if opts.ExcludeOtherFS && !opts.Stdin {
 		f, err := rejectByDevice(targets)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		fs = append(fs, f)
 	}
 
-	if len(opts.ExcludeLargerThan) != 0 && !opts.Stdin {
-		f, err := rejectBySize(opts.ExcludeLargerThan)
+	if len(opts.ExcludeMounts) > 0 && !opts.Stdin {
+		f, err := rejectMountPoints(opts.ExcludeMounts)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		fs = append(fs, f)
 	}
 
-	return fs, nil
+	if opts.ExcludeCommonCache && !opts.Stdin {
+		fs = append(fs, rejectCommonCaches(opts.CommonCacheNames, opts.KeepCommonCaches))
+	} else if len(opts.CommonCacheNames) > 0 || len(opts.KeepCommonCaches) > 0 {
+		return nil, nil, errors.Fatal("--common-cache-name and --keep-common-cache require --exclude-common-caches")
+	}
+
+	if (opts.ExcludeLargerThan != "" || opts.SizeLimitsFile != "") && !opts.Stdin {
+		var rules []sizeLimitRule
+		if opts.SizeLimitsFile != "" {
+			rules, err = readSizeLimitRules(opts.SizeLimitsFile)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		sizes, err = newSizeLimiter(opts.ExcludeLargerThan, rules)
+		if err != nil {
+			return nil, nil, err
+		}
+		fs = append(fs, sizes.reject)
+	}
+
+	if opts.ChangedSince != "" {
+		cutoff, err := parseTimeOrDuration(opts.ChangedSince, time.Now())
+		if err != nil {
+			return nil, nil, err
+		}
+		fs = append(fs, rejectByModTime(cutoff))
+	}
+
+	if opts.ExcludeCloudFiles && !opts.Stdin {
+		if f := rejectCloudFiles(); f != nil {
+			fs = append(fs, f)
+		} else {
+			Warnf("--exclude-cloud-files is not supported on this platform, ignoring it\n")
+		}
+	}
+
+	return fs, sizes, nil
 }
 
 // collectTargets returns a list of target files/dirs from several sources.
@@ -433,29 +597,164 @@ func collectTargets(opts BackupOptions, args []string) (targets []string, err er
 	return targets, nil
 }
 
+// recordedPaths returns the paths which are stored in the snapshot for the
+// given targets. With --set-path, the targets are replaced by the given paths,
+// either one per target or a single path for all of them.
+func recordedPaths(opts BackupOptions, targets []string) ([]string, error) {
+	switch {
+	case len(opts.SetPaths) == 0:
+		return targets, nil
+	case len(opts.SetPaths) == 1 || len(opts.SetPaths) == len(targets):
+		return opts.SetPaths, nil
+	default:
+		return nil, errors.Fatalf("--set-path was specified %d times for %d backup targets, specify it once or once per target",
+			len(opts.SetPaths), len(targets))
+	}
+}
+
+// snapshotTags returns the tags for the new snapshot. With --tag-from-path,
+// the base names of the targets are added to the tags passed via --tag.
+func snapshotTags(opts BackupOptions, targets []string) restic.TagList {
+	tags := opts.Tags.Flatten()
+	if opts.TagFromPath {
+		for _, target := range targets {
+			abs, err := filepath.Abs(target)
+			if err != nil {
+				abs = target
+			}
+			name := filepath.Base(abs)
+			if name == "." || name == string(filepath.Separator) {
+				// the root directory has no name
+				continue
+			}
+			tags = append(tags, name)
+		}
+		// several targets may have the same name
+		tags = tags.Unique()
+	}
+	if opts.ChangedSince != "" {
+		tags = append(tags, partialSnapshotTag)
+	}
+	return tags
+}
+
+// backupGroup contains the targets which are saved in a separate snapshot
+// because of --group-snapshots-by-tag.
+type backupGroup struct {
+	tag     string
+	targets []string
+}
+
+// options returns the backup options for the snapshot of the group, which
+// additionally carries the tag of the group.
+func (g backupGroup) options(opts BackupOptions) BackupOptions {
+	if g.tag != "" {
+		opts.Tags = append(append(restic.TagLists{}, opts.Tags...), restic.TagList{g.tag})
+	}
+	return opts
+}
+
+// groupTargets splits the targets into the groups given as "tag=path". Each
+// target belongs to the group with the longest path containing it, the groups
+// are returned in the order of the specs. The targets which do not belong to
+// any group form an additional group without a tag.
+func groupTargets(specs []string, targets []string) ([]backupGroup, error) {
+	type groupPath struct {
+		group int
+		path  string
+	}
+
+	var groups []backupGroup
+	var paths []groupPath
+	tagGroups := make(map[string]int)
+	for _, spec := range specs {
+		tag, dir, _ := strings.Cut(spec, "=")
+		if tag == "" || dir == "" || strings.Contains(tag, ",") {
+			return nil, errors.Fatalf("invalid value %q for --group-snapshots-by-tag, expected tag=path", spec)
+		}
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := tagGroups[tag]
+		if !ok {
+			i = len(groups)
+			tagGroups[tag] = i
+			groups = append(groups, backupGroup{tag: tag})
+		}
+		paths = append(paths, groupPath{group: i, path: dir})
+	}
+
+	var ungrouped []string
+	for _, target := range targets {
+		abs, err := filepath.Abs(target)
+		if err != nil {
+			return nil, err
+		}
+
+		best := -1
+		for i, p := range paths {
+			if fs.HasPathPrefix(p.path, abs) && (best < 0 || len(p.path) > len(paths[best].path)) {
+				best = i
+			}
+		}
+		if best < 0 {
+			ungrouped = append(ungrouped, target)
+			continue
+		}
+		g := &groups[paths[best].group]
+		g.targets = append(g.targets, target)
+	}
+
+	var result []backupGroup
+	for _, g := range groups {
+		if len(g.targets) == 0 {
+			Warnf("--group-snapshots-by-tag: no backup target for tag %v\n", g.tag)
+			continue
+		}
+		result = append(result, g)
+	}
+	if len(ungrouped) > 0 {
+		result = append(result, backupGroup{targets: ungrouped})
+	}
+	return result, nil
+}
+
 // parent returns the ID of the parent snapshot. If there is none, nil is
-// returned.
-func findParentSnapshot(ctx context.Context, repo restic.Repository, opts BackupOptions, targets []string, timeStampLimit time.Time) (*restic.Snapshot, error) {
+// returned. paths are the paths recorded in the new snapshot.
+func findParentSnapshot(ctx context.Context, repo restic.Repository, snapshotLister restic.Lister, opts BackupOptions, paths []string, timeStampLimit time.Time) (*restic.Snapshot, error) {
 	if opts.Force {
 		return nil, nil
 	}
 
-	snName := opts.Parent
-	if snName == "" {
-		snName = "latest"
-	}
-	f := restic.SnapshotFilter{TimestampLimit: timeStampLimit}
-	if opts.GroupBy.Host {
-		f.Hosts = []string{opts.Host}
-	}
-	if opts.GroupBy.Path {
-		f.Paths = targets
+	if opts.Parent != "" && opts.Parent != "latest" {
+		sn, _, err := restic.FindSnapshot(ctx, snapshotLister, repo, opts.Parent)
+		return sn, err
 	}
+
+	var sn *restic.Snapshot
+	var err error
 	if opts.GroupBy.Tag {
-		f.Tags = []restic.TagList{opts.Tags.Flatten()}
+		// select the latest snapshot in the same group as the new snapshot,
+		// using the same grouping as forget, such that the parent has exactly
+		// the same tags
+		var group *restic.Snapshot
+		group, err = restic.NewSnapshot(paths, snapshotTags(opts, paths), opts.Host, timeStampLimit)
+		if err != nil {
+			return nil, err
+		}
+		sn, err = restic.FindLatestInGroup(ctx, snapshotLister, repo, group, opts.GroupBy, timeStampLimit)
+	} else {
+		f := restic.SnapshotFilter{TimestampLimit: timeStampLimit}
+		if opts.GroupBy.Host {
+			f.Hosts = []string{opts.Host}
+		}
+		if opts.GroupBy.Path {
+			f.Paths = paths
+		}
+		sn, _, err = f.FindLatest(ctx, snapshotLister, repo, "latest")
 	}
-
-	sn, _, err := f.FindLatest(ctx, repo, repo, snName)
 	// Snapshot not found is ok if no explicit parent was set
 	if opts.Parent == "" && errors.Is(err, restic.ErrNoSnapshotFound) {
 		err = nil
@@ -463,16 +762,76 @@ func findParentSnapshot(ctx context.Context, repo restic.Repository, opts Backup
 	return sn, err
 }
 
-func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, term *termstatus.Terminal, args []string) error {
-	err := opts.Check(gopts, args)
+// secondaryBackupRepo is a repository which receives a copy of the snapshot
+// created by the backup command.
+type secondaryBackupRepo struct {
+	repo     *repository.Repository
+	lock     *restic.Lock
+	ctx      context.Context
+	location string
+}
+
+func openSecondaryBackupRepo(ctx context.Context, opts BackupOptions, gopts GlobalOptions, term *termstatus.Terminal) (*secondaryBackupRepo, error) {
+	secondaryGopts, err := fillSecondaryTargetGlobalOpts(opts.secondary, gopts)
+	if err != nil {
+		return nil, err
+	}
+
+	repoLocation, err := ReadRepo(secondaryGopts)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := OpenRepository(ctx, secondaryGopts)
+	if err != nil {
+		return nil, err
+	}
+
+	lock, ctx, err := lockRepo(ctx, repo, gopts.RetryLock, gopts.JSON)
+	if err != nil {
+		unlockRepo(lock)
+		return nil, err
+	}
+
+	bar := newIndexTerminalProgress(gopts.Quiet, gopts.JSON, term)
+	err = repo.LoadIndex(ctx, bar)
+	if err != nil {
+		unlockRepo(lock)
+		return nil, err
+	}
+
+	return &secondaryBackupRepo{
+		repo:     repo,
+		lock:     lock,
+		ctx:      ctx,
+		location: location.StripPassword(gopts.backends, repoLocation),
+	}, nil
+}
+
+func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, term *termstatus.Terminal, args []string) (err error) {
+	err = opts.Check(gopts, args)
 	if err != nil {
 		return err
 	}
 
+	report := newWebhookReport(opts.webhookOptions, gopts.TransportOptions, "backup")
+	defer func() { report.send(err) }()
+
 	targets, err := collectTargets(opts, args)
 	if err != nil {
 		return err
 	}
+	paths, err := recordedPaths(opts, targets)
+	if err != nil {
+		return err
+	}
+	var groups []backupGroup
+	if len(opts.SnapshotGroups) > 0 {
+		groups, err = groupTargets(opts.SnapshotGroups, targets)
+		if err != nil {
+			return err
+		}
+	}
 
 	timeStamp := time.Now()
 	if opts.TimeStamp != "" {
@@ -490,6 +849,7 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 	if err != nil {
 		return err
 	}
+	report.setRepository(repo)
 
 	var progressPrinter backup.ProgressPrinter
 	if gopts.JSON {
@@ -505,6 +865,15 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		repo.SetDryRun()
 	}
 
+	var budget *newDataBudget
+	if opts.MaxNewData != "" {
+		limit, err := ui.ParseBytes(opts.MaxNewData)
+		if err != nil {
+			return errors.Fatalf("invalid value for --max-new-data: %v", err)
+		}
+		budget = newNewDataBudget(uint64(limit))
+	}
+
 	if !gopts.JSON {
 		progressPrinter.V("lock repository")
 	}
@@ -517,6 +886,21 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		}
 	}
 
+	// the secondary repository uses its own context, such that losing its
+	// lock does not abort the backup to the main repository
+	var secondary *secondaryBackupRepo
+	if hasSecondaryTargetRepo(opts.secondary) && !opts.DryRun {
+		secondary, err = openSecondaryBackupRepo(ctx, opts, gopts, term)
+		if err != nil {
+			if !opts.ContinueOnSecondaryError {
+				return err
+			}
+			Warnf("unable to open secondary repository, continuing without it: %v\n", err)
+		} else {
+			defer unlockRepo(secondary.lock)
+		}
+	}
+
 	// rejectByNameFuncs collect functions that can reject items from the backup based on path only
 	rejectByNameFuncs, err := collectRejectByNameFuncs(opts, repo)
 	if err != nil {
@@ -524,14 +908,24 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 	}
 
 	// rejectFuncs collect functions that can reject items from the backup based on path and file info
-	rejectFuncs, err := collectRejectFuncs(opts, targets)
+	rejectFuncs, sizeLimits, err := collectRejectFuncs(opts, targets)
 	if err != nil {
 		return err
 	}
 
+	// the snapshots are only listed once, to select the parent snapshot of
+	// each group and to update the snapshot index
+	var snapshotLister restic.Lister = repo
+	if groups != nil || gopts.SnapshotIndex {
+		snapshotLister, err = restic.MemorizeList(ctx, repo, restic.SnapshotFile)
+		if err != nil {
+			return err
+		}
+	}
+
 	var parentSnapshot *restic.Snapshot
-	if !opts.Stdin {
-		parentSnapshot, err = findParentSnapshot(ctx, repo, opts, targets, timeStamp)
+	if !opts.Stdin && groups == nil {
+		parentSnapshot, err = findParentSnapshot(ctx, repo, snapshotLister, opts, paths, timeStamp)
 		if err != nil {
 			return err
 		}
@@ -545,6 +939,25 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		}
 	}
 
+	// with --group-snapshots-by-tag, the parent is selected for each group
+	groupParents := make([]*restic.Snapshot, len(groups))
+	if groups != nil {
+		for i, g := range groups {
+			groupParents[i], err = findParentSnapshot(ctx, repo, snapshotLister, g.options(opts), g.targets, timeStamp)
+			if err != nil {
+				return err
+			}
+
+			if !gopts.JSON {
+				if groupParents[i] != nil {
+					progressPrinter.P("using parent snapshot %v for %v\n", groupParents[i].ID().Str(), g.targets)
+				} else {
+					progressPrinter.P("no parent snapshot found for %v, will read all files\n", g.targets)
+				}
+			}
+		}
+	}
+
 	if !gopts.JSON {
 		progressPrinter.V("load index files")
 	}
@@ -614,6 +1027,7 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 			ReadCloser: source,
 		}
 		targets = []string{filename}
+		paths = targets
 	}
 
 	wg, wgCtx := errgroup.WithContext(ctx)
@@ -633,10 +1047,15 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		wg.Go(func() error { return sc.Scan(cancelCtx, targets) })
 	}
 
-	arch := archiver.New(repo, targetFS, archiver.Options{ReadConcurrency: backupOptions.ReadConcurrency})
+	arch := archiver.New(repo, targetFS, archiver.Options{
+		ReadConcurrency:    backupOptions.ReadConcurrency,
+		ReaddirConcurrency: opts.ReaddirConcurrency,
+	})
 	arch.SelectByName = selectByNameFilter
 	arch.Select = selectFilter
 	arch.WithAtime = opts.WithAtime
+	arch.WithBtime = opts.WithBtime
+	arch.OmitEmptyDirs = opts.ChangedSince != "" && !opts.KeepEmptyDirs
 	success := true
 	arch.Error = func(item string, err error) error {
 		success = false
@@ -652,6 +1071,23 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 	arch.StartFile = progressReporter.StartFile
 	arch.CompleteBlob = progressReporter.CompleteBlob
 
+	if budget != nil {
+		arch.CompleteItem = func(item string, previous, current *restic.Node, s archiver.ItemStats, d time.Duration) {
+			if current != nil {
+				budget.complete(item, s.DataSizeInRepo+s.TreeSizeInRepo)
+			}
+			progressReporter.CompleteItem(item, previous, current, s, d)
+		}
+		arch.SelectChanged = func(item string, fi os.FileInfo) bool {
+			if budget.reserve(item, uint64(fi.Size())) {
+				return true
+			}
+			progressReporter.SkipFile(item, uint64(fi.Size()))
+			return false
+		}
+		arch.SmallFilesFirst = true
+	}
+
 	if opts.IgnoreInode {
 		// --ignore-inode implies --ignore-ctime: on FUSE, the ctime is not
 		// reliable either.
@@ -662,18 +1098,49 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 	}
 
 	snapshotOpts := archiver.SnapshotOptions{
-		Excludes:       opts.Excludes,
-		Tags:           opts.Tags.Flatten(),
-		Time:           timeStamp,
-		Hostname:       opts.Host,
-		ParentSnapshot: parentSnapshot,
-		ProgramVersion: "restic " + version,
+		Excludes:        opts.Excludes,
+		Tags:            snapshotTags(opts, paths),
+		Paths:           paths,
+		Time:            timeStamp,
+		Hostname:        opts.Host,
+		ParentSnapshot:  parentSnapshot,
+		ProgramVersion:  "restic " + version,
+		SkipIfUnchanged: opts.SkipIfUnchanged,
+	}
+	if budget != nil {
+		snapshotOpts.ExtraTags = func() restic.TagList {
+			if budget.isExceeded() {
+				return restic.TagList{truncatedSnapshotTag}
+			}
+			return nil
+		}
 	}
 
-	if !gopts.JSON {
-		progressPrinter.V("start backup on %v", targets)
+	var id restic.ID
+	groupIDs := make([]restic.ID, len(groups))
+	if groups == nil {
+		if !gopts.JSON {
+			progressPrinter.V("start backup on %v", targets)
+		}
+		_, id, err = arch.Snapshot(ctx, targets, snapshotOpts)
+	}
+	for i, g := range groups {
+		groupOpts := g.options(opts)
+		snapshotOpts.Tags = snapshotTags(groupOpts, g.targets)
+		snapshotOpts.Paths = g.targets
+		snapshotOpts.ParentSnapshot = groupParents[i]
+		if !gopts.JSON {
+			progressPrinter.V("start backup on %v", g.targets)
+		}
+		_, groupIDs[i], err = arch.Snapshot(ctx, g.targets, snapshotOpts)
+		if err != nil {
+			break
+		}
+		if sizeLimits != nil {
+			progressReporter.ExcludedBySize(sizeLimits.excludedFiles())
+		}
+		progressReporter.EndGroup(g.tag, groupIDs[i])
 	}
-	_, id, err := arch.Snapshot(ctx, targets, snapshotOpts)
 
 	// cleanly shutdown all running goroutines
 	cancel()
@@ -687,9 +1154,42 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 	}
 
 	// Report finished execution
+	if sizeLimits != nil {
+		progressReporter.ExcludedBySize(sizeLimits.excludedFiles())
+	}
 	progressReporter.Finish(id, opts.DryRun)
-	if !gopts.JSON && !opts.DryRun {
-		progressPrinter.P("snapshot %s saved\n", id.Str())
+	if groups == nil {
+		report.setBackup(id, progressReporter.Summary(), opts.DryRun)
+	} else {
+		report.setBackupGroups(progressReporter.Groups(), opts.DryRun)
+	}
+	if !gopts.JSON && !opts.DryRun && groups == nil {
+		if id.IsNull() {
+			progressPrinter.P("skipped creating snapshot\n")
+		} else {
+			progressPrinter.P("snapshot %s saved\n", id.Str())
+		}
+	}
+
+	savedIDs := restic.IDs{id}
+	if groups != nil {
+		savedIDs = groupIDs
+	}
+	if !opts.DryRun {
+		updateSnapshotIndex(ctx, gopts, repo, snapshotLister, savedIDs, nil)
+	}
+	for _, id := range savedIDs {
+		if secondary == nil || id.IsNull() {
+			continue
+		}
+		if !gopts.JSON {
+			progressPrinter.V("copy snapshot %v to secondary repository", id.Str())
+		}
+		secondaryID, err := copySnapshot(secondary.ctx, repo, secondary.repo, id, gopts.Quiet || gopts.JSON)
+		progressPrinter.FinishSecondary(secondary.location, secondaryID, err)
+		if err != nil && !opts.ContinueOnSecondaryError {
+			return errors.Fatalf("unable to save snapshot to secondary repository: %v", err)
+		}
 	}
 	if !success {
 		return ErrInvalidSourceData
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/fs"
@@ -38,6 +42,32 @@ func TestBackup(t *testing.T) {
 	testBackup(t, false)
 }
 
+func TestBackupJSON(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	buf := bytes.NewBuffer(nil)
+	gopts := env.gopts
+	gopts.JSON = true
+	gopts.stdout = buf
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, gopts)
+	ids := testListSnapshots(t, env.gopts, 1)
+
+	// every line must be a JSON object, the summary comes last
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var summary map[string]interface{}
+	for _, line := range lines {
+		summary = nil
+		rtest.OK(t, json.Unmarshal([]byte(line), &summary))
+		rtest.Assert(t, summary["message_type"] != nil, "message without type: %v", line)
+	}
+	rtest.Equals(t, "summary", summary["message_type"])
+	rtest.Equals(t, ids[0].String(), summary["snapshot_id"])
+	rtest.Assert(t, summary["data_added"].(float64) > 0, "no data added: %v", lines[len(lines)-1])
+	rtest.Assert(t, summary["data_added_packed"].(float64) > 0, "no packed data added: %v", lines[len(lines)-1])
+}
+
 func TestBackupWithFilesystemSnapshots(t *testing.T) {
 	if runtime.GOOS == "windows" && fs.HasSufficientPrivilegesForVSS() == nil {
 		testBackup(t, true)
@@ -136,6 +166,191 @@ func TestBackupParentSelection(t *testing.T) {
 	rtest.Assert(t, latestSn.Parent != nil && latestSn.Parent.Equal(firstSnapshotID), "third snapshot selected unexpected parent %v instead of %v", latestSn.Parent, firstSnapshotID)
 }
 
+func TestBackupSkipIfUnchanged(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{SkipIfUnchanged: true}
+
+	for i := 0; i < 3; i++ {
+		testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+		testListSnapshots(t, env.gopts, 1)
+	}
+
+	// a changed file results in a new snapshot
+	rtest.OK(t, appendRandomData(filepath.Join(env.testdata, "0", "0", "9", "0"), 100))
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	testListSnapshots(t, env.gopts, 2)
+
+	// without a parent snapshot, there is nothing to compare with
+	opts.Force = true
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	testListSnapshots(t, env.gopts, 3)
+
+	testRunCheck(t, env.gopts)
+}
+
+func TestBackupGroupSnapshotsByTag(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	data := rtest.Random(23, 512*1024)
+	for _, dir := range []string{"a", "b", "c"} {
+		rtest.OK(t, os.MkdirAll(filepath.Join(env.testdata, dir), 0755))
+		rtest.OK(t, os.WriteFile(filepath.Join(env.testdata, dir, "file"), data, 0644))
+	}
+
+	opts := BackupOptions{SnapshotGroups: []string{"ta=a", "tb=b"}}
+	testRunBackup(t, env.testdata, []string{"a", "b", "c"}, opts, env.gopts)
+	ids := testListSnapshots(t, env.gopts, 3)
+
+	ctx := context.TODO()
+	repo, err := OpenRepository(ctx, env.gopts)
+	rtest.OK(t, err)
+
+	paths := make(map[string]string)
+	first := make(map[string]restic.ID)
+	for _, id := range ids {
+		sn, err := restic.LoadSnapshot(ctx, repo, id)
+		rtest.OK(t, err)
+		rtest.Equals(t, 1, len(sn.Paths))
+		tag := ""
+		if len(sn.Tags) > 0 {
+			rtest.Equals(t, 1, len(sn.Tags))
+			tag = sn.Tags[0]
+		}
+		paths[tag] = filepath.Base(sn.Paths[0])
+		first[tag] = id
+	}
+	rtest.Equals(t, map[string]string{"ta": "a", "tb": "b", "": "c"}, paths)
+
+	// each group uses the previous snapshot of the group as parent
+	testRunBackup(t, env.testdata, []string{"a", "b", "c"}, opts, env.gopts)
+	all := testListSnapshots(t, env.gopts, 6)
+	for _, id := range all {
+		sn, err := restic.LoadSnapshot(ctx, repo, id)
+		rtest.OK(t, err)
+		if sn.Parent == nil {
+			continue
+		}
+		tag := ""
+		if len(sn.Tags) > 0 {
+			tag = sn.Tags[0]
+		}
+		rtest.Equals(t, first[tag], *sn.Parent)
+		delete(first, tag)
+	}
+	rtest.Equals(t, 0, len(first))
+
+	// the file content is stored only once
+	rtest.OK(t, repo.LoadIndex(ctx, nil))
+	dataBlobs := 0
+	repo.Index().Each(ctx, func(pb restic.PackedBlob) {
+		if pb.Type == restic.DataBlob {
+			dataBlobs++
+		}
+	})
+	rtest.Equals(t, 1, dataBlobs)
+
+	testRunCheck(t, env.gopts)
+}
+
+func TestBackupGroupSnapshotsByTagJSON(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	data := rtest.Random(23, 512*1024)
+	for _, dir := range []string{"a", "b", "c"} {
+		rtest.OK(t, os.MkdirAll(filepath.Join(env.testdata, dir), 0755))
+		rtest.OK(t, os.WriteFile(filepath.Join(env.testdata, dir, "file"), data, 0644))
+	}
+
+	buf := bytes.NewBuffer(nil)
+	gopts := env.gopts
+	gopts.JSON = true
+	gopts.stdout = buf
+	opts := BackupOptions{SnapshotGroups: []string{"ta=a", "tb=b"}}
+	testRunBackup(t, env.testdata, []string{"a", "b", "c"}, opts, gopts)
+	ids := testListSnapshots(t, env.gopts, 3)
+
+	// one group summary with its own snapshot ID per saved snapshot
+	summaries := make(map[string]map[string]interface{})
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var msg map[string]interface{}
+		rtest.OK(t, json.Unmarshal([]byte(line), &msg))
+		rtest.Assert(t, msg["message_type"] != "summary", "unexpected total summary: %v", line)
+		if msg["message_type"] != "group_summary" {
+			continue
+		}
+		tag, _ := msg["tag"].(string)
+		summaries[tag] = msg
+	}
+	rtest.Equals(t, 3, len(summaries))
+
+	ctx := context.TODO()
+	repo, err := OpenRepository(ctx, env.gopts)
+	rtest.OK(t, err)
+	for _, id := range ids {
+		sn, err := restic.LoadSnapshot(ctx, repo, id)
+		rtest.OK(t, err)
+		tag := ""
+		if len(sn.Tags) > 0 {
+			tag = sn.Tags[0]
+		}
+		msg := summaries[tag]
+		rtest.Assert(t, msg != nil, "missing group summary for tag %q", tag)
+		rtest.Equals(t, id.String(), msg["snapshot_id"])
+		rtest.Equals(t, float64(1), msg["files_new"])
+		rtest.Equals(t, float64(len(data)), msg["total_bytes_processed"])
+	}
+
+	// the file content is only added by the first group
+	rtest.Assert(t, summaries["ta"]["data_added"].(float64) >= float64(len(data)), "no data added for first group: %v", summaries["ta"])
+	rtest.Assert(t, summaries["tb"]["data_added"].(float64) < float64(len(data)), "data added twice: %v", summaries["tb"])
+}
+
+func TestBackupJSONDataReused(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	data := rtest.Random(42, 512*1024)
+	rtest.OK(t, os.WriteFile(filepath.Join(env.testdata, "file"), data, 0644))
+
+	backup := func(opts BackupOptions) map[string]interface{} {
+		buf := bytes.NewBuffer(nil)
+		gopts := env.gopts
+		gopts.JSON = true
+		gopts.stdout = buf
+		testRunBackup(t, env.testdata, []string{"file"}, opts, gopts)
+
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var msg map[string]interface{}
+			rtest.OK(t, json.Unmarshal([]byte(line), &msg))
+			if msg["message_type"] == "summary" {
+				return msg
+			}
+		}
+		t.Fatalf("missing summary in output %q", buf.String())
+		return nil
+	}
+
+	// the first backup adds the whole file
+	msg := backup(BackupOptions{})
+	rtest.Equals(t, float64(0), msg["data_reused"])
+
+	// the second backup reads the file again and finds all data in the repository
+	msg = backup(BackupOptions{Force: true})
+	rtest.Equals(t, float64(len(data)), msg["total_bytes_processed"])
+	rtest.Equals(t, float64(len(data)), msg["data_reused"])
+}
+
 func TestDryRunBackup(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
@@ -441,6 +656,89 @@ func TestBackupTags(t *testing.T) {
 		"expected parent to be %v, got %v", parent.ID, newest.Parent)
 }
 
+func TestBackupParentGroupByTags(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{GroupBy: restic.SnapshotGroupByOptions{Host: true, Path: true, Tag: true}}
+
+	backup := func(tags ...string) *Snapshot {
+		opts.Tags = restic.TagLists{tags}
+		// testRunBackup resets the grouping to host and paths
+		rtest.OK(t, withTermStatus(env.gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+			return runBackup(ctx, opts, env.gopts, term, []string{env.testdata})
+		}))
+		newest, _ := testRunSnapshots(t, env.gopts)
+		rtest.Assert(t, newest != nil, "expected a backup, got nil")
+		return newest
+	}
+
+	// snapshots without tags form a group as well
+	untagged := backup()
+	next := backup()
+	rtest.Assert(t, next.Parent != nil && next.Parent.Equal(*untagged.ID),
+		"expected parent to be %v, got %v", untagged.ID, next.Parent)
+
+	prod := backup("prod")
+	rtest.Assert(t, prod.Parent == nil, "expected no parent, got %v", prod.Parent)
+	both := backup("prod", "staging")
+	rtest.Assert(t, both.Parent == nil, "expected no parent, got %v", both.Parent)
+
+	// a superset of the tags must not be used as parent
+	staging := backup("staging")
+	rtest.Assert(t, staging.Parent == nil, "expected no parent, got %v", staging.Parent)
+
+	next = backup("prod")
+	rtest.Assert(t, next.Parent != nil && next.Parent.Equal(*prod.ID),
+		"expected parent to be %v, got %v", prod.ID, next.Parent)
+	next = backup("staging")
+	rtest.Assert(t, next.Parent != nil && next.Parent.Equal(*staging.ID),
+		"expected parent to be %v, got %v", staging.ID, next.Parent)
+}
+
+func TestBackupParentSupersetPaths(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	dirs := []string{filepath.Join(env.testdata, "0", "0", "9", "2"), filepath.Join(env.testdata, "0", "0", "9", "3")}
+
+	// by default, a snapshot containing all paths of the new snapshot is used
+	// as parent, even if it contains further paths
+	testRunBackup(t, "", dirs, BackupOptions{Tags: restic.TagLists{{"foo"}}}, env.gopts)
+	parent, _ := testRunSnapshots(t, env.gopts)
+	testRunBackup(t, "", dirs[:1], BackupOptions{}, env.gopts)
+	newest, _ := testRunSnapshots(t, env.gopts)
+	rtest.Assert(t, newest.Parent != nil && newest.Parent.Equal(*parent.ID),
+		"expected parent to be %v, got %v", parent.ID, newest.Parent)
+}
+
+func TestBackupSetPath(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	logical, err := filepath.Abs(filepath.FromSlash("/srv/logical"))
+	rtest.OK(t, err)
+	opts := BackupOptions{SetPaths: []string{logical}}
+
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, opts, env.gopts)
+	first, _ := testRunSnapshots(t, env.gopts)
+	rtest.Equals(t, []string{logical}, first.Paths)
+
+	// the parent is selected using the recorded path
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, opts, env.gopts)
+	second, _ := testRunSnapshots(t, env.gopts)
+	rtest.Assert(t, second.Parent != nil && second.Parent.Equal(*first.ID),
+		"expected parent to be %v, got %v", first.ID, second.Parent)
+
+	// the number of paths must match the number of targets
+	opts.SetPaths = []string{logical, logical}
+	err = testRunBackupAssumeFailure(t, "", []string{env.testdata, filepath.Join(env.testdata, "0"), filepath.Join(env.testdata, "0", "0")}, opts, env.gopts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "--set-path"), "expected error for mismatching --set-path, got %v", err)
+}
+
 func TestBackupProgramVersion(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
@@ -637,3 +935,174 @@ func TestStdinFromCommandFailNoOutputAndExitCode(t *testing.T) {
 
 	testRunCheck(t, env.gopts)
 }
+
+func TestBackupSecondaryRepo(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	env2, cleanup2 := withTestEnvironment(t)
+	defer cleanup2()
+
+	testSetupBackupData(t, env)
+	testRunInit(t, env2.gopts)
+
+	opts := BackupOptions{
+		secondary: secondaryRepoOptions{
+			Repo:     env2.repo,
+			password: env2.gopts.password,
+		},
+	}
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, opts, env.gopts)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "2")}, opts, env.gopts)
+
+	snapshotIDs := testListSnapshots(t, env.gopts, 2)
+	copiedIDs := testListSnapshots(t, env2.gopts, 2)
+	testRunCheck(t, env2.gopts)
+
+	// the copies must reference the snapshots of the main repository
+	originals := restic.NewIDSet(snapshotIDs...)
+	repo, err := OpenRepository(context.TODO(), env2.gopts)
+	rtest.OK(t, err)
+	for _, id := range copiedIDs {
+		sn, err := restic.LoadSnapshot(context.TODO(), repo, id)
+		rtest.OK(t, err)
+		rtest.Assert(t, sn.Original != nil && originals.Has(*sn.Original),
+			"snapshot %v does not reference an original snapshot", id.Str())
+	}
+
+	// an unavailable secondary repository fails the backup, unless requested otherwise
+	opts.secondary.Repo = filepath.Join(env.base, "missing")
+	err = testRunBackupAssumeFailure(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, opts, env.gopts)
+	rtest.Assert(t, err != nil, "expected backup to fail without secondary repository")
+	testListSnapshots(t, env.gopts, 2)
+
+	opts.ContinueOnSecondaryError = true
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, opts, env.gopts)
+	testListSnapshots(t, env.gopts, 3)
+	testListSnapshots(t, env2.gopts, 2)
+}
+
+func TestBackupMaxNewData(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	for name, size := range map[string]uint{"small1": 1024, "large": 2 * 1024 * 1024, "small2": 1024} {
+		rtest.OK(t, appendRandomData(filepath.Join(env.testdata, name), size))
+	}
+
+	opts := BackupOptions{MaxNewData: "100K"}
+	testRunBackup(t, env.testdata, []string{"."}, opts, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+	testRunCheck(t, env.gopts)
+
+	repo, err := OpenRepository(context.TODO(), env.gopts)
+	rtest.OK(t, err)
+	sn, err := restic.LoadSnapshot(context.TODO(), repo, snapshotIDs[0])
+	rtest.OK(t, err)
+	rtest.Assert(t, sn.HasTags([]string{truncatedSnapshotTag}), "snapshot is not tagged as truncated: %v", sn.Tags)
+
+	files := testRunLs(t, env.gopts, snapshotIDs[0].String())
+	rtest.Equals(t, []string{"/small1", "/small2"}, files[:len(files)-1])
+
+	// without a limit, the remaining file is added
+	testRunBackup(t, env.testdata, []string{"."}, BackupOptions{}, env.gopts)
+	var newID restic.ID
+	for _, id := range testListSnapshots(t, env.gopts, 2) {
+		if id != snapshotIDs[0] {
+			newID = id
+		}
+	}
+	sn, err = restic.LoadSnapshot(context.TODO(), repo, newID)
+	rtest.OK(t, err)
+	rtest.Assert(t, !sn.HasTags([]string{truncatedSnapshotTag}), "snapshot is tagged as truncated: %v", sn.Tags)
+	files = testRunLs(t, env.gopts, newID.String())
+	rtest.Equals(t, []string{"/large", "/small1", "/small2"}, files[:len(files)-1])
+
+	// a modified file which is skipped keeps the version of the parent snapshot
+	rtest.OK(t, appendRandomData(filepath.Join(env.testdata, "large"), 2*1024*1024))
+	rtest.OK(t, appendRandomData(filepath.Join(env.testdata, "small1"), 2048))
+	testRunBackup(t, env.testdata, []string{"."}, opts, env.gopts)
+	var truncatedID restic.ID
+	for _, id := range testListSnapshots(t, env.gopts, 3) {
+		if id != snapshotIDs[0] && id != newID {
+			truncatedID = id
+		}
+	}
+	sn, err = restic.LoadSnapshot(context.TODO(), repo, truncatedID)
+	rtest.OK(t, err)
+	rtest.Assert(t, sn.HasTags([]string{truncatedSnapshotTag}), "snapshot is not tagged as truncated: %v", sn.Tags)
+	rtest.OK(t, repo.LoadIndex(context.TODO(), nil))
+	tree, err := restic.LoadTree(context.TODO(), repo, *sn.Tree)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint64(2*1024*1024), tree.Find("large").Size)
+	rtest.Equals(t, uint64(3*1024), tree.Find("small1").Size)
+	testRunCheck(t, env.gopts)
+}
+
+func TestBackupChangedSince(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	cutoff := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	for name, mtime := range map[string]time.Time{
+		"old/file":      cutoff.Add(-time.Hour),
+		"mixed/old":     cutoff.Add(-time.Hour),
+		"mixed/new":     cutoff.Add(time.Hour),
+		"mixed/sub/new": cutoff.Add(time.Hour),
+	} {
+		p := filepath.Join(env.testdata, filepath.FromSlash(name))
+		rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+		rtest.OK(t, appendRandomData(p, 100))
+		rtest.OK(t, os.Chtimes(p, mtime, mtime))
+	}
+
+	for _, test := range []struct {
+		opts BackupOptions
+		want []string
+	}{
+		{
+			opts: BackupOptions{ChangedSince: "2024-01-01"},
+			want: []string{"/mixed", "/mixed/new", "/mixed/sub", "/mixed/sub/new"},
+		},
+		{
+			opts: BackupOptions{ChangedSince: "2024-01-01", KeepEmptyDirs: true},
+			want: []string{"/mixed", "/mixed/new", "/mixed/sub", "/mixed/sub/new", "/old"},
+		},
+	} {
+		testRunBackup(t, env.testdata, []string{"."}, test.opts, env.gopts)
+		sn, _ := testRunSnapshots(t, env.gopts)
+		rtest.Assert(t, sn.HasTags([]string{partialSnapshotTag}), "snapshot is not tagged as partial: %v", sn.Tags)
+
+		files := testRunLs(t, env.gopts, sn.ID.String())
+		rtest.Equals(t, test.want, files[:len(files)-1])
+	}
+	testRunCheck(t, env.gopts)
+}
+
+func TestBackupSnapshotSummary(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	var size uint64
+	for i := 0; i < 5; i++ {
+		p := filepath.Join(env.testdata, "dir", fmt.Sprintf("file%d", i))
+		rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+		rtest.OK(t, appendRandomData(p, uint(1000*(i+1))))
+		size += uint64(1000 * (i + 1))
+	}
+
+	testRunBackup(t, env.testdata, []string{"."}, BackupOptions{}, env.gopts)
+	sn, _ := testRunSnapshots(t, env.gopts)
+	rtest.Assert(t, sn.Summary != nil, "snapshot has no summary")
+	rtest.Equals(t, uint(5), sn.Summary.TotalFilesProcessed)
+	rtest.Equals(t, size, sn.Summary.TotalBytesProcessed)
+	rtest.Equals(t, runtime.GOOS, sn.Summary.OS)
+	rtest.Equals(t, runtime.GOARCH, sn.Summary.Arch)
+	rtest.Assert(t, !sn.Summary.BackupEnd.Before(sn.Summary.BackupStart),
+		"backup end %v before start %v", sn.Summary.BackupEnd, sn.Summary.BackupStart)
+}
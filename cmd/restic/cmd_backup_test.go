@@ -10,6 +10,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 )
 
@@ -110,3 +111,44 @@ func TestReadFilenamesRaw(t *testing.T) {
 	rtest.Assert(t, strings.Contains(err.Error(), "zero byte"),
 		"wrong error message: %v", err.Error())
 }
+
+func TestSnapshotTags(t *testing.T) {
+	var tags restic.TagLists
+	rtest.OK(t, tags.Set("www,manual"))
+	targets := []string{
+		filepath.Join("srv", "www"),
+		filepath.Join("home", "user", "www"),
+		filepath.Join("home", "user", "docs") + string(filepath.Separator),
+	}
+
+	opts := BackupOptions{Tags: tags}
+	rtest.Equals(t, restic.TagList{"www", "manual"}, snapshotTags(opts, targets))
+
+	opts.TagFromPath = true
+	rtest.Equals(t, restic.TagList{"www", "manual", "docs"}, snapshotTags(opts, targets))
+
+	// the root directory does not result in a tag
+	rtest.Equals(t, restic.TagList{}, snapshotTags(BackupOptions{TagFromPath: true}, []string{string(filepath.Separator)}))
+
+	// the tags are only deduplicated for --tag-from-path
+	rtest.OK(t, tags.Set("www"))
+	opts = BackupOptions{Tags: tags}
+	rtest.Equals(t, restic.TagList{"www", "manual", "www"}, snapshotTags(opts, targets))
+	opts.TagFromPath = true
+	rtest.Equals(t, restic.TagList{"www", "manual", "docs"}, snapshotTags(opts, targets))
+}
+
+func TestNewDataBudget(t *testing.T) {
+	b := newNewDataBudget(100)
+	rtest.Assert(t, b.reserve("a", 60), "first file does not fit")
+	// the size of a file is reserved while it is read
+	rtest.Assert(t, !b.reserve("b", 60), "file exceeding the reservation fits")
+	rtest.Assert(t, b.isExceeded(), "budget not marked as exceeded")
+	rtest.Assert(t, b.reserve("c", 40), "small file does not fit")
+
+	// only the data actually added counts once a file is complete
+	b.complete("a", 10)
+	b.complete("c", 0)
+	rtest.Assert(t, b.reserve("b", 60), "file does not fit after deduplication")
+	rtest.Assert(t, !b.reserve("d", 31), "file exceeding the limit fits")
+}
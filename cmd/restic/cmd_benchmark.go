@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/restic/chunker"
+	"github.com/restic/restic/internal/crypto"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var cmdBenchmark = &cobra.Command{
+	Use:   "benchmark [flags] [file/dir] ...",
+	Short: "Measure the throughput of chunking, hashing, encryption and compression",
+	Long: `
+The "benchmark" command measures how fast the data of the given files and
+directories can be processed by the stages of a backup: splitting the data into
+chunks, hashing, encrypting and compressing the chunks. The same code as for
+a backup is used, but no repository is accessed and nothing is written.
+
+If no files or directories are given, pseudo-random data of the size given by
+--size is generated instead. Random data cannot be compressed, use real files
+to measure the compression throughput for typical data.
+
+The compression level corresponds to the --compression option, with
+"--compression off" the chunks are not compressed like data blobs in a backup.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBenchmark(cmd.Context(), benchmarkOptions, globalOptions, args)
+	},
+}
+
+// BenchmarkOptions bundles all options for the benchmark command.
+type BenchmarkOptions struct {
+	Size string
+}
+
+var benchmarkOptions BenchmarkOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdBenchmark)
+
+	f := cmdBenchmark.Flags()
+	f.StringVar(&benchmarkOptions.Size, "size", "256M", "amount of random data to process if no files are given (allowed suffixes: k/K, m/M, g/G, t/T)")
+}
+
+// benchmarkStages lists the measured stages in the order they are printed.
+var benchmarkStages = []string{"read", "chunker", "hash", "encrypt", "compress"}
+
+// benchmarker runs the data of all files through the stages of a backup and
+// records the time spent in each stage.
+type benchmarker struct {
+	pol     chunker.Pol
+	chunker *chunker.Chunker
+	key     *crypto.Key
+	enc     *zstd.Encoder
+
+	buf, sealed, compressed []byte
+
+	durations       map[string]time.Duration
+	files, chunks   uint64
+	bytes, compSize uint64
+}
+
+// timedReader records the time spent reading from rd.
+type timedReader struct {
+	rd      io.Reader
+	elapsed time.Duration
+}
+
+func (r *timedReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := r.rd.Read(p)
+	r.elapsed += time.Since(start)
+	return n, err
+}
+
+func newBenchmarker(mode repository.CompressionMode) (*benchmarker, error) {
+	pol, err := chunker.RandomPolynomial()
+	if err != nil {
+		return nil, err
+	}
+
+	enc, err := repository.NewZstdEncoder(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	return &benchmarker{
+		pol:       pol,
+		chunker:   chunker.New(nil, pol),
+		key:       crypto.NewRandomKey(),
+		enc:       enc,
+		buf:       make([]byte, chunker.MaxSize),
+		durations: make(map[string]time.Duration),
+	}, nil
+}
+
+func (b *benchmarker) measure(stage string, fn func()) {
+	start := time.Now()
+	fn()
+	b.durations[stage] += time.Since(start)
+}
+
+// process splits the data read from rd into chunks and runs each chunk through
+// the remaining stages.
+func (b *benchmarker) process(ctx context.Context, rd io.Reader) error {
+	trd := &timedReader{rd: rd}
+	b.chunker.Reset(trd, b.pol)
+
+	var chunking time.Duration
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		start := time.Now()
+		chunk, err := b.chunker.Next(b.buf)
+		chunking += time.Since(start)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		b.buf = chunk.Data
+		b.chunks++
+		b.bytes += uint64(chunk.Length)
+
+		b.measure("hash", func() {
+			_ = restic.Hash(chunk.Data)
+		})
+		b.measure("encrypt", func() {
+			b.sealed = b.key.Seal(b.sealed[:0], crypto.NewRandomNonce(), chunk.Data, nil)
+		})
+		if b.enc == nil {
+			// data blobs are not compressed with --compression off
+			b.compSize += uint64(chunk.Length)
+			continue
+		}
+		b.measure("compress", func() {
+			b.compressed = b.enc.EncodeAll(chunk.Data, b.compressed[:0])
+		})
+		b.compSize += uint64(len(b.compressed))
+	}
+
+	// reading the data is not part of the chunker throughput
+	b.durations["read"] += trd.elapsed
+	b.durations["chunker"] += chunking - trd.elapsed
+	return nil
+}
+
+func (b *benchmarker) processFile(ctx context.Context, filename string) error {
+	f, err := fs.Open(filename)
+	if err != nil {
+		return err
+	}
+
+	b.files++
+	err = b.process(ctx, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+type benchmarkStageJSON struct {
+	Name           string  `json:"name"`
+	Seconds        float64 `json:"seconds"`
+	BytesPerSecond float64 `json:"bytes_per_second"`
+}
+
+type benchmarkJSON struct {
+	Version         string               `json:"version"`
+	Compression     string               `json:"compression"`
+	Files           uint64               `json:"files"`
+	Chunks          uint64               `json:"chunks"`
+	TotalBytes      uint64               `json:"total_bytes"`
+	CompressedBytes uint64               `json:"compressed_bytes"`
+	Stages          []benchmarkStageJSON `json:"stages"`
+}
+
+func (b *benchmarker) results(mode repository.CompressionMode) benchmarkJSON {
+	res := benchmarkJSON{
+		Version:         version,
+		Compression:     mode.String(),
+		Files:           b.files,
+		Chunks:          b.chunks,
+		TotalBytes:      b.bytes,
+		CompressedBytes: b.compSize,
+		Stages:          []benchmarkStageJSON{},
+	}
+	for _, stage := range benchmarkStages {
+		seconds := b.durations[stage].Seconds()
+		var rate float64
+		if seconds > 0 {
+			rate = float64(b.bytes) / seconds
+		}
+		res.Stages = append(res.Stages, benchmarkStageJSON{Name: stage, Seconds: seconds, BytesPerSecond: rate})
+	}
+	return res
+}
+
+func runBenchmark(ctx context.Context, opts BenchmarkOptions, gopts GlobalOptions, args []string) error {
+	b, err := newBenchmarker(gopts.Compression)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		size, err := ui.ParseBytes(opts.Size)
+		if err != nil {
+			return errors.Fatalf("invalid size %q: %v", opts.Size, err)
+		}
+		rd := io.LimitReader(rand.New(rand.NewSource(time.Now().UnixNano())), size)
+		if err := b.process(ctx, rd); err != nil {
+			return err
+		}
+	}
+
+	for _, arg := range args {
+		err := filepath.Walk(arg, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !fi.Mode().IsRegular() {
+				return nil
+			}
+			Verboseff("processing %v\n", path)
+			return b.processFile(ctx, path)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	res := b.results(gopts.Compression)
+	if gopts.JSON {
+		return json.NewEncoder(globalOptions.stdout).Encode(res)
+	}
+
+	if len(args) == 0 {
+		Printf("processed %s of random data in %d chunks\n", ui.FormatBytes(res.TotalBytes), res.Chunks)
+	} else {
+		Printf("processed %d files, %s in %d chunks\n", res.Files, ui.FormatBytes(res.TotalBytes), res.Chunks)
+	}
+	for _, stage := range res.Stages {
+		Printf("%-10s %12s/s\n", stage.Name, ui.FormatBytes(uint64(stage.BytesPerSecond)))
+	}
+	if res.TotalBytes > 0 {
+		Printf("compressed size: %s (%s)\n", ui.FormatBytes(res.CompressedBytes), ui.FormatPercent(res.CompressedBytes, res.TotalBytes))
+	}
+	return nil
+}
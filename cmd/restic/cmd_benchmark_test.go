@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestBenchmarkJSON(t *testing.T) {
+	dir := t.TempDir()
+	rtest.OK(t, os.WriteFile(filepath.Join(dir, "zeros"), make([]byte, 3*1024*1024), 0600))
+	rtest.OK(t, os.Mkdir(filepath.Join(dir, "sub"), 0700))
+	rtest.OK(t, os.WriteFile(filepath.Join(dir, "sub", "small"), []byte("foobar"), 0600))
+
+	gopts := GlobalOptions{JSON: true}
+	buf, err := withCaptureStdout(func() error {
+		return runBenchmark(context.TODO(), BenchmarkOptions{}, gopts, []string{dir})
+	})
+	rtest.OK(t, err)
+
+	var res benchmarkJSON
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &res))
+	rtest.Equals(t, uint64(2), res.Files)
+	rtest.Equals(t, uint64(3*1024*1024+6), res.TotalBytes)
+	rtest.Assert(t, res.CompressedBytes < res.TotalBytes, "expected zeros to be compressible, got %d of %d bytes", res.CompressedBytes, res.TotalBytes)
+
+	var names []string
+	for _, stage := range res.Stages {
+		names = append(names, stage.Name)
+	}
+	rtest.Equals(t, benchmarkStages, names)
+}
+
+func TestBenchmarkCompressionOff(t *testing.T) {
+	dir := t.TempDir()
+	rtest.OK(t, os.WriteFile(filepath.Join(dir, "zeros"), make([]byte, 3*1024*1024), 0600))
+
+	gopts := GlobalOptions{JSON: true}
+	rtest.OK(t, gopts.Compression.Set("off"))
+	buf, err := withCaptureStdout(func() error {
+		return runBenchmark(context.TODO(), BenchmarkOptions{}, gopts, []string{dir})
+	})
+	rtest.OK(t, err)
+
+	var res benchmarkJSON
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &res))
+	rtest.Equals(t, "off", res.Compression)
+	rtest.Equals(t, res.TotalBytes, res.CompressedBytes)
+}
+
+func TestBenchmarkRandomData(t *testing.T) {
+	gopts := GlobalOptions{JSON: true}
+	buf, err := withCaptureStdout(func() error {
+		return runBenchmark(context.TODO(), BenchmarkOptions{Size: "2M"}, gopts, nil)
+	})
+	rtest.OK(t, err)
+
+	var res benchmarkJSON
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &res))
+	rtest.Equals(t, uint64(0), res.Files)
+	rtest.Equals(t, uint64(2*1024*1024), res.TotalBytes)
+
+	err = runBenchmark(context.TODO(), BenchmarkOptions{Size: "foo"}, gopts, nil)
+	rtest.Assert(t, err != nil, "expected error for invalid size")
+}
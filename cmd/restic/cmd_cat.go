@@ -3,12 +3,14 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/index"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 )
@@ -19,6 +21,15 @@ var cmdCat = &cobra.Command{
 	Long: `
 The "cat" command is used to print internal objects to stdout.
 
+For pack files, "--list-blobs" prints the blobs listed in the pack header
+instead of the raw pack content, together with the information whether the
+index references them.
+
+For index files, "--json" prints the decoded index, listing the blobs of each
+pack, "--all" processes all index files of the repository and "--stats" prints
+statistics about the blobs and packs in the index files instead of their
+content.
+
 EXIT STATUS
 ===========
 
@@ -26,15 +37,29 @@ Exit status is 0 if the command was successful, and non-zero if there was any er
 `,
 	DisableAutoGenTag: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runCat(cmd.Context(), globalOptions, args)
+		return runCat(cmd.Context(), catOptions, globalOptions, args)
 	},
 }
 
+// CatOptions collects all options for the cat command.
+type CatOptions struct {
+	ListBlobs bool
+	All       bool
+	Stats     bool
+}
+
+var catOptions CatOptions
+
 func init() {
 	cmdRoot.AddCommand(cmdCat)
+
+	f := cmdCat.Flags()
+	f.BoolVar(&catOptions.ListBlobs, "list-blobs", false, "for packs, list the blobs in the pack header instead of printing the pack")
+	f.BoolVar(&catOptions.All, "all", false, "for indexes, process all index files of the repository")
+	f.BoolVar(&catOptions.Stats, "stats", false, "for indexes, print statistics instead of the index content")
 }
 
-func validateCatArgs(args []string) error {
+func validateCatArgs(args []string, allIndexes bool) error {
 	var allowedCmds = []string{"config", "index", "snapshot", "key", "masterkey", "lock", "pack", "blob", "tree"}
 
 	if len(args) < 1 {
@@ -52,6 +77,16 @@ func validateCatArgs(args []string) error {
 		return errors.Fatalf("invalid type %q, must be one of [%s]", args[0], strings.Join(allowedCmds, "|"))
 	}
 
+	if allIndexes {
+		if args[0] != "index" {
+			return errors.Fatal("--all can only be used with type index")
+		}
+		if len(args) != 1 {
+			return errors.Fatal("--all cannot be used together with an ID")
+		}
+		return nil
+	}
+
 	if args[0] != "masterkey" && args[0] != "config" && len(args) != 2 {
 		return errors.Fatal("ID not specified")
 	}
@@ -59,10 +94,16 @@ func validateCatArgs(args []string) error {
 	return nil
 }
 
-func runCat(ctx context.Context, gopts GlobalOptions, args []string) error {
-	if err := validateCatArgs(args); err != nil {
+func runCat(ctx context.Context, opts CatOptions, gopts GlobalOptions, args []string) error {
+	if err := validateCatArgs(args, opts.All); err != nil {
 		return err
 	}
+	if opts.ListBlobs && args[0] != "pack" {
+		return errors.Fatal("--list-blobs can only be used with type pack")
+	}
+	if opts.Stats && args[0] != "index" {
+		return errors.Fatal("--stats can only be used with type index")
+	}
 
 	repo, err := OpenRepository(ctx, gopts)
 	if err != nil {
@@ -81,7 +122,7 @@ func runCat(ctx context.Context, gopts GlobalOptions, args []string) error {
 	tpe := args[0]
 
 	var id restic.ID
-	if tpe != "masterkey" && tpe != "config" && tpe != "snapshot" && tpe != "tree" {
+	if tpe != "masterkey" && tpe != "config" && tpe != "snapshot" && tpe != "tree" && !opts.All {
 		id, err = restic.ParseID(args[1])
 		if err != nil {
 			return errors.Fatalf("unable to parse ID: %v\n", err)
@@ -98,6 +139,10 @@ func runCat(ctx context.Context, gopts GlobalOptions, args []string) error {
 		Println(string(buf))
 		return nil
 	case "index":
+		if opts.All || opts.Stats || gopts.JSON {
+			return catIndexes(ctx, repo, opts, gopts, id)
+		}
+
 		buf, err := repo.LoadUnpacked(ctx, restic.IndexFile, id)
 		if err != nil {
 			return err
@@ -154,6 +199,10 @@ func runCat(ctx context.Context, gopts GlobalOptions, args []string) error {
 		return nil
 
 	case "pack":
+		if opts.ListBlobs {
+			return listPackBlobs(ctx, repo, id, gopts)
+		}
+
 		h := backend.Handle{Type: restic.PackFile, Name: id.String()}
 		buf, err := backend.LoadAll(ctx, nil, repo.Backend(), h)
 		if err != nil {
@@ -220,3 +269,275 @@ func runCat(ctx context.Context, gopts GlobalOptions, args []string) error {
 		return errors.Fatal("invalid type")
 	}
 }
+
+// catPackBlob describes a blob from the header of a pack file.
+type catPackBlob struct {
+	ID                 restic.ID       `json:"id"`
+	Type               restic.BlobType `json:"type"`
+	Offset             uint            `json:"offset"`
+	Length             uint            `json:"length"`
+	UncompressedLength uint            `json:"uncompressed_length,omitempty"`
+	Referenced         bool            `json:"referenced"`
+}
+
+// catPack is the JSON output of cat pack --list-blobs.
+type catPack struct {
+	ID    restic.ID     `json:"id"`
+	Size  int64         `json:"size"`
+	Blobs []catPackBlob `json:"blobs"`
+	// blobs which the index lists for this pack but which are missing in its
+	// header
+	MissingBlobs []catPackBlob `json:"missing_blobs,omitempty"`
+}
+
+// listPackBlobs prints the blobs contained in the header of the pack file.
+// Only the header is decrypted, the blobs themselves are not read.
+func listPackBlobs(ctx context.Context, repo *repository.Repository, id restic.ID, gopts GlobalOptions) error {
+	fi, err := repo.Backend().Stat(ctx, backend.Handle{Type: restic.PackFile, Name: id.String()})
+	if err != nil {
+		return err
+	}
+
+	blobs, _, err := repo.ListPack(ctx, id, fi.Size)
+	if err != nil {
+		return errors.Fatalf("unable to read pack header: %v", err)
+	}
+	sort.Slice(blobs, func(i, j int) bool {
+		return blobs[i].Offset < blobs[j].Offset
+	})
+
+	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
+	err = repo.LoadIndex(ctx, bar)
+	if err != nil {
+		return err
+	}
+
+	// blobs at the same offset are identical, thus use it as key
+	indexed := make(map[uint]restic.Blob)
+	for pbs := range repo.Index().ListPacks(ctx, restic.NewIDSet(id)) {
+		for _, blob := range pbs.Blobs {
+			indexed[blob.Offset] = blob
+		}
+	}
+
+	p := catPack{ID: id, Size: fi.Size, Blobs: make([]catPackBlob, 0, len(blobs))}
+	for _, blob := range blobs {
+		ib, ok := indexed[blob.Offset]
+		referenced := ok && ib.BlobHandle == blob.BlobHandle && ib.Length == blob.Length
+		if referenced {
+			delete(indexed, blob.Offset)
+		}
+		p.Blobs = append(p.Blobs, catPackBlob{
+			ID:                 blob.ID,
+			Type:               blob.Type,
+			Offset:             blob.Offset,
+			Length:             blob.Length,
+			UncompressedLength: blob.UncompressedLength,
+			Referenced:         referenced,
+		})
+	}
+	for _, blob := range indexed {
+		p.MissingBlobs = append(p.MissingBlobs, catPackBlob{
+			ID:                 blob.ID,
+			Type:               blob.Type,
+			Offset:             blob.Offset,
+			Length:             blob.Length,
+			UncompressedLength: blob.UncompressedLength,
+			Referenced:         true,
+		})
+	}
+	sort.Slice(p.MissingBlobs, func(i, j int) bool {
+		return p.MissingBlobs[i].Offset < p.MissingBlobs[j].Offset
+	})
+
+	if gopts.JSON {
+		return json.NewEncoder(globalOptions.stdout).Encode(p)
+	}
+
+	referenced := 0
+	for _, blob := range p.Blobs {
+		status := "unreferenced"
+		if blob.Referenced {
+			status = "referenced"
+			referenced++
+		}
+		Printf("%v %v offset %-8d length %-8d %v\n", blob.Type, blob.ID, blob.Offset, blob.Length, status)
+	}
+	for _, blob := range p.MissingBlobs {
+		Printf("%v %v offset %-8d length %-8d missing from pack header\n", blob.Type, blob.ID, blob.Offset, blob.Length)
+	}
+	Printf("pack %v: %d blobs, %d referenced by the index", id.Str(), len(p.Blobs), referenced)
+	if len(p.MissingBlobs) > 0 {
+		Printf(", %d indexed blobs missing from the header", len(p.MissingBlobs))
+	}
+	Printf("\n")
+	return nil
+}
+
+// catIndexBlob describes a blob listed in an index file.
+type catIndexBlob struct {
+	ID                 restic.ID       `json:"id"`
+	Type               restic.BlobType `json:"type"`
+	Offset             uint            `json:"offset"`
+	Length             uint            `json:"length"`
+	UncompressedLength uint            `json:"uncompressed_length,omitempty"`
+}
+
+// catIndexPack describes a pack file listed in an index file.
+type catIndexPack struct {
+	ID    restic.ID      `json:"id"`
+	Blobs []catIndexBlob `json:"blobs"`
+}
+
+// catIndex is the JSON output of cat index --json.
+type catIndex struct {
+	ID         restic.ID      `json:"id"`
+	Supersedes restic.IDs     `json:"supersedes,omitempty"`
+	Packs      []catIndexPack `json:"packs"`
+}
+
+// catIndexStats is the JSON output of cat index --stats.
+type catIndexStats struct {
+	IndexFiles     int     `json:"index_files"`
+	Packs          int     `json:"packs"`
+	Blobs          int     `json:"blobs"`
+	DataBlobs      int     `json:"data_blobs"`
+	TreeBlobs      int     `json:"tree_blobs"`
+	BlobsPerPack   float64 `json:"average_blobs_per_pack"`
+	DuplicateBlobs int     `json:"duplicate_blobs"`
+}
+
+// newCatIndex converts the decoded index into its JSON representation, the
+// packs are sorted by ID and the blobs by their offset.
+func newCatIndex(ctx context.Context, id restic.ID, idx *index.Index) catIndex {
+	ci := catIndex{ID: id, Supersedes: idx.Supersedes(), Packs: []catIndexPack{}}
+	for pbs := range idx.EachByPack(ctx, nil) {
+		p := catIndexPack{ID: pbs.PackID, Blobs: make([]catIndexBlob, 0, len(pbs.Blobs))}
+		for _, blob := range pbs.Blobs {
+			p.Blobs = append(p.Blobs, catIndexBlob{
+				ID:                 blob.ID,
+				Type:               blob.Type,
+				Offset:             blob.Offset,
+				Length:             blob.Length,
+				UncompressedLength: blob.UncompressedLength,
+			})
+		}
+		sort.Slice(p.Blobs, func(i, j int) bool {
+			return p.Blobs[i].Offset < p.Blobs[j].Offset
+		})
+		ci.Packs = append(ci.Packs, p)
+	}
+	sort.Slice(ci.Packs, func(i, j int) bool {
+		return string(ci.Packs[i].ID[:]) < string(ci.Packs[j].ID[:])
+	})
+	return ci
+}
+
+// catIndexes prints the content of or statistics about the index file id, or
+// all index files if opts.All is set. Blobs are counted as duplicate if the
+// index files list them more than once, either in several packs or because a
+// pack is contained in several index files.
+func catIndexes(ctx context.Context, repo restic.Repository, opts CatOptions, gopts GlobalOptions, id restic.ID) error {
+	if !opts.Stats && !gopts.JSON {
+		return catAllIndexesRaw(ctx, repo)
+	}
+
+	var indexes []catIndex
+	handle := func(id restic.ID, idx *index.Index, _ bool, err error) error {
+		if err != nil {
+			return errors.Fatalf("unable to load index %v: %v", id.Str(), err)
+		}
+		indexes = append(indexes, newCatIndex(ctx, id, idx))
+		return nil
+	}
+
+	if opts.All {
+		err := index.ForAllIndexes(ctx, repo, repo, handle)
+		if err != nil {
+			return err
+		}
+		sort.Slice(indexes, func(i, j int) bool {
+			return string(indexes[i].ID[:]) < string(indexes[j].ID[:])
+		})
+	} else {
+		buf, err := repo.LoadUnpacked(ctx, restic.IndexFile, id)
+		if err != nil {
+			return err
+		}
+		idx, oldFormat, err := index.DecodeIndex(buf, id)
+		if err := handle(id, idx, oldFormat, err); err != nil {
+			return err
+		}
+	}
+
+	if !opts.Stats {
+		enc := json.NewEncoder(globalOptions.stdout)
+		for _, ci := range indexes {
+			if err := enc.Encode(ci); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	stats := catIndexStats{IndexFiles: len(indexes)}
+	packs := restic.NewIDSet()
+	blobs := restic.NewBlobSet()
+	for _, ci := range indexes {
+		for _, p := range ci.Packs {
+			packs.Insert(p.ID)
+			for _, blob := range p.Blobs {
+				stats.Blobs++
+				if blob.Type == restic.DataBlob {
+					stats.DataBlobs++
+				} else {
+					stats.TreeBlobs++
+				}
+
+				h := restic.BlobHandle{ID: blob.ID, Type: blob.Type}
+				if blobs.Has(h) {
+					stats.DuplicateBlobs++
+				}
+				blobs.Insert(h)
+			}
+		}
+	}
+	stats.Packs = len(packs)
+	if stats.Packs > 0 {
+		stats.BlobsPerPack = float64(stats.Blobs) / float64(stats.Packs)
+	}
+
+	if gopts.JSON {
+		return json.NewEncoder(globalOptions.stdout).Encode(stats)
+	}
+
+	Printf("index files:            %d\n", stats.IndexFiles)
+	Printf("packs:                  %d\n", stats.Packs)
+	Printf("blobs:                  %d (%d data, %d tree)\n", stats.Blobs, stats.DataBlobs, stats.TreeBlobs)
+	Printf("average blobs per pack: %.1f\n", stats.BlobsPerPack)
+	Printf("duplicate blobs:        %d\n", stats.DuplicateBlobs)
+	return nil
+}
+
+// catAllIndexesRaw prints the content of all index files as stored in the
+// repository.
+func catAllIndexesRaw(ctx context.Context, repo restic.Repository) error {
+	var ids restic.IDs
+	err := repo.List(ctx, restic.IndexFile, func(id restic.ID, _ int64) error {
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Sort(ids)
+
+	for _, id := range ids {
+		buf, err := repo.LoadUnpacked(ctx, restic.IndexFile, id)
+		if err != nil {
+			return err
+		}
+		Println(string(buf))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunCatListBlobs(t testing.TB, gopts GlobalOptions, packID string) string {
+	buf, err := withCaptureStdout(func() error {
+		return runCat(context.TODO(), CatOptions{ListBlobs: true}, gopts, []string{"pack", packID})
+	})
+	rtest.OK(t, err)
+	return buf.String()
+}
+
+func TestCatPackListBlobs(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+
+	gopts := env.gopts
+	gopts.JSON = true
+	for id := range listPacks(env.gopts, t) {
+		var p catPack
+		rtest.OK(t, json.Unmarshal([]byte(testRunCatListBlobs(t, gopts, id.String())), &p))
+		rtest.Equals(t, id, p.ID)
+		rtest.Assert(t, len(p.Blobs) > 0, "pack %v contains no blobs", id.Str())
+		rtest.Equals(t, 0, len(p.MissingBlobs))
+
+		var offset uint
+		for _, blob := range p.Blobs {
+			rtest.Assert(t, blob.Referenced, "blob %v is not referenced", blob.ID.Str())
+			rtest.Equals(t, offset, blob.Offset)
+			offset += blob.Length
+		}
+
+		out := testRunCatListBlobs(t, env.gopts, id.String())
+		rtest.Assert(t, strings.Contains(out, "referenced by the index"), "unexpected output %q", out)
+	}
+
+	err := runCat(context.TODO(), CatOptions{ListBlobs: true}, env.gopts, []string{"config"})
+	rtest.Assert(t, err != nil, "expected error for --list-blobs with type config")
+}
+
+func testRunCatIndex(t testing.TB, opts CatOptions, gopts GlobalOptions, args ...string) string {
+	buf, err := withCaptureStdout(func() error {
+		return runCat(context.TODO(), opts, gopts, append([]string{"index"}, args...))
+	})
+	rtest.OK(t, err)
+	return buf.String()
+}
+
+func TestCatIndex(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{Force: true}, env.gopts)
+
+	gopts := env.gopts
+	gopts.JSON = true
+	indexIDs := testRunList(t, "index", env.gopts)
+	packs := restic.NewIDSet()
+	for _, id := range indexIDs {
+		var idx catIndex
+		rtest.OK(t, json.Unmarshal([]byte(testRunCatIndex(t, CatOptions{}, gopts, id.String())), &idx))
+		rtest.Equals(t, id, idx.ID)
+		for _, p := range idx.Packs {
+			rtest.Assert(t, len(p.Blobs) > 0, "pack %v contains no blobs", p.ID.Str())
+			packs.Insert(p.ID)
+		}
+	}
+	rtest.Equals(t, listPacks(env.gopts, t), packs)
+
+	var stats catIndexStats
+	rtest.OK(t, json.Unmarshal([]byte(testRunCatIndex(t, CatOptions{All: true, Stats: true}, gopts)), &stats))
+	rtest.Equals(t, len(indexIDs), stats.IndexFiles)
+	rtest.Equals(t, len(packs), stats.Packs)
+	rtest.Equals(t, stats.Blobs, stats.DataBlobs+stats.TreeBlobs)
+	rtest.Equals(t, 0, stats.DuplicateBlobs)
+
+	out := testRunCatIndex(t, CatOptions{All: true, Stats: true}, env.gopts)
+	rtest.Assert(t, strings.Contains(out, "average blobs per pack"), "unexpected output %q", out)
+
+	err := runCat(context.TODO(), CatOptions{Stats: true}, env.gopts, []string{"config"})
+	rtest.Assert(t, err != nil, "expected error for --stats with type config")
+}
@@ -10,16 +10,20 @@ import (
 func TestCatArgsValidation(t *testing.T) {
 	for _, test := range []struct {
 		args []string
+		all  bool
 		err  string
 	}{
-		{[]string{}, "Fatal: type not specified"},
-		{[]string{"masterkey"}, ""},
-		{[]string{"invalid"}, `Fatal: invalid type "invalid"`},
-		{[]string{"snapshot"}, "Fatal: ID not specified"},
-		{[]string{"snapshot", "12345678"}, ""},
+		{[]string{}, false, "Fatal: type not specified"},
+		{[]string{"masterkey"}, false, ""},
+		{[]string{"invalid"}, false, `Fatal: invalid type "invalid"`},
+		{[]string{"snapshot"}, false, "Fatal: ID not specified"},
+		{[]string{"snapshot", "12345678"}, false, ""},
+		{[]string{"index"}, true, ""},
+		{[]string{"index", "12345678"}, true, "Fatal: --all cannot be used together with an ID"},
+		{[]string{"snapshot"}, true, "Fatal: --all can only be used with type index"},
 	} {
 		t.Run("", func(t *testing.T) {
-			err := validateCatArgs(test.args)
+			err := validateCatArgs(test.args, test.all)
 			if test.err == "" {
 				rtest.Assert(t, err == nil, "unexpected error %q", err)
 			} else {
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"math/rand"
 	"os"
 	"strconv"
@@ -11,10 +13,12 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/cache"
 	"github.com/restic/restic/internal/checker"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui"
 )
@@ -29,6 +33,51 @@ finds. It can also be used to read all data and therefore simulate a restore.
 By default, the "check" command will always load all data directly from the
 repository and not use a local cache.
 
+The check runs at one of the following levels, which are mutually exclusive:
+
+* "--struct-only" (the default) verifies that the index, the pack files, the
+  snapshots and the tree structure are consistent. It does not read any data
+  blobs and thus cannot detect damaged file contents.
+* "--read-data-subset" additionally reads a subset of the pack files and
+  verifies all blobs contained therein.
+* "--read-data" additionally reads all pack files, which verifies that all data
+  can be restored.
+
+The level used is reported at the end of the check.
+
+The "--verify-snapshots-loadable" option enables a quick mode, which only checks
+that all snapshots can be loaded and that the trees and blobs they reference
+are contained in the index. It skips checking the pack files and can run
+concurrently with other operations such as backup.
+
+The "--report-fragmentation" option prints how much of the pack files is still
+referenced by snapshots, which helps to decide whether running "prune" is
+worthwhile.
+
+The "--cache" option only verifies the files in the local cache instead of the
+repository. Each cached file is compared with the corresponding file in the
+repository, cached files which are missing in the repository or whose content
+differs are removed from the cache. This helps to tell a damaged cache from a
+damaged repository. Use the global "--no-cache" option to run any command
+without a cache.
+
+The "--read-concurrency" option sets how many pack files are read in parallel
+while verifying the data. With "--quarantine-file", damaged pack files found by
+"--read-data" or "--read-data-subset" are removed from the index and their IDs
+are appended to the given file. The pack files themselves are kept in the
+repository, "restic repair index" adds them to the index again.
+
+The "--cleanup-partial-packs" option removes pack files which are not
+referenced by any index, for example partially written pack files left behind
+by an interrupted backup. They are only removed if no errors were found and if
+they were last modified more than "--partial-pack-min-age" ago, such that pack
+files of a backup which is still running are kept.
+
+With the global "--no-lock" option, the repository is checked without creating
+a lock. The check then never writes to the repository and can run while other
+clients modify it. In that case, errors can be reported for data that is added
+or removed during the check.
+
 EXIT STATUS
 ===========
 
@@ -45,10 +94,21 @@ Exit status is 0 if the command was successful, and non-zero if there was any er
 
 // CheckOptions bundles all options for the 'check' command.
 type CheckOptions struct {
+	StructOnly     bool
 	ReadData       bool
 	ReadDataSubset string
 	CheckUnused    bool
 	WithCache      bool
+	VerifyCache    bool
+
+	VerifySnapshotsLoadable bool
+	ReportFragmentation     bool
+
+	ReadConcurrency uint
+	QuarantineFile  string
+
+	CleanupPartialPacks bool
+	PartialPackMinAge   time.Duration
 }
 
 var checkOptions CheckOptions
@@ -57,6 +117,7 @@ func init() {
 	cmdRoot.AddCommand(cmdCheck)
 
 	f := cmdCheck.Flags()
+	f.BoolVar(&checkOptions.StructOnly, "struct-only", false, "only verify the structure of the repository without reading data blobs (default)")
 	f.BoolVar(&checkOptions.ReadData, "read-data", false, "read all data blobs")
 	f.StringVar(&checkOptions.ReadDataSubset, "read-data-subset", "", "read a `subset` of data packs, specified as 'n/t' for specific part, or either 'x%' or 'x.y%' or a size in bytes with suffixes k/K, m/M, g/G, t/T for a random subset")
 	var ignored bool
@@ -67,12 +128,37 @@ func init() {
 		panic(err)
 	}
 	f.BoolVar(&checkOptions.WithCache, "with-cache", false, "use existing cache, only read uncached data from repository")
+	f.BoolVar(&checkOptions.VerifyCache, "cache", false, "only verify the local cache against the repository and remove damaged cached files")
+	f.BoolVar(&checkOptions.VerifySnapshotsLoadable, "verify-snapshots-loadable", false, "only check that all snapshots can be loaded and that the referenced trees and blobs are indexed")
+	f.BoolVar(&checkOptions.ReportFragmentation, "report-fragmentation", false, "report the ratio of referenced to total data in the pack files")
+	f.UintVar(&checkOptions.ReadConcurrency, "read-concurrency", 0, "read `n` pack files concurrently (default: number of backend connections)")
+	f.StringVar(&checkOptions.QuarantineFile, "quarantine-file", "", "remove damaged pack files from the index and append their IDs to `file`")
+	f.BoolVar(&checkOptions.CleanupPartialPacks, "cleanup-partial-packs", false, "remove pack files which are not referenced by any index, for example left behind by an interrupted backup")
+	f.DurationVar(&checkOptions.PartialPackMinAge, "partial-pack-min-age", 24*time.Hour, "with --cleanup-partial-packs, only remove pack files last modified more than `duration` ago")
 }
 
 func checkFlags(opts CheckOptions) error {
 	if opts.ReadData && opts.ReadDataSubset != "" {
 		return errors.Fatal("check flags --read-data and --read-data-subset cannot be used together")
 	}
+	if opts.StructOnly && (opts.ReadData || opts.ReadDataSubset != "") {
+		return errors.Fatal("check flag --struct-only cannot be used together with --read-data or --read-data-subset")
+	}
+	if opts.VerifySnapshotsLoadable && (opts.ReadData || opts.ReadDataSubset != "") {
+		return errors.Fatal("check flag --verify-snapshots-loadable cannot be used together with --read-data or --read-data-subset")
+	}
+	if opts.VerifyCache && (opts.ReadData || opts.ReadDataSubset != "" || opts.VerifySnapshotsLoadable || opts.ReportFragmentation || opts.QuarantineFile != "") {
+		return errors.Fatal("check flag --cache cannot be used together with other check modes")
+	}
+	if opts.CleanupPartialPacks && (opts.VerifySnapshotsLoadable || opts.VerifyCache) {
+		return errors.Fatal("check flag --cleanup-partial-packs cannot be used together with --verify-snapshots-loadable or --cache")
+	}
+	if opts.PartialPackMinAge < 0 {
+		return errors.Fatal("check flag --partial-pack-min-age must not be negative")
+	}
+	if (opts.ReadConcurrency > 0 || opts.QuarantineFile != "") && !opts.ReadData && opts.ReadDataSubset == "" {
+		return errors.Fatal("check flags --read-concurrency and --quarantine-file require --read-data or --read-data-subset")
+	}
 	if opts.ReadDataSubset != "" {
 		dataSubset, err := stringToIntSlice(opts.ReadDataSubset)
 		argumentError := errors.Fatal("check flag --read-data-subset has invalid value, please see documentation")
@@ -150,13 +236,13 @@ func parsePercentage(s string) (float64, error) {
 
 // prepareCheckCache configures a special cache directory for check.
 //
-//   - if --with-cache is specified, the default cache is used
+//   - if --with-cache or --cache is specified, the default cache is used
 //   - if the user explicitly requested --no-cache, we don't use any cache
 //   - if the user provides --cache-dir, we use a cache in a temporary sub-directory of the specified directory and the sub-directory is deleted after the check
 //   - by default, we use a cache in a temporary directory that is deleted after the check
 func prepareCheckCache(opts CheckOptions, gopts *GlobalOptions) (cleanup func()) {
 	cleanup = func() {}
-	if opts.WithCache {
+	if opts.WithCache || opts.VerifyCache {
 		// use the default cache, no setup needed
 		return cleanup
 	}
@@ -172,7 +258,7 @@ func prepareCheckCache(opts CheckOptions, gopts *GlobalOptions) (cleanup func())
 	}
 
 	// use a cache in a temporary directory
-	tempdir, err := os.MkdirTemp(cachedir, "restic-check-cache-")
+	tempdir, err := fs.MkdirTemp(cachedir, "restic-check-cache-")
 	if err != nil {
 		// if an error occurs, don't use any cache
 		Warnf("unable to create temporary directory for cache during check, disabling cache: %v\n", err)
@@ -204,22 +290,45 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 		return code, nil
 	})
 
+	if opts.VerifyCache && gopts.NoCache {
+		return errors.Fatal("check flag --cache cannot be used together with --no-cache")
+	}
+	if opts.QuarantineFile != "" && gopts.NoLock {
+		return errors.Fatal("check flag --quarantine-file cannot be used together with --no-lock")
+	}
+	if opts.CleanupPartialPacks && gopts.NoLock {
+		return errors.Fatal("check flag --cleanup-partial-packs cannot be used together with --no-lock")
+	}
+
 	repo, err := OpenRepository(ctx, gopts)
 	if err != nil {
 		return err
 	}
 
 	if !gopts.NoLock {
-		Verbosef("create exclusive lock for repository\n")
 		var lock *restic.Lock
-		lock, ctx, err = lockRepoExclusive(ctx, repo, gopts.RetryLock, gopts.JSON)
+		if opts.VerifySnapshotsLoadable || opts.VerifyCache {
+			// the quick check only reads snapshots, the index and trees,
+			// verifying the cache does not read the repository contents at all
+			lock, ctx, err = lockRepo(ctx, repo, gopts.RetryLock, gopts.JSON)
+		} else {
+			Verbosef("create exclusive lock for repository\n")
+			lock, ctx, err = lockRepoExclusive(ctx, repo, gopts.RetryLock, gopts.JSON)
+		}
 		defer unlockRepo(lock)
 		if err != nil {
 			return err
 		}
+	} else {
+		Verbosef("repository is not locked, errors may be reported if it is modified during the check\n")
+	}
+
+	if opts.VerifyCache {
+		return verifyCache(ctx, repo)
 	}
 
-	chkr := checker.New(repo, opts.CheckUnused)
+	chkr := checker.New(repo, opts.CheckUnused || opts.ReportFragmentation)
+	chkr.ReadConcurrency = opts.ReadConcurrency
 	err = chkr.LoadSnapshots(ctx)
 	if err != nil {
 		return err
@@ -260,30 +369,33 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 		return errors.Fatal("LoadIndex returned errors")
 	}
 
-	orphanedPacks := 0
-	errChan := make(chan error)
+	var orphanedPacks []*checker.PackError
+	if !opts.VerifySnapshotsLoadable {
+		errChan := make(chan error)
 
-	Verbosef("check all packs\n")
-	go chkr.Packs(ctx, errChan)
+		Verbosef("check all packs\n")
+		go chkr.Packs(ctx, errChan)
 
-	for err := range errChan {
-		if checker.IsOrphanedPack(err) {
-			orphanedPacks++
-			Verbosef("%v\n", err)
-		} else if err == checker.ErrLegacyLayout {
-			Verbosef("repository still uses the S3 legacy layout\nPlease run `restic migrate s3legacy` to correct this.\n")
-		} else {
-			errorsFound = true
-			Warnf("%v\n", err)
+		for err := range errChan {
+			var packErr *checker.PackError
+			if checker.IsOrphanedPack(err) && errors.As(err, &packErr) {
+				orphanedPacks = append(orphanedPacks, packErr)
+				Verbosef("%v\n", err)
+			} else if err == checker.ErrLegacyLayout {
+				Verbosef("repository still uses the S3 legacy layout\nPlease run `restic migrate s3legacy` to correct this.\n")
+			} else {
+				errorsFound = true
+				Warnf("%v\n", err)
+			}
 		}
-	}
 
-	if orphanedPacks > 0 {
-		Verbosef("%d additional files were found in the repo, which likely contain duplicate data.\nThis is non-critical, you can run `restic prune` to correct this.\n", orphanedPacks)
+		if len(orphanedPacks) > 0 && !opts.CleanupPartialPacks {
+			Verbosef("%d additional files were found in the repo, which likely contain duplicate data.\nThis is non-critical, you can run `restic prune` to correct this.\n", len(orphanedPacks))
+		}
 	}
 
 	Verbosef("check snapshots, trees and blobs\n")
-	errChan = make(chan error)
+	errChan := make(chan error)
 	var wg sync.WaitGroup
 
 	wg.Add(1)
@@ -315,6 +427,13 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 	// deadlocking in the case of errors.
 	wg.Wait()
 
+	if opts.ReportFragmentation {
+		err = printFragmentation(chkr.Fragmentation(ctx), gopts)
+		if err != nil {
+			return err
+		}
+	}
+
 	if opts.CheckUnused {
 		for _, id := range chkr.UnusedBlobs(ctx) {
 			Verbosef("unused blob %v\n", id)
@@ -322,11 +441,17 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 		}
 	}
 
+	var damagedPacks restic.IDs
 	doReadData := func(packs map[restic.ID]int64) {
 		packCount := uint64(len(packs))
+		var totalSize uint64
+		for _, size := range packs {
+			totalSize += uint64(size)
+		}
 
 		p := newProgressMax(!gopts.Quiet, packCount, "packs")
 		errChan := make(chan error)
+		start := time.Now()
 
 		go chkr.ReadPacks(ctx, packs, p, errChan)
 
@@ -336,6 +461,7 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 			errorsFound = true
 			Warnf("%v\n", err)
 			if err, ok := err.(*checker.ErrPackData); ok {
+				damagedPacks = append(damagedPacks, err.PackID)
 				if strings.Contains(err.Error(), "wrong data returned, hash is") {
 					salvagePacks = append(salvagePacks, err.PackID)
 				}
@@ -343,6 +469,12 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 		}
 		p.Done()
 
+		elapsed := time.Since(start)
+		if elapsed > 0 {
+			Verbosef("read %s in %s (%s/s)\n", ui.FormatBytes(totalSize), ui.FormatDuration(elapsed),
+				ui.FormatBytes(uint64(float64(totalSize)/elapsed.Seconds())))
+		}
+
 		if len(salvagePacks) > 0 {
 			Warnf("\nThe repository contains pack files with damaged blobs. These blobs must be removed to repair the repository. This can be done using the following commands:\n\n")
 			var strIds []string
@@ -395,6 +527,28 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 		doReadData(packs)
 	}
 
+	if opts.QuarantineFile != "" && len(damagedPacks) > 0 {
+		err = quarantinePacks(ctx, gopts, repo, opts.QuarantineFile, damagedPacks)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.CleanupPartialPacks && len(orphanedPacks) > 0 {
+		if errorsFound {
+			// the unreferenced packs may contain blobs which are missing from the index
+			Warnf("the repository contains errors, not removing %d unreferenced pack files\n", len(orphanedPacks))
+		} else {
+			err = cleanupPartialPacks(ctx, gopts, repo, orphanedPacks, opts.PartialPackMinAge)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !gopts.Quiet && !gopts.JSON {
+		Printf("%v\n", checkLevel(opts))
+	}
 	if errorsFound {
 		return errors.Fatal("repository contains errors")
 	}
@@ -404,6 +558,175 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 	return nil
 }
 
+// checkLevel describes what the check with the given options verified.
+func checkLevel(opts CheckOptions) string {
+	switch {
+	case opts.VerifySnapshotsLoadable:
+		return "checked that snapshots are loadable; pack files and data not read"
+	case opts.ReadData:
+		return "checked structure and all data"
+	case opts.ReadDataSubset != "":
+		return fmt.Sprintf("checked structure and data subset %v", opts.ReadDataSubset)
+	default:
+		return "checked structure; data not read"
+	}
+}
+
+// cleanupPartialPacks removes the pack files which are not referenced by any
+// index and were last modified more than minAge ago. Such packs are left behind
+// by interrupted backups. As the check found no errors, all blobs referenced by
+// the snapshots are contained in indexed packs, thus the removed packs are not
+// needed. Packs whose modification time is unknown are only removed if minAge
+// is zero, as they may belong to a backup which is still running.
+func cleanupPartialPacks(ctx context.Context, gopts GlobalOptions, repo restic.Repository, packs []*checker.PackError, minAge time.Duration) error {
+	cutoff := time.Now().Add(-minAge)
+	remove := restic.NewIDSet()
+	var removeSize uint64
+	recent := 0
+	for _, p := range packs {
+		if minAge > 0 && (p.ModTime.IsZero() || p.ModTime.After(cutoff)) {
+			recent++
+			continue
+		}
+		remove.Insert(p.ID)
+		removeSize += uint64(p.Size)
+	}
+
+	if recent > 0 && !gopts.JSON {
+		Printf("keeping %d unreferenced pack files modified within the last %v\n", recent, minAge)
+	}
+	if len(remove) == 0 {
+		return nil
+	}
+
+	if !gopts.JSON {
+		Verbosef("removing %d unreferenced pack files\n", len(remove))
+	}
+	err := DeleteFilesChecked(ctx, gopts, repo, remove, restic.PackFile)
+	if err != nil {
+		return err
+	}
+	if !gopts.JSON {
+		Printf("removed %d unreferenced pack files, freed %s\n", len(remove), ui.FormatBytes(removeSize))
+	}
+	return nil
+}
+
+// verifyCache compares the files in the local cache with the repository and
+// removes damaged cached files.
+func verifyCache(ctx context.Context, repo *repository.Repository) error {
+	if repo.Cache == nil {
+		return errors.Fatal("no local cache available for this repository")
+	}
+
+	Verbosef("verify cache in %v\n", repo.Cache.RepoDir())
+	res, err := repo.Cache.Verify(ctx, repo.Backend(), func(h backend.Handle, reason string) {
+		Printf("removed cached file %v: %v\n", h, reason)
+	})
+	if err != nil {
+		return err
+	}
+
+	Printf("verified %d cached files, removed %d damaged files\n", res.Checked, res.Removed)
+	return nil
+}
+
+// quarantinePacks appends the IDs of the damaged packs to filename and removes
+// the packs from the index. The pack files are kept in the repository, such
+// that the quarantine can be reverted by running "repair index".
+func quarantinePacks(ctx context.Context, gopts GlobalOptions, repo restic.Repository, filename string, ids restic.IDs) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return errors.Fatalf("unable to open quarantine file: %v", err)
+	}
+	for _, id := range ids {
+		if _, err := fmt.Fprintln(f, id); err != nil {
+			_ = f.Close()
+			return errors.Fatalf("unable to write quarantine file: %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return errors.Fatalf("unable to write quarantine file: %v", err)
+	}
+
+	Printf("removing %d damaged pack files from the index, their IDs were added to %v\n", len(ids), filename)
+	err = rebuildIndexFiles(ctx, gopts, repo, restic.NewIDSet(ids...), nil)
+	if err != nil {
+		return errors.Fatalf("%s", err)
+	}
+	Printf("the pack files are still stored in the repository, run `restic repair index` to add them to the index again\n")
+	return nil
+}
+
+// fragmentationThresholds are the live ratios for which the number of packs
+// below that ratio is reported.
+var fragmentationThresholds = []float64{0.25, 0.5, 0.75, 1}
+
+type fragmentationPackJSON struct {
+	ID        restic.ID `json:"id"`
+	Size      uint64    `json:"size"`
+	LiveSize  uint64    `json:"live_size"`
+	LiveRatio float64   `json:"live_ratio"`
+}
+
+type fragmentationThresholdJSON struct {
+	LiveRatio float64 `json:"live_ratio"`
+	Packs     int     `json:"packs"`
+}
+
+type fragmentationJSON struct {
+	MessageType         string                       `json:"message_type"` // "fragmentation"
+	TotalPacks          int                          `json:"total_packs"`
+	TotalSize           uint64                       `json:"total_size"`
+	LiveSize            uint64                       `json:"live_size"`
+	ReclaimableSize     uint64                       `json:"reclaimable_size"`
+	ReclaimablePercent  float64                      `json:"reclaimable_percent"`
+	PacksBelowThreshold []fragmentationThresholdJSON `json:"packs_below_threshold"`
+	Packs               []fragmentationPackJSON      `json:"packs"`
+}
+
+// printFragmentation prints the fragmentation statistics of the repository.
+// The individual packs are only listed in verbose mode or as JSON.
+func printFragmentation(f *checker.Fragmentation, gopts GlobalOptions) error {
+	var reclaimablePercent float64
+	if f.TotalSize > 0 {
+		reclaimablePercent = 100 * float64(f.Reclaimable()) / float64(f.TotalSize)
+	}
+
+	if gopts.JSON {
+		out := fragmentationJSON{
+			MessageType:        "fragmentation",
+			TotalPacks:         len(f.Packs),
+			TotalSize:          f.TotalSize,
+			LiveSize:           f.LiveSize,
+			ReclaimableSize:    f.Reclaimable(),
+			ReclaimablePercent: reclaimablePercent,
+			Packs:              make([]fragmentationPackJSON, 0, len(f.Packs)),
+		}
+		for _, t := range fragmentationThresholds {
+			out.PacksBelowThreshold = append(out.PacksBelowThreshold, fragmentationThresholdJSON{LiveRatio: t, Packs: f.PacksBelow(t)})
+		}
+		for _, p := range f.Packs {
+			out.Packs = append(out.Packs, fragmentationPackJSON{ID: p.ID, Size: p.Size, LiveSize: p.LiveSize, LiveRatio: p.LiveRatio()})
+		}
+		return json.NewEncoder(globalOptions.stdout).Encode(out)
+	}
+
+	for _, p := range f.Packs {
+		Verboseff("pack %v: %s of %s referenced (%.1f%%)\n", p.ID.Str(), ui.FormatBytes(p.LiveSize), ui.FormatBytes(p.Size), 100*p.LiveRatio())
+	}
+
+	Printf("\nfragmentation:\n")
+	Printf("  packs:        %d / %s\n", len(f.Packs), ui.FormatBytes(f.TotalSize))
+	Printf("  referenced:   %s\n", ui.FormatBytes(f.LiveSize))
+	Printf("  reclaimable:  %s (%.1f%%)\n", ui.FormatBytes(f.Reclaimable()), reclaimablePercent)
+	for _, t := range fragmentationThresholds {
+		Printf("  packs less than %3.0f%% referenced: %d\n", 100*t, f.PacksBelow(t))
+	}
+	Printf("\n")
+	return nil
+}
+
 // selectPacksByBucket selects subsets of packs by ranges of buckets.
 func selectPacksByBucket(allPacks map[restic.ID]int64, bucket, totalBuckets uint) map[restic.ID]int64 {
 	packs := make(map[restic.ID]int64)
@@ -2,8 +2,16 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 )
 
@@ -32,3 +40,274 @@ func testRunCheckOutput(gopts GlobalOptions, checkUnused bool) (string, error) {
 	})
 	return buf.String(), err
 }
+
+func testRunCheckSnapshotsLoadable(gopts GlobalOptions) error {
+	_, err := withCaptureStdout(func() error {
+		opts := CheckOptions{VerifySnapshotsLoadable: true}
+		return runCheck(context.TODO(), opts, gopts, nil)
+	})
+	return err
+}
+
+func TestCheckVerifySnapshotsLoadable(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	rtest.OK(t, testRunCheckSnapshotsLoadable(env.gopts))
+
+	// the quick check does not read data pack files
+	removePacksExcept(env.gopts, t, restic.NewIDSet(), false)
+	rtest.OK(t, testRunCheckSnapshotsLoadable(env.gopts))
+	testRunCheckMustFail(t, env.gopts)
+
+	// but it must detect trees that cannot be loaded
+	removePacksExcept(env.gopts, t, restic.NewIDSet(), true)
+	rtest.Assert(t, testRunCheckSnapshotsLoadable(env.gopts) != nil,
+		"expected an error for a repository without tree packs")
+}
+
+func testRunCheckFragmentation(t testing.TB, gopts GlobalOptions) fragmentationJSON {
+	buf, err := withCaptureStdout(func() error {
+		gopts.JSON = true
+		gopts.Quiet = false
+		opts := CheckOptions{ReportFragmentation: true}
+		return runCheck(context.TODO(), opts, gopts, nil)
+	})
+	rtest.OK(t, err)
+
+	var f fragmentationJSON
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &f))
+	rtest.Equals(t, "fragmentation", f.MessageType)
+	return f
+}
+
+func TestCheckReportFragmentation(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{}
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, opts, env.gopts)
+
+	f := testRunCheckFragmentation(t, env.gopts)
+	rtest.Assert(t, f.TotalPacks > 0 && f.TotalPacks == len(f.Packs), "unexpected number of packs %v", f.TotalPacks)
+	rtest.Equals(t, f.TotalSize, f.LiveSize)
+	rtest.Equals(t, uint64(0), f.ReclaimableSize)
+	rtest.Equals(t, 0, f.PacksBelowThreshold[len(f.PacksBelowThreshold)-1].Packs)
+
+	// only keep a snapshot of a subdirectory
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "2")}, opts, env.gopts)
+	testRunForget(t, env.gopts, testListSnapshots(t, env.gopts, 2)[0].String())
+	testListSnapshots(t, env.gopts, 1)
+
+	f = testRunCheckFragmentation(t, env.gopts)
+	rtest.Assert(t, f.ReclaimableSize > 0, "expected reclaimable data, got %v", f.ReclaimableSize)
+	rtest.Equals(t, f.TotalSize-f.LiveSize, f.ReclaimableSize)
+	rtest.Assert(t, f.PacksBelowThreshold[len(f.PacksBelowThreshold)-1].Packs > 0, "expected partially used packs")
+	for _, p := range f.Packs {
+		rtest.Assert(t, p.LiveSize <= p.Size, "pack %v has more live data than its size", p.ID)
+	}
+}
+
+func TestCheckNoLock(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+
+	// simulate another client which currently modifies the repository
+	repo, err := OpenRepository(context.TODO(), env.gopts)
+	rtest.OK(t, err)
+	lock, err := restic.NewExclusiveLock(context.TODO(), repo)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, lock.Unlock())
+	}()
+
+	gopts := env.gopts
+	gopts.NoLock = true
+	// the check must not attempt to write to the repository
+	gopts.backendTestHook = func(r backend.Backend) (backend.Backend, error) {
+		return &readOnlyBackend{r}, nil
+	}
+	output, err := testRunCheckOutput(gopts, false)
+	if err != nil {
+		t.Error(output)
+		t.Fatalf("unexpected error: %+v", err)
+	}
+}
+
+func TestCheckQuarantineFile(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+
+	// damage the content of the largest pack file, which contains data blobs
+	var damaged string
+	var damagedSize int64
+	rtest.OK(t, filepath.Walk(filepath.Join(env.repo, "data"), func(p string, fi os.FileInfo, err error) error {
+		if err == nil && fi.Mode().IsRegular() && fi.Size() > damagedSize {
+			damaged, damagedSize = p, fi.Size()
+		}
+		return err
+	}))
+	rtest.OK(t, os.Chmod(damaged, 0o644))
+	f, err := os.OpenFile(damaged, os.O_RDWR, 0)
+	rtest.OK(t, err)
+	_, err = f.WriteAt([]byte("damaged"), 100)
+	rtest.OK(t, err)
+	rtest.OK(t, f.Close())
+
+	quarantine := filepath.Join(env.base, "quarantine")
+	_, err = withCaptureStdout(func() error {
+		opts := CheckOptions{ReadData: true, ReadConcurrency: 1, QuarantineFile: quarantine}
+		return runCheck(context.TODO(), opts, env.gopts, nil)
+	})
+	rtest.Assert(t, err != nil, "expected error for damaged pack file")
+	data, err := os.ReadFile(quarantine)
+	rtest.OK(t, err)
+	rtest.Equals(t, filepath.Base(damaged)+"\n", string(data))
+
+	// the pack file is kept, but no longer referenced by the index
+	_, err = os.Stat(damaged)
+	rtest.OK(t, err)
+	output, err := withCaptureStdout(func() error {
+		return runCheck(context.TODO(), CheckOptions{}, env.gopts, nil)
+	})
+	rtest.Assert(t, err != nil, "expected error for snapshot referencing quarantined data")
+	rtest.Assert(t, !strings.Contains(output.String(), "contains 1 errors"), "unexpected pack error in output: %v", output.String())
+
+	// repairing the index reverts the quarantine
+	testRunRebuildIndex(t, env.gopts)
+	testRunCheckMustFail(t, env.gopts)
+}
+
+func testRunCheckCache(t testing.TB, gopts GlobalOptions) string {
+	buf, err := withCaptureStdout(func() error {
+		return runCheck(context.TODO(), CheckOptions{VerifyCache: true}, gopts, nil)
+	})
+	rtest.OK(t, err)
+	return buf.String()
+}
+
+func TestCheckVerifyCache(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	testListSnapshots(t, env.gopts, 1)
+
+	output := testRunCheckCache(t, env.gopts)
+	rtest.Assert(t, strings.Contains(output, ", removed 0 damaged files"), "unexpected output %q", output)
+
+	// damage all cached snapshot files
+	var damaged []string
+	err := filepath.Walk(env.cache, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() && filepath.Base(filepath.Dir(filepath.Dir(path))) == "snapshots" {
+			damaged = append(damaged, path)
+			return os.WriteFile(path, []byte("damaged cache file, not a snapshot"), 0o600)
+		}
+		return nil
+	})
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(damaged))
+
+	output = testRunCheckCache(t, env.gopts)
+	rtest.Assert(t, strings.Contains(output, ", removed 1 damaged files"), "unexpected output %q", output)
+	_, err = os.Stat(damaged[0])
+	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "damaged file was not removed: %v", err)
+
+	// the repository itself is intact
+	testRunCheck(t, env.gopts)
+}
+
+func testRunCheckCleanupPartialPacks(gopts GlobalOptions, minAge time.Duration) (string, error) {
+	buf, err := withCaptureStdout(func() error {
+		opts := CheckOptions{CleanupPartialPacks: true, PartialPackMinAge: minAge}
+		return runCheck(context.TODO(), opts, gopts, nil)
+	})
+	return buf.String(), err
+}
+
+func TestCheckCleanupPartialPacks(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	packs := listPacks(env.gopts, t)
+
+	// simulate a pack file left behind by an interrupted backup
+	repo, err := OpenRepository(context.TODO(), env.gopts)
+	rtest.OK(t, err)
+	buf := rtest.Random(23, 1000)
+	id := restic.Hash(buf)
+	rtest.OK(t, repo.Backend().Save(context.TODO(), backend.Handle{Type: restic.PackFile, Name: id.String()}, backend.NewByteReader(buf, repo.Backend().Hasher())))
+
+	// the pack file could belong to a running backup
+	output, err := testRunCheckCleanupPartialPacks(env.gopts, 24*time.Hour)
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(output, "keeping 1 unreferenced pack files"), "unexpected output %q", output)
+	rtest.Assert(t, listPacks(env.gopts, t).Has(id), "recent pack file was removed")
+
+	old := time.Now().Add(-48 * time.Hour)
+	rtest.OK(t, os.Chtimes(filepath.Join(env.repo, "data", id.String()[:2], id.String()), old, old))
+	output, err = testRunCheckCleanupPartialPacks(env.gopts, 24*time.Hour)
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(output, "removed 1 unreferenced pack files"), "unexpected output %q", output)
+	rtest.Equals(t, packs, listPacks(env.gopts, t))
+
+	// without index, the snapshots reference data in unreferenced packs
+	for _, id := range testRunList(t, "index", env.gopts) {
+		rtest.OK(t, os.Remove(filepath.Join(env.repo, "index", id.String())))
+	}
+	_, err = testRunCheckCleanupPartialPacks(env.gopts, 0)
+	rtest.Assert(t, err != nil, "expected error for repository without index")
+	rtest.Equals(t, packs, listPacks(env.gopts, t))
+}
+
+func TestCheckLevel(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+
+	// the check level is not printed with --quiet
+	gopts := env.gopts
+	gopts.Quiet = false
+
+	for _, test := range []struct {
+		opts  CheckOptions
+		level string
+	}{
+		{CheckOptions{}, "checked structure; data not read"},
+		{CheckOptions{StructOnly: true}, "checked structure; data not read"},
+		{CheckOptions{ReadDataSubset: "50%"}, "checked structure and data subset 50%"},
+		{CheckOptions{ReadData: true}, "checked structure and all data"},
+	} {
+		rtest.OK(t, checkFlags(test.opts))
+		out, err := withCaptureStdout(func() error {
+			return runCheck(context.TODO(), test.opts, gopts, nil)
+		})
+		rtest.OK(t, err)
+		rtest.Assert(t, strings.Contains(out.String(), test.level+"\n"), "missing check level %q in output:\n%s", test.level, out.String())
+	}
+
+	for _, opts := range []CheckOptions{
+		{StructOnly: true, ReadData: true},
+		{StructOnly: true, ReadDataSubset: "1/2"},
+		{ReadData: true, ReadDataSubset: "1/2"},
+	} {
+		rtest.Assert(t, checkFlags(opts) != nil, "missing error for conflicting check levels %+v", opts)
+	}
+}
@@ -2,9 +2,13 @@ package main
 
 import (
 	"math"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 )
@@ -163,3 +167,24 @@ func TestSelectNoRandomPacksByFileSize(t *testing.T) {
 	selectedPacks := selectRandomPacksByFileSize(testPacks, 10, 500)
 	rtest.Assert(t, len(selectedPacks) == 0, "Expected 0 selected packs")
 }
+
+func TestPrepareCheckCacheTempDir(t *testing.T) {
+	t.Setenv("RESTIC_CACHE_DIR", "")
+	tempdir := rtest.TempDir(t)
+	rtest.OK(t, fs.SetTempDir(tempdir))
+	defer func() {
+		rtest.OK(t, fs.SetTempDir(""))
+	}()
+
+	// without --cache-dir, the temporary cache is created in --tmp-dir
+	gopts := GlobalOptions{}
+	cleanup := prepareCheckCache(CheckOptions{}, &gopts)
+	rtest.Assert(t, !gopts.NoCache, "cache was disabled")
+	rtest.Equals(t, tempdir, filepath.Dir(gopts.CacheDir))
+	_, err := os.Stat(gopts.CacheDir)
+	rtest.OK(t, err)
+
+	cleanup()
+	_, err = os.Stat(gopts.CacheDir)
+	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "temporary cache was not removed: %v", err)
+}
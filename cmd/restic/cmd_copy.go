@@ -182,6 +182,25 @@ func similarSnapshots(sna *restic.Snapshot, snb *restic.Snapshot) bool {
 	return true
 }
 
+// copySnapshot copies the snapshot with the given id and all data referenced by
+// it from srcRepo to dstRepo. Blobs already stored in dstRepo are not copied.
+func copySnapshot(ctx context.Context, srcRepo restic.Repository, dstRepo restic.Repository, id restic.ID, quiet bool) (restic.ID, error) {
+	sn, err := restic.LoadSnapshot(ctx, srcRepo, id)
+	if err != nil {
+		return restic.ID{}, err
+	}
+
+	err = copyTree(ctx, srcRepo, dstRepo, restic.NewIDSet(), *sn.Tree, quiet)
+	if err != nil {
+		return restic.ID{}, err
+	}
+
+	// Parent does not have relevance in the new repo.
+	sn.Parent = nil
+	sn.Original = &id
+	return restic.SaveSnapshot(ctx, dstRepo, sn)
+}
+
 func copyTree(ctx context.Context, srcRepo restic.Repository, dstRepo restic.Repository,
 	visitedTrees restic.IDSet, rootTreeID restic.ID, quiet bool) error {
 
@@ -236,7 +255,7 @@ func copyTree(ctx context.Context, srcRepo restic.Repository, dstRepo restic.Rep
 	}
 
 	bar := newProgressMax(!quiet, uint64(len(packList)), "packs copied")
-	_, err = repository.Repack(ctx, srcRepo, dstRepo, packList, copyBlobs, bar)
+	_, err = repository.Repack(ctx, srcRepo, dstRepo, packList, copyBlobs, bar, nil)
 	bar.Done()
 	if err != nil {
 		return errors.Fatal(err.Error())
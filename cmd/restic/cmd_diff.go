@@ -3,19 +3,24 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"os"
 	"path"
+	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
 
+	"github.com/restic/restic/internal/archiver"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui"
 	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
 )
 
 var cmdDiff = &cobra.Command{
-	Use:   "diff [flags] snapshotID snapshotID",
+	Use:   "diff [flags] snapshotID [snapshotID]",
 	Short: "Show differences between two snapshots",
 	Long: `
 The "diff" command shows differences from the first to the second snapshot. The
@@ -32,10 +37,21 @@ directory:
 Metadata comparison will likely not work if a backup was created using the
 '--ignore-inode' or '--ignore-ctime' option.
 
+The snapshot ID "latest" selects the latest snapshot matching the options
+"--host" and "--tag" as well as the time range given by "--since" and "--until".
+
 To only compare files in specific subfolders, you can use the
 "<snapshotID>:<subfolder>" syntax, where "subfolder" is a path within the
 snapshot.
 
+With "--path dir", a single snapshot is compared to the directory "dir" in the
+local filesystem instead, which shows the changes the next backup of that
+directory would capture. Unless a subfolder is specified, the directory is
+compared to the same path within the snapshot. Files are reported as modified
+using the same change detection as the "backup" command, which only compares
+the metadata of files but not their content. Files matching one of the exclude
+patterns are treated as if they were not present in the local filesystem.
+
 EXIT STATUS
 ===========
 
@@ -50,6 +66,12 @@ Exit status is 0 if the command was successful, and non-zero if there was any er
 // DiffOptions collects all options for the diff command.
 type DiffOptions struct {
 	ShowMetadata bool
+	Path         string
+	IgnoreInode  bool
+	IgnoreCtime  bool
+	PathMap      []string
+	excludePatternOptions
+	restic.SnapshotFilter
 }
 
 var diffOptions DiffOptions
@@ -59,10 +81,17 @@ func init() {
 
 	f := cmdDiff.Flags()
 	f.BoolVar(&diffOptions.ShowMetadata, "metadata", false, "print changes in metadata")
+	f.StringVar(&diffOptions.Path, "path", "", "compare the snapshot to the `directory` in the local filesystem")
+	f.BoolVar(&diffOptions.IgnoreInode, "ignore-inode", false, "ignore inode number changes when checking for modified files (only with --path)")
+	f.BoolVar(&diffOptions.IgnoreCtime, "ignore-ctime", false, "ignore ctime changes when checking for modified files (only with --path)")
+	initExcludePatternOptions(f, &diffOptions.excludePatternOptions)
+	initPathMap(f, &diffOptions.PathMap)
+	// --path compares to a local directory, thus only filter by host, tag and time
+	initSnapshotFilter(f, &diffOptions.SnapshotFilter, "H", latestFilterSuffix, false)
 }
 
-func loadSnapshot(ctx context.Context, be restic.Lister, repo restic.Repository, desc string) (*restic.Snapshot, string, error) {
-	sn, subfolder, err := restic.FindSnapshot(ctx, be, repo, desc)
+func loadSnapshot(ctx context.Context, be restic.Lister, repo restic.Repository, filter *restic.SnapshotFilter, desc string) (*restic.Snapshot, string, error) {
+	sn, subfolder, err := filter.FindLatest(ctx, be, repo, desc)
 	if err != nil {
 		return nil, "", errors.Fatal(err.Error())
 	}
@@ -74,16 +103,100 @@ type Comparer struct {
 	repo        restic.Repository
 	opts        DiffOptions
 	printChange func(change *Change)
+
+	// only used when comparing with the local filesystem
+	rejectByName []RejectByNameFunc
+	ignoreFlags  uint
+}
+
+// newComparer returns a Comparer which prints the changes according to gopts.
+// The paths of the changes are printed after applying pathMap.
+func newComparer(repo restic.Repository, opts DiffOptions, gopts GlobalOptions, pathMap restic.PathMap) *Comparer {
+	c := &Comparer{
+		repo: repo,
+		opts: opts,
+		printChange: func(change *Change) {
+			Printf("%-5s%v\n", change.Modifier, change.Path)
+		},
+	}
+
+	if gopts.JSON {
+		enc := json.NewEncoder(globalOptions.stdout)
+		c.printChange = func(change *Change) {
+			err := enc.Encode(change)
+			if err != nil {
+				Warnf("JSON encode failed: %v\n", err)
+			}
+		}
+	}
+
+	if gopts.Quiet {
+		c.printChange = func(change *Change) {}
+	}
+
+	if len(pathMap) > 0 {
+		printChange := c.printChange
+		c.printChange = func(change *Change) {
+			mapped := pathMap.Apply(change.Path)
+			if strings.HasSuffix(change.Path, "/") && !strings.HasSuffix(mapped, "/") {
+				mapped += "/"
+			}
+			change.Path = mapped
+			printChange(change)
+		}
+	}
+
+	return c
 }
 
 type Change struct {
 	MessageType string `json:"message_type"` // "change"
 	Path        string `json:"path"`
 	Modifier    string `json:"modifier"`
+	ChangeType  string `json:"change_type"`
+	// the sizes are only set for files, the modes for all existing items
+	OldSize *uint64      `json:"old_size,omitempty"`
+	NewSize *uint64      `json:"new_size,omitempty"`
+	OldMode *os.FileMode `json:"old_mode,omitempty"`
+	NewMode *os.FileMode `json:"new_mode,omitempty"`
+}
+
+// NewChange returns a change for path. The nodes before and after the change
+// may be nil if the item was added or removed.
+func NewChange(path string, mode string, before, after *restic.Node) *Change {
+	c := &Change{MessageType: "change", Path: path, Modifier: mode, ChangeType: changeType(mode)}
+	c.OldSize, c.OldMode = changeSizeMode(before)
+	c.NewSize, c.NewMode = changeSizeMode(after)
+	return c
 }
 
-func NewChange(path string, mode string) *Change {
-	return &Change{MessageType: "change", Path: path, Modifier: mode}
+// changeSizeMode returns the size and mode of node for a change. The size is
+// only returned for files.
+func changeSizeMode(node *restic.Node) (size *uint64, mode *os.FileMode) {
+	if node == nil {
+		return nil, nil
+	}
+	if node.Type == "file" {
+		s := node.Size
+		size = &s
+	}
+	m := node.Mode
+	return size, &m
+}
+
+// changeType returns a descriptive name for the modifier of a change.
+func changeType(mode string) string {
+	switch {
+	case mode == "+":
+		return "added"
+	case mode == "-":
+		return "removed"
+	case strings.Contains(mode, "T"):
+		return "type-changed"
+	default:
+		// changed content ("M"), metadata ("U") or detected bitrot ("?")
+		return "modified"
+	}
 }
 
 // DiffStat collects stats for all types of items.
@@ -139,7 +252,8 @@ func addBlobs(bs restic.BlobSet, node *restic.Node) {
 type DiffStatsContainer struct {
 	MessageType                          string         `json:"message_type"` // "statistics"
 	SourceSnapshot                       string         `json:"source_snapshot"`
-	TargetSnapshot                       string         `json:"target_snapshot"`
+	TargetSnapshot                       string         `json:"target_snapshot,omitempty"`
+	TargetPath                           string         `json:"target_path,omitempty"`
 	ChangedFiles                         int            `json:"changed_files"`
 	Added                                DiffStat       `json:"added"`
 	Removed                              DiffStat       `json:"removed"`
@@ -178,7 +292,11 @@ func (c *Comparer) printDir(ctx context.Context, mode string, stats *DiffStat, b
 		if node.Type == "dir" {
 			name += "/"
 		}
-		c.printChange(NewChange(name, mode))
+		if mode == "-" {
+			c.printChange(NewChange(name, mode, node, nil))
+		} else {
+			c.printChange(NewChange(name, mode, nil, node))
+		}
 		stats.Add(node)
 		addBlobs(blobs, node)
 
@@ -239,13 +357,20 @@ func uniqueNodeNames(tree1, tree2 *restic.Tree) (tree1Nodes, tree2Nodes map[stri
 
 func (c *Comparer) diffTree(ctx context.Context, stats *DiffStatsContainer, prefix string, id1, id2 restic.ID) error {
 	debug.Log("diffing %v to %v", id1, id2)
-	tree1, err := restic.LoadTree(ctx, c.repo, id1)
-	if err != nil {
-		return err
-	}
 
-	tree2, err := restic.LoadTree(ctx, c.repo, id2)
-	if err != nil {
+	// load both trees concurrently, the changes are still reported in the
+	// order of the sorted node names
+	var tree1, tree2 *restic.Tree
+	wg, wgCtx := errgroup.WithContext(ctx)
+	wg.Go(func() (err error) {
+		tree1, err = restic.LoadTree(wgCtx, c.repo, id1)
+		return err
+	})
+	wg.Go(func() (err error) {
+		tree2, err = restic.LoadTree(wgCtx, c.repo, id2)
+		return err
+	})
+	if err := wg.Wait(); err != nil {
 		return err
 	}
 
@@ -291,7 +416,7 @@ func (c *Comparer) diffTree(ctx context.Context, stats *DiffStatsContainer, pref
 			}
 
 			if mod != "" {
-				c.printChange(NewChange(name, mod))
+				c.printChange(NewChange(name, mod, node1, node2))
 			}
 
 			if node1.Type == "dir" && node2.Type == "dir" {
@@ -310,7 +435,7 @@ func (c *Comparer) diffTree(ctx context.Context, stats *DiffStatsContainer, pref
 			if node1.Type == "dir" {
 				prefix += "/"
 			}
-			c.printChange(NewChange(prefix, "-"))
+			c.printChange(NewChange(prefix, "-", node1, nil))
 			stats.Removed.Add(node1)
 
 			if node1.Type == "dir" {
@@ -324,7 +449,7 @@ func (c *Comparer) diffTree(ctx context.Context, stats *DiffStatsContainer, pref
 			if node2.Type == "dir" {
 				prefix += "/"
 			}
-			c.printChange(NewChange(prefix, "+"))
+			c.printChange(NewChange(prefix, "+", nil, node2))
 			stats.Added.Add(node2)
 
 			if node2.Type == "dir" {
@@ -340,10 +465,23 @@ func (c *Comparer) diffTree(ctx context.Context, stats *DiffStatsContainer, pref
 }
 
 func runDiff(ctx context.Context, opts DiffOptions, gopts GlobalOptions, args []string) error {
+	if opts.Path != "" {
+		return runDiffLive(ctx, opts, gopts, args)
+	}
+
+	if !opts.excludePatternOptions.Empty() || opts.IgnoreInode || opts.IgnoreCtime {
+		return errors.Fatal("--exclude, --ignore-inode and --ignore-ctime can only be used together with --path")
+	}
+
 	if len(args) != 2 {
 		return errors.Fatalf("specify two snapshot IDs")
 	}
 
+	pathMap, err := parsePathMap(opts.PathMap)
+	if err != nil {
+		return err
+	}
+
 	repo, err := OpenRepository(ctx, gopts)
 	if err != nil {
 		return err
@@ -363,12 +501,12 @@ func runDiff(ctx context.Context, opts DiffOptions, gopts GlobalOptions, args []
 	if err != nil {
 		return err
 	}
-	sn1, subfolder1, err := loadSnapshot(ctx, be, repo, args[0])
+	sn1, subfolder1, err := loadSnapshot(ctx, be, repo, &opts.SnapshotFilter, args[0])
 	if err != nil {
 		return err
 	}
 
-	sn2, subfolder2, err := loadSnapshot(ctx, be, repo, args[1])
+	sn2, subfolder2, err := loadSnapshot(ctx, be, repo, &opts.SnapshotFilter, args[1])
 	if err != nil {
 		return err
 	}
@@ -399,27 +537,7 @@ func runDiff(ctx context.Context, opts DiffOptions, gopts GlobalOptions, args []
 		return err
 	}
 
-	c := &Comparer{
-		repo: repo,
-		opts: diffOptions,
-		printChange: func(change *Change) {
-			Printf("%-5s%v\n", change.Modifier, change.Path)
-		},
-	}
-
-	if gopts.JSON {
-		enc := json.NewEncoder(globalOptions.stdout)
-		c.printChange = func(change *Change) {
-			err := enc.Encode(change)
-			if err != nil {
-				Warnf("JSON encode failed: %v\n", err)
-			}
-		}
-	}
-
-	if gopts.Quiet {
-		c.printChange = func(change *Change) {}
-	}
+	c := newComparer(repo, opts, gopts, pathMap)
 
 	stats := &DiffStatsContainer{
 		MessageType:    "statistics",
@@ -459,3 +577,290 @@ func runDiff(ctx context.Context, opts DiffOptions, gopts GlobalOptions, args []
 
 	return nil
 }
+
+// snapshotPath returns the path at which the archiver stores the absolute
+// path dir within a snapshot.
+func snapshotPath(dir string) string {
+	volume := filepath.VolumeName(dir)
+	p := filepath.ToSlash(dir[len(volume):])
+	if volume != "" {
+		p = "/" + strings.TrimSuffix(volume, ":") + p
+	}
+	return p
+}
+
+func (c *Comparer) rejected(item string) bool {
+	for _, reject := range c.rejectByName {
+		if reject(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadLiveDir returns a tree with nodes for all items in the directory dir in
+// the local filesystem, which are not excluded, together with their FileInfos.
+func (c *Comparer) loadLiveDir(dir string) (*restic.Tree, map[string]os.FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tree := restic.NewTree(len(entries))
+	fileInfos := make(map[string]os.FileInfo, len(entries))
+	for _, entry := range entries {
+		item := filepath.Join(dir, entry.Name())
+		if c.rejected(item) {
+			debug.Log("%v is excluded", item)
+			continue
+		}
+
+		fi, err := os.Lstat(item)
+		if err != nil {
+			Warnf("error: %v\n", err)
+			continue
+		}
+
+		node, err := restic.NodeFromFileInfo(item, fi)
+		if err != nil {
+			Warnf("error: %v\n", err)
+		}
+		tree.Nodes = append(tree.Nodes, node)
+		fileInfos[node.Name] = fi
+	}
+
+	return tree, fileInfos, nil
+}
+
+// printLiveDir prints all items in the directory dir in the local filesystem
+// as added.
+func (c *Comparer) printLiveDir(ctx context.Context, stats *DiffStat, prefix string, dir string) error {
+	tree, _, err := c.loadLiveDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range tree.Nodes {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		name := path.Join(prefix, node.Name)
+		if node.Type == "dir" {
+			name += "/"
+		}
+		c.printChange(NewChange(name, "+", nil, node))
+		stats.Add(node)
+
+		if node.Type == "dir" {
+			err := c.printLiveDir(ctx, stats, name, filepath.Join(dir, node.Name))
+			if err != nil {
+				Warnf("error: %v\n", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// metadataChanged returns true if the metadata of the file in the local
+// filesystem differs from the node in the snapshot.
+func metadataChanged(node, live *restic.Node) bool {
+	return node.Mode != live.Mode || node.UID != live.UID || node.GID != live.GID ||
+		!node.ModTime.Equal(live.ModTime)
+}
+
+// diffLive compares the tree with the directory dir in the local filesystem.
+func (c *Comparer) diffLive(ctx context.Context, stats *DiffStatsContainer, prefix string, id restic.ID, dir string) error {
+	debug.Log("diffing %v to %v", id, dir)
+
+	tree, err := restic.LoadTree(ctx, c.repo, id)
+	if err != nil {
+		return err
+	}
+
+	liveTree, fileInfos, err := c.loadLiveDir(dir)
+	if err != nil {
+		return err
+	}
+
+	treeNodes, liveNodes, names := uniqueNodeNames(tree, liveTree)
+
+	for _, name := range names {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		node1, t1 := treeNodes[name]
+		node2, t2 := liveNodes[name]
+
+		switch {
+		case t1 && t2:
+			item := path.Join(prefix, name)
+			mod := ""
+
+			if node1.Type != node2.Type {
+				mod += "T"
+			}
+
+			if node2.Type == "dir" {
+				item += "/"
+			}
+
+			switch {
+			case node1.Type == "file" && node2.Type == "file" &&
+				archiver.FileChanged(fileInfos[name], node1, c.ignoreFlags):
+				mod += "M"
+				stats.ChangedFiles++
+			case node1.Type == "symlink" && node2.Type == "symlink" &&
+				node1.LinkTarget != node2.LinkTarget:
+				mod += "M"
+			case c.opts.ShowMetadata && metadataChanged(node1, node2):
+				mod += "U"
+			}
+
+			if mod != "" {
+				c.printChange(NewChange(item, mod, node1, node2))
+			}
+
+			if node1.Type == "dir" && node2.Type == "dir" {
+				err := c.diffLive(ctx, stats, item, *node1.Subtree, filepath.Join(dir, name))
+				if err != nil {
+					Warnf("error: %v\n", err)
+				}
+			}
+		case t1 && !t2:
+			item := path.Join(prefix, name)
+			if node1.Type == "dir" {
+				item += "/"
+			}
+			c.printChange(NewChange(item, "-", node1, nil))
+			stats.Removed.Add(node1)
+
+			if node1.Type == "dir" {
+				err := c.printDir(ctx, "-", &stats.Removed, restic.NewBlobSet(), item, *node1.Subtree)
+				if err != nil {
+					Warnf("error: %v\n", err)
+				}
+			}
+		case !t1 && t2:
+			item := path.Join(prefix, name)
+			if node2.Type == "dir" {
+				item += "/"
+			}
+			c.printChange(NewChange(item, "+", nil, node2))
+			stats.Added.Add(node2)
+
+			if node2.Type == "dir" {
+				err := c.printLiveDir(ctx, &stats.Added, item, filepath.Join(dir, name))
+				if err != nil {
+					Warnf("error: %v\n", err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func runDiffLive(ctx context.Context, opts DiffOptions, gopts GlobalOptions, args []string) error {
+	if len(args) != 1 {
+		return errors.Fatal("specify one snapshot ID to compare with --path")
+	}
+
+	target, err := filepath.Abs(opts.Path)
+	if err != nil {
+		return errors.Fatal(err.Error())
+	}
+	fi, err := os.Stat(target)
+	if err != nil {
+		return errors.Fatal(err.Error())
+	}
+	if !fi.IsDir() {
+		return errors.Fatalf("%v is not a directory", opts.Path)
+	}
+
+	rejectByNameFuncs, err := opts.excludePatternOptions.CollectPatterns()
+	if err != nil {
+		return err
+	}
+
+	pathMap, err := parsePathMap(opts.PathMap)
+	if err != nil {
+		return err
+	}
+
+	repo, err := OpenRepository(ctx, gopts)
+	if err != nil {
+		return err
+	}
+
+	if !gopts.NoLock {
+		var lock *restic.Lock
+		lock, ctx, err = lockRepo(ctx, repo, gopts.RetryLock, gopts.JSON)
+		defer unlockRepo(lock)
+		if err != nil {
+			return err
+		}
+	}
+
+	sn, subfolder, err := loadSnapshot(ctx, repo, repo, &opts.SnapshotFilter, args[0])
+	if err != nil {
+		return err
+	}
+	if subfolder == "" {
+		subfolder = snapshotPath(target)
+	}
+
+	if !gopts.JSON {
+		Verbosef("comparing snapshot %v to %v:\n\n", sn.ID().Str(), target)
+	}
+	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
+	if err = repo.LoadIndex(ctx, bar); err != nil {
+		return err
+	}
+
+	if sn.Tree == nil {
+		return errors.Errorf("snapshot %v has nil tree", sn.ID().Str())
+	}
+
+	sn.Tree, err = restic.FindTreeDirectory(ctx, repo, sn.Tree, subfolder)
+	if err != nil {
+		return err
+	}
+
+	c := newComparer(repo, opts, gopts, pathMap)
+	c.rejectByName = rejectByNameFuncs
+	if opts.IgnoreInode {
+		// like for backup, --ignore-inode implies --ignore-ctime
+		c.ignoreFlags |= archiver.ChangeIgnoreCtime | archiver.ChangeIgnoreInode
+	}
+	if opts.IgnoreCtime {
+		c.ignoreFlags |= archiver.ChangeIgnoreCtime
+	}
+
+	stats := &DiffStatsContainer{
+		MessageType:    "statistics",
+		SourceSnapshot: args[0],
+		TargetPath:     target,
+	}
+
+	err = c.diffLive(ctx, stats, "/", *sn.Tree, target)
+	if err != nil {
+		return err
+	}
+
+	if gopts.JSON {
+		err := json.NewEncoder(globalOptions.stdout).Encode(stats)
+		if err != nil {
+			Warnf("JSON encode failed: %v\n", err)
+		}
+	} else {
+		Printf("\n")
+		Printf("Files:       %5d new, %5d removed, %5d changed\n", stats.Added.Files, stats.Removed.Files, stats.ChangedFiles)
+		Printf("Dirs:        %5d new, %5d removed\n", stats.Added.Dirs, stats.Removed.Dirs)
+		Printf("Others:      %5d new, %5d removed\n", stats.Added.Others, stats.Removed.Others)
+	}
+
+	return nil
+}
@@ -6,11 +6,13 @@ import (
 	"encoding/json"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
 
+	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 )
 
@@ -159,6 +161,7 @@ func TestDiffJSON(t *testing.T) {
 
 	var stat DiffStatsContainer
 	var changes int
+	changeTypes := make(map[string]Change)
 
 	scanner := bufio.NewScanner(strings.NewReader(out))
 	for scanner.Scan() {
@@ -168,6 +171,9 @@ func TestDiffJSON(t *testing.T) {
 		switch sniffer.MessageType {
 		case "change":
 			changes++
+			var change Change
+			rtest.OK(t, json.Unmarshal([]byte(line), &change))
+			changeTypes[path.Base(change.Path)] = change
 		case "statistics":
 			rtest.OK(t, json.Unmarshal([]byte(line), &stat))
 		default:
@@ -175,6 +181,16 @@ func TestDiffJSON(t *testing.T) {
 		}
 	}
 	rtest.Equals(t, 9, changes)
+	modified := changeTypes["modfile1"]
+	rtest.Equals(t, "modified", modified.ChangeType)
+	rtest.Equals(t, uint64(256*1024), *modified.OldSize)
+	rtest.Equals(t, uint64(512*1024), *modified.NewSize)
+	rtest.Assert(t, modified.OldMode != nil && modified.NewMode != nil, "missing file modes")
+	rtest.Equals(t, "removed", changeTypes["modfile"].ChangeType)
+	rtest.Assert(t, changeTypes["modfile"].NewSize == nil && changeTypes["modfile"].NewMode == nil,
+		"unexpected size or mode for removed file")
+	rtest.Equals(t, "added", changeTypes["modfile2"].ChangeType)
+	rtest.Equals(t, uint64(256*1024), *changeTypes["modfile2"].NewSize)
 	rtest.Assert(t, stat.Added.Files == 2 && stat.Added.Dirs == 3 && stat.Added.DataBlobs == 2 &&
 		stat.Removed.Files == 1 && stat.Removed.Dirs == 2 && stat.Removed.DataBlobs == 1 &&
 		stat.ChangedFiles == 1, "unexpected statistics")
@@ -191,3 +207,81 @@ func TestDiffJSON(t *testing.T) {
 		stat.ChangedFiles == 1, "unexpected statistics")
 	rtest.Assert(t, stat.SourceSnapshot == firstSnapshotID && stat.TargetSnapshot == secondSnapshotID, "unexpected snapshot ids")
 }
+
+func testRunDiffLiveOutput(gopts GlobalOptions, opts DiffOptions, snapshotID string) (string, error) {
+	buf, err := withCaptureStdout(func() error {
+		return runDiff(context.TODO(), opts, gopts, []string{snapshotID})
+	})
+	return buf.String(), err
+}
+
+func TestDiffLive(t *testing.T) {
+	env, cleanup, _, secondSnapshotID := setupDiffRepo(t)
+	defer cleanup()
+
+	env.gopts.Quiet = false
+	datadir := filepath.Join(env.base, "testdata")
+	opts := DiffOptions{Path: datadir}
+
+	out, err := testRunDiffLiveOutput(env.gopts, opts, secondSnapshotID)
+	rtest.OK(t, err)
+	rtest.Assert(t, regexp.MustCompile("Files: +0 new, +0 removed, +0 changed").MatchString(out),
+		"expected no changes, got\n%v", out)
+
+	modfile := filepath.Join(datadir, "moddir", "modfile")
+	rtest.OK(t, appendRandomData(modfile+"1", 1024))
+	rtest.OK(t, appendRandomData(modfile+"5", 1024))
+	rtest.OK(t, appendRandomData(modfile+"5.tmp", 1024))
+	rtest.OK(t, os.Remove(filepath.Join(datadir, "testdir", "testfile")))
+
+	opts.Excludes = []string{"*.tmp"}
+	out, err = testRunDiffLiveOutput(env.gopts, opts, secondSnapshotID)
+	rtest.OK(t, err)
+
+	for _, pattern := range []string{
+		"M.+moddir/modfile1\n",
+		"\\+.+moddir/modfile5\n",
+		"-.+testdir/testfile\n",
+		"Files: +1 new, +1 removed, +1 changed",
+	} {
+		r := regexp.MustCompile(pattern)
+		rtest.Assert(t, r.MatchString(out), "expected pattern %v in output, got\n%v", pattern, out)
+	}
+	rtest.Assert(t, !strings.Contains(out, "modfile5.tmp"), "excluded file in output:\n%v", out)
+
+	_, err = testRunDiffLiveOutput(env.gopts, DiffOptions{Path: modfile + "1"}, secondSnapshotID)
+	rtest.Assert(t, err != nil, "expected error for a path that is not a directory")
+}
+
+func TestDiffLatestFilter(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	datadir := filepath.Join(env.base, "testdata")
+	rtest.OK(t, os.MkdirAll(datadir, 0755))
+	rtest.OK(t, appendRandomData(filepath.Join(datadir, "file1"), 1024))
+	testRunBackup(t, "", []string{datadir}, BackupOptions{Host: "web-01"}, env.gopts)
+
+	rtest.OK(t, appendRandomData(filepath.Join(datadir, "file2"), 1024))
+	testRunBackup(t, "", []string{datadir}, BackupOptions{Host: "db-01"}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 2)
+
+	_, snapmap := testRunSnapshotsFiltered(t, env.gopts, restic.SnapshotFilter{Hosts: []string{"web-*"}})
+	rtest.Equals(t, 1, len(snapmap))
+
+	// the latest snapshot of all hosts
+	var otherID string
+	for _, id := range snapshotIDs {
+		if _, ok := snapmap[id]; !ok {
+			otherID = id.String()
+		}
+	}
+	out, err := withCaptureStdout(func() error {
+		opts := DiffOptions{SnapshotFilter: restic.SnapshotFilter{Hosts: []string{"web-*"}}}
+		return runDiff(context.TODO(), opts, env.gopts, []string{"latest", otherID})
+	})
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(out.String(), "Files:           1 new,     0 removed"), "file2 not reported as added:\n%s", out.String())
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestChangeType(t *testing.T) {
+	for mode, want := range map[string]string{
+		"+":  "added",
+		"-":  "removed",
+		"T":  "type-changed",
+		"M":  "modified",
+		"U":  "modified",
+		"?":  "modified",
+		"MU": "modified",
+		"TU": "type-changed",
+	} {
+		rtest.Equals(t, want, changeType(mode))
+	}
+}
+
+func TestNewChangeZeroValues(t *testing.T) {
+	// a file truncated to zero bytes still reports its sizes and modes
+	before := &restic.Node{Type: "file", Size: 42, Mode: 0644}
+	after := &restic.Node{Type: "file", Size: 0, Mode: 0}
+	buf, err := json.Marshal(NewChange("/file", "M", before, after))
+	rtest.OK(t, err)
+	rtest.Equals(t, `{"message_type":"change","path":"/file","modifier":"M","change_type":"modified",`+
+		`"old_size":42,"new_size":0,"old_mode":420,"new_mode":0}`, string(buf))
+
+	// directories have no size
+	buf, err = json.Marshal(NewChange("/dir", "+", nil, &restic.Node{Type: "dir", Mode: 0755}))
+	rtest.OK(t, err)
+	rtest.Equals(t, `{"message_type":"change","path":"/dir","modifier":"+","change_type":"added","new_mode":493}`, string(buf))
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"path"
 	"sort"
 	"strings"
 	"time"
@@ -25,6 +26,7 @@ repo.
 It can also be used to search for restic blobs or trees for troubleshooting.`,
 	Example: `restic find config.json
 restic find --json "*.yml" "*.json"
+restic find --snapshot latest --subfolder /home/user "*.pdf"
 restic find --json --blob 420f620f b46ebe8a ddd38656
 restic find --show-pack-id --blob 420f620f
 restic find --tree 577c2bc9 f81f2e22 a62827a9
@@ -45,7 +47,10 @@ Exit status is 0 if the command was successful, and non-zero if there was any er
 type FindOptions struct {
 	Oldest             string
 	Newest             string
+	NewerThan          string
+	OlderThan          string
 	Snapshots          []string
+	Subfolder          string
 	BlobID, TreeID     bool
 	PackID, ShowPackID bool
 	CaseInsensitive    bool
@@ -62,7 +67,10 @@ func init() {
 	f := cmdFind.Flags()
 	f.StringVarP(&findOptions.Oldest, "oldest", "O", "", "oldest modification date/time")
 	f.StringVarP(&findOptions.Newest, "newest", "N", "", "newest modification date/time")
+	f.StringVar(&findOptions.NewerThan, "newer-than", "", "only match entries modified after `time`, a date/time or a duration (eg. 1d2h) before now")
+	f.StringVar(&findOptions.OlderThan, "older-than", "", "only match entries modified before `time`, a date/time or a duration (eg. 1d2h) before now")
 	f.StringArrayVarP(&findOptions.Snapshots, "snapshot", "s", nil, "snapshot `id` to search in (can be given multiple times)")
+	f.StringVar(&findOptions.Subfolder, "subfolder", "", "only search below the directory `path` within the snapshots")
 	f.BoolVar(&findOptions.BlobID, "blob", false, "pattern is a blob-ID")
 	f.BoolVar(&findOptions.TreeID, "tree", false, "pattern is a tree-ID")
 	f.BoolVar(&findOptions.PackID, "pack", false, "pattern is a pack-ID")
@@ -104,6 +112,41 @@ func parseTime(str string) (time.Time, error) {
 	return time.Time{}, errors.Fatalf("unable to parse time: %q", str)
 }
 
+// parseTimeOrDuration parses str either as a date/time or as a duration like
+// "1d2h", which is then subtracted from now.
+func parseTimeOrDuration(str string, now time.Time) (time.Time, error) {
+	if t, err := parseTime(str); err == nil {
+		return t, nil
+	}
+
+	d, err := restic.ParseDuration(str)
+	if err != nil {
+		return time.Time{}, errors.Fatalf("unable to parse time or duration: %q", str)
+	}
+
+	return now.AddDate(-d.Years, -d.Months, -d.Days).Add(time.Hour * time.Duration(-d.Hours)), nil
+}
+
+// parseModTimeRange returns the range of modification times selected by the
+// --newer-than and --older-than options. The zero time means no limit.
+func parseModTimeRange(newerThan, olderThan string) (oldest, newest time.Time, err error) {
+	now := time.Now()
+
+	if newerThan != "" {
+		if oldest, err = parseTimeOrDuration(newerThan, now); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	if olderThan != "" {
+		if newest, err = parseTimeOrDuration(olderThan, now); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	return oldest, newest, nil
+}
+
 type statefulOutput struct {
 	ListLong      bool
 	HumanReadable bool
@@ -248,9 +291,10 @@ type Finder struct {
 	pat         findPattern
 	out         statefulOutput
 	ignoreTrees restic.IDSet
+	subfolder   string
 	blobIDs     map[string]struct{}
 	treeIDs     map[string]struct{}
-	itemsFound  int
+	treeMatches map[restic.ID][]idMatch
 }
 
 func (f *Finder) findInSnapshot(ctx context.Context, sn *restic.Snapshot) error {
@@ -260,8 +304,23 @@ func (f *Finder) findInSnapshot(ctx context.Context, sn *restic.Snapshot) error
 		return errors.Errorf("snapshot %v has no tree", sn.ID().Str())
 	}
 
+	treeID := sn.Tree
+	if f.subfolder != "" {
+		// only load the trees below the subfolder
+		var err error
+		treeID, err = restic.FindTreeDirectory(ctx, f.repo, sn.Tree, f.subfolder)
+		if err != nil {
+			debug.Log("snapshot %v: subfolder %v: %v", sn.ID().Str(), f.subfolder, err)
+			if !f.out.JSON {
+				Verboseff("skipping snapshot %s: %v\n", sn.ID().Str(), err)
+			}
+			return nil
+		}
+	}
+
 	f.out.newsn = sn
-	return walker.Walk(ctx, f.repo, *sn.Tree, f.ignoreTrees, func(parentTreeID restic.ID, nodepath string, node *restic.Node, err error) (bool, error) {
+	return walker.Walk(ctx, f.repo, *treeID, f.ignoreTrees, func(parentTreeID restic.ID, nodepath string, node *restic.Node, err error) (bool, error) {
+		nodepath = path.Join(f.subfolder, nodepath)
 		if err != nil {
 			debug.Log("Error loading tree %v: %v", parentTreeID, err)
 
@@ -337,6 +396,15 @@ func (f *Finder) findInSnapshot(ctx context.Context, sn *restic.Snapshot) error
 	})
 }
 
+// idMatch is a blob or tree found by findIDs. The path is relative to the
+// tree the match was found in.
+type idMatch struct {
+	objectType string
+	id         string
+	path       string
+	parentTree string
+}
+
 func (f *Finder) findIDs(ctx context.Context, sn *restic.Snapshot) error {
 	debug.Log("searching IDs in snapshot %s", sn.ID())
 
@@ -345,36 +413,51 @@ func (f *Finder) findIDs(ctx context.Context, sn *restic.Snapshot) error {
 	}
 
 	f.out.newsn = sn
-	return walker.Walk(ctx, f.repo, *sn.Tree, f.ignoreTrees, func(parentTreeID restic.ID, nodepath string, node *restic.Node, err error) (bool, error) {
-		if err != nil {
-			debug.Log("Error loading tree %v: %v", parentTreeID, err)
+	matches, err := f.findIDsInTree(ctx, sn, *sn.Tree)
+	if err != nil {
+		return err
+	}
 
-			Printf("Unable to load tree %s\n ... which belongs to snapshot %s\n", parentTreeID, sn.ID())
+	for _, m := range matches {
+		f.out.PrintObject(m.objectType, m.id, path.Join("/", m.path), m.parentTree, sn)
+	}
+	return nil
+}
 
-			return false, walker.ErrSkipNode
-		}
+// findIDsInTree returns all matches in the tree and its subtrees. The result
+// is cached for each tree, such that trees shared by several snapshots are
+// only loaded once.
+func (f *Finder) findIDsInTree(ctx context.Context, sn *restic.Snapshot, treeID restic.ID) ([]idMatch, error) {
+	if matches, ok := f.treeMatches[treeID]; ok {
+		return matches, nil
+	}
 
-		if node == nil {
-			return false, nil
-		}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	tree, err := restic.LoadTree(ctx, f.repo, treeID)
+	if err != nil {
+		debug.Log("Error loading tree %v: %v", treeID, err)
+		Printf("Unable to load tree %s\n ... which belongs to snapshot %s\n", treeID, sn.ID())
+		// do not cache the result, the tree is reported for each snapshot
+		return nil, nil
+	}
 
-		if node.Type == "dir" && f.treeIDs != nil {
-			treeID := node.Subtree
-			found := false
-			if _, ok := f.treeIDs[treeID.Str()]; ok {
-				found = true
-			} else if _, ok := f.treeIDs[treeID.String()]; ok {
-				found = true
+	var matches []idMatch
+	for _, node := range tree.Nodes {
+		if node.Type == "dir" && node.Subtree != nil {
+			if f.treeIDs != nil && f.hasTreeID(*node.Subtree) {
+				matches = append(matches, idMatch{"tree", node.Subtree.String(), node.Name, ""})
 			}
-			if found {
-				f.out.PrintObject("tree", treeID.String(), nodepath, "", sn)
-				f.itemsFound++
-				// Terminate if we have found all trees (and we are not
-				// looking for blobs)
-				if f.itemsFound >= len(f.treeIDs) && f.blobIDs == nil {
-					// Return an error to terminate the Walk
-					return true, errors.New("OK")
-				}
+
+			submatches, err := f.findIDsInTree(ctx, sn, *node.Subtree)
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range submatches {
+				m.path = path.Join(node.Name, m.path)
+				matches = append(matches, m)
 			}
 		}
 
@@ -390,12 +473,23 @@ func (f *Finder) findIDs(ctx context.Context, sn *restic.Snapshot) error {
 					f.blobIDs[idStr] = struct{}{}
 					delete(f.blobIDs, id.Str())
 				}
-				f.out.PrintObject("blob", idStr, nodepath, parentTreeID.String(), sn)
+				matches = append(matches, idMatch{"blob", idStr, node.Name, treeID.String()})
 			}
 		}
+	}
 
-		return false, nil
-	})
+	f.treeMatches[treeID] = matches
+	return matches, nil
+}
+
+// hasTreeID returns true if the tree ID is searched for, either in long or
+// in short form.
+func (f *Finder) hasTreeID(id restic.ID) bool {
+	if _, ok := f.treeIDs[id.Str()]; ok {
+		return true
+	}
+	_, ok := f.treeIDs[id.String()]
+	return ok
 }
 
 var errAllPacksFound = errors.New("all packs found")
@@ -561,6 +655,25 @@ func runFind(ctx context.Context, opts FindOptions, gopts GlobalOptions, args []
 		}
 	}
 
+	if opts.NewerThan != "" && opts.Oldest != "" {
+		return errors.Fatal("--newer-than and --oldest cannot be used together")
+	}
+	if opts.OlderThan != "" && opts.Newest != "" {
+		return errors.Fatal("--older-than and --newest cannot be used together")
+	}
+	if opts.NewerThan != "" || opts.OlderThan != "" {
+		oldest, newest, err := parseModTimeRange(opts.NewerThan, opts.OlderThan)
+		if err != nil {
+			return err
+		}
+		if !oldest.IsZero() {
+			pat.oldest = oldest
+		}
+		if !newest.IsZero() {
+			pat.newest = newest
+		}
+	}
+
 	// Check at most only one kind of IDs is provided: currently we
 	// can't mix types
 	if (opts.BlobID && opts.TreeID) ||
@@ -569,6 +682,14 @@ func runFind(ctx context.Context, opts FindOptions, gopts GlobalOptions, args []
 		return errors.Fatal("cannot have several ID types")
 	}
 
+	subfolder := ""
+	if opts.Subfolder != "" {
+		if opts.BlobID || opts.TreeID || opts.PackID {
+			return errors.Fatal("--subfolder cannot be used with --blob, --tree or --pack")
+		}
+		subfolder = path.Clean("/" + opts.Subfolder)
+	}
+
 	repo, err := OpenRepository(ctx, gopts)
 	if err != nil {
 		return err
@@ -597,6 +718,8 @@ func runFind(ctx context.Context, opts FindOptions, gopts GlobalOptions, args []
 		pat:         pat,
 		out:         statefulOutput{ListLong: opts.ListLong, HumanReadable: opts.HumanReadable, JSON: gopts.JSON},
 		ignoreTrees: restic.NewIDSet(),
+		subfolder:   subfolder,
+		treeMatches: make(map[restic.ID][]idMatch),
 	}
 
 	if opts.BlobID {
@@ -630,7 +753,7 @@ func runFind(ctx context.Context, opts FindOptions, gopts GlobalOptions, args []
 
 	for _, sn := range filteredSnapshots {
 		if f.blobIDs != nil || f.treeIDs != nil {
-			if err = f.findIDs(ctx, sn); err != nil && err.Error() != "OK" {
+			if err = f.findIDs(ctx, sn); err != nil {
 				return err
 			}
 			continue
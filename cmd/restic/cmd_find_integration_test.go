@@ -3,10 +3,13 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 )
 
@@ -85,3 +88,84 @@ func TestFindJSON(t *testing.T) {
 	rtest.Assert(t, len(matches[0].Matches) == 3, "expected 3 files to match (%v)", datafile)
 	rtest.Assert(t, matches[0].Hits == 3, "expected hits to show 3 matches (%v)", datafile)
 }
+
+func TestFindBlobInAllSnapshots(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	data := []byte("content which is searched for by find --blob")
+	rtest.OK(t, os.WriteFile(filepath.Join(env.testdata, "0", "blobfile"), data, 0644))
+
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	// the second snapshot shares all subtrees except for the root
+	rtest.OK(t, os.WriteFile(filepath.Join(env.testdata, "other"), []byte("other"), 0644))
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	snapshotIDs := restic.NewIDSet(testListSnapshots(t, env.gopts, 2)...)
+
+	blobID := restic.Hash(data)
+	for _, id := range []string{blobID.String(), blobID.Str()} {
+		buf, err := withCaptureStdout(func() error {
+			gopts := env.gopts
+			gopts.JSON = true
+			return runFind(context.TODO(), FindOptions{BlobID: true}, gopts, []string{id})
+		})
+		rtest.OK(t, err)
+
+		var matches []struct {
+			ObjectType string `json:"object_type"`
+			Path       string `json:"path"`
+			SnapshotID string `json:"snapshot"`
+		}
+		rtest.OK(t, json.Unmarshal(buf.Bytes(), &matches))
+		rtest.Equals(t, 2, len(matches))
+
+		found := restic.NewIDSet()
+		for _, m := range matches {
+			rtest.Equals(t, "blob", m.ObjectType)
+			rtest.Assert(t, strings.HasSuffix(m.Path, "/0/blobfile"), "unexpected path %v", m.Path)
+			snID, err := restic.ParseID(m.SnapshotID)
+			rtest.OK(t, err)
+			found.Insert(snID)
+		}
+		rtest.Equals(t, snapshotIDs, found)
+	}
+}
+
+func TestFindSubfolder(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	testRunInit(t, env.gopts)
+
+	for _, dir := range []string{"a", "b"} {
+		rtest.OK(t, os.MkdirAll(filepath.Join(env.testdata, dir, "sub"), 0755))
+		rtest.OK(t, os.WriteFile(filepath.Join(env.testdata, dir, "sub", "file.txt"), []byte(dir), 0644))
+	}
+	testRunBackup(t, env.testdata, []string{"."}, BackupOptions{}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+
+	find := func(subfolder string) []testMatches {
+		buf, err := withCaptureStdout(func() error {
+			gopts := env.gopts
+			gopts.JSON = true
+			opts := FindOptions{Snapshots: []string{snapshotIDs[0].String()}, Subfolder: subfolder}
+			return runFind(context.TODO(), opts, gopts, []string{"*.txt"})
+		})
+		rtest.OK(t, err)
+		matches := []testMatches{}
+		rtest.OK(t, json.Unmarshal(buf.Bytes(), &matches))
+		return matches
+	}
+
+	matches := find("")
+	rtest.Equals(t, 1, len(matches))
+	rtest.Equals(t, 2, matches[0].Hits)
+
+	matches = find("a/")
+	rtest.Equals(t, 1, len(matches))
+	rtest.Equals(t, 1, matches[0].Hits)
+	rtest.Equals(t, "/a/sub/file.txt", matches[0].Matches[0].Path)
+
+	// a missing subfolder skips the snapshot
+	rtest.Equals(t, 0, len(find("/missing")))
+}
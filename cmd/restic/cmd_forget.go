@@ -1,14 +1,23 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var cmdForget = &cobra.Command{
@@ -19,6 +28,10 @@ The "forget" command removes snapshots according to a policy. All snapshots are
 first divided into groups according to "--group-by", and after that the policy
 specified by the "--keep-*" options is applied to each group individually.
 
+If the policy would remove snapshots of several hosts and no host was selected
+using "--host", the command asks for a confirmation. If stdin is not a
+terminal, "--all-hosts" must be passed instead.
+
 Please note that this command really only deletes the snapshot object in the
 repository, which is a reference to data stored there. In order to remove the
 unreferenced data after "forget" was run successfully, see the "prune" command.
@@ -33,10 +46,51 @@ Exit status is 0 if the command was successful, and non-zero if there was any er
 `,
 	DisableAutoGenTag: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyForgetEnvDefaults(cmd.Flags()); err != nil {
+			return err
+		}
 		return runForget(cmd.Context(), forgetOptions, globalOptions, args)
 	},
 }
 
+// forgetEnvDefaults lists the environment variables which provide default
+// values for the policy flags.
+var forgetEnvDefaults = []struct {
+	flag, env string
+}{
+	{"keep-last", "RESTIC_KEEP_LAST"},
+	{"keep-hourly", "RESTIC_KEEP_HOURLY"},
+	{"keep-daily", "RESTIC_KEEP_DAILY"},
+	{"keep-weekly", "RESTIC_KEEP_WEEKLY"},
+	{"keep-monthly", "RESTIC_KEEP_MONTHLY"},
+	{"keep-yearly", "RESTIC_KEEP_YEARLY"},
+	{"keep-within", "RESTIC_KEEP_WITHIN"},
+	{"keep-within-hourly", "RESTIC_KEEP_WITHIN_HOURLY"},
+	{"keep-within-daily", "RESTIC_KEEP_WITHIN_DAILY"},
+	{"keep-within-weekly", "RESTIC_KEEP_WITHIN_WEEKLY"},
+	{"keep-within-monthly", "RESTIC_KEEP_WITHIN_MONTHLY"},
+	{"keep-within-yearly", "RESTIC_KEEP_WITHIN_YEARLY"},
+	{"keep-last-per-tag", "RESTIC_KEEP_LAST_PER_TAG"},
+	{"keep-min-interval", "RESTIC_KEEP_MIN_INTERVAL"},
+}
+
+// applyForgetEnvDefaults sets all policy flags which were not specified on the
+// command line from the corresponding environment variables. Invalid values
+// are an error, so that a typo cannot cause more snapshots to be removed.
+func applyForgetEnvDefaults(flags *pflag.FlagSet) error {
+	for _, d := range forgetEnvDefaults {
+		value := os.Getenv(d.env)
+		if value == "" || flags.Lookup(d.flag) == nil || flags.Changed(d.flag) {
+			continue
+		}
+
+		if err := flags.Set(d.flag, value); err != nil {
+			return errors.Fatalf("invalid value %q in $%s: %v", value, d.env, err)
+		}
+	}
+	return nil
+}
+
 type ForgetPolicyCount int
 
 var ErrNegativePolicyCount = errors.New("negative values not allowed, use 'unlimited' instead")
@@ -86,15 +140,36 @@ type ForgetOptions struct {
 	WithinWeekly  restic.Duration
 	WithinMonthly restic.Duration
 	WithinYearly  restic.Duration
+	Keep          restic.KeepTiers
 	KeepTags      restic.TagLists
+	LastPerTag    ForgetPolicyCount
+	RemoveTags    restic.TagLists
+	Force         bool
+	MinInterval   restic.Duration
+
+	CollapseIdentical bool
 
 	restic.SnapshotFilter
 	Compact bool
 
 	// Grouping
-	GroupBy restic.SnapshotGroupByOptions
-	DryRun  bool
-	Prune   bool
+	GroupBy     restic.SnapshotGroupByOptions
+	DryRun      bool
+	Simulate    bool
+	ShowReasons bool
+	Prune       bool
+	PruneHint   bool
+
+	RemovedIDsFile string
+
+	// AllHosts confirms that snapshots of at least AllHostsThreshold hosts
+	// may be removed. Otherwise, the user is asked for confirmation unless
+	// NoConfirmPrompt is set or stdin is not a terminal.
+	AllHosts          bool
+	AllHostsThreshold int
+	NoConfirmPrompt   bool
+
+	webhookOptions
 }
 
 var forgetOptions ForgetOptions
@@ -115,7 +190,13 @@ func init() {
 	f.VarP(&forgetOptions.WithinWeekly, "keep-within-weekly", "", "keep weekly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
 	f.VarP(&forgetOptions.WithinMonthly, "keep-within-monthly", "", "keep monthly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
 	f.VarP(&forgetOptions.WithinYearly, "keep-within-yearly", "", "keep yearly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
+	f.Var(&forgetOptions.Keep, "keep", "keep snapshots using tiered `policy` (eg. 7d8w12m for daily snapshots within 7 days, weekly within 8 weeks and monthly within 12 months)")
 	f.Var(&forgetOptions.KeepTags, "keep-tag", "keep snapshots with this `taglist` (can be specified multiple times)")
+	f.Var(&forgetOptions.LastPerTag, "keep-last-per-tag", "keep the last `n` snapshots for each tag (use 'unlimited' to keep all tagged snapshots)")
+	f.Var(&forgetOptions.RemoveTags, "remove-tag", "remove snapshots with this `taglist` unless kept by --keep-tag or --keep-last (can be specified multiple times)")
+	f.BoolVar(&forgetOptions.Force, "force", false, "also remove snapshots matching --remove-tag that are kept by --keep-last")
+	f.Var(&forgetOptions.MinInterval, "keep-min-interval", "remove kept snapshots which are less than `duration` (eg. 1d2h) newer than the previous kept one, except those kept by --keep-last or --keep-tag")
+	f.BoolVar(&forgetOptions.CollapseIdentical, "collapse-identical", false, "remove snapshots whose tree is identical to a newer snapshot in the same group and tag the newer one with '"+collapsedSnapshotTag+"'")
 
 	initMultiSnapshotFilter(f, &forgetOptions.SnapshotFilter, false)
 	f.StringArrayVar(&forgetOptions.Hosts, "hostname", nil, "only consider snapshots with the given `hostname` (can be specified multiple times)")
@@ -129,7 +210,15 @@ func init() {
 	forgetOptions.GroupBy = restic.SnapshotGroupByOptions{Host: true, Path: true}
 	f.VarP(&forgetOptions.GroupBy, "group-by", "g", "`group` snapshots by host, paths and/or tags, separated by comma (disable grouping with '')")
 	f.BoolVarP(&forgetOptions.DryRun, "dry-run", "n", false, "do not delete anything, just print what would be done")
+	f.BoolVar(&forgetOptions.Simulate, "simulate", false, "only print which snapshots the policy would keep and remove, without locking or modifying the repository")
+	f.BoolVar(&forgetOptions.ShowReasons, "show-reasons", false, "show why snapshots are kept, also in the compact output format")
 	f.BoolVar(&forgetOptions.Prune, "prune", false, "automatically run the 'prune' command if snapshots have been removed")
+	f.BoolVar(&forgetOptions.PruneHint, "prune-hint", false, "estimate the unused data after removing the snapshots and recommend whether to run 'prune' based on --max-unused")
+	f.BoolVar(&forgetOptions.AllHosts, "all-hosts", false, "confirm that the policy may remove snapshots of several hosts")
+	f.IntVar(&forgetOptions.AllHostsThreshold, "all-hosts-threshold", 2, "require --all-hosts or a confirmation if snapshots of at least `n` hosts would be removed (0 to disable)")
+	f.BoolVar(&forgetOptions.NoConfirmPrompt, "no-confirm-prompt", false, "do not ask for confirmation on a terminal, fail unless --all-hosts is given")
+	f.StringVar(&forgetOptions.RemovedIDsFile, "removed-ids-file", "", "append the IDs of removed and kept snapshots to `file` (JSON lines if the name ends in .jsonl)")
+	initWebhookOptions(f, &forgetOptions.webhookOptions)
 
 	f.SortFlags = false
 	addPruneOptions(cmdForget)
@@ -137,7 +226,7 @@ func init() {
 
 func verifyForgetOptions(opts *ForgetOptions) error {
 	if opts.Last < -1 || opts.Hourly < -1 || opts.Daily < -1 || opts.Weekly < -1 ||
-		opts.Monthly < -1 || opts.Yearly < -1 {
+		opts.Monthly < -1 || opts.Yearly < -1 || opts.LastPerTag < -1 {
 		return errors.Fatal("negative values other than -1 are not allowed for --keep-*")
 	}
 
@@ -147,16 +236,42 @@ func verifyForgetOptions(opts *ForgetOptions) error {
 			return errors.Fatal("durations containing negative values are not allowed for --keep-within*")
 		}
 	}
+	if d := opts.MinInterval; d.Hours < 0 || d.Days < 0 || d.Months < 0 || d.Years < 0 {
+		return errors.Fatal("durations containing negative values are not allowed for --keep-min-interval")
+	}
+
+	if opts.Force && len(opts.RemoveTags) == 0 {
+		return errors.Fatal("--force can only be used in combination with --remove-tag")
+	}
+
+	if opts.Simulate && opts.Prune {
+		return errors.Fatal("--simulate cannot be used together with --prune")
+	}
+
+	if opts.PruneHint && opts.Prune {
+		return errors.Fatal("--prune-hint cannot be used together with --prune")
+	}
+
+	if opts.AllHostsThreshold < 0 {
+		return errors.Fatal("--all-hosts-threshold must not be negative")
+	}
 
 	return nil
 }
 
-func runForget(ctx context.Context, opts ForgetOptions, gopts GlobalOptions, args []string) error {
-	err := verifyForgetOptions(&opts)
+func runForget(ctx context.Context, opts ForgetOptions, gopts GlobalOptions, args []string) (err error) {
+	err = verifyForgetOptions(&opts)
 	if err != nil {
 		return err
 	}
 
+	if opts.CollapseIdentical && len(args) > 0 {
+		return errors.Fatal("--collapse-identical cannot be used together with snapshot IDs")
+	}
+
+	report := newWebhookReport(opts.webhookOptions, gopts.TransportOptions, "forget")
+	defer func() { report.send(err) }()
+
 	err = verifyPruneOptions(&pruneOptions)
 	if err != nil {
 		return err
@@ -166,12 +281,15 @@ func runForget(ctx context.Context, opts ForgetOptions, gopts GlobalOptions, arg
 	if err != nil {
 		return err
 	}
+	report.setRepository(repo)
 
-	if gopts.NoLock && !opts.DryRun {
+	if gopts.NoLock && !opts.DryRun && !opts.Simulate {
 		return errors.Fatal("--no-lock is only applicable in combination with --dry-run for forget command")
 	}
 
-	if !opts.DryRun || !gopts.NoLock {
+	// a simulation only reads snapshots and must not write anything, not
+	// even a lock file
+	if !opts.Simulate && (!opts.DryRun || !gopts.NoLock) {
 		var lock *restic.Lock
 		lock, ctx, err = lockRepoExclusive(ctx, repo, gopts.RetryLock, gopts.JSON)
 		defer unlockRepo(lock)
@@ -180,14 +298,23 @@ func runForget(ctx context.Context, opts ForgetOptions, gopts GlobalOptions, arg
 		}
 	}
 
+	snapshotLister, err := restic.MemorizeList(ctx, repo, restic.SnapshotFile)
+	if err != nil {
+		return err
+	}
+
 	var snapshots restic.Snapshots
 	removeSnIDs := restic.NewIDSet()
+	keepSnIDs := restic.NewIDSet()
 
-	for sn := range FindFilteredSnapshots(ctx, repo, repo, &opts.SnapshotFilter, args) {
+	for sn := range FindFilteredSnapshots(ctx, snapshotLister, repo, &opts.SnapshotFilter, args) {
 		snapshots = append(snapshots, sn)
 	}
 
 	var jsonGroups []*ForgetGroup
+	keepCount := 0
+	// snapshots which replace identical older snapshots
+	var collapsedInto restic.Snapshots
 
 	if len(args) > 0 {
 		// When explicit snapshots args are given, remove them immediately.
@@ -214,20 +341,34 @@ func runForget(ctx context.Context, opts ForgetOptions, gopts GlobalOptions, arg
 			WithinMonthly: opts.WithinMonthly,
 			WithinYearly:  opts.WithinYearly,
 			Tags:          opts.KeepTags,
+			LastPerTag:    int(opts.LastPerTag),
+			RemoveTags:    opts.RemoveTags,
+			ForceRemove:   opts.Force,
+			MinInterval:   opts.MinInterval,
+		}
+		if err := opts.Keep.Apply(&policy); err != nil {
+			return errors.Fatalf("invalid value for --keep: %v", err)
 		}
 
-		if policy.Empty() && len(args) == 0 {
+		if policy.Empty() && !opts.CollapseIdentical {
 			if !gopts.JSON {
 				Verbosef("no policy was specified, no snapshots will be removed\n")
 			}
 		}
 
-		if !policy.Empty() {
+		if !policy.Empty() || opts.CollapseIdentical {
 			if !gopts.JSON {
-				Verbosef("Applying Policy: %v\n", policy)
+				if !policy.Empty() {
+					Verbosef("Applying Policy: %v\n", policy)
+				}
+				if opts.CollapseIdentical {
+					Verbosef("Removing snapshots identical to newer snapshots\n")
+				}
 			}
 
-			for k, snapshotGroup := range snapshotGroups {
+			idLen := shortIDLength(snapshots)
+			for _, k := range restic.SortedGroupKeys(snapshotGroups) {
+				snapshotGroup := snapshotGroups[k]
 				if gopts.Verbose >= 1 && !gopts.JSON {
 					err = PrintSnapshotGroupHeader(globalOptions.stdout, k)
 					if err != nil {
@@ -245,18 +386,34 @@ func runForget(ctx context.Context, opts ForgetOptions, gopts GlobalOptions, arg
 				fg.Host = key.Hostname
 				fg.Paths = key.Paths
 
-				keep, remove, reasons := restic.ApplyPolicy(snapshotGroup, policy)
+				var collapsed restic.Snapshots
+				if opts.CollapseIdentical {
+					var into restic.Snapshots
+					snapshotGroup, collapsed, into = collapseIdenticalSnapshots(snapshotGroup)
+					collapsedInto = append(collapsedInto, into...)
+				}
+
+				keep, remove, reasons := snapshotGroup, restic.Snapshots(nil), []restic.KeepReason(nil)
+				if !policy.Empty() {
+					keep, remove, reasons = restic.ApplyPolicy(snapshotGroup, policy)
+				}
+				remove = append(remove, collapsed...)
+				keepCount += len(keep)
 
 				if len(keep) != 0 && !gopts.Quiet && !gopts.JSON {
+					printReasons := reasons
+					if opts.Compact && !opts.ShowReasons {
+						printReasons = nil
+					}
 					Printf("keep %d snapshots:\n", len(keep))
-					PrintSnapshots(globalOptions.stdout, keep, reasons, opts.Compact)
+					PrintSnapshots(globalOptions.stdout, keep, printReasons, opts.Compact, false, nil, idLen)
 					Printf("\n")
 				}
 				addJSONSnapshots(&fg.Keep, keep)
 
 				if len(remove) != 0 && !gopts.Quiet && !gopts.JSON {
 					Printf("remove %d snapshots:\n", len(remove))
-					PrintSnapshots(globalOptions.stdout, remove, nil, opts.Compact)
+					PrintSnapshots(globalOptions.stdout, remove, nil, opts.Compact, false, nil, idLen)
 					Printf("\n")
 				}
 				addJSONSnapshots(&fg.Remove, remove)
@@ -268,11 +425,41 @@ func runForget(ctx context.Context, opts ForgetOptions, gopts GlobalOptions, arg
 				for _, sn := range remove {
 					removeSnIDs.Insert(*sn.ID())
 				}
+				for _, sn := range keep {
+					keepSnIDs.Insert(*sn.ID())
+				}
 			}
 		}
 	}
 
-	if len(removeSnIDs) > 0 {
+	report.setForget(keepCount, len(removeSnIDs), opts.DryRun || opts.Simulate)
+
+	// the estimate needs the removed snapshots, thus compute it before
+	// deleting them
+	var hint pruneHint
+	if opts.PruneHint {
+		hint, err = estimatePruneHint(ctx, pruneOptions, gopts, repo, removeSnIDs)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !opts.Simulate && !opts.DryRun && len(args) == 0 {
+		err = confirmForgetHosts(opts, gopts, snapshots, removeSnIDs)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.Simulate {
+		if !gopts.JSON {
+			if len(removeSnIDs) > 0 {
+				Printf("Would remove the following snapshots:\n%v\n\n", removeSnIDs)
+			}
+			Printf("simulation: %d snapshots would be kept, %d snapshots would be removed, the repository was not modified\n",
+				keepCount, len(removeSnIDs))
+		}
+	} else if len(removeSnIDs) > 0 {
 		if !opts.DryRun {
 			err := DeleteFilesChecked(ctx, gopts, repo, removeSnIDs, restic.SnapshotFile)
 			if err != nil {
@@ -285,6 +472,37 @@ func runForget(ctx context.Context, opts ForgetOptions, gopts GlobalOptions, arg
 		}
 	}
 
+	if !opts.DryRun && !opts.Simulate {
+		// tagging a snapshot replaces it with a new one
+		var tagged restic.IDs
+		removedIDs := restic.NewIDSet()
+		removedIDs.Merge(removeSnIDs)
+		for _, sn := range collapsedInto {
+			if removeSnIDs.Has(*sn.ID()) {
+				continue
+			}
+			changed, newID, err := changeTags(ctx, repo, sn, nil, []string{collapsedSnapshotTag}, nil)
+			if err != nil {
+				return err
+			}
+			if changed {
+				tagged = append(tagged, newID)
+				removedIDs.Insert(*sn.ID())
+			}
+		}
+
+		if len(removedIDs) > 0 {
+			updateSnapshotIndex(ctx, gopts, repo, snapshotLister, tagged, removedIDs)
+		}
+
+		if opts.RemovedIDsFile != "" {
+			err := appendForgetIDsFile(opts.RemovedIDsFile, time.Now(), removeSnIDs, keepSnIDs)
+			if err != nil {
+				return errors.Fatalf("unable to write removed snapshot IDs: %v", err)
+			}
+		}
+	}
+
 	if gopts.JSON && len(jsonGroups) > 0 {
 		err = printJSONForget(globalOptions.stdout, jsonGroups)
 		if err != nil {
@@ -292,6 +510,17 @@ func runForget(ctx context.Context, opts ForgetOptions, gopts GlobalOptions, arg
 		}
 	}
 
+	if opts.PruneHint {
+		if gopts.JSON {
+			err = json.NewEncoder(globalOptions.stdout).Encode(hint)
+			if err != nil {
+				return err
+			}
+		} else if !gopts.Quiet {
+			printPruneHint(hint, pruneOptions.MaxUnused)
+		}
+	}
+
 	if len(removeSnIDs) > 0 && opts.Prune {
 		if !gopts.JSON {
 			if opts.DryRun {
@@ -307,6 +536,181 @@ func runForget(ctx context.Context, opts ForgetOptions, gopts GlobalOptions, arg
 	return nil
 }
 
+// confirmForgetHosts guards against removing the snapshots of all hosts by a
+// policy which was meant for a single host. If the snapshots of at least
+// --all-hosts-threshold hosts would be removed and no host was selected using
+// --host, either --all-hosts or a confirmation on the terminal is required.
+func confirmForgetHosts(opts ForgetOptions, gopts GlobalOptions, snapshots restic.Snapshots, remove restic.IDSet) error {
+	if opts.AllHosts || opts.AllHostsThreshold == 0 || len(opts.Hosts) > 0 {
+		return nil
+	}
+
+	hostSet := make(map[string]struct{})
+	for _, sn := range snapshots {
+		if remove.Has(*sn.ID()) {
+			hostSet[sn.Hostname] = struct{}{}
+		}
+	}
+	if len(hostSet) < opts.AllHostsThreshold {
+		return nil
+	}
+
+	hosts := make([]string, 0, len(hostSet))
+	for host := range hostSet {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	msg := fmt.Sprintf("the policy would remove %d snapshots of %d hosts (%v)", len(remove), len(hosts), strings.Join(hosts, ", "))
+
+	if opts.NoConfirmPrompt || gopts.JSON || !stdinIsTerminal() {
+		return errors.Fatalf("%s, pass --all-hosts to confirm or select a host using --host", msg)
+	}
+
+	Warnf("%s, continue? [y/N] ", msg)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return errors.Fatal("no snapshots were removed")
+	}
+}
+
+// pruneHint is the recommendation printed by --prune-hint.
+type pruneHint struct {
+	MessageType    string  `json:"message_type"` // "prune_hint"
+	Recommended    bool    `json:"recommended"`
+	UnusedBytes    uint64  `json:"unused_bytes"`
+	UnusedPercent  float64 `json:"unused_percent"`
+	MaxUnusedBytes uint64  `json:"max_unused_bytes"`
+	ForgottenBytes uint64  `json:"forgotten_bytes"`
+}
+
+// estimatePruneHint plans a prune without the removed snapshots and
+// recommends running it if more data would be unused than tolerated by
+// --max-unused and --max-unused-percent. The repository is not modified.
+func estimatePruneHint(ctx context.Context, opts PruneOptions, gopts GlobalOptions, repo *repository.Repository, removed restic.IDSet) (pruneHint, error) {
+	if !gopts.JSON {
+		Verbosef("loading indexes...\n")
+	}
+	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
+	err := repo.LoadIndex(ctx, bar)
+	if err != nil {
+		return pruneHint{}, err
+	}
+
+	// only plan, the dry run also computes the data of the removed snapshots
+	opts.DryRun = true
+	_, stats, err := planPrune(ctx, opts, repo, removed, gopts)
+	if err != nil {
+		return pruneHint{}, err
+	}
+
+	hint := pruneHint{
+		MessageType:    "prune_hint",
+		UnusedBytes:    stats.size.duplicate + stats.size.unused + stats.size.unref,
+		UnusedPercent:  stats.unusedPercent(),
+		MaxUnusedBytes: opts.maxUnusedBytes(stats.size.used),
+		ForgottenBytes: stats.size.forgotten,
+	}
+	hint.Recommended = hint.UnusedBytes > hint.MaxUnusedBytes &&
+		(opts.MaxUnusedPercent == 0 || hint.UnusedPercent >= opts.MaxUnusedPercent)
+	return hint, nil
+}
+
+func printPruneHint(hint pruneHint, maxUnused string) {
+	Printf("%s unused data after forget (%.2f%% of the repository), %s thereof only referenced by the removed snapshots\n",
+		ui.FormatBytes(hint.UnusedBytes), hint.UnusedPercent, ui.FormatBytes(hint.ForgottenBytes))
+	if hint.Recommended {
+		Printf("running prune is recommended, the unused data exceeds the limit of %v set by --max-unused\n", maxUnused)
+	} else {
+		Printf("running prune is not worthwhile yet, the unused data is within the limit of %v set by --max-unused\n", maxUnused)
+	}
+}
+
+// forgetIDRecord is written to the file passed to --removed-ids-file for each
+// snapshot in the JSON lines format.
+type forgetIDRecord struct {
+	Time       time.Time  `json:"time"`
+	Action     string     `json:"action"`
+	SnapshotID *restic.ID `json:"snapshot_id"`
+}
+
+// appendForgetIDsFile appends the IDs of the removed and kept snapshots to
+// filename. Files ending in ".jsonl" receive one JSON object per snapshot,
+// otherwise lines like "<time> removed <ID>" are written.
+func appendForgetIDsFile(filename string, now time.Time, removed, kept restic.IDSet) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	wr := bufio.NewWriter(f)
+	enc := json.NewEncoder(wr)
+	for _, list := range []struct {
+		action string
+		ids    restic.IDs
+	}{
+		{"removed", removed.List()},
+		{"kept", kept.List()},
+	} {
+		for _, id := range list.ids {
+			id := id
+			if strings.HasSuffix(filename, ".jsonl") {
+				err = enc.Encode(forgetIDRecord{Time: now, Action: list.action, SnapshotID: &id})
+			} else {
+				_, err = fmt.Fprintf(wr, "%s %s %s\n", now.Format(time.RFC3339), list.action, id)
+			}
+			if err != nil {
+				_ = f.Close()
+				return err
+			}
+		}
+	}
+
+	if err := wr.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// collapsedSnapshotTag is added to snapshots which replace older snapshots
+// with an identical tree removed by --collapse-identical.
+const collapsedSnapshotTag = "collapsed"
+
+// collapseIdenticalSnapshots splits the snapshots of a group into those to
+// keep and those whose tree is identical to that of a newer snapshot. into
+// contains the kept snapshots which replace at least one removed snapshot.
+func collapseIdenticalSnapshots(list restic.Snapshots) (keep, remove, into restic.Snapshots) {
+	list = append(restic.Snapshots{}, list...)
+	// newest snapshot first
+	sort.Stable(sort.Reverse(list))
+
+	newest := make(map[restic.ID]*restic.Snapshot)
+	replacing := make(map[*restic.Snapshot]struct{})
+	for _, sn := range list {
+		if sn.Tree == nil {
+			keep = append(keep, sn)
+			continue
+		}
+		if newer, ok := newest[*sn.Tree]; ok {
+			remove = append(remove, sn)
+			if _, ok := replacing[newer]; !ok {
+				replacing[newer] = struct{}{}
+				into = append(into, newer)
+			}
+			continue
+		}
+		newest[*sn.Tree] = sn
+		keep = append(keep, sn)
+	}
+	return keep, remove, into
+}
+
 // ForgetGroup helps to print what is forgotten in JSON.
 type ForgetGroup struct {
 	Tags    []string            `json:"tags"`
@@ -1,9 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 )
 
@@ -11,3 +22,344 @@ func testRunForget(t testing.TB, gopts GlobalOptions, args ...string) {
 	opts := ForgetOptions{}
 	rtest.OK(t, runForget(context.TODO(), opts, gopts, args))
 }
+
+func TestDeleteFilesAlreadyRemoved(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+	repo, err := OpenRepository(context.TODO(), env.gopts)
+	rtest.OK(t, err)
+
+	// a snapshot removed concurrently by another client must not cause an error
+	missing := restic.NewIDSet(restic.NewRandomID())
+	rtest.OK(t, DeleteFilesChecked(context.TODO(), env.gopts, repo, missing, restic.SnapshotFile))
+}
+
+type readOnlyBackend struct {
+	backend.Backend
+}
+
+func (b *readOnlyBackend) Save(_ context.Context, h backend.Handle, _ backend.RewindReader) error {
+	return errors.Errorf("Failed to save %v", h)
+}
+
+func (b *readOnlyBackend) Remove(_ context.Context, h backend.Handle) error {
+	return errors.Errorf("Failed to remove %v", h)
+}
+
+func TestForgetSimulate(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	for i := 0; i < 3; i++ {
+		testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	}
+	snapshotIDs := testListSnapshots(t, env.gopts, 3)
+
+	gopts := env.gopts
+	gopts.backendTestHook = func(r backend.Backend) (backend.Backend, error) {
+		return &readOnlyBackend{r}, nil
+	}
+
+	buf, err := withCaptureStdout(func() error {
+		opts := ForgetOptions{Last: 1, Simulate: true}
+		return runForget(context.TODO(), opts, gopts, nil)
+	})
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(buf.String(), "simulation: 1 snapshots would be kept, 2 snapshots would be removed"),
+		"missing summary in output: %v", buf.String())
+
+	// explicitly specified snapshots must not be removed either
+	_, err = withCaptureStdout(func() error {
+		opts := ForgetOptions{Simulate: true}
+		return runForget(context.TODO(), opts, gopts, []string{snapshotIDs[0].String()})
+	})
+	rtest.OK(t, err)
+	testListSnapshots(t, env.gopts, 3)
+
+	err = runForget(context.TODO(), ForgetOptions{Last: 1, Simulate: true, Prune: true}, env.gopts, nil)
+	rtest.Assert(t, err != nil, "expected error for --simulate with --prune")
+}
+
+func TestForgetPruneDryRun(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	// data only referenced by the first snapshot
+	createRandomFile(t, env, "removed", 1<<20)
+	opts := BackupOptions{}
+	testRunBackup(t, "", []string{env.testdata}, opts, env.gopts)
+	rtest.OK(t, os.Remove(filepath.Join(env.testdata, "removed")))
+	testRunBackup(t, "", []string{env.testdata}, opts, env.gopts)
+
+	oldPruneOptions := pruneOptions
+	defer func() { pruneOptions = oldPruneOptions }()
+	pruneOptions = PruneOptions{MaxUnused: "5%"}
+	defer func(verbosity uint) { globalOptions.verbosity = verbosity }(globalOptions.verbosity)
+	globalOptions.verbosity = 1
+
+	// the preview must neither lock nor modify the repository
+	gopts := env.gopts
+	gopts.NoLock = true
+	gopts.backendTestHook = func(r backend.Backend) (backend.Backend, error) {
+		return &readOnlyBackend{r}, nil
+	}
+	buf, err := withCaptureStdout(func() error {
+		return runForget(context.TODO(), ForgetOptions{Last: 1, DryRun: true, Prune: true}, gopts, nil)
+	})
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(buf.String(), "only referenced by the removed snapshots"),
+		"missing forgotten data in output: %v", buf.String())
+	testListSnapshots(t, env.gopts, 2)
+	testRunCheck(t, env.gopts)
+}
+
+func TestForgetPruneHint(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	// data only referenced by the first snapshot
+	createRandomFile(t, env, "removed", 1<<20)
+	opts := BackupOptions{}
+	testRunBackup(t, "", []string{env.testdata}, opts, env.gopts)
+	rtest.OK(t, os.Remove(filepath.Join(env.testdata, "removed")))
+	testRunBackup(t, "", []string{env.testdata}, opts, env.gopts)
+
+	oldPruneOptions := pruneOptions
+	defer func() { pruneOptions = oldPruneOptions }()
+
+	runHint := func(maxUnused string, dryRun bool) pruneHint {
+		pruneOptions = PruneOptions{MaxUnused: maxUnused}
+		gopts := env.gopts
+		gopts.JSON = true
+		// like prune, the estimate lists the snapshots again
+		gopts.backendTestHook = nil
+		buf, err := withCaptureStdout(func() error {
+			return runForget(context.TODO(), ForgetOptions{Last: 1, DryRun: dryRun, PruneHint: true}, gopts, nil)
+		})
+		rtest.OK(t, err)
+
+		dec := json.NewDecoder(buf)
+		var groups []ForgetGroup
+		rtest.OK(t, dec.Decode(&groups))
+		var hint pruneHint
+		rtest.OK(t, dec.Decode(&hint))
+		rtest.Equals(t, "prune_hint", hint.MessageType)
+		return hint
+	}
+
+	hint := runHint("unlimited", true)
+	rtest.Assert(t, !hint.Recommended, "prune recommended despite unlimited --max-unused")
+	rtest.Assert(t, hint.ForgottenBytes >= 1<<20, "expected at least 1 MiB forgotten data, got %d", hint.ForgottenBytes)
+	rtest.Assert(t, hint.UnusedBytes >= hint.ForgottenBytes, "unused data %d is less than forgotten data %d", hint.UnusedBytes, hint.ForgottenBytes)
+	testListSnapshots(t, env.gopts, 2)
+
+	hint = runHint("0", false)
+	rtest.Assert(t, hint.Recommended, "prune not recommended for --max-unused 0")
+	testListSnapshots(t, env.gopts, 1)
+
+	err := runForget(context.TODO(), ForgetOptions{Last: 1, Prune: true, PruneHint: true}, env.gopts, nil)
+	rtest.Assert(t, err != nil, "expected error for --prune-hint with --prune")
+}
+
+func TestForgetRemovedIDsFile(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	for i := 0; i < 3; i++ {
+		testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	}
+	testListSnapshots(t, env.gopts, 3)
+
+	// nothing is recorded in a dry run
+	jsonFile := filepath.Join(env.base, "ids.jsonl")
+	opts := ForgetOptions{Last: 2, DryRun: true, RemovedIDsFile: jsonFile}
+	rtest.OK(t, runForget(context.TODO(), opts, env.gopts, nil))
+	_, err := os.Stat(jsonFile)
+	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "file was created in dry run: %v", err)
+
+	opts.DryRun = false
+	rtest.OK(t, runForget(context.TODO(), opts, env.gopts, nil))
+	kept := testListSnapshots(t, env.gopts, 2)
+
+	data, err := os.ReadFile(jsonFile)
+	rtest.OK(t, err)
+	var records []forgetIDRecord
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var rec forgetIDRecord
+		rtest.OK(t, dec.Decode(&rec))
+		records = append(records, rec)
+	}
+	rtest.Equals(t, 3, len(records))
+	rtest.Equals(t, "removed", records[0].Action)
+	for _, rec := range records[1:] {
+		rtest.Equals(t, "kept", rec.Action)
+		rtest.Assert(t, restic.NewIDSet(kept...).Has(*rec.SnapshotID), "unexpected kept snapshot %v", rec.SnapshotID)
+	}
+
+	// records are appended, other file names use the line based format
+	textFile := filepath.Join(env.base, "ids.txt")
+	for i := 0; i < 2; i++ {
+		rtest.OK(t, runForget(context.TODO(), ForgetOptions{RemovedIDsFile: textFile}, env.gopts, []string{kept[i].String()}))
+	}
+	data, err = os.ReadFile(textFile)
+	rtest.OK(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	rtest.Equals(t, 2, len(lines))
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		rtest.Equals(t, []string{"removed", kept[i].String()}, fields[1:])
+	}
+}
+
+func TestForgetWebhook(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	var m sync.Mutex
+	var webhookReports []webhookReport
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report webhookReport
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil || r.Method != http.MethodPost {
+			t.Errorf("invalid webhook request %v: %v", r.Method, err)
+		}
+
+		m.Lock()
+		defer m.Unlock()
+		webhookReports = append(webhookReports, report)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	received := func() []webhookReport {
+		m.Lock()
+		defer m.Unlock()
+		return append([]webhookReport{}, webhookReports...)
+	}
+	setStatus := func(code int) {
+		m.Lock()
+		defer m.Unlock()
+		status = code
+	}
+
+	testSetupBackupData(t, env)
+	backupOpts := BackupOptions{webhookOptions: webhookOptions{Webhook: srv.URL}}
+	testRunBackup(t, "", []string{env.testdata}, backupOpts, env.gopts)
+	testRunBackup(t, "", []string{env.testdata}, backupOpts, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 2)
+
+	forgetOpts := ForgetOptions{Last: 1, webhookOptions: webhookOptions{Webhook: srv.URL}}
+	rtest.OK(t, runForget(context.TODO(), forgetOpts, env.gopts, nil))
+
+	reports := received()
+	rtest.Equals(t, 3, len(reports))
+	for _, report := range reports {
+		rtest.Assert(t, report.Success, "expected successful command, got %v", report)
+		rtest.Assert(t, report.RepositoryID != "", "missing repository ID")
+	}
+	rtest.Equals(t, "backup", reports[0].Command)
+	rtest.Assert(t, reports[0].Backup != nil && reports[0].Backup.FilesNew > 0, "missing backup summary: %v", reports[0].Backup)
+	rtest.Assert(t, reports[0].Backup.SnapshotID == snapshotIDs[0].String() || reports[0].Backup.SnapshotID == snapshotIDs[1].String(),
+		"unexpected snapshot ID %v", reports[0].Backup.SnapshotID)
+	rtest.Equals(t, "forget", reports[2].Command)
+	rtest.Equals(t, &webhookForgetSummary{SnapshotsKept: 1, SnapshotsRemoved: 1}, reports[2].Forget)
+
+	// failing to deliver the webhook does not fail the command
+	setStatus(http.StatusInternalServerError)
+	rtest.OK(t, runForget(context.TODO(), forgetOpts, env.gopts, nil))
+	rtest.Equals(t, 4, len(received()))
+
+	// failures are reported
+	setStatus(http.StatusOK)
+	gopts := env.gopts
+	gopts.password = "wrong"
+	rtest.Assert(t, runForget(context.TODO(), forgetOpts, gopts, nil) != nil, "expected forget to fail")
+	reports = received()
+	rtest.Equals(t, 5, len(reports))
+	rtest.Assert(t, !reports[4].Success && reports[4].Error != "", "expected failure to be reported, got %v", reports[4])
+}
+
+func TestForgetCollapseIdentical(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	for i := 0; i < 3; i++ {
+		testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	}
+	rtest.OK(t, appendRandomData(filepath.Join(env.testdata, "0", "0", "9", "0"), 100))
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	testListSnapshots(t, env.gopts, 4)
+
+	opts := ForgetOptions{CollapseIdentical: true, DryRun: true}
+	rtest.OK(t, runForget(context.TODO(), opts, env.gopts, nil))
+	testListSnapshots(t, env.gopts, 4)
+
+	opts.DryRun = false
+	rtest.OK(t, runForget(context.TODO(), opts, env.gopts, nil))
+	newest, snapmap := testRunSnapshots(t, env.gopts)
+	rtest.Equals(t, 2, len(snapmap))
+	rtest.Assert(t, !newest.HasTags([]string{collapsedSnapshotTag}), "newest snapshot must not be tagged")
+	for id, sn := range snapmap {
+		if id == *newest.ID {
+			continue
+		}
+		rtest.Assert(t, sn.HasTags([]string{collapsedSnapshotTag}), "snapshot %v is not tagged as collapsed", id.Str())
+		rtest.Assert(t, sn.Original != nil, "snapshot %v has no original id", id.Str())
+	}
+
+	// nothing left to collapse
+	rtest.OK(t, runForget(context.TODO(), opts, env.gopts, nil))
+	_, snapmap2 := testRunSnapshots(t, env.gopts)
+	rtest.Equals(t, snapmap, snapmap2)
+}
+
+func TestForgetAllHostsGuard(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	for _, host := range []string{"a", "a", "b", "b"} {
+		testRunBackup(t, "", []string{env.testdata}, BackupOptions{Host: host}, env.gopts)
+	}
+	testListSnapshots(t, env.gopts, 4)
+
+	opts := ForgetOptions{
+		Last:              1,
+		GroupBy:           restic.SnapshotGroupByOptions{Host: true},
+		AllHostsThreshold: 2,
+		NoConfirmPrompt:   true,
+	}
+	err := runForget(context.TODO(), opts, env.gopts, nil)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "snapshots of 2 hosts (a, b)"), "unexpected error %v", err)
+	testListSnapshots(t, env.gopts, 4)
+
+	// a dry run does not remove anything
+	dryRunOpts := opts
+	dryRunOpts.DryRun = true
+	_, err = withCaptureStdout(func() error {
+		return runForget(context.TODO(), dryRunOpts, env.gopts, nil)
+	})
+	rtest.OK(t, err)
+	testListSnapshots(t, env.gopts, 4)
+
+	allOpts := opts
+	allOpts.AllHosts = true
+	rtest.OK(t, runForget(context.TODO(), allOpts, env.gopts, nil))
+	testListSnapshots(t, env.gopts, 2)
+
+	// selecting a host does not require a confirmation
+	for _, host := range []string{"a", "b"} {
+		testRunBackup(t, "", []string{env.testdata}, BackupOptions{Host: host}, env.gopts)
+	}
+	hostOpts := opts
+	hostOpts.Hosts = []string{"a"}
+	rtest.OK(t, runForget(context.TODO(), hostOpts, env.gopts, nil))
+	testListSnapshots(t, env.gopts, 3)
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
+	"github.com/spf13/pflag"
 )
 
 func TestForgetPolicyValues(t *testing.T) {
@@ -92,3 +93,30 @@ func TestForgetOptionValues(t *testing.T) {
 		}
 	}
 }
+
+func TestForgetEnvDefaults(t *testing.T) {
+	var opts ForgetOptions
+	f := pflag.NewFlagSet("forget", pflag.ContinueOnError)
+	f.Var(&opts.Daily, "keep-daily", "")
+	f.Var(&opts.Weekly, "keep-weekly", "")
+	f.Var(&opts.Monthly, "keep-monthly", "")
+	f.Var(&opts.WithinDaily, "keep-within-daily", "")
+	rtest.OK(t, f.Parse([]string{"--keep-weekly", "2"}))
+
+	t.Setenv("RESTIC_KEEP_DAILY", "7")
+	t.Setenv("RESTIC_KEEP_WEEKLY", "5")
+	t.Setenv("RESTIC_KEEP_MONTHLY", "unlimited")
+	t.Setenv("RESTIC_KEEP_WITHIN_DAILY", "10d")
+	rtest.OK(t, applyForgetEnvDefaults(f))
+
+	rtest.Equals(t, ForgetPolicyCount(7), opts.Daily)
+	// the command line takes precedence
+	rtest.Equals(t, ForgetPolicyCount(2), opts.Weekly)
+	rtest.Equals(t, ForgetPolicyCount(-1), opts.Monthly)
+	rtest.Equals(t, restic.ParseDurationOrPanic("10d"), opts.WithinDaily)
+
+	t.Setenv("RESTIC_KEEP_DAILY", "seven")
+	f = pflag.NewFlagSet("forget", pflag.ContinueOnError)
+	f.Var(&opts.Daily, "keep-daily", "")
+	rtest.Assert(t, applyForgetEnvDefaults(f) != nil, "expected error for invalid value")
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"strconv"
+	"time"
 
 	"github.com/restic/chunker"
 	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/crypto"
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 
@@ -36,6 +39,10 @@ type InitOptions struct {
 	secondaryRepoOptions
 	CopyChunkerParameters bool
 	RepositoryVersion     string
+	Layout                string
+	KDF                   string
+	KDFCalibrate          time.Duration
+	KDFMemory             int
 }
 
 var initOptions InitOptions
@@ -47,6 +54,10 @@ func init() {
 	initSecondaryRepoOptions(f, &initOptions.secondaryRepoOptions, "secondary", "to copy chunker parameters from")
 	f.BoolVar(&initOptions.CopyChunkerParameters, "copy-chunker-params", false, "copy chunker parameters from the secondary repository (useful with the copy command)")
 	f.StringVar(&initOptions.RepositoryVersion, "repository-version", "stable", "repository format version to use, allowed values are a format version, 'latest' and 'stable'")
+	f.StringVar(&initOptions.Layout, "layout", "", "backend `layout` to use for the new repository, allowed values are 'default' and 's3legacy' (only for local, sftp and s3)")
+	f.StringVar(&initOptions.KDF, "kdf", "", "key derivation `function` used for the password, allowed values are 'scrypt' and 'argon2id' (default: scrypt)")
+	f.DurationVar(&initOptions.KDFCalibrate, "kdf-calibrate", 0, "calibrate the KDF parameters such that deriving the key takes about `duration` (default: 500ms)")
+	f.IntVar(&initOptions.KDFMemory, "kdf-memory", 0, "limit the memory used by the KDF to `n` MiB (default: 60 for scrypt, 64 for argon2id)")
 }
 
 func runInit(ctx context.Context, opts InitOptions, gopts GlobalOptions, args []string) error {
@@ -70,6 +81,11 @@ func runInit(ctx context.Context, opts InitOptions, gopts GlobalOptions, args []
 		return errors.Fatalf("only repository versions between %v and %v are allowed", restic.MinRepoVersion, restic.MaxRepoVersion)
 	}
 
+	kdfParams, err := initKDFParams(opts)
+	if err != nil {
+		return err
+	}
+
 	chunkerPolynomial, err := maybeReadChunkerPolynomial(ctx, opts, gopts)
 	if err != nil {
 		return err
@@ -80,6 +96,13 @@ func runInit(ctx context.Context, opts InitOptions, gopts GlobalOptions, args []
 		return err
 	}
 
+	if opts.Layout != "" {
+		gopts.extended, err = applyInitLayout(gopts, opts.Layout)
+		if err != nil {
+			return err
+		}
+	}
+
 	gopts.password, err = ReadPasswordTwice(gopts,
 		"enter password for new repository: ",
 		"enter password again: ")
@@ -100,6 +123,15 @@ func runInit(ctx context.Context, opts InitOptions, gopts GlobalOptions, args []
 		return errors.Fatal(err.Error())
 	}
 
+	if kdfParams != nil {
+		oldParams := repository.Params
+		repository.Params = kdfParams
+		defer func() { repository.Params = oldParams }()
+		if !gopts.JSON {
+			Verbosef("using KDF parameters %v\n", kdfParams)
+		}
+	}
+
 	err = s.Init(ctx, version, gopts.password, chunkerPolynomial)
 	if err != nil {
 		return errors.Fatalf("create key in repository at %s failed: %v\n", location.StripPassword(gopts.backends, gopts.Repo), err)
@@ -129,6 +161,73 @@ func runInit(ctx context.Context, opts InitOptions, gopts GlobalOptions, args []
 	return nil
 }
 
+// applyInitLayout returns the extended options with the layout option set for
+// the backend of the repository. The layout is detected automatically when the
+// repository is opened later on.
+func applyInitLayout(gopts GlobalOptions, layout string) (options.Options, error) {
+	if layout != "default" && layout != "s3legacy" {
+		return nil, errors.Fatalf("invalid layout %q, allowed values are 'default' and 's3legacy'", layout)
+	}
+
+	loc, err := location.Parse(gopts.backends, gopts.Repo)
+	if err != nil {
+		return nil, errors.Fatal(err.Error())
+	}
+
+	switch loc.Scheme {
+	case "local", "sftp", "s3":
+	default:
+		return nil, errors.Fatalf("--layout is not supported for the %s backend", loc.Scheme)
+	}
+
+	key := loc.Scheme + ".layout"
+	if v, ok := gopts.extended[key]; ok && v != layout {
+		return nil, errors.Fatalf("--layout %s conflicts with option %s=%s", layout, key, v)
+	}
+
+	extended := make(options.Options, len(gopts.extended)+1)
+	for k, v := range gopts.extended {
+		extended[k] = v
+	}
+	extended[key] = layout
+	return extended, nil
+}
+
+// initKDFParams calibrates the KDF parameters for the new repository if any of
+// the KDF options is set. Otherwise it returns nil and the default parameters
+// are used.
+func initKDFParams(opts InitOptions) (*crypto.Params, error) {
+	switch opts.KDF {
+	case "", crypto.KDFScrypt, crypto.KDFArgon2id:
+	default:
+		return nil, errors.Fatalf("invalid KDF %q, allowed values are 'scrypt' and 'argon2id'", opts.KDF)
+	}
+	if opts.KDFCalibrate < 0 || opts.KDFMemory < 0 {
+		return nil, errors.Fatal("--kdf-calibrate and --kdf-memory must not be negative")
+	}
+
+	if opts.KDF == "" && opts.KDFCalibrate == 0 && opts.KDFMemory == 0 {
+		return nil, nil
+	}
+
+	timeout := repository.KDFTimeout
+	if opts.KDFCalibrate != 0 {
+		timeout = opts.KDFCalibrate
+	}
+	memory := repository.KDFMemory
+	if opts.KDFMemory != 0 {
+		memory = opts.KDFMemory
+	} else if opts.KDF == crypto.KDFArgon2id {
+		memory = int(crypto.DefaultArgon2idParams.Memory / 1024)
+	}
+
+	params, err := crypto.CalibrateKDF(opts.KDF, timeout, memory)
+	if err != nil {
+		return nil, errors.Fatalf("calibrating KDF failed: %v", err)
+	}
+	return &params, nil
+}
+
 func maybeReadChunkerPolynomial(ctx context.Context, opts InitOptions, gopts GlobalOptions) (*chunker.Pol, error) {
 	if opts.CopyChunkerParameters {
 		otherGopts, _, err := fillSecondaryGlobalOpts(opts.secondaryRepoOptions, gopts, "secondary")
@@ -2,8 +2,13 @@ package main
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
@@ -47,3 +52,88 @@ func TestInitCopyChunkerParams(t *testing.T) {
 		"expected equal chunker polynomials, got %v expected %v", repo.Config().ChunkerPolynomial,
 		otherRepo.Config().ChunkerPolynomial)
 }
+
+func TestInitLayout(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	repository.TestUseLowSecurityKDFParameters(t)
+	restic.TestDisableCheckPolynomial(t)
+	restic.TestSetLockTimeout(t, 0)
+
+	rtest.Assert(t, runInit(context.TODO(), InitOptions{Layout: "flat"}, env.gopts, nil) != nil,
+		"expected invalid layout to fail")
+
+	gopts := env.gopts
+	gopts.extended = options.Options{"local.layout": "default"}
+	rtest.Assert(t, runInit(context.TODO(), InitOptions{Layout: "s3legacy"}, gopts, nil) != nil,
+		"expected conflicting layout options to fail")
+
+	rtest.OK(t, runInit(context.TODO(), InitOptions{Layout: "s3legacy"}, env.gopts, nil))
+	_, err := os.Stat(filepath.Join(env.repo, "key"))
+	rtest.OK(t, err)
+
+	// the layout is not stored in the config, but detected from the key
+	// directory when the repository is opened without the option
+	rtest.SetupTarTestFixture(t, env.testdata, filepath.Join("testdata", "backup-data.tar.gz"))
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+	testRunCheck(t, env.gopts)
+
+	_, err = os.Stat(filepath.Join(env.repo, "snapshot", snapshotIDs[0].String()))
+	rtest.OK(t, err)
+	for _, dir := range []string{"keys", "snapshots"} {
+		_, err = os.Stat(filepath.Join(env.repo, dir))
+		rtest.Assert(t, errors.Is(err, os.ErrNotExist), "unexpected directory %v for the s3legacy layout: %v", dir, err)
+	}
+	entries, err := os.ReadDir(filepath.Join(env.repo, "data"))
+	rtest.OK(t, err)
+	for _, entry := range entries {
+		rtest.Assert(t, !entry.IsDir(), "unexpected subdirectory %v of data for the s3legacy layout", entry.Name())
+	}
+}
+
+func TestInitKDF(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	// must list keys more than once
+	env.gopts.backendTestHook = nil
+	defer cleanup()
+
+	repository.TestUseLowSecurityKDFParameters(t)
+	restic.TestDisableCheckPolynomial(t)
+	restic.TestSetLockTimeout(t, 0)
+
+	rtest.Assert(t, runInit(context.TODO(), InitOptions{KDF: "bcrypt"}, env.gopts, nil) != nil,
+		"expected invalid KDF to fail")
+
+	initOpts := InitOptions{
+		KDF:          "argon2id",
+		KDFCalibrate: 10 * time.Millisecond,
+		KDFMemory:    8,
+	}
+	rtest.OK(t, runInit(context.TODO(), initOpts, env.gopts, nil))
+
+	keys := testRunKeyListJSON(t, env.gopts)
+	rtest.Equals(t, 1, len(keys))
+	rtest.Equals(t, "argon2id", keys[0].KDF)
+	rtest.Equals(t, uint32(8*1024), keys[0].Memory)
+	rtest.Assert(t, keys[0].Time >= 3, "expected at least 3 passes, got %d", keys[0].Time)
+	rtest.Equals(t, 0, keys[0].N)
+
+	// new keys keep using the KDF of the current key
+	oldTimeout, oldMemory := repository.KDFTimeout, repository.KDFMemory
+	repository.KDFTimeout, repository.KDFMemory = time.Millisecond, 8
+	defer func() { repository.KDFTimeout, repository.KDFMemory = oldTimeout, oldMemory }()
+	testKeyNewPassword = "geheim2"
+	defer func() { testKeyNewPassword = "" }()
+	params := repository.Params
+	rtest.OK(t, runKey(context.TODO(), env.gopts, []string{"add"}))
+	for _, key := range testRunKeyListJSON(t, env.gopts) {
+		rtest.Equals(t, "argon2id", key.KDF)
+	}
+	// the parameters for other repositories are not modified
+	rtest.Assert(t, repository.Params == params && params.Name() == "scrypt",
+		"KDF parameters were modified: %v", repository.Params)
+
+	testRunCheck(t, env.gopts)
+}
@@ -8,6 +8,7 @@ import (
 	"sync"
 
 	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/crypto"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
@@ -17,11 +18,15 @@ import (
 )
 
 var cmdKey = &cobra.Command{
-	Use:   "key [flags] [list|add|remove|passwd] [ID]",
+	Use:   "key [flags] [list|add|remove|passwd|upgrade] [ID]",
 	Short: "Manage keys (passwords)",
 	Long: `
 The "key" command manages keys (passwords) for accessing the repository.
 
+The "upgrade" sub-command re-encrypts the master key for the current password
+using KDF parameters calibrated for the current hardware, if they are stronger
+than those of the current key. The password itself is not changed.
+
 EXIT STATUS
 ===========
 
@@ -55,6 +60,13 @@ func listKeys(ctx context.Context, s *repository.Repository, gopts GlobalOptions
 		UserName string `json:"userName"`
 		HostName string `json:"hostName"`
 		Created  string `json:"created"`
+		KDF      string `json:"kdf"`
+		N        int    `json:"N,omitempty"`
+		R        int    `json:"r,omitempty"`
+		P        int    `json:"p,omitempty"`
+		Time     uint32 `json:"time,omitempty"`
+		Memory   uint32 `json:"memory,omitempty"`
+		Threads  uint8  `json:"threads,omitempty"`
 	}
 
 	var m sync.Mutex
@@ -73,6 +85,13 @@ func listKeys(ctx context.Context, s *repository.Repository, gopts GlobalOptions
 			UserName: k.Username,
 			HostName: k.Hostname,
 			Created:  k.Created.Local().Format(TimeFormat),
+			KDF:      k.KDF,
+			N:        k.N,
+			R:        k.R,
+			P:        k.P,
+			Time:     k.Time,
+			Memory:   k.Memory,
+			Threads:  k.Threads,
 		}
 
 		m.Lock()
@@ -130,7 +149,12 @@ func addKey(ctx context.Context, repo *repository.Repository, gopts GlobalOption
 		return err
 	}
 
-	id, err := repository.AddKey(ctx, repo, pw, keyUsername, keyHostname, repo.Key())
+	params, err := currentKDFParams(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	id, err := repository.AddKeyWithParams(ctx, repo, params, pw, keyUsername, keyHostname, repo.Key())
 	if err != nil {
 		return errors.Fatalf("creating new key failed: %v\n", err)
 	}
@@ -145,6 +169,17 @@ func addKey(ctx context.Context, repo *repository.Repository, gopts GlobalOption
 	return nil
 }
 
+// currentKDFParams returns the parameters for new keys, such that they use the
+// same KDF as the key currently used to access the repository.
+func currentKDFParams(ctx context.Context, repo *repository.Repository) (crypto.Params, error) {
+	k, err := repository.LoadKey(ctx, repo, repo.KeyID())
+	if err != nil {
+		return crypto.Params{}, err
+	}
+
+	return repository.KDFParamsFor(k.KDF)
+}
+
 func deleteKey(ctx context.Context, repo *repository.Repository, id restic.ID) error {
 	if id == repo.KeyID() {
 		return errors.Fatal("refusing to remove key currently used to access repository")
@@ -166,7 +201,12 @@ func changePassword(ctx context.Context, repo *repository.Repository, gopts Glob
 		return err
 	}
 
-	id, err := repository.AddKey(ctx, repo, pw, "", "", repo.Key())
+	params, err := currentKDFParams(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	id, err := repository.AddKeyWithParams(ctx, repo, params, pw, "", "", repo.Key())
 	if err != nil {
 		return errors.Fatalf("creating new key failed: %v\n", err)
 	}
@@ -188,6 +228,47 @@ func changePassword(ctx context.Context, repo *repository.Repository, gopts Glob
 	return nil
 }
 
+func upgradeKey(ctx context.Context, repo *repository.Repository, gopts GlobalOptions) error {
+	oldID := repo.KeyID()
+	oldKey, err := repository.LoadKey(ctx, repo, oldID)
+	if err != nil {
+		return err
+	}
+
+	// keep the KDF of the existing key
+	params, err := repository.KDFParamsFor(oldKey.KDF)
+	if err != nil {
+		return err
+	}
+
+	oldParams := oldKey.Params()
+	if params.Cost() <= oldParams.Cost() {
+		Verbosef("key %v already uses KDF parameters %v, nothing to do\n", oldID.Str(), oldParams)
+		return nil
+	}
+
+	id, err := repository.AddKeyWithParams(ctx, repo, params, gopts.password, oldKey.Username, oldKey.Hostname, repo.Key())
+	if err != nil {
+		return errors.Fatalf("creating new key failed: %v\n", err)
+	}
+
+	err = switchToNewKeyAndRemoveIfBroken(ctx, repo, id, gopts.password)
+	if err != nil {
+		return err
+	}
+
+	h := backend.Handle{Type: restic.KeyFile, Name: oldID.String()}
+	err = repo.Backend().Remove(ctx, h)
+	if err != nil {
+		return err
+	}
+
+	Verbosef("upgraded KDF parameters from %v to %v\n", oldParams, params)
+	Verbosef("saved new key as %s\n", id)
+
+	return nil
+}
+
 func switchToNewKeyAndRemoveIfBroken(ctx context.Context, repo *repository.Repository, key *repository.Key, pw string) error {
 	// Verify new key to make sure it really works. A broken key can render the
 	// whole repository inaccessible
@@ -206,6 +287,15 @@ func runKey(ctx context.Context, gopts GlobalOptions, args []string) error {
 		return errors.Fatal("wrong number of arguments")
 	}
 
+	if args[0] == "upgrade" {
+		// the password is required again to create the new key
+		var err error
+		gopts.password, err = ReadPassword(gopts, "enter password for repository: ")
+		if err != nil {
+			return err
+		}
+	}
+
 	repo, err := OpenRepository(ctx, gopts)
 	if err != nil {
 		return err
@@ -252,6 +342,14 @@ func runKey(ctx context.Context, gopts GlobalOptions, args []string) error {
 		}
 
 		return changePassword(ctx, repo, gopts)
+	case "upgrade":
+		lock, ctx, err := lockRepoExclusive(ctx, repo, gopts.RetryLock, gopts.JSON)
+		defer unlockRepo(lock)
+		if err != nil {
+			return err
+		}
+
+		return upgradeKey(ctx, repo, gopts)
 	}
 
 	return nil
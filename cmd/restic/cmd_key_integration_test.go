@@ -3,10 +3,12 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"regexp"
 	"testing"
 
 	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/crypto"
 	"github.com/restic/restic/internal/repository"
 	rtest "github.com/restic/restic/internal/test"
 )
@@ -143,3 +145,60 @@ func TestKeyProblems(t *testing.T) {
 	rtest.OK(t, runKey(context.TODO(), env.gopts, []string{"list"}))
 	testRunCheck(t, env.gopts)
 }
+
+type testKeyInfo struct {
+	Current bool   `json:"current"`
+	ID      string `json:"id"`
+	KDF     string `json:"kdf"`
+	N       int    `json:"N"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+}
+
+func testRunKeyListJSON(t testing.TB, gopts GlobalOptions) []testKeyInfo {
+	buf, err := withCaptureStdout(func() error {
+		gopts.JSON = true
+		return runKey(context.TODO(), gopts, []string{"list"})
+	})
+	rtest.OK(t, err)
+
+	var keys []testKeyInfo
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &keys))
+	return keys
+}
+
+func TestKeyUpgrade(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	// must list keys more than once
+	env.gopts.backendTestHook = nil
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	keys := testRunKeyListJSON(t, env.gopts)
+	rtest.Equals(t, 1, len(keys))
+	rtest.Equals(t, "scrypt", keys[0].KDF)
+	oldKey := keys[0]
+
+	oldParams := repository.Params
+	defer func() {
+		repository.Params = oldParams
+	}()
+	repository.Params = &crypto.Params{N: oldKey.N * 2, R: oldKey.R, P: oldKey.P}
+
+	rtest.OK(t, runKey(context.TODO(), env.gopts, []string{"upgrade"}))
+
+	keys = testRunKeyListJSON(t, env.gopts)
+	rtest.Equals(t, 1, len(keys))
+	rtest.Assert(t, keys[0].ID != oldKey.ID, "expected new key, got %v", keys[0].ID)
+	rtest.Equals(t, oldKey.N*2, keys[0].N)
+
+	// parameters are already up to date
+	rtest.OK(t, runKey(context.TODO(), env.gopts, []string{"upgrade"}))
+	rtest.Equals(t, keys, testRunKeyListJSON(t, env.gopts))
+
+	testRunCheck(t, env.gopts)
+}
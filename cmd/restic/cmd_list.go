@@ -11,7 +11,7 @@ import (
 )
 
 var cmdList = &cobra.Command{
-	Use:   "list [flags] [blobs|packs|index|snapshots|keys|locks]",
+	Use:   "list [flags] [blobs|packs|index|snapshots|snapshot-index|keys|locks]",
 	Short: "List objects in the repository",
 	Long: `
 The "list" command allows listing objects in the repository based on type.
@@ -58,6 +58,8 @@ func runList(ctx context.Context, cmd *cobra.Command, gopts GlobalOptions, args
 		t = restic.IndexFile
 	case "snapshots":
 		t = restic.SnapshotFile
+	case "snapshot-index":
+		t = restic.SnapshotIndexFile
 	case "keys":
 		t = restic.KeyFile
 	case "locks":
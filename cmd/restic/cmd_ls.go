@@ -34,6 +34,10 @@ will allow traversing into matching directories' subfolders.
 Any directory paths specified must be absolute (starting with
 a path separator); paths use the forward slash '/' as separator.
 
+The --newer-than and --older-than options only list entries whose
+modification time is within the given range. Both accept either a
+date/time or a duration like "1d2h", which is relative to now.
+
 EXIT STATUS
 ===========
 
@@ -51,6 +55,9 @@ type LsOptions struct {
 	restic.SnapshotFilter
 	Recursive     bool
 	HumanReadable bool
+	NewerThan     string
+	OlderThan     string
+	PathMap       []string
 }
 
 var lsOptions LsOptions
@@ -63,6 +70,9 @@ func init() {
 	flags.BoolVarP(&lsOptions.ListLong, "long", "l", false, "use a long listing format showing size and mode")
 	flags.BoolVar(&lsOptions.Recursive, "recursive", false, "include files in subfolders of the listed directories")
 	flags.BoolVar(&lsOptions.HumanReadable, "human-readable", false, "print sizes in human readable format")
+	flags.StringVar(&lsOptions.NewerThan, "newer-than", "", "only list entries modified after `time`, a date/time or a duration (eg. 1d2h) before now")
+	flags.StringVar(&lsOptions.OlderThan, "older-than", "", "only list entries modified before `time`, a date/time or a duration (eg. 1d2h) before now")
+	initPathMap(flags, &lsOptions.PathMap)
 }
 
 type lsSnapshot struct {
@@ -86,7 +96,9 @@ func lsNodeJSON(enc *json.Encoder, path string, node *restic.Node) error {
 		ModTime     time.Time   `json:"mtime,omitempty"`
 		AccessTime  time.Time   `json:"atime,omitempty"`
 		ChangeTime  time.Time   `json:"ctime,omitempty"`
+		BirthTime   *time.Time  `json:"btime,omitempty"`
 		Inode       uint64      `json:"inode,omitempty"`
+		Content     restic.IDs  `json:"content,omitempty"`
 		StructType  string      `json:"struct_type"` // "node"
 
 		size uint64 // Target for Size pointer.
@@ -105,10 +117,14 @@ func lsNodeJSON(enc *json.Encoder, path string, node *restic.Node) error {
 		Inode:       node.Inode,
 		StructType:  "node",
 	}
+	if !node.BirthTime.IsZero() {
+		n.BirthTime = &node.BirthTime
+	}
 	// Always print size for regular files, even when empty,
 	// but never for other types.
 	if node.Type == "file" {
 		n.Size = &n.size
+		n.Content = node.Content
 	}
 
 	return enc.Encode(n)
@@ -130,6 +146,26 @@ func runLs(ctx context.Context, opts LsOptions, gopts GlobalOptions, args []stri
 		}
 	}
 
+	oldest, newest, err := parseModTimeRange(opts.NewerThan, opts.OlderThan)
+	if err != nil {
+		return err
+	}
+
+	pathMap, err := parsePathMap(opts.PathMap)
+	if err != nil {
+		return err
+	}
+
+	withinTimeRange := func(node *restic.Node) bool {
+		if !oldest.IsZero() && node.ModTime.Before(oldest) {
+			return false
+		}
+		if !newest.IsZero() && node.ModTime.After(newest) {
+			return false
+		}
+		return true
+	}
+
 	withinDir := func(nodepath string) bool {
 		if len(dirs) == 0 {
 			return true
@@ -228,6 +264,7 @@ func runLs(ctx context.Context, opts LsOptions, gopts GlobalOptions, args []stri
 		return err
 	}
 
+	sn.Paths = pathMap.ApplyAll(sn.Paths)
 	printSnapshot(sn)
 
 	err = walker.Walk(ctx, repo, *sn.Tree, nil, func(_ restic.ID, nodepath string, node *restic.Node, err error) (bool, error) {
@@ -239,8 +276,11 @@ func runLs(ctx context.Context, opts LsOptions, gopts GlobalOptions, args []stri
 		}
 
 		if withinDir(nodepath) {
-			// if we're within a dir, print the node
-			printNode(nodepath, node)
+			// if we're within a dir, print the node, unless it was modified
+			// outside of the selected time range
+			if withinTimeRange(node) {
+				printNode(pathMap.Apply(nodepath), node)
+			}
 
 			// if recursive listing is requested, signal the walker that it
 			// should continue walking recursively
@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	rtest "github.com/restic/restic/internal/test"
 )
@@ -17,3 +20,59 @@ func testRunLs(t testing.TB, gopts GlobalOptions, snapshotID string) []string {
 	rtest.OK(t, err)
 	return strings.Split(buf.String(), "\n")
 }
+
+func TestLsModTimeRange(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	now := time.Now()
+	for name, mtime := range map[string]time.Time{
+		"old.log":    now.AddDate(0, 0, -10),
+		"recent.log": now.Add(-time.Hour),
+		"recent.txt": now.Add(-2 * time.Hour),
+	} {
+		filename := filepath.Join(env.testdata, name)
+		rtest.OK(t, os.WriteFile(filename, []byte(name), 0644))
+		rtest.OK(t, os.Chtimes(filename, mtime, mtime))
+	}
+	testRunBackup(t, env.testdata, []string{"."}, BackupOptions{}, env.gopts)
+
+	ls := func(opts LsOptions) []string {
+		buf, err := withCaptureStdout(func() error {
+			gopts := env.gopts
+			gopts.Quiet = true
+			return runLs(context.TODO(), opts, gopts, []string{"latest"})
+		})
+		rtest.OK(t, err)
+
+		var files []string
+		for _, line := range strings.Split(buf.String(), "\n") {
+			if strings.HasSuffix(line, ".log") || strings.HasSuffix(line, ".txt") {
+				files = append(files, line)
+			}
+		}
+		return files
+	}
+
+	rtest.Equals(t, []string{"/old.log", "/recent.log", "/recent.txt"}, ls(LsOptions{}))
+	rtest.Equals(t, []string{"/recent.log", "/recent.txt"}, ls(LsOptions{NewerThan: "1d"}))
+	rtest.Equals(t, []string{"/old.log"}, ls(LsOptions{OlderThan: "1d"}))
+	// note that "m" stands for months in durations, use a date/time instead
+	olderThan := now.Add(-90 * time.Minute).Format("2006-01-02 15:04:05")
+	rtest.Equals(t, []string{"/recent.txt"}, ls(LsOptions{NewerThan: "3h", OlderThan: olderThan}))
+
+	// find combines the time range with the patterns
+	buf, err := withCaptureStdout(func() error {
+		opts := FindOptions{NewerThan: "1d"}
+		return runFind(context.TODO(), opts, env.gopts, []string{"*.log"})
+	})
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(buf.String(), "/recent.log"), "missing recent.log in output: %v", buf.String())
+	rtest.Assert(t, !strings.Contains(buf.String(), "/old.log") && !strings.Contains(buf.String(), "/recent.txt"),
+		"unexpected files in output: %v", buf.String())
+
+	err = runLs(context.TODO(), LsOptions{NewerThan: "yesterday"}, env.gopts, []string{"latest"})
+	rtest.Assert(t, err != nil, "expected error for invalid --newer-than value")
+}
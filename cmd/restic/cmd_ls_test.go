@@ -52,6 +52,18 @@ func TestLsNodeJSON(t *testing.T) {
 			expect: `{"name":"empty","type":"file","path":"/foo/empty","uid":1001,"gid":1001,"size":0,"permissions":"----------","mtime":"0001-01-01T00:00:00Z","atime":"0001-01-01T00:00:00Z","ctime":"0001-01-01T00:00:00Z","struct_type":"node"}`,
 		},
 
+		// Files list the IDs of their data blobs.
+		{
+			path: "/foo/data",
+			Node: restic.Node{
+				Name:    "data",
+				Type:    "file",
+				Size:    42,
+				Content: restic.IDs{restic.Hash([]byte("foo"))},
+			},
+			expect: `{"name":"data","type":"file","path":"/foo/data","uid":0,"gid":0,"size":42,"permissions":"----------","mtime":"0001-01-01T00:00:00Z","atime":"0001-01-01T00:00:00Z","ctime":"0001-01-01T00:00:00Z","content":["2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"],"struct_type":"node"}`,
+		},
+
 		// Non-regular files do not get a size.
 		// Mode is printed in decimal, including the type bits.
 		{
@@ -7,6 +7,7 @@ import (
 	"context"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -34,7 +35,10 @@ Snapshot Directories
 
 If you need a different template for directories that contain snapshots,
 you can pass a time template via --time-template and path templates via
---path-template.
+--path-template. The option --snapshot-template overrides --time-template
+and additionally replaces the following patterns:
+    %host by hostname
+    %tag by the comma-separated tags
 
 Example time template without colons:
 
@@ -55,6 +59,9 @@ For path templates, you can use the following patterns which will be replaced:
     %t by tags
     %T by timestamp as specified by --time-template
 
+If several snapshots map to the same path, the short snapshot ID is appended
+to make the path unique.
+
 The default path templates are:
     "ids/%i"
     "snapshots/%T"
@@ -78,8 +85,9 @@ type MountOptions struct {
 	AllowOther           bool
 	NoDefaultPermissions bool
 	restic.SnapshotFilter
-	TimeTemplate  string
-	PathTemplates []string
+	TimeTemplate     string
+	SnapshotTemplate string
+	PathTemplates    []string
 }
 
 var mountOptions MountOptions
@@ -95,12 +103,15 @@ func init() {
 	initMultiSnapshotFilter(mountFlags, &mountOptions.SnapshotFilter, true)
 
 	mountFlags.StringArrayVar(&mountOptions.PathTemplates, "path-template", nil, "set `template` for path names (can be specified multiple times)")
-	mountFlags.StringVar(&mountOptions.TimeTemplate, "snapshot-template", time.RFC3339, "set `template` to use for snapshot dirs")
+	mountFlags.StringVar(&mountOptions.SnapshotTemplate, "snapshot-template", "", "set `template` to use for snapshot dirs, supports %host and %tag (overrides --time-template)")
 	mountFlags.StringVar(&mountOptions.TimeTemplate, "time-template", time.RFC3339, "set `template` to use for times")
-	_ = mountFlags.MarkDeprecated("snapshot-template", "use --time-template")
 }
 
 func runMount(ctx context.Context, opts MountOptions, gopts GlobalOptions, args []string) error {
+	if opts.SnapshotTemplate != "" {
+		opts.TimeTemplate = opts.SnapshotTemplate
+	}
+
 	if opts.TimeTemplate == "" {
 		return errors.Fatal("time template string cannot be empty")
 	}
@@ -160,12 +171,19 @@ func runMount(ctx context.Context, opts MountOptions, gopts GlobalOptions, args
 		}
 	}
 
+	var umountOnce sync.Once
+	umountMountpoint := func() {
+		umountOnce.Do(func() {
+			err := umount(mountpoint)
+			if err != nil {
+				Warnf("unable to umount (maybe already umounted or still in use?): %v\n", err)
+			}
+		})
+	}
+
 	AddCleanupHandler(func(code int) (int, error) {
 		debug.Log("running umount cleanup handler for mount at %v", mountpoint)
-		err := umount(mountpoint)
-		if err != nil {
-			Warnf("unable to umount (maybe already umounted or still in use?): %v\n", err)
-		}
+		umountMountpoint()
 		// replace error code of sigint
 		if code == 130 {
 			code = 0
@@ -194,6 +212,18 @@ func runMount(ctx context.Context, opts MountOptions, gopts GlobalOptions, args
 	Printf("Use another terminal or tool to browse the contents of this folder.\n")
 	Printf("When finished, quit with Ctrl-c here or umount the mountpoint.\n")
 
+	// unmount when the command is interrupted, this lets fs.Serve return
+	serveDone := make(chan struct{})
+	defer close(serveDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			debug.Log("context cancelled, unmounting %v", mountpoint)
+			umountMountpoint()
+		case <-serveDone:
+		}
+	}()
+
 	debug.Log("serving mount at %v", mountpoint)
 	err = fs.Serve(c, root)
 	if err != nil {
@@ -2,12 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"math"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/index"
@@ -15,6 +21,7 @@ import (
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
 
 	"github.com/spf13/cobra"
 )
@@ -45,18 +52,26 @@ Exit status is 0 if the command was successful, and non-zero if there was any er
 type PruneOptions struct {
 	DryRun                bool
 	UnsafeNoSpaceRecovery string
+	CacheOnly             bool
+	CompactIndex          bool
 
 	unsafeRecovery bool
 
 	MaxUnused      string
 	maxUnusedBytes func(used uint64) (unused uint64) // calculates the number of unused bytes after repacking, according to MaxUnused
 
+	MaxUnusedPercent float64
+
 	MaxRepackSize  string
 	MaxRepackBytes uint64
 
 	RepackCachableOnly bool
 	RepackSmall        bool
 	RepackUncompressed bool
+
+	KeepRecentlyCreatedPacks time.Duration
+
+	NoCacheCleanup bool
 }
 
 var pruneOptions PruneOptions
@@ -65,6 +80,8 @@ func init() {
 	cmdRoot.AddCommand(cmdPrune)
 	f := cmdPrune.Flags()
 	f.BoolVarP(&pruneOptions.DryRun, "dry-run", "n", false, "do not modify the repository, just print what would be done")
+	f.BoolVar(&pruneOptions.CacheOnly, "cache-only", false, "reconcile the index with the pack files in the repository before pruning, e.g. after an interrupted prune")
+	f.BoolVar(&pruneOptions.CompactIndex, "compact-index", false, "merge all index files into as few index files as possible")
 	f.StringVarP(&pruneOptions.UnsafeNoSpaceRecovery, "unsafe-recover-no-free-space", "", "", "UNSAFE, READ THE DOCUMENTATION BEFORE USING! Try to recover a repository stuck with no free space. Do not use without trying out 'prune --max-repack-size 0' first.")
 	addPruneOptions(cmdPrune)
 }
@@ -72,10 +89,13 @@ func init() {
 func addPruneOptions(c *cobra.Command) {
 	f := c.Flags()
 	f.StringVar(&pruneOptions.MaxUnused, "max-unused", "5%", "tolerate given `limit` of unused data (absolute value in bytes with suffixes k/K, m/M, g/G, t/T, a value in % or the word 'unlimited')")
+	f.Float64Var(&pruneOptions.MaxUnusedPercent, "max-unused-percent", 0, "skip pruning if less than `percent` of the repository is unused")
 	f.StringVar(&pruneOptions.MaxRepackSize, "max-repack-size", "", "maximum `size` to repack (allowed suffixes: k/K, m/M, g/G, t/T)")
 	f.BoolVar(&pruneOptions.RepackCachableOnly, "repack-cacheable-only", false, "only repack packs which are cacheable")
 	f.BoolVar(&pruneOptions.RepackSmall, "repack-small", false, "repack pack files below 80% of target pack size")
 	f.BoolVar(&pruneOptions.RepackUncompressed, "repack-uncompressed", false, "repack all uncompressed data")
+	f.DurationVar(&pruneOptions.KeepRecentlyCreatedPacks, "keep-recently-created-packs", 0, "do not repack pack files created within the given `duration` (e.g. 1h)")
+	f.BoolVar(&pruneOptions.NoCacheCleanup, "no-cache-cleanup", false, "do not remove the pack and index files deleted by prune from the local cache")
 }
 
 func verifyPruneOptions(opts *PruneOptions) error {
@@ -92,6 +112,18 @@ func verifyPruneOptions(opts *PruneOptions) error {
 		opts.MaxRepackBytes = 0
 	}
 
+	if opts.KeepRecentlyCreatedPacks < 0 {
+		return errors.Fatal("--keep-recently-created-packs must not be negative")
+	}
+
+	if opts.MaxUnusedPercent < 0 || opts.MaxUnusedPercent >= 100 {
+		return errors.Fatal("--max-unused-percent must be at least 0 and below 100")
+	}
+	if opts.MaxUnusedPercent > 0 && (opts.CompactIndex || opts.UnsafeNoSpaceRecovery != "") {
+		// skipping the prune run would also skip rewriting the index
+		return errors.Fatal("--max-unused-percent cannot be combined with --compact-index or --unsafe-recover-no-free-space")
+	}
+
 	maxUnused := strings.TrimSpace(opts.MaxUnused)
 	if maxUnused == "" {
 		return errors.Fatalf("invalid value for --max-unused: %q", opts.MaxUnused)
@@ -143,6 +175,14 @@ func runPrune(ctx context.Context, opts PruneOptions, gopts GlobalOptions) error
 		return err
 	}
 
+	if opts.CacheOnly && (opts.DryRun || gopts.JSON) {
+		return errors.Fatal("--cache-only cannot be combined with --dry-run or --json")
+	}
+
+	if opts.CompactIndex && opts.UnsafeNoSpaceRecovery != "" {
+		return errors.Fatal("--compact-index cannot be combined with --unsafe-recover-no-free-space")
+	}
+
 	if opts.RepackUncompressed && gopts.Compression == repository.CompressionOff {
 		return errors.Fatal("disabled compression and `--repack-uncompressed` are mutually exclusive")
 	}
@@ -182,10 +222,29 @@ func runPruneWithRepo(ctx context.Context, opts PruneOptions, gopts GlobalOption
 	repo.DisableAutoIndexUpdate()
 
 	if repo.Cache == nil {
-		Print("warning: running prune without a cache, this may be very slow!\n")
+		Warnf("warning: running prune without a cache, this may be very slow!\n")
 	}
 
-	Verbosef("loading indexes...\n")
+	if opts.CacheOnly {
+		// The index files are read from the local cache if possible. Only
+		// pack files which are missing from the index or whose size does not
+		// match are read from the repository.
+		Verbosef("reconciling index with pack files in the repository...\n")
+		err := rebuildIndex(ctx, RepairIndexOptions{}, gopts, repo)
+		if err != nil {
+			return err
+		}
+
+		// LoadIndex adds to the in-memory index, thus start from scratch
+		err = repo.SetIndex(index.NewMasterIndex())
+		if err != nil {
+			return err
+		}
+	}
+
+	if !gopts.JSON {
+		Verbosef("loading indexes...\n")
+	}
 	// loading the index before the snapshots is ok, as we use an exclusive lock here
 	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
 	err := repo.LoadIndex(ctx, bar)
@@ -193,18 +252,40 @@ func runPruneWithRepo(ctx context.Context, opts PruneOptions, gopts GlobalOption
 		return err
 	}
 
-	plan, stats, err := planPrune(ctx, opts, repo, ignoreSnapshots, gopts.Quiet)
+	plan, stats, err := planPrune(ctx, opts, repo, ignoreSnapshots, gopts)
 	if err != nil {
 		return err
 	}
 
-	if opts.DryRun {
-		Verbosef("\nWould have made the following changes:")
+	if opts.MaxUnusedPercent > 0 {
+		unused := stats.unusedPercent()
+		if unused < opts.MaxUnusedPercent {
+			// the caller releases the lock as soon as we return
+			if gopts.JSON {
+				return printPrunePlanJSON(globalOptions.stdout, plan, stats, opts.DryRun, true)
+			}
+			Printf("unused data is %.2f%% of the repository, below --max-unused-percent %v%%: nothing worth pruning\n", unused, opts.MaxUnusedPercent)
+			return nil
+		}
+		if !gopts.JSON {
+			Verbosef("unused data is %.2f%% of the repository\n", unused)
+		}
 	}
 
-	err = printPruneStats(stats)
-	if err != nil {
-		return err
+	if gopts.JSON {
+		err = printPrunePlanJSON(globalOptions.stdout, plan, stats, opts.DryRun, false)
+		if err != nil {
+			return err
+		}
+	} else {
+		if opts.DryRun {
+			Verbosef("\nWould have made the following changes:")
+		}
+
+		err = printPruneStats(stats)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Trigger GC to reset garbage collection threshold
@@ -221,6 +302,7 @@ type pruneStats struct {
 		remove    uint
 		repack    uint
 		repackrm  uint
+		forgotten uint
 	}
 	size struct {
 		used         uint64
@@ -231,15 +313,18 @@ type pruneStats struct {
 		repackrm     uint64
 		unref        uint64
 		uncompressed uint64
+		forgotten    uint64
 	}
 	packs struct {
-		used       uint
-		unused     uint
-		partlyUsed uint
-		unref      uint
-		keep       uint
-		repack     uint
-		remove     uint
+		used        uint
+		unused      uint
+		partlyUsed  uint
+		unref       uint
+		keep        uint
+		repack      uint
+		repackSmall uint
+		recent      uint
+		remove      uint
 	}
 }
 
@@ -249,6 +334,8 @@ type prunePlan struct {
 	keepBlobs        restic.CountedBlobSet // blobs to keep during repacking
 	removePacks      restic.IDSet          // packs to remove
 	ignorePacks      restic.IDSet          // packs to ignore when rebuilding the index
+
+	packSizes map[restic.ID]packInfo // used and unused size of all packs to remove or repack
 }
 
 type packInfo struct {
@@ -264,26 +351,39 @@ type packInfoWithID struct {
 	ID restic.ID
 	packInfo
 	mustCompress bool
+	// small is set for packs that are only repacked to consolidate them
+	small bool
 }
 
 // planPrune selects which files to rewrite and which to delete and which blobs to keep.
 // Also some summary statistics are returned.
-func planPrune(ctx context.Context, opts PruneOptions, repo restic.Repository, ignoreSnapshots restic.IDSet, quiet bool) (prunePlan, pruneStats, error) {
+func planPrune(ctx context.Context, opts PruneOptions, repo restic.Repository, ignoreSnapshots restic.IDSet, gopts GlobalOptions) (prunePlan, pruneStats, error) {
 	var stats pruneStats
 
-	usedBlobs, err := getUsedBlobs(ctx, repo, ignoreSnapshots, quiet)
+	usedBlobs, err := getUsedBlobs(ctx, repo, ignoreSnapshots, gopts)
 	if err != nil {
 		return prunePlan{}, stats, err
 	}
 
-	Verbosef("searching used packs...\n")
+	if opts.DryRun && len(ignoreSnapshots) > 0 {
+		err = findForgottenBlobs(ctx, repo, ignoreSnapshots, usedBlobs, &stats)
+		if err != nil {
+			return prunePlan{}, stats, err
+		}
+	}
+
+	if !gopts.JSON {
+		Verbosef("searching used packs...\n")
+	}
 	keepBlobs, indexPack, err := packInfoFromIndex(ctx, repo.Index(), usedBlobs, &stats)
 	if err != nil {
 		return prunePlan{}, stats, err
 	}
 
-	Verbosef("collecting packs for deletion and repacking\n")
-	plan, err := decidePackAction(ctx, opts, repo, indexPack, &stats, quiet)
+	if !gopts.JSON {
+		Verbosef("collecting packs for deletion and repacking\n")
+	}
+	plan, err := decidePackAction(ctx, opts, repo, indexPack, &stats, gopts)
 	if err != nil {
 		return prunePlan{}, stats, err
 	}
@@ -462,10 +562,12 @@ func packInfoFromIndex(ctx context.Context, idx restic.MasterIndex, usedBlobs re
 	return usedBlobs, indexPack, nil
 }
 
-func decidePackAction(ctx context.Context, opts PruneOptions, repo restic.Repository, indexPack map[restic.ID]packInfo, stats *pruneStats, quiet bool) (prunePlan, error) {
+func decidePackAction(ctx context.Context, opts PruneOptions, repo restic.Repository, indexPack map[restic.ID]packInfo, stats *pruneStats, gopts GlobalOptions) (prunePlan, error) {
 	removePacksFirst := restic.NewIDSet()
 	removePacks := restic.NewIDSet()
 	repackPacks := restic.NewIDSet()
+	// size information for the selected packs, used for the JSON output
+	packSizes := make(map[restic.ID]packInfo)
 
 	var repackCandidates []packInfoWithID
 	var repackSmallCandidates []packInfoWithID
@@ -477,14 +579,31 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo restic.Reposi
 		targetPackSize = repo.PackSize() / 5 * 4
 	}
 
+	// packs modified after this time are not repacked. The modification time
+	// is unknown for some backends, such packs are treated as old.
+	var recentCutoff time.Time
+	if opts.KeepRecentlyCreatedPacks > 0 {
+		recentCutoff = time.Now().Add(-opts.KeepRecentlyCreatedPacks)
+	}
+
 	// loop over all packs and decide what to do
-	bar := newProgressMax(!quiet, uint64(len(indexPack)), "packs processed")
-	err := repo.List(ctx, restic.PackFile, func(id restic.ID, packSize int64) error {
+	bar := newPruneProgress(gopts, "scan_packs", uint64(len(indexPack)), "packs processed", nil)
+	err := repo.Backend().List(ctx, restic.PackFile, func(fi backend.FileInfo) error {
+		id, err := restic.ParseID(fi.Name)
+		if err != nil {
+			debug.Log("unable to parse %v as an ID", fi.Name)
+			return nil
+		}
+		packSize := fi.Size
+
 		p, ok := indexPack[id]
 		if !ok {
 			// Pack was not referenced in index and is not used  => immediately remove!
-			Verboseff("will remove pack %v as it is unused and not indexed\n", id.Str())
+			if !gopts.JSON {
+				Verboseff("will remove pack %v as it is unused and not indexed\n", id.Str())
+			}
 			removePacksFirst.Insert(id)
+			packSizes[id] = packInfo{unusedSize: uint64(packSize)}
 			stats.size.unref += uint64(packSize)
 			return nil
 		}
@@ -523,9 +642,15 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo restic.Reposi
 		case p.usedBlobs == 0:
 			// All blobs in pack are no longer used => remove pack!
 			removePacks.Insert(id)
+			packSizes[id] = p
 			stats.blobs.remove += p.unusedBlobs
 			stats.size.remove += p.unusedSize
 
+		case !recentCutoff.IsZero() && fi.ModTime.After(recentCutoff):
+			// pack was created recently, e.g. by a backup running just before => keep pack!
+			stats.packs.keep++
+			stats.packs.recent++
+
 		case opts.RepackCachableOnly && p.tpe == restic.DataBlob:
 			// if this is a data pack and --repack-cacheable-only is set => keep pack!
 			stats.packs.keep++
@@ -535,7 +660,7 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo restic.Reposi
 				// All blobs in pack are used and not mixed => keep pack!
 				stats.packs.keep++
 			} else {
-				repackSmallCandidates = append(repackSmallCandidates, packInfoWithID{ID: id, packInfo: p, mustCompress: mustCompress})
+				repackSmallCandidates = append(repackSmallCandidates, packInfoWithID{ID: id, packInfo: p, mustCompress: mustCompress, small: true})
 			}
 
 		default:
@@ -608,8 +733,12 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo restic.Reposi
 		return pi.unusedSize*pj.usedSize > pj.unusedSize*pi.usedSize
 	})
 
-	repack := func(id restic.ID, p packInfo) {
-		repackPacks.Insert(id)
+	repack := func(p packInfoWithID) {
+		if p.small {
+			stats.packs.repackSmall++
+		}
+		repackPacks.Insert(p.ID)
+		packSizes[p.ID] = p.packInfo
 		stats.blobs.repack += p.unusedBlobs + p.usedBlobs
 		stats.size.repack += p.unusedSize + p.usedSize
 		stats.blobs.repackrm += p.unusedBlobs
@@ -633,14 +762,14 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo restic.Reposi
 
 		case p.tpe != restic.DataBlob, p.mustCompress:
 			// repacking non-data packs / uncompressed-trees is only limited by repackSize
-			repack(p.ID, p.packInfo)
+			repack(p)
 
 		case reachedUnusedSizeAfter && packIsLargeEnough:
 			// for all other packs stop repacking if tolerated unused size is reached.
 			stats.packs.keep++
 
 		default:
-			repack(p.ID, p.packInfo)
+			repack(p)
 		}
 	}
 
@@ -657,9 +786,88 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo restic.Reposi
 		removePacks: removePacks,
 		repackPacks: repackPacks,
 		ignorePacks: ignorePacks,
+		packSizes:   packSizes,
 	}, nil
 }
 
+// prunePackJSON describes a single pack selected for removal or repacking.
+type prunePackJSON struct {
+	ID           restic.ID `json:"id"`
+	UsedBytes    uint64    `json:"used_bytes"`
+	UnusedBytes  uint64    `json:"unused_bytes"`
+	Unreferenced bool      `json:"unreferenced,omitempty"`
+}
+
+// prunePlanJSON is the JSON representation of a prune plan.
+type prunePlanJSON struct {
+	MessageType      string          `json:"message_type"` // "prune_plan"
+	DryRun           bool            `json:"dry_run"`
+	RemovePacks      []prunePackJSON `json:"remove_packs"`
+	RepackPacks      []prunePackJSON `json:"repack_packs"`
+	ReclaimableBytes uint64          `json:"reclaimable_bytes"`
+	RewriteBytes     uint64          `json:"rewrite_bytes"`
+	SmallPacks       uint            `json:"small_packs"`
+	UnusedPercent    float64         `json:"unused_percent"`
+	ForgottenBytes   uint64          `json:"forgotten_bytes,omitempty"`
+	Skipped          bool            `json:"skipped,omitempty"`
+}
+
+func newPrunePacksJSON(ids restic.IDs, sizes map[restic.ID]packInfo, unreferenced bool) []prunePackJSON {
+	packs := make([]prunePackJSON, 0, len(ids))
+	for _, id := range ids {
+		p := sizes[id]
+		packs = append(packs, prunePackJSON{
+			ID:           id,
+			UsedBytes:    p.usedSize,
+			UnusedBytes:  p.unusedSize,
+			Unreferenced: unreferenced,
+		})
+	}
+	return packs
+}
+
+// printPrunePlanJSON prints the packs selected by the plan and the resulting
+// sizes as a single JSON object. If skipped is set, the plan is not executed
+// and thus no packs are listed.
+func printPrunePlanJSON(w io.Writer, plan prunePlan, stats pruneStats, dryRun bool, skipped bool) error {
+	if skipped {
+		return json.NewEncoder(w).Encode(prunePlanJSON{
+			MessageType:    "prune_plan",
+			DryRun:         dryRun,
+			RemovePacks:    []prunePackJSON{},
+			RepackPacks:    []prunePackJSON{},
+			UnusedPercent:  stats.unusedPercent(),
+			ForgottenBytes: stats.size.forgotten,
+			Skipped:        true,
+		})
+	}
+
+	removePacks := newPrunePacksJSON(plan.removePacksFirst.List(), plan.packSizes, true)
+	removePacks = append(removePacks, newPrunePacksJSON(plan.removePacks.List(), plan.packSizes, false)...)
+
+	return json.NewEncoder(w).Encode(prunePlanJSON{
+		MessageType:      "prune_plan",
+		DryRun:           dryRun,
+		RemovePacks:      removePacks,
+		RepackPacks:      newPrunePacksJSON(plan.repackPacks.List(), plan.packSizes, false),
+		ReclaimableBytes: stats.size.remove + stats.size.repackrm + stats.size.unref,
+		RewriteBytes:     stats.size.repack - stats.size.repackrm,
+		SmallPacks:       stats.packs.repackSmall,
+		UnusedPercent:    stats.unusedPercent(),
+		ForgottenBytes:   stats.size.forgotten,
+	})
+}
+
+// unusedPercent returns the percentage of unused data in the repository.
+func (stats pruneStats) unusedPercent() float64 {
+	unusedSize := stats.size.duplicate + stats.size.unused + stats.size.unref
+	totalSize := stats.size.used + unusedSize
+	if totalSize == 0 {
+		return 0
+	}
+	return 100 * float64(unusedSize) / float64(totalSize)
+}
+
 // printPruneStats prints out the statistics
 func printPruneStats(stats pruneStats) error {
 	Verboseff("\nused:         %10d blobs / %s\n", stats.blobs.used, ui.FormatBytes(stats.size.used))
@@ -676,6 +884,9 @@ func printPruneStats(stats pruneStats) error {
 	Verboseff("total:        %10d blobs / %s\n", totalBlobs, ui.FormatBytes(totalSize))
 	Verboseff("unused size: %s of total size\n", ui.FormatPercent(unusedSize, totalSize))
 
+	if stats.blobs.forgotten > 0 {
+		Verbosef("\nforgotten:    %10d blobs / %s only referenced by the removed snapshots\n", stats.blobs.forgotten, ui.FormatBytes(stats.size.forgotten))
+	}
 	Verbosef("\nto repack:    %10d blobs / %s\n", stats.blobs.repack, ui.FormatBytes(stats.size.repack))
 	Verbosef("this removes: %10d blobs / %s\n", stats.blobs.repackrm, ui.FormatBytes(stats.size.repackrm))
 	Verbosef("to delete:    %10d blobs / %s\n", stats.blobs.remove, ui.FormatBytes(stats.size.remove+stats.size.unref))
@@ -694,7 +905,13 @@ func printPruneStats(stats pruneStats) error {
 	Verboseff("unused packs:       %10d\n\n", stats.packs.unused)
 
 	Verboseff("to keep:      %10d packs\n", stats.packs.keep)
+	if stats.packs.recent > 0 {
+		Verboseff("recent packs: %10d packs kept\n", stats.packs.recent)
+	}
 	Verboseff("to repack:    %10d packs\n", stats.packs.repack)
+	if stats.packs.repackSmall > 0 {
+		Verboseff("small packs:  %10d packs consolidated\n", stats.packs.repackSmall)
+	}
 	Verboseff("to delete:    %10d packs\n", stats.packs.remove)
 	if stats.packs.unref > 0 {
 		Verboseff("to delete:    %10d unreferenced packs\n\n", stats.packs.unref)
@@ -704,11 +921,12 @@ func printPruneStats(stats pruneStats) error {
 
 // doPrune does the actual pruning:
 // - remove unreferenced packs first
+// - with --unsafe-recover-no-free-space, also delete the index and all unused packs
 // - repack given pack files while keeping the given blobs
 // - rebuild the index while ignoring all files that will be deleted
 // - delete the files
 // plan.removePacks and plan.ignorePacks are modified in this function.
-func doPrune(ctx context.Context, opts PruneOptions, gopts GlobalOptions, repo restic.Repository, plan prunePlan) (err error) {
+func doPrune(ctx context.Context, opts PruneOptions, gopts GlobalOptions, repo *repository.Repository, plan prunePlan) (err error) {
 	if opts.DryRun {
 		if !gopts.JSON && gopts.verbosity >= 2 {
 			Printf("Repeated prune dry-runs can report slightly different amounts of data to keep or repack. This is expected behavior.\n\n")
@@ -718,20 +936,51 @@ func doPrune(ctx context.Context, opts PruneOptions, gopts GlobalOptions, repo r
 			Printf("Would have repacked and removed the following packs:\n%v\n\n", plan.repackPacks)
 			Printf("Would have removed the following no longer used packs:\n%v\n\n", plan.removePacks)
 		}
+		if opts.CompactIndex && !gopts.JSON {
+			Printf("Would have compacted %d index files\n", len(repo.Index().(*index.MasterIndex).IDs()))
+		}
 		// Always quit here if DryRun was set!
 		return nil
 	}
 
+	// all index files loaded at the start are replaced if the index is rewritten
+	oldIndexes := repo.Index().(*index.MasterIndex).IDs()
+	removedPacks := restic.NewIDSet()
+	removedPacks.Merge(plan.removePacksFirst)
+
 	// unreferenced packs can be safely deleted first
 	if len(plan.removePacksFirst) != 0 {
-		Verbosef("deleting unreferenced packs\n")
+		if !gopts.JSON {
+			Verbosef("deleting unreferenced packs\n")
+		}
 		DeleteFiles(ctx, gopts, repo, plan.removePacksFirst, restic.PackFile)
 	}
 
+	// In recovery mode the index is rewritten from scratch at the end. Thus,
+	// packs which contain no used blobs at all can already be deleted before
+	// repacking, which frees space for the repacked packs. The index files are
+	// only deleted once repacking has succeeded, the index written at the end
+	// no longer contains the deleted packs.
+	if opts.unsafeRecovery && len(plan.removePacks) != 0 {
+		if !gopts.JSON {
+			Verbosef("removing %d unused packs\n", len(plan.removePacks))
+		}
+		DeleteFiles(ctx, gopts, repo, plan.removePacks, restic.PackFile)
+		if plan.ignorePacks == nil {
+			plan.ignorePacks = restic.NewIDSet()
+		}
+		plan.ignorePacks.Merge(plan.removePacks)
+		plan.removePacks = restic.NewIDSet()
+	}
+
 	if len(plan.repackPacks) != 0 {
-		Verbosef("repacking packs\n")
-		bar := newProgressMax(!gopts.Quiet, uint64(len(plan.repackPacks)), "packs repacked")
-		_, err := repository.Repack(ctx, repo, repo, plan.repackPacks, plan.keepBlobs, bar)
+		if !gopts.JSON {
+			Verbosef("repacking packs\n")
+		}
+		// only used to count the bytes, the progress is reported by bar
+		written := &progress.Counter{}
+		bar := newPruneProgress(gopts, "repack", uint64(len(plan.repackPacks)), "packs repacked", written)
+		_, err := repository.Repack(ctx, repo, repo, plan.repackPacks, plan.keepBlobs, bar, written)
 		bar.Done()
 		if err != nil {
 			return errors.Fatal(err.Error())
@@ -759,12 +1008,19 @@ func doPrune(ctx context.Context, opts PruneOptions, gopts GlobalOptions, repo r
 	}
 
 	if opts.unsafeRecovery {
-		Verbosef("deleting index files\n")
+		if !gopts.JSON {
+			Verbosef("deleting index files\n")
+		}
 		indexFiles := repo.Index().(*index.MasterIndex).IDs()
 		err = DeleteFilesChecked(ctx, gopts, repo, indexFiles, restic.IndexFile)
 		if err != nil {
 			return errors.Fatalf("%s", err)
 		}
+	} else if opts.CompactIndex {
+		err = compactIndexFiles(ctx, gopts, repo, plan.ignorePacks)
+		if err != nil {
+			return errors.Fatalf("%s", err)
+		}
 	} else if len(plan.ignorePacks) != 0 {
 		err = rebuildIndexFiles(ctx, gopts, repo, plan.ignorePacks, nil)
 		if err != nil {
@@ -773,7 +1029,9 @@ func doPrune(ctx context.Context, opts PruneOptions, gopts GlobalOptions, repo r
 	}
 
 	if len(plan.removePacks) != 0 {
-		Verbosef("removing %d old packs\n", len(plan.removePacks))
+		if !gopts.JSON {
+			Verbosef("removing %d old packs\n", len(plan.removePacks))
+		}
 		DeleteFiles(ctx, gopts, repo, plan.removePacks, restic.PackFile)
 	}
 
@@ -784,14 +1042,51 @@ func doPrune(ctx context.Context, opts PruneOptions, gopts GlobalOptions, repo r
 		}
 	}
 
-	Verbosef("done\n")
+	if !opts.NoCacheCleanup {
+		removedPacks.Merge(plan.ignorePacks)
+		removedIndexes := restic.NewIDSet()
+		if opts.unsafeRecovery || opts.CompactIndex || len(plan.ignorePacks) != 0 {
+			removedIndexes = oldIndexes
+		}
+		cleanupPruneCache(gopts, repo, removedPacks, removedIndexes)
+	}
+
+	if !gopts.JSON {
+		Verbosef("done\n")
+	}
 	return nil
 }
 
+// cleanupPruneCache removes the pack and index files deleted by prune from the
+// local cache. Usually, they have already been removed along with the files in
+// the repository. The cache may still contain them if the removal failed or
+// the files were removed concurrently, in which case they would be served from
+// the cache although they no longer exist in the repository.
+func cleanupPruneCache(gopts GlobalOptions, repo *repository.Repository, packs, indexes restic.IDSet) {
+	if repo.Cache == nil {
+		return
+	}
+
+	removed := 0
+	for t, ids := range map[restic.FileType]restic.IDSet{restic.PackFile: packs, restic.IndexFile: indexes} {
+		n, err := repo.Cache.Purge(t, ids)
+		removed += n
+		if err != nil {
+			Warnf("unable to remove deleted %v files from the cache: %v\n", t, err)
+		}
+	}
+
+	if removed > 0 && !gopts.JSON {
+		Verbosef("removed %d stale files from the cache\n", removed)
+	}
+}
+
 func writeIndexFiles(ctx context.Context, gopts GlobalOptions, repo restic.Repository, removePacks restic.IDSet, extraObsolete restic.IDs) (restic.IDSet, error) {
-	Verbosef("rebuilding index\n")
+	if !gopts.JSON {
+		Verbosef("rebuilding index\n")
+	}
 
-	bar := newProgressMax(!gopts.Quiet, 0, "packs processed")
+	bar := newProgressMax(!gopts.Quiet && !gopts.JSON, 0, "packs processed")
 	obsoleteIndexes, err := repo.Index().Save(ctx, repo, removePacks, extraObsolete, bar)
 	bar.Done()
 	return obsoleteIndexes, err
@@ -803,13 +1098,137 @@ func rebuildIndexFiles(ctx context.Context, gopts GlobalOptions, repo restic.Rep
 		return err
 	}
 
-	Verbosef("deleting obsolete index files\n")
+	if !gopts.JSON {
+		Verbosef("deleting obsolete index files\n")
+	}
 	return DeleteFilesChecked(ctx, gopts, repo, obsoleteIndexes, restic.IndexFile)
 }
 
-func getUsedBlobs(ctx context.Context, repo restic.Repository, ignoreSnapshots restic.IDSet, quiet bool) (usedBlobs restic.CountedBlobSet, err error) {
+// compactIndexFiles merges all index files into as few index files as
+// possible, leaving out the packs in removePacks. The old index files are only
+// deleted after the new ones have been loaded again and found to contain all
+// packs and blobs of the in-memory index. The index files are not listed again,
+// as a second listing may be inconsistent on some backends.
+func compactIndexFiles(ctx context.Context, gopts GlobalOptions, repo restic.Repository, removePacks restic.IDSet) error {
+	oldSize, err := indexFilesSize(ctx, repo, repo.Index().(*index.MasterIndex).IDs())
+	if err != nil {
+		return err
+	}
+
+	if !gopts.JSON {
+		Verbosef("compacting index\n")
+	}
+	saver := &recordingSaver{SaverUnpacked: repo}
+	bar := newProgressMax(!gopts.Quiet && !gopts.JSON, 0, "packs processed")
+	obsoleteIndexes, err := repo.Index().Save(ctx, saver, removePacks, nil, bar)
+	bar.Done()
+	if err != nil {
+		return err
+	}
+	newIndexes := restic.NewIDSet(saver.ids...)
+
+	if !gopts.JSON {
+		Verbosef("verifying %d new index files\n", len(newIndexes))
+	}
+	err = verifyCompactedIndex(ctx, repo, newIndexes, removePacks)
+	if err != nil {
+		return errors.Errorf("new index files are incomplete, keeping the old index files: %v", err)
+	}
+	newSize, err := indexFilesSize(ctx, repo, newIndexes)
+	if err != nil {
+		return err
+	}
+
+	if !gopts.JSON {
+		Verbosef("deleting obsolete index files\n")
+	}
+	err = DeleteFilesChecked(ctx, gopts, repo, obsoleteIndexes, restic.IndexFile)
+	if err != nil {
+		return err
+	}
+
+	if !gopts.JSON {
+		Printf("compacted %d index files (%s) into %d index files (%s)\n",
+			len(obsoleteIndexes), ui.FormatBytes(oldSize), len(newIndexes), ui.FormatBytes(newSize))
+	}
+	return nil
+}
+
+// recordingSaver records the IDs of all files saved via SaveUnpacked.
+type recordingSaver struct {
+	restic.SaverUnpacked
+
+	m   sync.Mutex
+	ids restic.IDs
+}
+
+func (s *recordingSaver) SaveUnpacked(ctx context.Context, t restic.FileType, buf []byte) (restic.ID, error) {
+	id, err := s.SaverUnpacked.SaveUnpacked(ctx, t, buf)
+	if err == nil {
+		s.m.Lock()
+		s.ids = append(s.ids, id)
+		s.m.Unlock()
+	}
+	return id, err
+}
+
+// indexFilesSize returns the total size of the given index files in the backend.
+func indexFilesSize(ctx context.Context, repo restic.Repository, ids restic.IDSet) (uint64, error) {
+	var size uint64
+	for id := range ids {
+		fi, err := repo.Backend().Stat(ctx, backend.Handle{Type: restic.IndexFile, Name: id.String()})
+		if err != nil {
+			return 0, err
+		}
+		size += uint64(fi.Size)
+	}
+	return size, nil
+}
+
+// verifyCompactedIndex loads the given index files and checks that they
+// contain exactly the packs and blobs of the in-memory index, except for the
+// packs in removePacks.
+func verifyCompactedIndex(ctx context.Context, repo restic.Repository, ids restic.IDSet, removePacks restic.IDSet) error {
+	mi := repo.Index().(*index.MasterIndex)
+	wantPacks := mi.Packs(removePacks)
+	var wantBlobs int
+	mi.Each(ctx, func(pb restic.PackedBlob) {
+		if !removePacks.Has(pb.PackID) {
+			wantBlobs++
+		}
+	})
+
+	packs := restic.NewIDSet()
+	var blobs int
+	for id := range ids {
+		buf, err := repo.LoadUnpacked(ctx, restic.IndexFile, id)
+		if err != nil {
+			return err
+		}
+		idx, _, err := index.DecodeIndex(buf, id)
+		if err != nil {
+			return err
+		}
+		packs.Merge(idx.Packs())
+		idx.Each(ctx, func(restic.PackedBlob) {
+			blobs++
+		})
+	}
+
+	if !packs.Equals(wantPacks) {
+		return errors.Errorf("found %d packs, expected %d", len(packs), len(wantPacks))
+	}
+	if blobs != wantBlobs {
+		return errors.Errorf("found %d blobs, expected %d", blobs, wantBlobs)
+	}
+	return ctx.Err()
+}
+
+func getUsedBlobs(ctx context.Context, repo restic.Repository, ignoreSnapshots restic.IDSet, gopts GlobalOptions) (usedBlobs restic.CountedBlobSet, err error) {
 	var snapshotTrees restic.IDs
-	Verbosef("loading all snapshots...\n")
+	if !gopts.JSON {
+		Verbosef("loading all snapshots...\n")
+	}
 	err = restic.ForAllSnapshots(ctx, repo, repo, ignoreSnapshots,
 		func(id restic.ID, sn *restic.Snapshot, err error) error {
 			if err != nil {
@@ -824,11 +1243,13 @@ func getUsedBlobs(ctx context.Context, repo restic.Repository, ignoreSnapshots r
 		return nil, errors.Fatalf("failed loading snapshot: %v", err)
 	}
 
-	Verbosef("finding data that is still in use for %d snapshots\n", len(snapshotTrees))
+	if !gopts.JSON {
+		Verbosef("finding data that is still in use for %d snapshots\n", len(snapshotTrees))
+	}
 
 	usedBlobs = restic.NewCountedBlobSet()
 
-	bar := newProgressMax(!quiet, uint64(len(snapshotTrees)), "snapshots")
+	bar := newPruneProgress(gopts, "find_used_blobs", uint64(len(snapshotTrees)), "snapshots", nil)
 	defer bar.Done()
 
 	err = restic.FindUsedBlobs(ctx, repo, snapshotTrees, usedBlobs, bar)
@@ -841,3 +1262,107 @@ func getUsedBlobs(ctx context.Context, repo restic.Repository, ignoreSnapshots r
 	}
 	return usedBlobs, nil
 }
+
+// findForgottenBlobs collects the blobs which are only referenced by the
+// snapshots in removedSnapshots, but not by any snapshot in usedBlobs. The
+// snapshots are only ignored in memory, this allows previewing the effect of
+// forget without modifying the repository.
+func findForgottenBlobs(ctx context.Context, repo restic.Repository, removedSnapshots restic.IDSet, usedBlobs restic.CountedBlobSet, stats *pruneStats) error {
+	var trees restic.IDs
+	for id := range removedSnapshots {
+		sn, err := restic.LoadSnapshot(ctx, repo, id)
+		if err != nil {
+			return errors.Fatalf("failed loading snapshot: %v", err)
+		}
+		trees = append(trees, *sn.Tree)
+	}
+
+	// trees which are still in use are skipped as their content is in use, too
+	reachable := usedBlobs.Copy()
+	err := restic.FindUsedBlobs(ctx, repo, trees, reachable, nil)
+	if err != nil {
+		return err
+	}
+
+	for bh := range reachable {
+		if _, ok := usedBlobs[bh]; ok {
+			continue
+		}
+		pbs := repo.Index().Lookup(bh)
+		if len(pbs) == 0 {
+			continue
+		}
+		stats.blobs.forgotten++
+		stats.size.forgotten += uint64(pbs[0].Length)
+	}
+	return nil
+}
+
+// pruneStatus is printed periodically during prune when using --json.
+type pruneStatus struct {
+	MessageType      string  `json:"message_type"` // "status"
+	Phase            string  `json:"phase"`
+	SecondsElapsed   uint64  `json:"seconds_elapsed"`
+	SecondsRemaining uint64  `json:"seconds_remaining,omitempty"`
+	PercentDone      float64 `json:"percent_done"`
+	Done             uint64  `json:"done"`
+	Total            uint64  `json:"total"`
+	BytesRewritten   uint64  `json:"bytes_rewritten,omitempty"`
+}
+
+// newPruneProgress returns a progress counter for a phase of prune. Besides
+// the number of processed items it reports the estimated remaining time and,
+// if written is set, the amount of data rewritten so far. With --json, the
+// progress is printed to stderr as status messages.
+func newPruneProgress(gopts GlobalOptions, phase string, max uint64, description string, written *progress.Counter) *progress.Counter {
+	if gopts.Quiet {
+		return nil
+	}
+
+	rewritten := func() uint64 {
+		if written == nil {
+			return 0
+		}
+		v, _ := written.Get()
+		return v
+	}
+
+	if !gopts.JSON {
+		return newGenericProgressMax(true, max, description, printProgress, func(v uint64, max uint64, d time.Duration, final bool) string {
+			var extra string
+			if written != nil {
+				extra += fmt.Sprintf(", %s rewritten", ui.FormatBytes(rewritten()))
+			}
+			if remaining := remainingTime(v, max, d); remaining > 0 && !final {
+				extra += fmt.Sprintf(", ETA %s", ui.FormatDuration(remaining))
+			}
+			return extra
+		})
+	}
+
+	interval := calculateProgressInterval(true, true)
+	return progress.NewCounter(interval, max, func(v uint64, max uint64, d time.Duration, final bool) {
+		status := pruneStatus{
+			MessageType:      "status",
+			Phase:            phase,
+			SecondsElapsed:   uint64(d / time.Second),
+			SecondsRemaining: uint64(remainingTime(v, max, d) / time.Second),
+			Done:             v,
+			Total:            max,
+			BytesRewritten:   rewritten(),
+		}
+		if max > 0 {
+			status.PercentDone = float64(v) / float64(max)
+		}
+		_ = json.NewEncoder(gopts.stderr).Encode(status)
+	})
+}
+
+// remainingTime estimates the time needed to process the remaining items
+// based on the time d spent for the first v items.
+func remainingTime(v uint64, max uint64, d time.Duration) time.Duration {
+	if v == 0 || v >= max {
+		return 0
+	}
+	return time.Duration(float64(d) / float64(v) * float64(max-v))
+}
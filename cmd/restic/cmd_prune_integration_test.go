@@ -1,12 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 )
 
@@ -105,6 +113,139 @@ func testPrune(t *testing.T, pruneOpts PruneOptions, checkOpts CheckOptions) {
 	rtest.OK(t, runCheck(context.TODO(), checkOpts, env.gopts, nil))
 }
 
+// packSaveErrorBackend fails to save pack files.
+type packSaveErrorBackend struct {
+	backend.Backend
+}
+
+func (be *packSaveErrorBackend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	if h.Type == restic.PackFile {
+		return errors.New("pack file cannot be saved")
+	}
+	return be.Backend.Save(ctx, h, rd)
+}
+
+func TestPruneUnsafeRecoveryRepackError(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	createPrunableRepo(t, env)
+	oldIndexes := restic.NewIDSet(testRunList(t, "index", env.gopts)...)
+
+	gopts := env.gopts
+	gopts.backendTestHook = func(r backend.Backend) (backend.Backend, error) {
+		return newListOnceBackend(&packSaveErrorBackend{r}), nil
+	}
+	opts := PruneOptions{MaxUnused: "0%", unsafeRecovery: true}
+	err := runPrune(context.TODO(), opts, gopts)
+	rtest.Assert(t, err != nil, "expected prune to fail")
+
+	// the index files are kept if repacking fails
+	rtest.Equals(t, oldIndexes, restic.NewIDSet(testRunList(t, "index", env.gopts)...))
+
+	// the entries of the already deleted packs are removed by the next prune
+	testRunPrune(t, env.gopts, opts)
+	testRunCheck(t, env.gopts)
+}
+
+func TestPruneDryRunJSON(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	createPrunableRepo(t, env)
+	oldPacks := listPacks(env.gopts, t)
+
+	// skipping would also skip compacting the index
+	err := runPrune(context.TODO(), PruneOptions{MaxUnused: "5%", MaxUnusedPercent: 10, CompactIndex: true}, env.gopts)
+	rtest.Assert(t, err != nil, "expected an error for --max-unused-percent with --compact-index")
+
+	buf, err := withCaptureStdout(func() error {
+		gopts := env.gopts
+		gopts.JSON = true
+		gopts.backendTestHook = func(r backend.Backend) (backend.Backend, error) { return newListOnceBackend(r), nil }
+		opts := PruneOptions{MaxUnused: "0%", DryRun: true}
+		return runPrune(context.TODO(), opts, gopts)
+	})
+	rtest.OK(t, err)
+
+	var plan prunePlanJSON
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &plan))
+	rtest.Equals(t, "prune_plan", plan.MessageType)
+	rtest.Assert(t, plan.DryRun, "expected dry_run to be set")
+	rtest.Assert(t, len(plan.RemovePacks)+len(plan.RepackPacks) > 0, "expected packs to be selected for pruning")
+	rtest.Assert(t, plan.ReclaimableBytes > 0, "expected reclaimable bytes, got %v", plan.ReclaimableBytes)
+
+	var unused uint64
+	for _, p := range append(plan.RemovePacks, plan.RepackPacks...) {
+		rtest.Assert(t, oldPacks.Has(p.ID), "pack %v not found in repository", p.ID)
+		unused += p.UnusedBytes
+	}
+	rtest.Equals(t, plan.ReclaimableBytes, unused)
+
+	// a dry run must not modify the repository
+	rtest.Equals(t, oldPacks, listPacks(env.gopts, t))
+}
+
+func TestPruneRepackSmall(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	// every backup of a small file creates a new small data pack
+	for i := 0; i < 12; i++ {
+		filename := filepath.Join(env.testdata, fmt.Sprintf("file%d", i))
+		rtest.OK(t, appendRandomData(filename, 1000))
+		testRunBackup(t, "", []string{filename}, BackupOptions{}, env.gopts)
+	}
+	oldPacks := listPacks(env.gopts, t)
+
+	buf, err := withCaptureStdout(func() error {
+		gopts := env.gopts
+		gopts.JSON = true
+		gopts.backendTestHook = func(r backend.Backend) (backend.Backend, error) { return newListOnceBackend(r), nil }
+		opts := PruneOptions{MaxUnused: "unlimited", RepackSmall: true, DryRun: true}
+		return runPrune(context.TODO(), opts, gopts)
+	})
+	rtest.OK(t, err)
+
+	var plan prunePlanJSON
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &plan))
+	rtest.Equals(t, uint(len(oldPacks)), plan.SmallPacks)
+	rtest.Equals(t, len(oldPacks), len(plan.RepackPacks))
+	rtest.Equals(t, uint64(0), plan.ReclaimableBytes)
+
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "unlimited", RepackSmall: true})
+	newPacks := listPacks(env.gopts, t)
+	rtest.Assert(t, len(newPacks) < len(oldPacks),
+		"expected fewer packs after consolidation, got %v, had %v", len(newPacks), len(oldPacks))
+	testRunCheck(t, env.gopts)
+}
+
+func TestPruneKeepRecentlyCreatedPacks(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	for i := 0; i < 12; i++ {
+		filename := filepath.Join(env.testdata, fmt.Sprintf("file%d", i))
+		rtest.OK(t, appendRandomData(filename, 1000))
+		testRunBackup(t, "", []string{filename}, BackupOptions{}, env.gopts)
+	}
+	oldPacks := listPacks(env.gopts, t)
+
+	// all packs were just created and thus must not be repacked
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "unlimited", RepackSmall: true, KeepRecentlyCreatedPacks: time.Hour})
+	rtest.Equals(t, oldPacks, listPacks(env.gopts, t))
+
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "unlimited", RepackSmall: true, KeepRecentlyCreatedPacks: time.Nanosecond})
+	newPacks := listPacks(env.gopts, t)
+	rtest.Assert(t, len(newPacks) < len(oldPacks),
+		"expected fewer packs after consolidation, got %v, had %v", len(newPacks), len(oldPacks))
+	testRunCheck(t, env.gopts)
+}
+
 var pruneDefaultOptions = PruneOptions{MaxUnused: "5%"}
 
 func TestPruneWithDamagedRepository(t *testing.T) {
@@ -219,3 +360,184 @@ func testEdgeCaseRepo(t *testing.T, tarfile string, optionsCheck CheckOptions, o
 			"prune should have reported an error")
 	}
 }
+
+func TestPruneCacheOnly(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	// the index is loaded before and after reconciling it
+	env.gopts.backendTestHook = nil
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{}
+
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "2")}, opts, env.gopts)
+	firstIndexes := restic.NewIDSet(testRunList(t, "index", env.gopts)...)
+
+	// lose the index of the second backup, as if prune was interrupted
+	// before writing the new index
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "3")}, opts, env.gopts)
+	for _, id := range testRunList(t, "index", env.gopts) {
+		if !firstIndexes.Has(id) {
+			rtest.OK(t, os.Remove(filepath.Join(env.repo, "index", id.String())))
+		}
+	}
+
+	rtest.Assert(t, runPrune(context.TODO(), pruneDefaultOptions, env.gopts) != nil,
+		"expected prune with incomplete index to fail")
+
+	pruneOpts := pruneDefaultOptions
+	pruneOpts.CacheOnly = true
+	rtest.OK(t, runPrune(context.TODO(), pruneOpts, env.gopts))
+	testRunCheck(t, env.gopts)
+}
+
+func TestPruneMaxUnusedPercent(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	createPrunableRepo(t, env)
+	oldPacks := listPacks(env.gopts, t)
+
+	// skipping would also skip compacting the index
+	err := runPrune(context.TODO(), PruneOptions{MaxUnused: "5%", MaxUnusedPercent: 10, CompactIndex: true}, env.gopts)
+	rtest.Assert(t, err != nil, "expected an error for --max-unused-percent with --compact-index")
+
+	buf, err := withCaptureStdout(func() error {
+		gopts := env.gopts
+		gopts.JSON = true
+		gopts.backendTestHook = func(r backend.Backend) (backend.Backend, error) { return newListOnceBackend(r), nil }
+		opts := PruneOptions{MaxUnused: "0%", MaxUnusedPercent: 99.9}
+		return runPrune(context.TODO(), opts, gopts)
+	})
+	rtest.OK(t, err)
+
+	var plan prunePlanJSON
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &plan))
+	rtest.Assert(t, plan.Skipped, "expected prune to be skipped")
+	rtest.Assert(t, plan.UnusedPercent > 0 && plan.UnusedPercent < 99.9, "unexpected unused percentage %v", plan.UnusedPercent)
+	rtest.Equals(t, 0, len(plan.RemovePacks)+len(plan.RepackPacks))
+
+	// skipping must not modify the repository and release the lock
+	rtest.Equals(t, oldPacks, listPacks(env.gopts, t))
+	rtest.OK(t, runCheck(context.TODO(), CheckOptions{}, env.gopts, nil))
+
+	// enough unused data to prune
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "0%", MaxUnusedPercent: plan.UnusedPercent / 2})
+	rtest.Assert(t, !oldPacks.Equals(listPacks(env.gopts, t)), "expected prune to modify the repository")
+	testRunCheck(t, env.gopts)
+}
+
+func TestPruneProgressJSON(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	createPrunableRepo(t, env)
+
+	stderr := &bytes.Buffer{}
+	buf, err := withCaptureStdout(func() error {
+		gopts := env.gopts
+		gopts.JSON = true
+		gopts.Quiet = false
+		gopts.stderr = stderr
+		gopts.backendTestHook = func(r backend.Backend) (backend.Backend, error) { return newListOnceBackend(r), nil }
+		return runPrune(context.TODO(), PruneOptions{MaxUnused: "0%"}, gopts)
+	})
+	rtest.OK(t, err)
+
+	// stdout only contains the prune plan
+	var plan prunePlanJSON
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &plan))
+	rtest.Equals(t, "prune_plan", plan.MessageType)
+
+	// keep the last status message of each phase
+	phases := make(map[string]pruneStatus)
+	for _, line := range strings.Split(strings.TrimSpace(stderr.String()), "\n") {
+		var status pruneStatus
+		rtest.OK(t, json.Unmarshal([]byte(line), &status))
+		rtest.Equals(t, "status", status.MessageType)
+		phases[status.Phase] = status
+	}
+
+	for _, phase := range []string{"find_used_blobs", "scan_packs", "repack"} {
+		status, ok := phases[phase]
+		rtest.Assert(t, ok, "missing status for phase %v", phase)
+		rtest.Equals(t, status.Total, status.Done)
+	}
+	rtest.Assert(t, phases["repack"].BytesRewritten > 0, "expected rewritten bytes, got %v", phases["repack"])
+	testRunCheck(t, env.gopts)
+}
+
+func TestPruneCompactIndex(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{}
+	for _, dir := range []string{"2", "3", "4"} {
+		testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", dir)}, opts, env.gopts)
+	}
+	oldIndexes := restic.NewIDSet(testRunList(t, "index", env.gopts)...)
+	rtest.Equals(t, 3, len(oldIndexes))
+
+	pruneOpts := pruneDefaultOptions
+	pruneOpts.CompactIndex = true
+	output, err := withCaptureStdout(func() error {
+		gopts := env.gopts
+		gopts.Quiet = false
+		testRunPrune(t, gopts, pruneOpts)
+		return nil
+	})
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(output.String(), "compacted 3 index files"), "missing compaction report in output:\n%s", output)
+
+	newIndexes := restic.NewIDSet(testRunList(t, "index", env.gopts)...)
+	rtest.Equals(t, 1, len(newIndexes))
+	rtest.Assert(t, len(newIndexes.Intersect(oldIndexes)) == 0, "old index files were not removed")
+	testRunCheck(t, env.gopts)
+}
+
+// listCachedFiles returns the IDs of all files of type tpe in the cache of repo.
+func listCachedFiles(t *testing.T, repo *repository.Repository, tpe restic.FileType) restic.IDSet {
+	dir := map[restic.FileType]string{restic.IndexFile: "index", restic.PackFile: "data"}[tpe]
+	ids := restic.NewIDSet()
+	rtest.OK(t, filepath.Walk(filepath.Join(repo.Cache.RepoDir(), dir), func(name string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		id, err := restic.ParseID(fi.Name())
+		rtest.OK(t, err)
+		ids.Insert(id)
+		return nil
+	}))
+	return ids
+}
+
+func TestPruneCacheCleanup(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	createPrunableRepo(t, env)
+	pruneOpts := pruneDefaultOptions
+	pruneOpts.MaxUnused = "0%"
+	testRunPrune(t, env.gopts, pruneOpts)
+
+	// the cache only contains files which still exist in the repository
+	repo, err := OpenRepository(context.TODO(), env.gopts)
+	rtest.OK(t, err)
+	for tpe, name := range map[restic.FileType]string{restic.IndexFile: "index", restic.PackFile: "packs"} {
+		valid := restic.NewIDSet(testRunList(t, name, env.gopts)...)
+		cached := listCachedFiles(t, repo, tpe)
+		rtest.Assert(t, len(cached) > 0, "no %v files cached", tpe)
+		for id := range cached {
+			rtest.Assert(t, valid.Has(id), "deleted %v file %v is still cached", tpe, id.Str())
+		}
+	}
+
+	// cached files which were not removed along with those in the repository
+	// are purged afterwards
+	stale := restic.NewRandomID()
+	h := backend.Handle{Type: restic.PackFile, Name: stale.String()}
+	rtest.OK(t, repo.Cache.Save(h, strings.NewReader("stale")))
+	cleanupPruneCache(env.gopts, repo, restic.NewIDSet(stale), restic.NewIDSet())
+	rtest.Assert(t, !repo.Cache.Has(h), "stale pack file is still cached")
+}
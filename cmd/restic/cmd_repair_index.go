@@ -133,12 +133,17 @@ func rebuildIndex(ctx context.Context, opts RepairIndexOptions, gopts GlobalOpti
 	if err != nil {
 		return err
 	}
+	missingPacks := len(packSizeFromIndex)
 	for id := range packSizeFromIndex {
 		// forget pack files that are referenced in the index but do not exist
 		// when rebuilding the index
 		removePacks.Insert(id)
 		Warnf("removing not found pack file %v\n", id)
 	}
+	if !opts.ReadAllPacks {
+		Verbosef("found %d pack files not contained in or mismatching the index and %d missing pack files\n",
+			len(packSizeFromList), missingPacks)
+	}
 
 	if len(packSizeFromList) > 0 {
 		Verbosef("reading pack files\n")
@@ -93,6 +93,9 @@ func runRepairSnapshots(ctx context.Context, gopts GlobalOptions, opts RepairOpt
 		return err
 	}
 
+	// count the damaged items for the summary
+	var damagedDirs, damagedFiles int
+
 	// Three error cases are checked:
 	// - tree is a nil tree (-> will be replaced by an empty tree)
 	// - trees which cannot be loaded (-> the tree contents will be removed)
@@ -117,6 +120,7 @@ func runRepairSnapshots(ctx context.Context, gopts GlobalOptions, opts RepairOpt
 			}
 			if !ok {
 				Verbosef("  file %q: removed missing content\n", path)
+				damagedFiles++
 			} else if newSize != node.Size {
 				Verbosef("  file %q: fixed incorrect size\n", path)
 			}
@@ -126,6 +130,7 @@ func runRepairSnapshots(ctx context.Context, gopts GlobalOptions, opts RepairOpt
 			return node
 		},
 		RewriteFailedTree: func(nodeID restic.ID, path string, _ error) (restic.ID, error) {
+			damagedDirs++
 			if path == "/" {
 				Verbosef("  dir %q: not readable\n", path)
 				// remove snapshots with invalid root node
@@ -158,6 +163,13 @@ func runRepairSnapshots(ctx context.Context, gopts GlobalOptions, opts RepairOpt
 	}
 
 	Verbosef("\n")
+	if damagedDirs > 0 || damagedFiles > 0 {
+		if !opts.DryRun {
+			Verbosef("removed %v damaged directories and missing content from %v files\n", damagedDirs, damagedFiles)
+		} else {
+			Verbosef("would remove %v damaged directories and missing content from %v files\n", damagedDirs, damagedFiles)
+		}
+	}
 	if changedCount == 0 {
 		if !opts.DryRun {
 			Verbosef("no snapshots were modified\n")
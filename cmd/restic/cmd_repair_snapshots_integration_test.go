@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/restic/restic/internal/restic"
@@ -97,6 +98,45 @@ func TestRepairSnapshotsWithLostTree(t *testing.T) {
 	rtest.OK(t, err)
 }
 
+func TestRepairSnapshotsDryRun(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	createRandomFile(t, env, "foo/bar/file", 12345)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	oldSnapshot := testListSnapshots(t, env.gopts, 1)
+	oldPacks := testRunList(t, "packs", env.gopts)
+
+	createRandomFile(t, env, "foo/bar2", 1024)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	testRunForget(t, env.gopts, oldSnapshot[0].String())
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+
+	// remove tree for foo/bar
+	removePacks(env.gopts, t, restic.NewIDSet(oldPacks...))
+	testRunRebuildIndex(t, env.gopts)
+
+	// the test environment is quiet, restore the default verbosity to get the report
+	oldVerbosity := globalOptions.verbosity
+	globalOptions.verbosity = 1
+	defer func() {
+		globalOptions.verbosity = oldVerbosity
+	}()
+	buf, err := withCaptureStdout(func() error {
+		return runRepairSnapshots(context.TODO(), env.gopts, RepairOptions{DryRun: true}, nil)
+	})
+	rtest.OK(t, err)
+	output := buf.String()
+	rtest.Assert(t, strings.Contains(output, "/bar\": replaced with empty directory"), "missing damaged directory in output: %v", output)
+	rtest.Assert(t, strings.Contains(output, "would remove 1 damaged directories and missing content from 0 files"), "missing summary in output: %v", output)
+
+	// the repository must not be modified
+	rtest.Equals(t, snapshotIDs, testListSnapshots(t, env.gopts, 1))
+	testRunCheckMustFail(t, env.gopts)
+}
+
 func TestRepairSnapshotsWithLostRootTree(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
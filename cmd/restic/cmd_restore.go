@@ -2,13 +2,19 @@ package main
 
 import (
 	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/dump"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/filter"
+	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/restorer"
 	"github.com/restic/restic/internal/ui"
@@ -31,6 +37,13 @@ repository.
 To only restore a specific subfolder, you can use the "<snapshotID>:<subfolder>"
 syntax, where "subfolder" is a path within the snapshot.
 
+Pass "-" as target to write the snapshot as an archive to stdout instead. The
+archive format is selected using --archive.
+
+With --dry-run, nothing is written. Instead, the command prints which files
+would be restored, whether they already exist in the target and what happens
+to them, as well as the number of bytes that would be written and downloaded.
+
 EXIT STATUS
 ===========
 
@@ -73,9 +86,23 @@ type RestoreOptions struct {
 	Include            []string
 	InsensitiveInclude []string
 	Target             string
+	Archive            string
 	restic.SnapshotFilter
-	Sparse bool
-	Verify bool
+	Sparse          bool
+	Verify          bool
+	Overwrite       restorer.OverwriteBehavior
+	MetadataOnly    bool
+	NoContentCheck  bool
+	OnConflict      restorer.ConflictBehavior
+	Order           restorer.RestoreOrder
+	TimeLimit       time.Duration
+	ReadConcurrency uint
+	NoXattrs        bool
+	IncludeXattrs   []string
+	Chmod           string
+	ChmodDir        string
+	PathMap         []string
+	DryRun          bool
 }
 
 var restoreOptions RestoreOptions
@@ -88,11 +115,25 @@ func init() {
 	flags.StringArrayVar(&restoreOptions.InsensitiveExclude, "iexclude", nil, "same as --exclude but ignores the casing of `pattern`")
 	flags.StringArrayVarP(&restoreOptions.Include, "include", "i", nil, "include a `pattern`, exclude everything else (can be specified multiple times)")
 	flags.StringArrayVar(&restoreOptions.InsensitiveInclude, "iinclude", nil, "same as --include but ignores the casing of `pattern`")
-	flags.StringVarP(&restoreOptions.Target, "target", "t", "", "directory to extract data to")
+	flags.StringVarP(&restoreOptions.Target, "target", "t", "", "directory to extract data to, or \"-\" to write an archive to stdout")
+	flags.StringVarP(&restoreOptions.Archive, "archive", "a", "tar", "set archive `format` as \"tar\" or \"zip\" for --target -")
 
 	initSingleSnapshotFilter(flags, &restoreOptions.SnapshotFilter)
 	flags.BoolVar(&restoreOptions.Sparse, "sparse", false, "restore files as sparse")
 	flags.BoolVar(&restoreOptions.Verify, "verify", false, "verify restored files content")
+	flags.Var(&restoreOptions.Overwrite, "overwrite", "overwrite behavior, one of (always|if-changed)")
+	flags.BoolVar(&restoreOptions.MetadataOnly, "metadata-only", false, "only restore the metadata of existing files whose content matches the snapshot, restore other files completely")
+	flags.BoolVar(&restoreOptions.NoContentCheck, "no-content-check", false, "with --metadata-only, do not compare the content of existing files and never rewrite them")
+	flags.Var(&restoreOptions.OnConflict, "on-conflict", "how to restore files which already exist in the target, one of (overwrite|skip|rename)")
+	flags.Var(&restoreOptions.Order, "restore-order", "order in which files are restored, one of (tree|size|mtime)")
+	flags.DurationVar(&restoreOptions.TimeLimit, "time-limit", 0, "stop the restore after `duration`, it can be resumed by running the same command again")
+	flags.UintVar(&restoreOptions.ReadConcurrency, "read-concurrency", 0, "download `n` pack files concurrently (default: number of backend connections)")
+	flags.BoolVar(&restoreOptions.NoXattrs, "no-xattrs", false, "do not restore extended attributes and ACLs")
+	flags.StringArrayVar(&restoreOptions.IncludeXattrs, "include-xattrs", nil, "only restore extended attributes whose name matches `pattern` (can be specified multiple times)")
+	flags.StringVar(&restoreOptions.Chmod, "chmod", "", "set the permissions of restored files to `mode` instead of the stored ones (octal like 0640, or symbolic like g+r)")
+	flags.StringVar(&restoreOptions.ChmodDir, "chmod-dir", "", "set the permissions of restored directories to `mode` instead of the stored ones (octal like 0750, or symbolic like g+rx)")
+	initPathMap(flags, &restoreOptions.PathMap)
+	flags.BoolVarP(&restoreOptions.DryRun, "dry-run", "n", false, "do not write any files, just print what would be restored")
 }
 
 func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
@@ -123,6 +164,41 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 		}
 	}
 
+	for _, pattern := range opts.IncludeXattrs {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Fatalf("--include-xattrs: invalid pattern %q: %v", pattern, err)
+		}
+	}
+	if opts.NoXattrs && len(opts.IncludeXattrs) > 0 {
+		return errors.Fatal("--no-xattrs and --include-xattrs are mutually exclusive")
+	}
+
+	var fileMode, dirMode func(os.FileMode) os.FileMode
+	if opts.Chmod != "" {
+		var err error
+		if fileMode, err = parseChmod(opts.Chmod); err != nil {
+			return errors.Fatalf("--chmod: %v", err)
+		}
+	}
+	if opts.ChmodDir != "" {
+		var err error
+		if dirMode, err = parseChmod(opts.ChmodDir); err != nil {
+			return errors.Fatalf("--chmod-dir: %v", err)
+		}
+	}
+
+	if opts.NoContentCheck && !opts.MetadataOnly {
+		return errors.Fatal("--no-content-check requires --metadata-only")
+	}
+	if opts.DryRun && (opts.Verify || opts.TimeLimit != 0) {
+		return errors.Fatal("--verify and --time-limit cannot be used with --dry-run")
+	}
+
+	pathMap, err := parsePathMap(opts.PathMap)
+	if err != nil {
+		return err
+	}
+
 	for i, str := range opts.InsensitiveExclude {
 		opts.InsensitiveExclude[i] = strings.ToLower(str)
 	}
@@ -146,6 +222,42 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 		return errors.Fatal("exclude and include patterns are mutually exclusive")
 	}
 
+	toStdout := opts.Target == "-"
+	if toStdout {
+		switch opts.Archive {
+		case "tar", "zip":
+		default:
+			return errors.Fatalf("unknown archive format %q", opts.Archive)
+		}
+		if hasExcludes || hasIncludes {
+			return errors.Fatal("--exclude and --include cannot be used with --target -")
+		}
+		if opts.Sparse || opts.Verify || opts.Overwrite != restorer.OverwriteAlways || opts.OnConflict != restorer.ConflictOverwrite {
+			return errors.Fatal("--sparse, --verify, --overwrite and --on-conflict cannot be used with --target -")
+		}
+		if opts.NoXattrs || len(opts.IncludeXattrs) > 0 {
+			return errors.Fatal("--no-xattrs and --include-xattrs cannot be used with --target -")
+		}
+		if opts.MetadataOnly {
+			return errors.Fatal("--metadata-only cannot be used with --target -")
+		}
+		if opts.Order != restorer.RestoreOrderTree || opts.TimeLimit != 0 {
+			return errors.Fatal("--restore-order and --time-limit cannot be used with --target -")
+		}
+		if fileMode != nil || dirMode != nil {
+			return errors.Fatal("--chmod and --chmod-dir cannot be used with --target -")
+		}
+		if len(pathMap) > 0 {
+			return errors.Fatal("--path-map cannot be used with --target -")
+		}
+		if opts.DryRun {
+			return errors.Fatal("--dry-run cannot be used with --target -")
+		}
+		if err := checkStdoutArchive(); err != nil {
+			return errors.Fatal(err.Error())
+		}
+	}
+
 	snapshotIDString := args[0]
 
 	debug.Log("restore %v to %v", snapshotIDString, opts.Target)
@@ -173,7 +285,8 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 		return errors.Fatalf("failed to find snapshot: %v", err)
 	}
 
-	bar := newIndexTerminalProgress(gopts.Quiet, gopts.JSON, term)
+	// the archive is written to stdout, don't mix it with progress output
+	bar := newIndexTerminalProgress(gopts.Quiet || toStdout, gopts.JSON, term)
 	err = repo.LoadIndex(ctx, bar)
 	if err != nil {
 		return err
@@ -184,6 +297,20 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 		return err
 	}
 
+	if toStdout {
+		tree, err := restic.LoadTree(ctx, repo, *sn.Tree)
+		if err != nil {
+			return errors.Fatalf("loading tree for snapshot %q failed: %v", snapshotIDString, err)
+		}
+
+		d := dump.New(opts.Archive, repo, os.Stdout)
+		err = d.DumpTree(ctx, tree, "/")
+		if err != nil {
+			return errors.Fatalf("cannot write archive: %v", err)
+		}
+		return nil
+	}
+
 	msg := ui.NewMessage(term, gopts.verbosity)
 	var printer restoreui.ProgressPrinter
 	if gopts.JSON {
@@ -192,15 +319,45 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 		printer = restoreui.NewTextProgress(term)
 	}
 
+	if opts.TimeLimit > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.TimeLimit)
+		defer cancel()
+	}
+
 	progress := restoreui.NewProgress(printer, calculateProgressInterval(!gopts.Quiet, gopts.JSON))
 	res := restorer.NewRestorer(repo, sn, opts.Sparse, progress)
+	res.Overwrite = opts.Overwrite
+	res.MetadataOnly = opts.MetadataOnly
+	res.NoContentCheck = opts.NoContentCheck
+	res.OnConflict = opts.OnConflict
+	res.Conflict = func(location, target string, action restorer.ConflictBehavior) {
+		if action == restorer.ConflictSkip {
+			msg.V("skipping %s, %s already exists\n", location, target)
+		} else {
+			msg.V("restoring %s to %s, the target already exists\n", location, target)
+		}
+	}
+	res.Order = opts.Order
+	res.PathMap = pathMap
+	res.ReadConcurrency = opts.ReadConcurrency
 
 	totalErrors := 0
 	res.Error = func(location string, err error) error {
+		if ctx.Err() != nil {
+			// the restore was interrupted, do not report an error for each file
+			return ctx.Err()
+		}
 		msg.E("ignoring error for %s: %s\n", location, err)
 		totalErrors++
 		return nil
 	}
+	res.Warn = func(location string, err error) {
+		msg.E("Warning: %s: %v\n", location, err)
+	}
+	res.XattrFilter = xattrFilter(opts.NoXattrs, opts.IncludeXattrs)
+	res.FileMode = fileMode
+	res.DirMode = dirMode
 
 	excludePatterns := filter.ParsePatterns(opts.Exclude)
 	insensitiveExcludePatterns := filter.ParsePatterns(opts.InsensitiveExclude)
@@ -250,11 +407,50 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 		res.SelectFilter = selectIncludeFilter
 	}
 
+	// files restored by an interrupted run would be considered conflicts
+	var state *restorer.State
+	if opts.OnConflict == restorer.ConflictOverwrite {
+		state, err = openRestoreState(repo, *sn.Tree, opts.Target, !opts.DryRun)
+		if err != nil {
+			Warnf("unable to open restore state, restore cannot be resumed: %v\n", err)
+		}
+	}
+	if state != nil {
+		res.State = state
+		defer func() {
+			if state != nil {
+				_ = state.Close()
+			}
+		}()
+	}
+
+	if opts.DryRun {
+		plan, err := res.Plan(ctx, opts.Target)
+		if err != nil {
+			return err
+		}
+		if totalErrors > 0 {
+			return errors.Fatalf("There were %d errors\n", totalErrors)
+		}
+		printRestorePlan(term, plan, gopts.JSON)
+		return nil
+	}
+
 	if !gopts.JSON {
 		msg.P("restoring %s to %s\n", res.Snapshot(), opts.Target)
+		if state != nil && state.Len() > 0 {
+			msg.P("resuming interrupted restore, %d files were already completed\n", state.Len())
+		}
 	}
 
 	err = res.RestoreTo(ctx, opts.Target)
+	if err != nil && errors.Is(err, context.DeadlineExceeded) && opts.TimeLimit > 0 {
+		progress.Finish()
+		if state == nil {
+			return errors.Fatalf("time limit of %v reached", opts.TimeLimit)
+		}
+		return errors.Fatalf("time limit of %v reached, run the same command again to resume the restore", opts.TimeLimit)
+	}
 	if err != nil {
 		return err
 	}
@@ -265,6 +461,14 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 		return errors.Fatalf("There were %d errors\n", totalErrors)
 	}
 
+	if state != nil {
+		err = state.Remove()
+		state = nil
+		if err != nil {
+			Warnf("unable to remove restore state: %v\n", err)
+		}
+	}
+
 	if opts.Verify {
 		if !gopts.JSON {
 			msg.P("verifying files in %s\n", opts.Target)
@@ -287,3 +491,211 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 
 	return nil
 }
+
+// openRestoreState opens the state used for resuming an interrupted restore of
+// tree to target. It is stored in the cache and returns nil if no cache is
+// used. Unless create is set, it also returns nil if no state exists.
+func openRestoreState(repo *repository.Repository, tree restic.ID, target string, create bool) (*restorer.State, error) {
+	if repo.Cache == nil {
+		return nil, nil
+	}
+
+	target, err := filepath.Abs(target)
+	if err != nil {
+		return nil, errors.Wrap(err, "Abs")
+	}
+
+	dir := filepath.Join(repo.Cache.RepoDir(), "restore")
+	filename := restorer.StateFilename(dir, tree, target)
+	if !create {
+		if _, err := os.Stat(filename); errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+	}
+	return restorer.OpenState(filename)
+}
+
+type restorePlanNodeJSON struct {
+	MessageType string `json:"message_type"` // "dry_run"
+	Action      string `json:"action"`
+	Path        string `json:"path"`
+	Location    string `json:"location"`
+	Type        string `json:"type"`
+	Size        uint64 `json:"size"`
+}
+
+type restorePlanSummaryJSON struct {
+	MessageType      string `json:"message_type"` // "dry_run_summary"
+	TotalFiles       uint   `json:"total_files"`
+	NewFiles         uint   `json:"files_new"`
+	OverwrittenFiles uint   `json:"files_overwritten"`
+	UnchangedFiles   uint   `json:"files_unchanged"`
+	SkippedFiles     uint   `json:"files_skipped"`
+	RenamedFiles     uint   `json:"files_renamed"`
+	TotalBytes       uint64 `json:"total_bytes"`
+	WriteBytes       uint64 `json:"bytes_to_write"`
+	DownloadBytes    uint64 `json:"bytes_to_download"`
+}
+
+// printRestorePlan prints the nodes of plan along with a summary.
+func printRestorePlan(term *termstatus.Terminal, plan *restorer.RestorePlan, json bool) {
+	counts := make(map[restorer.PlanAction]uint)
+	for _, node := range plan.Nodes {
+		counts[node.Action]++
+		if json {
+			term.Print(ui.ToJSONString(restorePlanNodeJSON{
+				MessageType: "dry_run",
+				Action:      node.Action.String(),
+				Path:        node.Target,
+				Location:    node.Location,
+				Type:        node.Type,
+				Size:        node.Size,
+			}))
+			continue
+		}
+		size := ""
+		if node.Type == "file" {
+			size = ui.FormatBytes(node.Size)
+		}
+		term.Printf("%-9v  %10s  %s", node.Action, size, node.Target)
+	}
+
+	if json {
+		term.Print(ui.ToJSONString(restorePlanSummaryJSON{
+			MessageType:      "dry_run_summary",
+			TotalFiles:       uint(len(plan.Nodes)),
+			NewFiles:         counts[restorer.PlanCreate],
+			OverwrittenFiles: counts[restorer.PlanOverwrite],
+			UnchangedFiles:   counts[restorer.PlanUnchanged],
+			SkippedFiles:     counts[restorer.PlanSkip],
+			RenamedFiles:     counts[restorer.PlanRename],
+			TotalBytes:       plan.TotalBytes,
+			WriteBytes:       plan.WriteBytes,
+			DownloadBytes:    plan.DownloadBytes,
+		}))
+		return
+	}
+
+	term.Printf("\nwould restore %d files (%v), %d new", len(plan.Nodes), ui.FormatBytes(plan.TotalBytes), counts[restorer.PlanCreate])
+	term.Printf("existing files: %d overwritten, %d unchanged, %d skipped, %d renamed",
+		counts[restorer.PlanOverwrite], counts[restorer.PlanUnchanged], counts[restorer.PlanSkip], counts[restorer.PlanRename])
+	term.Printf("would write %v, download %v", ui.FormatBytes(plan.WriteBytes), ui.FormatBytes(plan.DownloadBytes))
+}
+
+// xattrFilter returns the filter deciding which extended attributes are
+// restored, or nil if all of them should be restored.
+func xattrFilter(noXattrs bool, patterns []string) func(name string) bool {
+	switch {
+	case noXattrs:
+		return func(string) bool { return false }
+	case len(patterns) == 0:
+		return nil
+	}
+
+	return func(name string) bool {
+		for _, pattern := range patterns {
+			// patterns have been validated before
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// parseChmod parses a mode passed to --chmod or --chmod-dir and returns a
+// function which computes the new mode from the stored one. The mode is either
+// an octal number, which replaces the permission bits, or a comma-separated
+// list of symbolic clauses like "u=rwx,g+rX,o-w" as understood by chmod(1).
+func parseChmod(s string) (func(os.FileMode) os.FileMode, error) {
+	if s == "" {
+		return nil, errors.New("empty mode")
+	}
+
+	if s[0] >= '0' && s[0] <= '9' {
+		v, err := strconv.ParseUint(s, 8, 32)
+		if err != nil || v > 0o777 {
+			return nil, errors.Errorf("invalid octal mode %q", s)
+		}
+		perm := os.FileMode(v)
+		return func(mode os.FileMode) os.FileMode {
+			return mode&^os.ModePerm | perm
+		}, nil
+	}
+
+	type change struct {
+		who  os.FileMode
+		op   byte
+		perm string
+	}
+	var changes []change
+
+	for _, clause := range strings.Split(s, ",") {
+		var who os.FileMode
+		i := 0
+		for ; i < len(clause) && strings.IndexByte("ugoa", clause[i]) >= 0; i++ {
+			switch clause[i] {
+			case 'u':
+				who |= 0o700
+			case 'g':
+				who |= 0o070
+			case 'o':
+				who |= 0o007
+			case 'a':
+				who |= 0o777
+			}
+		}
+		if who == 0 {
+			who = 0o777
+		}
+
+		if i == len(clause) {
+			return nil, errors.Errorf("invalid mode %q: missing operator in %q", s, clause)
+		}
+		for i < len(clause) {
+			op := clause[i]
+			if op != '+' && op != '-' && op != '=' {
+				return nil, errors.Errorf("invalid mode %q: unexpected %q in %q", s, op, clause)
+			}
+			i++
+			start := i
+			for i < len(clause) && strings.IndexByte("rwxX", clause[i]) >= 0 {
+				i++
+			}
+			changes = append(changes, change{who: who, op: op, perm: clause[start:i]})
+		}
+	}
+
+	return func(mode os.FileMode) os.FileMode {
+		for _, c := range changes {
+			var bits os.FileMode
+			for _, p := range c.perm {
+				switch p {
+				case 'r':
+					bits |= 0o444
+				case 'w':
+					bits |= 0o222
+				case 'x':
+					bits |= 0o111
+				case 'X':
+					// only set the execute bit for directories and files
+					// which are already executable by someone
+					if mode.IsDir() || mode&0o111 != 0 {
+						bits |= 0o111
+					}
+				}
+			}
+			bits &= c.who
+
+			switch c.op {
+			case '+':
+				mode |= bits
+			case '-':
+				mode &^= bits
+			case '=':
+				mode = mode&^c.who | bits
+			}
+		}
+		return mode
+	}, nil
+}
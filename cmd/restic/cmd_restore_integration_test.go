@@ -1,18 +1,24 @@
 package main
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	mrand "math/rand"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
 
 	"github.com/restic/restic/internal/filter"
 	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/restorer"
 	rtest "github.com/restic/restic/internal/test"
 	"github.com/restic/restic/internal/ui/termstatus"
 )
@@ -132,6 +138,70 @@ func TestRestore(t *testing.T) {
 	rtest.Assert(t, diff == "", "directories are not equal %v", diff)
 }
 
+func TestRestoreChmod(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not restored on Windows")
+	}
+
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	p := filepath.Join(env.testdata, "dir", "file")
+	rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0700))
+	rtest.OK(t, appendRandomData(p, 100))
+	rtest.OK(t, os.Chmod(p, 0600))
+
+	testRunBackup(t, env.testdata, []string{"."}, BackupOptions{}, env.gopts)
+	snapshotID := testListSnapshots(t, env.gopts, 1)[0]
+
+	restoredir := filepath.Join(env.base, "restore")
+	opts := RestoreOptions{Target: restoredir, Chmod: "g+r", ChmodDir: "0750"}
+	rtest.OK(t, testRunRestoreAssumeFailure(snapshotID.String(), opts, env.gopts))
+
+	fi, err := os.Stat(filepath.Join(restoredir, "dir", "file"))
+	rtest.OK(t, err)
+	rtest.Equals(t, os.FileMode(0640), fi.Mode().Perm())
+	fi, err = os.Stat(filepath.Join(restoredir, "dir"))
+	rtest.OK(t, err)
+	rtest.Equals(t, os.FileMode(0750), fi.Mode().Perm())
+
+	opts.ChmodDir = "u+q"
+	err = testRunRestoreAssumeFailure(snapshotID.String(), opts, env.gopts)
+	rtest.Assert(t, err != nil, "expected error for invalid mode")
+}
+
+func TestRestoreOrderAndTimeLimit(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	for i := 0; i < 5; i++ {
+		p := filepath.Join(env.testdata, fmt.Sprintf("testfile%v", i))
+		rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+		rtest.OK(t, appendRandomData(p, uint(mrand.Intn(2<<20))))
+	}
+
+	testRunBackup(t, env.testdata, []string{"."}, BackupOptions{}, env.gopts)
+	snapshotID := testListSnapshots(t, env.gopts, 1)[0]
+
+	restoredir := filepath.Join(env.base, "restore")
+	opts := RestoreOptions{Target: restoredir, TimeLimit: time.Nanosecond}
+	err := testRunRestoreAssumeFailure(snapshotID.String(), opts, env.gopts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "time limit"), "expected time limit error, got %v", err)
+
+	for _, order := range []string{"size", "mtime"} {
+		opts = RestoreOptions{Target: restoredir}
+		rtest.OK(t, opts.Order.Set(order))
+		rtest.OK(t, testRunRestoreAssumeFailure(snapshotID.String(), opts, env.gopts))
+
+		diff := directoriesContentsDiff(env.testdata, restoredir)
+		rtest.Assert(t, diff == "", "directories are not equal %v", diff)
+	}
+}
+
 func TestRestoreLatest(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
@@ -305,3 +375,168 @@ func TestRestoreLocalLayout(t *testing.T) {
 		rtest.RemoveAll(t, target)
 	}
 }
+
+func TestRestoreToStdoutTar(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	p := filepath.Join(env.testdata, "subdir", "file")
+	rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+	rtest.OK(t, appendRandomData(p, 1234))
+	rtest.OK(t, os.Symlink("file", filepath.Join(env.testdata, "subdir", "link")))
+
+	testRunBackup(t, env.testdata, []string{"."}, BackupOptions{}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+
+	archive := filepath.Join(env.base, "restore.tar")
+	f, err := os.Create(archive)
+	rtest.OK(t, err)
+
+	prevStdout := os.Stdout
+	os.Stdout = f
+	err = testRunRestoreAssumeFailure(snapshotIDs[0].String(), RestoreOptions{Target: "-", Archive: "tar"}, env.gopts)
+	os.Stdout = prevStdout
+	rtest.OK(t, err)
+	rtest.OK(t, f.Close())
+
+	f, err = os.Open(archive)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, f.Close())
+	}()
+
+	headers := make(map[string]*tar.Header)
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		rtest.OK(t, err)
+		headers[hdr.Name] = hdr
+	}
+
+	fi, err := os.Lstat(p)
+	rtest.OK(t, err)
+	hdr, ok := headers["subdir/file"]
+	rtest.Assert(t, ok, "file missing from archive, got %v", headers)
+	rtest.Equals(t, fi.Size(), hdr.Size)
+	rtest.Equals(t, fi.ModTime().Round(time.Second).Unix(), hdr.ModTime.Unix())
+
+	hdr, ok = headers["subdir/link"]
+	rtest.Assert(t, ok, "symlink missing from archive, got %v", headers)
+	rtest.Equals(t, byte(tar.TypeSymlink), hdr.Typeflag)
+	rtest.Equals(t, "file", hdr.Linkname)
+
+	err = testRunRestoreAssumeFailure(snapshotIDs[0].String(), RestoreOptions{Target: "-", Archive: "rar"}, env.gopts)
+	rtest.Assert(t, err != nil, "expected error for unknown archive format")
+}
+
+func TestPathMap(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	rtest.OK(t, appendRandomData(filepath.Join(env.testdata, "0", "0", "9", "0"), 100))
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 2)
+
+	pathMap := []string{env.testdata + ":" + filepath.FromSlash("/moved")}
+	moved := "/moved"
+
+	// snapshots
+	buf, err := withCaptureStdout(func() error {
+		gopts := env.gopts
+		gopts.JSON = true
+		return runSnapshots(context.TODO(), SnapshotOptions{PathMap: pathMap}, gopts, nil)
+	})
+	rtest.OK(t, err)
+	var snapshots []Snapshot
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &snapshots))
+	rtest.Equals(t, 2, len(snapshots))
+	rtest.Equals(t, []string{filepath.FromSlash("/moved")}, snapshots[0].Paths)
+
+	// ls
+	buf, err = withCaptureStdout(func() error {
+		gopts := env.gopts
+		gopts.Quiet = true
+		return runLs(context.TODO(), LsOptions{PathMap: pathMap}, gopts, []string{"latest"})
+	})
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(buf.String(), moved+"/0/0/9/0\n"), "missing remapped file in ls output: %v", buf.String())
+	rtest.Assert(t, !strings.Contains(buf.String(), "testdata"), "unexpected original path in ls output: %v", buf.String())
+
+	// diff
+	buf, err = withCaptureStdout(func() error {
+		gopts := env.gopts
+		gopts.Quiet = false
+		return runDiff(context.TODO(), DiffOptions{PathMap: pathMap}, gopts,
+			[]string{snapshotIDs[0].String(), snapshotIDs[1].String()})
+	})
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(buf.String(), moved+"/0/0/9/0\n"), "missing remapped file in diff output: %v", buf.String())
+
+	// restore
+	target := filepath.Join(env.base, "restore")
+	rtest.OK(t, testRunRestoreAssumeFailure("latest", RestoreOptions{Target: target, PathMap: pathMap}, env.gopts))
+	diff := directoriesContentsDiff(env.testdata, filepath.Join(target, "moved"))
+	rtest.Assert(t, diff == "", "directories are not equal: %#v", diff)
+
+	err = testRunRestoreAssumeFailure("latest", RestoreOptions{Target: target, PathMap: []string{"invalid"}}, env.gopts)
+	rtest.Assert(t, err != nil, "expected error for invalid path mapping")
+}
+
+func TestRestoreDryRun(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	for _, name := range []string{"existing", "new"} {
+		p := filepath.Join(env.testdata, name)
+		rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+		rtest.OK(t, appendRandomData(p, 1000))
+	}
+	testRunBackup(t, env.testdata, []string{"."}, BackupOptions{}, env.gopts)
+	snapshotID := testListSnapshots(t, env.gopts, 1)[0]
+
+	restoredir := filepath.Join(env.base, "restore")
+	rtest.OK(t, os.MkdirAll(restoredir, 0755))
+	rtest.OK(t, os.WriteFile(filepath.Join(restoredir, "existing"), []byte("existing"), 0644))
+
+	buf := bytes.NewBuffer(nil)
+	gopts := env.gopts
+	gopts.stdout = buf
+	opts := RestoreOptions{Target: restoredir, DryRun: true, OnConflict: restorer.ConflictSkip}
+	rtest.OK(t, testRunRestoreAssumeFailure(snapshotID.String(), opts, gopts))
+	rtest.Assert(t, strings.Contains(buf.String(), "would restore 2 files (1.953 KiB), 1 new"), "unexpected output %q", buf.String())
+	rtest.Assert(t, strings.Contains(buf.String(), "existing files: 0 overwritten, 0 unchanged, 1 skipped, 0 renamed"), "unexpected output %q", buf.String())
+
+	buf.Reset()
+	gopts.JSON = true
+	rtest.OK(t, testRunRestoreAssumeFailure(snapshotID.String(), opts, gopts))
+	actions := make(map[string]string)
+	var summary restorePlanSummaryJSON
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var node restorePlanNodeJSON
+		rtest.OK(t, json.Unmarshal([]byte(line), &node))
+		if node.MessageType == "dry_run_summary" {
+			rtest.OK(t, json.Unmarshal([]byte(line), &summary))
+			continue
+		}
+		rtest.Equals(t, "dry_run", node.MessageType)
+		actions[filepath.Base(node.Path)] = node.Action
+	}
+	rtest.Equals(t, map[string]string{"existing": "skip", "new": "new"}, actions)
+	rtest.Equals(t, uint(2), summary.TotalFiles)
+	rtest.Equals(t, uint64(1000), summary.WriteBytes)
+	rtest.Assert(t, summary.DownloadBytes > 0, "no download size in %v", summary)
+
+	// nothing was restored
+	entries, err := os.ReadDir(restoredir)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(entries))
+}
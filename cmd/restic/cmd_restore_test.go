@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestParseChmod(t *testing.T) {
+	for _, test := range []struct {
+		mode string
+		in   os.FileMode
+		want os.FileMode
+	}{
+		{"0640", 0o755, 0o640},
+		{"750", os.ModeDir | 0o700, os.ModeDir | 0o750},
+		{"g+r", 0o600, 0o640},
+		{"go-rwx", 0o755, 0o700},
+		{"a=r", 0o755, 0o444},
+		{"u=rw,g=r,o=", 0o777, 0o640},
+		{"u+x-w", 0o644, 0o544},
+		{"+w", 0o444, 0o666},
+		{"g+rX", 0o600, 0o640},
+		{"g+rX", 0o700, 0o750},
+		{"g+rX", os.ModeDir | 0o700, os.ModeDir | 0o750},
+	} {
+		fn, err := parseChmod(test.mode)
+		rtest.OK(t, err)
+		rtest.Assert(t, fn(test.in) == test.want, "%q applied to %v: want %v, got %v", test.mode, test.in, test.want, fn(test.in))
+	}
+
+	for _, mode := range []string{"", "1000", "0789", "g", "u+y", "g+r,", "z+r"} {
+		_, err := parseChmod(mode)
+		rtest.Assert(t, err != nil, "expected error for mode %q", mode)
+	}
+}
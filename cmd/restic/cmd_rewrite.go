@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -137,7 +136,11 @@ func rewriteSnapshot(ctx context.Context, repo *repository.Repository, sn *resti
 				if selectByName(path) {
 					return node
 				}
-				Verbosef(fmt.Sprintf("excluding %s\n", path))
+				if opts.DryRun {
+					Verbosef("would exclude %s\n", path)
+				} else {
+					Verbosef("excluding %s\n", path)
+				}
 				return nil
 			},
 			DisableNodeCache: true,
@@ -209,7 +212,7 @@ func filterAndReplaceSnapshot(ctx context.Context, repo restic.Repository, sn *r
 		}
 
 		if newMetadata != nil && newMetadata.Hostname != "" {
-			Verbosef("would set time to %s\n", newMetadata.Hostname)
+			Verbosef("would set host to %s\n", newMetadata.Hostname)
 		}
 
 		return true, nil
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/restic/restic/internal/restic"
@@ -105,3 +106,31 @@ func TestRewriteMetadata(t *testing.T) {
 		testRewriteMetadata(t, metadata)
 	}
 }
+
+func TestRewriteDryRun(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	createBasicRewriteRepo(t, env)
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 2)
+
+	defer func(verbosity uint) { globalOptions.verbosity = verbosity }(globalOptions.verbosity)
+	globalOptions.verbosity = 1
+
+	buf, err := withCaptureStdout(func() error {
+		opts := RewriteOptions{
+			excludePatternOptions: excludePatternOptions{Excludes: []string{"3"}},
+			Forget:                true,
+			DryRun:                true,
+		}
+		return runRewrite(context.TODO(), opts, env.gopts, nil)
+	})
+	rtest.OK(t, err)
+	output := buf.String()
+	rtest.Assert(t, strings.Contains(output, "would exclude /testdata/0/0/9/3\n"), "excluded path missing in output: %v", output)
+	rtest.Assert(t, strings.Contains(output, "would modify 2 snapshots"), "summary missing in output: %v", output)
+
+	// the repository must not be modified
+	rtest.Equals(t, snapshotIDs, testListSnapshots(t, env.gopts, 2))
+	testRunCheck(t, env.gopts)
+}
@@ -7,8 +7,11 @@ import (
 	"io"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
 	"github.com/restic/restic/internal/ui/table"
 	"github.com/spf13/cobra"
 )
@@ -19,10 +22,16 @@ var cmdSnapshots = &cobra.Command{
 	Long: `
 The "snapshots" command lists all snapshots stored in the repository.
 
+With "--expect host:path" and "--max-age", the command instead checks that for
+each expected host and path a snapshot exists which is not older than the given
+duration, and reports the status of each expectation.
+
 EXIT STATUS
 ===========
 
 Exit status is 0 if the command was successful, and non-zero if there was any error.
+When checking expectations, the exit status is also non-zero if any expected
+path has no recent enough snapshot.
 `,
 	DisableAutoGenTag: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -33,10 +42,17 @@ Exit status is 0 if the command was successful, and non-zero if there was any er
 // SnapshotOptions bundles all options for the snapshots command.
 type SnapshotOptions struct {
 	restic.SnapshotFilter
-	Compact bool
-	Last    bool // This option should be removed in favour of Latest.
-	Latest  int
-	GroupBy restic.SnapshotGroupByOptions
+	Compact    bool
+	Long       bool
+	Columns    []string
+	GroupPaths bool
+	Last       bool // This option should be removed in favour of Latest.
+	Latest     int
+	GroupBy    restic.SnapshotGroupByOptions
+	Expect     []string
+	MaxAge     restic.Duration
+	CountOnly  bool
+	PathMap    []string
 }
 
 var snapshotOptions SnapshotOptions
@@ -47,6 +63,9 @@ func init() {
 	f := cmdSnapshots.Flags()
 	initMultiSnapshotFilter(f, &snapshotOptions.SnapshotFilter, true)
 	f.BoolVarP(&snapshotOptions.Compact, "compact", "c", false, "use compact output format")
+	f.BoolVar(&snapshotOptions.Long, "long", false, "also show the backup duration, number of files, processed size and operating system")
+	f.StringSliceVar(&snapshotOptions.Columns, "columns", nil, "only show the given `columns` in this order (id, time, host, tags, paths, parent, duration, files, size, os)")
+	f.BoolVar(&snapshotOptions.GroupPaths, "group-paths", false, "print identical paths only once for consecutive snapshots")
 	f.BoolVar(&snapshotOptions.Last, "last", false, "only show the last snapshot for each host and path")
 	err := f.MarkDeprecated("last", "use --latest 1")
 	if err != nil {
@@ -55,9 +74,48 @@ func init() {
 	}
 	f.IntVar(&snapshotOptions.Latest, "latest", 0, "only show the last `n` snapshots for each host and path")
 	f.VarP(&snapshotOptions.GroupBy, "group-by", "g", "`group` snapshots by host, paths and/or tags, separated by comma")
+	f.StringArrayVar(&snapshotOptions.Expect, "expect", nil, "check that a snapshot of `host:path` not older than --max-age exists (can be specified multiple times)")
+	f.Var(&snapshotOptions.MaxAge, "max-age", "maximum age of the latest snapshot for --expect as a `duration` (e.g. 1d12h)")
+	f.BoolVar(&snapshotOptions.CountOnly, "count-only", false, "only print the number of snapshots, per group if combined with --group-by")
+	initPathMap(f, &snapshotOptions.PathMap)
 }
 
 func runSnapshots(ctx context.Context, opts SnapshotOptions, gopts GlobalOptions, args []string) error {
+	for _, column := range opts.Columns {
+		if _, ok := snapshotColumns[column]; !ok {
+			return errors.Fatalf("unknown column %q for --columns", column)
+		}
+	}
+	columns := opts.Columns
+	if opts.Long {
+		if len(columns) > 0 {
+			return errors.Fatal("--long and --columns cannot be used together")
+		}
+		columns = longSnapshotColumns
+	}
+
+	expectations, err := parseSnapshotExpectations(opts.Expect)
+	if err != nil {
+		return err
+	}
+	if len(expectations) > 0 {
+		if opts.MaxAge.Zero() {
+			return errors.Fatal("--expect requires --max-age")
+		}
+		if len(args) > 0 || opts.Latest > 0 || opts.Last {
+			return errors.Fatal("--expect cannot be combined with snapshot IDs, --latest or --last")
+		}
+	} else if !opts.MaxAge.Zero() {
+		return errors.Fatal("--max-age requires --expect")
+	}
+	if opts.CountOnly && len(expectations) > 0 {
+		return errors.Fatal("--count-only cannot be combined with --expect")
+	}
+	pathMap, err := parsePathMap(opts.PathMap)
+	if err != nil {
+		return err
+	}
+
 	repo, err := OpenRepository(ctx, gopts)
 	if err != nil {
 		return err
@@ -72,10 +130,51 @@ func runSnapshots(ctx context.Context, opts SnapshotOptions, gopts GlobalOptions
 		}
 	}
 
+	if opts.CountOnly && len(args) == 0 && !opts.Last && opts.Latest == 0 &&
+		opts.SnapshotFilter.Empty() && opts.GroupBy == (restic.SnapshotGroupByOptions{}) {
+		// counting all snapshots does not require loading them
+		count := 0
+		err := repo.List(ctx, restic.SnapshotFile, func(restic.ID, int64) error {
+			count++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return printSnapshotCount(globalOptions.stdout, count, gopts.JSON)
+	}
+
 	var snapshots restic.Snapshots
 	for sn := range FindFilteredSnapshots(ctx, repo, repo, &opts.SnapshotFilter, args) {
+		// only the printed paths are changed, the snapshot is not modified
+		sn.Paths = pathMap.ApplyAll(sn.Paths)
 		snapshots = append(snapshots, sn)
 	}
+
+	if len(expectations) > 0 {
+		d := opts.MaxAge
+		cutoff := time.Now().AddDate(-d.Years, -d.Months, -d.Days).Add(time.Hour * time.Duration(-d.Hours))
+		results, err := checkSnapshotFreshness(snapshots, expectations, cutoff)
+		if err != nil {
+			return err
+		}
+		err = printSnapshotFreshness(globalOptions.stdout, results, gopts.JSON)
+		if err != nil {
+			Warnf("error printing snapshots: %v\n", err)
+		}
+
+		var stale int
+		for _, res := range results {
+			if res.Status != "ok" {
+				stale++
+			}
+		}
+		if stale > 0 {
+			return errors.Fatalf("%d of %d expected paths have no snapshot within the last %v", stale, len(results), opts.MaxAge)
+		}
+		return nil
+	}
+
 	snapshotGroups, grouped, err := restic.GroupSnapshots(snapshots, opts.GroupBy)
 	if err != nil {
 		return err
@@ -93,6 +192,10 @@ func runSnapshots(ctx context.Context, opts SnapshotOptions, gopts GlobalOptions
 		snapshotGroups[k] = list
 	}
 
+	if opts.CountOnly {
+		return printSnapshotCounts(globalOptions.stdout, snapshotGroups, grouped, gopts.JSON)
+	}
+
 	if gopts.JSON {
 		err := printSnapshotGroupJSON(globalOptions.stdout, snapshotGroups, grouped)
 		if err != nil {
@@ -101,7 +204,10 @@ func runSnapshots(ctx context.Context, opts SnapshotOptions, gopts GlobalOptions
 		return nil
 	}
 
-	for k, list := range snapshotGroups {
+	// use the same length of the short IDs for all groups
+	idLen := shortIDLength(snapshots)
+	for _, k := range restic.SortedGroupKeys(snapshotGroups) {
+		list := snapshotGroups[k]
 		if grouped {
 			err := PrintSnapshotGroupHeader(globalOptions.stdout, k)
 			if err != nil {
@@ -109,12 +215,135 @@ func runSnapshots(ctx context.Context, opts SnapshotOptions, gopts GlobalOptions
 				return nil
 			}
 		}
-		PrintSnapshots(globalOptions.stdout, list, nil, opts.Compact)
+		PrintSnapshots(globalOptions.stdout, list, nil, opts.Compact, opts.GroupPaths, columns, idLen)
 	}
 
 	return nil
 }
 
+// snapshotExpectation is a host and path for which a recent snapshot must exist.
+type snapshotExpectation struct {
+	Host string
+	Path string
+}
+
+// parseSnapshotExpectations parses a list of expectations in the format
+// host:path. The path is split off at the first colon, so it may contain
+// colons itself.
+func parseSnapshotExpectations(list []string) ([]snapshotExpectation, error) {
+	var expectations []snapshotExpectation
+	for _, s := range list {
+		host, path, found := strings.Cut(s, ":")
+		if !found || host == "" || path == "" {
+			return nil, errors.Fatalf("invalid expectation %q, must be in the format host:path", s)
+		}
+		expectations = append(expectations, snapshotExpectation{Host: host, Path: path})
+	}
+	return expectations, nil
+}
+
+// snapshotFreshness is the result of checking a single snapshotExpectation.
+type snapshotFreshness struct {
+	Host       string     `json:"host"`
+	Path       string     `json:"path"`
+	Status     string     `json:"status"`
+	SnapshotID *restic.ID `json:"snapshot_id,omitempty"`
+	ShortID    string     `json:"short_id,omitempty"`
+	Time       *time.Time `json:"time,omitempty"`
+}
+
+// checkSnapshotFreshness determines the latest snapshot for each expectation
+// from the snapshots grouped by host and paths. A group matches an expectation
+// if its host is equal to the expected one and the expected path is one of the
+// paths of the group. The status of an expectation is "ok" if the latest
+// snapshot is not older than cutoff, "stale" if it is older, and "missing" if
+// no snapshot matches at all.
+func checkSnapshotFreshness(snapshots restic.Snapshots, expectations []snapshotExpectation, cutoff time.Time) ([]snapshotFreshness, error) {
+	snapshotGroups, _, err := restic.GroupSnapshots(snapshots, restic.SnapshotGroupByOptions{Host: true, Path: true})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]restic.SnapshotGroupKey, len(snapshotGroups))
+	for k := range snapshotGroups {
+		var key restic.SnapshotGroupKey
+		if err := json.Unmarshal([]byte(k), &key); err != nil {
+			return nil, err
+		}
+		keys[k] = key
+	}
+
+	results := make([]snapshotFreshness, 0, len(expectations))
+	for _, e := range expectations {
+		var latest *restic.Snapshot
+		for k, list := range snapshotGroups {
+			key := keys[k]
+			if key.Hostname != e.Host || !containsString(key.Paths, e.Path) {
+				continue
+			}
+			for _, sn := range list {
+				if latest == nil || sn.Time.After(latest.Time) {
+					latest = sn
+				}
+			}
+		}
+
+		res := snapshotFreshness{Host: e.Host, Path: e.Path, Status: "missing"}
+		if latest != nil {
+			res.SnapshotID = latest.ID()
+			res.ShortID = latest.ID().Str()
+			res.Time = &latest.Time
+			res.Status = "ok"
+			if latest.Time.Before(cutoff) {
+				res.Status = "stale"
+			}
+		}
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// printSnapshotFreshness prints the results of checkSnapshotFreshness either
+// as a table or as JSON.
+func printSnapshotFreshness(stdout io.Writer, results []snapshotFreshness, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(stdout).Encode(results)
+	}
+
+	tab := table.New()
+	tab.AddColumn("Host      ", "{{ .Host }}")
+	tab.AddColumn("Path", "{{ .Path }}")
+	tab.AddColumn("Latest", "{{ .Latest }}")
+	tab.AddColumn("Status", "{{ .Status }}")
+
+	var stale int
+	for _, res := range results {
+		row := struct {
+			Host, Path, Latest, Status string
+		}{Host: res.Host, Path: res.Path, Latest: "-", Status: res.Status}
+		if res.Time != nil {
+			row.Latest = res.Time.Local().Format(TimeFormat) + " (" + res.ShortID + ")"
+		}
+		if res.Status != "ok" {
+			stale++
+		}
+		tab.AddRow(row)
+	}
+	tab.AddFooter(fmt.Sprintf("%d of %d expected paths are stale or missing", stale, len(results)))
+
+	return tab.Write(stdout)
+}
+
 // filterLastSnapshotsKey is used by FilterLastSnapshots.
 type filterLastSnapshotsKey struct {
 	Hostname    string
@@ -152,8 +381,58 @@ func FilterLastestSnapshots(list restic.Snapshots, limit int) restic.Snapshots {
 	return results
 }
 
-// PrintSnapshots prints a text table of the snapshots in list to stdout.
-func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.KeepReason, compact bool) {
+// snapshotColumn describes a column of the snapshots table.
+type snapshotColumn struct {
+	header   string
+	template string
+	// compactTemplate is used instead of template if set and the compact
+	// output format was requested.
+	compactTemplate string
+}
+
+// snapshotColumns contains the columns which can be selected using --columns.
+var snapshotColumns = map[string]snapshotColumn{
+	"id":     {header: "ID", template: "{{ .ID }}"},
+	"time":   {header: "Time", template: "{{ .Timestamp }}"},
+	"host":   {header: "Host      ", template: "{{ .Hostname }}"},
+	"tags":   {header: "Tags      ", template: `{{ join .Tags "," }}`},
+	"paths":  {header: "Paths", template: `{{ join .Paths "\n" }}`, compactTemplate: `{{ join .Paths "," }}`},
+	"parent": {header: "Parent", template: "{{ .Parent }}"},
+
+	// taken from the summary, empty for snapshots created by older versions
+	"duration": {header: "Duration", template: "{{ .Duration }}"},
+	"files":    {header: "Files", template: "{{ .Files }}"},
+	"size":     {header: "Size", template: "{{ .Size }}"},
+	"os":       {header: "OS", template: "{{ .OS }}"},
+}
+
+// longSnapshotColumns are shown by --long.
+var longSnapshotColumns = []string{"id", "time", "host", "tags", "duration", "files", "size", "os", "paths"}
+
+// shortIDLength returns the number of characters of the short snapshot IDs
+// which are required to distinguish all snapshots in list.
+func shortIDLength(list restic.Snapshots) int {
+	ids := make(restic.IDs, 0, len(list))
+	for _, sn := range list {
+		if id := sn.ID(); id != nil {
+			ids = append(ids, *id)
+		}
+	}
+	return restic.UniquePrefixLength(ids)
+}
+
+// PrintSnapshots prints a text table of the snapshots in list to stdout. If
+// groupPaths is set, snapshots with identical paths are listed together below
+// a single header line instead of repeating the paths on every row. If
+// columns is not empty, only the given columns from snapshotColumns are shown
+// in that order. The short snapshot IDs are printed with idLen characters,
+// which should be determined using shortIDLength from all snapshots shown to
+// the user. If idLen is zero, it is determined from list.
+func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.KeepReason, compact bool, groupPaths bool, columns []string, idLen int) {
+	if idLen == 0 {
+		idLen = shortIDLength(list)
+	}
+
 	// keep the reasons a snasphot is being kept in a map, so that it doesn't
 	// get lost when the list of snapshots is sorted
 	keepReasons := make(map[restic.ID]restic.KeepReason, len(reasons))
@@ -169,6 +448,14 @@ func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.Ke
 		return list[i].Time.Before(list[j].Time)
 	})
 
+	if groupPaths {
+		// cluster snapshots with the same paths, the stable sort keeps them
+		// ordered by time within each cluster
+		sort.SliceStable(list, func(i, j int) bool {
+			return joinedPaths(list[i]) < joinedPaths(list[j])
+		})
+	}
+
 	// Determine the max widths for host and tag.
 	maxHost, maxTag := 10, 6
 	for _, sn := range list {
@@ -184,11 +471,31 @@ func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.Ke
 
 	tab := table.New()
 
-	if compact {
+	showPaths := !compact && !groupPaths
+	if len(columns) > 0 {
+		showPaths = false
+		for _, name := range columns {
+			column := snapshotColumns[name]
+			if name == "paths" {
+				if groupPaths {
+					continue
+				}
+				showPaths = !compact
+			}
+			template := column.template
+			if compact && column.compactTemplate != "" {
+				template = column.compactTemplate
+			}
+			tab.AddColumn(column.header, template)
+		}
+	} else if compact {
 		tab.AddColumn("ID", "{{ .ID }}")
 		tab.AddColumn("Time", "{{ .Timestamp }}")
 		tab.AddColumn("Host", "{{ .Hostname }}")
 		tab.AddColumn("Tags  ", `{{ join .Tags "\n" }}`)
+		if len(reasons) > 0 {
+			tab.AddColumn("Reasons", `{{ join .Reasons "\n" }}`)
+		}
 	} else {
 		tab.AddColumn("ID", "{{ .ID }}")
 		tab.AddColumn("Time", "{{ .Timestamp }}")
@@ -197,7 +504,9 @@ func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.Ke
 		if len(reasons) > 0 {
 			tab.AddColumn("Reasons", `{{ join .Reasons "\n" }}`)
 		}
-		tab.AddColumn("Paths", `{{ join .Paths "\n" }}`)
+		if !groupPaths {
+			tab.AddColumn("Paths", `{{ join .Paths "\n" }}`)
+		}
 	}
 
 	type snapshot struct {
@@ -207,24 +516,48 @@ func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.Ke
 		Tags      []string
 		Reasons   []string
 		Paths     []string
+		Parent    string
+		Duration  string
+		Files     string
+		Size      string
+		OS        string
 	}
 
+	// pathHeaders maps the row index of the first snapshot of each group of
+	// identical paths to the header printed above it
+	pathHeaders := make(map[int]string)
+
 	var multiline bool
-	for _, sn := range list {
+	for i, sn := range list {
 		data := snapshot{
-			ID:        sn.ID().Str(),
+			ID:        sn.ID().StrN(idLen),
 			Timestamp: sn.Time.Local().Format(TimeFormat),
 			Hostname:  sn.Hostname,
 			Tags:      sn.Tags,
 			Paths:     sn.Paths,
 		}
+		if sn.Parent != nil {
+			data.Parent = sn.Parent.StrN(idLen)
+		}
+		if s := sn.Summary; s != nil {
+			data.Duration = ui.FormatDuration(s.Duration())
+			data.Files = fmt.Sprintf("%d", s.TotalFilesProcessed)
+			data.Size = ui.FormatBytes(s.TotalBytesProcessed)
+			if s.OS != "" {
+				data.OS = s.OS + "/" + s.Arch
+			}
+		}
 
 		if len(reasons) > 0 {
 			id := sn.ID()
 			data.Reasons = keepReasons[*id].Matches
 		}
 
-		if len(sn.Paths) > 1 && !compact {
+		if groupPaths {
+			if i == 0 || joinedPaths(list[i-1]) != joinedPaths(sn) {
+				pathHeaders[i] = "paths [" + strings.Join(sn.Paths, ", ") + "]:"
+			}
+		} else if len(sn.Paths) > 1 && showPaths {
 			multiline = true
 		}
 
@@ -233,7 +566,22 @@ func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.Ke
 
 	tab.AddFooter(fmt.Sprintf("%d snapshots", len(list)))
 
-	if multiline {
+	if groupPaths {
+		// print the paths header above the first snapshot of each group
+		last := -1
+		tab.PrintData = func(w io.Writer, idx int, s string) error {
+			if idx != last {
+				if header, ok := pathHeaders[idx]; ok {
+					if _, err := fmt.Fprintf(w, "%s\n", header); err != nil {
+						return err
+					}
+				}
+			}
+			last = idx
+			_, err := fmt.Fprintf(w, "%s\n", s)
+			return err
+		}
+	} else if multiline {
 		// print an additional blank line between snapshots
 
 		var last int
@@ -255,6 +603,12 @@ func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.Ke
 	}
 }
 
+// joinedPaths returns the paths of sn as a single string suitable for
+// comparing the path sets of two snapshots.
+func joinedPaths(sn *restic.Snapshot) string {
+	return strings.Join(sn.Paths, "\n")
+}
+
 // PrintSnapshotGroupHeader prints which group of the group-by option the
 // following snapshots belong to.
 // Prints nothing, if we did not group at all.
@@ -272,6 +626,16 @@ func PrintSnapshotGroupHeader(stdout io.Writer, groupKeyJSON string) error {
 
 	// Info
 	fmt.Fprintf(stdout, "snapshots")
+	if info := snapshotGroupDescription(key); info != "" {
+		fmt.Fprintf(stdout, " for (%s)", info)
+	}
+	fmt.Fprintf(stdout, ":\n")
+
+	return nil
+}
+
+// snapshotGroupDescription describes the host, tags and paths of a group key.
+func snapshotGroupDescription(key restic.SnapshotGroupKey) string {
 	var infoStrings []string
 	if key.Hostname != "" {
 		infoStrings = append(infoStrings, "host ["+key.Hostname+"]")
@@ -282,14 +646,56 @@ func PrintSnapshotGroupHeader(stdout io.Writer, groupKeyJSON string) error {
 	if key.Paths != nil {
 		infoStrings = append(infoStrings, "paths ["+strings.Join(key.Paths, ", ")+"]")
 	}
-	if infoStrings != nil {
-		fmt.Fprintf(stdout, " for (%s)", strings.Join(infoStrings, ", "))
+	return strings.Join(infoStrings, ", ")
+}
+
+// snapshotCountJSON is the JSON representation of the number of snapshots in
+// a group.
+type snapshotCountJSON struct {
+	GroupKey *restic.SnapshotGroupKey `json:"group_key,omitempty"`
+	Count    int                      `json:"count"`
+}
+
+// printSnapshotCounts prints the number of snapshots in each group. Without
+// grouping, only the total number is printed.
+func printSnapshotCounts(stdout io.Writer, snGroups map[string]restic.Snapshots, grouped bool, printJSON bool) error {
+	if !grouped {
+		count := 0
+		for _, list := range snGroups {
+			count += len(list)
+		}
+		return printSnapshotCount(stdout, count, printJSON)
 	}
-	fmt.Fprintf(stdout, ":\n")
 
+	counts := []snapshotCountJSON{}
+	for _, k := range restic.SortedGroupKeys(snGroups) {
+		var key restic.SnapshotGroupKey
+		if err := json.Unmarshal([]byte(k), &key); err != nil {
+			return err
+		}
+		counts = append(counts, snapshotCountJSON{GroupKey: &key, Count: len(snGroups[k])})
+	}
+	if printJSON {
+		return json.NewEncoder(stdout).Encode(counts)
+	}
+
+	for _, c := range counts {
+		if _, err := fmt.Fprintf(stdout, "%d snapshots for (%s)\n", c.Count, snapshotGroupDescription(*c.GroupKey)); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// printSnapshotCount prints the total number of snapshots.
+func printSnapshotCount(stdout io.Writer, count int, printJSON bool) error {
+	if printJSON {
+		return json.NewEncoder(stdout).Encode(snapshotCountJSON{Count: count})
+	}
+	_, err := fmt.Fprintf(stdout, "%d\n", count)
+	return err
+}
+
 // Snapshot helps to print Snapshots as JSON with their ID included.
 type Snapshot struct {
 	*restic.Snapshot
@@ -309,7 +715,8 @@ func printSnapshotGroupJSON(stdout io.Writer, snGroups map[string]restic.Snapsho
 	if grouped {
 		snapshotGroups := []SnapshotGroup{}
 
-		for k, list := range snGroups {
+		for _, k := range restic.SortedGroupKeys(snGroups) {
+			list := snGroups[k]
 			var key restic.SnapshotGroupKey
 			var err error
 			var snapshots []Snapshot
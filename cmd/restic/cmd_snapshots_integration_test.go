@@ -3,17 +3,24 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 )
 
 func testRunSnapshots(t testing.TB, gopts GlobalOptions) (newest *Snapshot, snapmap map[restic.ID]Snapshot) {
+	return testRunSnapshotsFiltered(t, gopts, restic.SnapshotFilter{})
+}
+
+func testRunSnapshotsFiltered(t testing.TB, gopts GlobalOptions, filter restic.SnapshotFilter) (newest *Snapshot, snapmap map[restic.ID]Snapshot) {
 	buf, err := withCaptureStdout(func() error {
 		gopts.JSON = true
 
-		opts := SnapshotOptions{}
+		opts := SnapshotOptions{SnapshotFilter: filter}
 		return runSnapshots(context.TODO(), opts, gopts, []string{})
 	})
 	rtest.OK(t, err)
@@ -30,3 +37,85 @@ func testRunSnapshots(t testing.TB, gopts GlobalOptions) (newest *Snapshot, snap
 	}
 	return
 }
+
+func TestSnapshotsFilter(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	for _, backup := range []struct{ host, time string }{
+		{"web-01", "2020-01-01 10:00:00"},
+		{"web-02", "2021-01-01 10:00:00"},
+		{"db-01", "2022-01-01 10:00:00"},
+	} {
+		opts := BackupOptions{Host: backup.host, TimeStamp: backup.time}
+		testRunBackup(t, "", []string{env.testdata}, opts, env.gopts)
+	}
+
+	parse := func(s string) time.Time {
+		ts, err := time.ParseInLocation(TimeFormat, s, time.Local)
+		rtest.OK(t, err)
+		return ts
+	}
+
+	for _, test := range []struct {
+		filter restic.SnapshotFilter
+		hosts  []string
+	}{
+		{restic.SnapshotFilter{Hosts: []string{"web-*"}}, []string{"web-01", "web-02"}},
+		{restic.SnapshotFilter{Hosts: []string{"web-0?", "db-01"}}, []string{"db-01", "web-01", "web-02"}},
+		{restic.SnapshotFilter{Since: parse("2021-01-01 10:00:00")}, []string{"db-01", "web-02"}},
+		{restic.SnapshotFilter{Until: parse("2021-06-01 00:00:00")}, []string{"web-01", "web-02"}},
+		{restic.SnapshotFilter{Hosts: []string{"web-*"}, Since: parse("2020-06-01 00:00:00")}, []string{"web-02"}},
+		{restic.SnapshotFilter{Hosts: []string{"mail-*"}}, nil},
+	} {
+		_, snapmap := testRunSnapshotsFiltered(t, env.gopts, test.filter)
+		var hosts []string
+		for _, sn := range snapmap {
+			hosts = append(hosts, sn.Hostname)
+		}
+		sort.Strings(hosts)
+		rtest.Equals(t, test.hosts, hosts)
+
+		// --count-only must apply the same filter
+		buf, err := withCaptureStdout(func() error {
+			opts := SnapshotOptions{SnapshotFilter: test.filter, CountOnly: true}
+			return runSnapshots(context.TODO(), opts, env.gopts, []string{})
+		})
+		rtest.OK(t, err)
+		rtest.Equals(t, fmt.Sprintf("%d\n", len(test.hosts)), buf.String())
+	}
+}
+
+func TestSnapshotsSnapshotIndex(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	env.gopts.SnapshotIndex = true
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{}
+	testRunBackup(t, "", []string{env.testdata}, opts, env.gopts)
+	testRunBackup(t, "", []string{env.testdata}, opts, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 2)
+	rtest.Equals(t, 1, len(testRunList(t, "snapshot-index", env.gopts)))
+
+	_, snapmap := testRunSnapshots(t, env.gopts)
+	rtest.Equals(t, 2, len(snapmap))
+
+	testRunForget(t, env.gopts, snapshotIDs[0].String())
+	indexIDs := testRunList(t, "snapshot-index", env.gopts)
+	rtest.Equals(t, 1, len(indexIDs))
+	_, snapmap = testRunSnapshots(t, env.gopts)
+	rtest.Equals(t, 1, len(snapmap))
+	_, ok := snapmap[snapshotIDs[1]]
+	rtest.Assert(t, ok, "snapshot %v missing", snapshotIDs[1])
+
+	// snapshots added without updating the snapshot index are loaded from their file
+	env.gopts.SnapshotIndex = false
+	testRunBackup(t, "", []string{env.testdata}, opts, env.gopts)
+	rtest.Equals(t, indexIDs, testRunList(t, "snapshot-index", env.gopts))
+	env.gopts.SnapshotIndex = true
+	_, snapmap = testRunSnapshots(t, env.gopts)
+	rtest.Equals(t, 2, len(snapmap))
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 )
 
@@ -17,3 +19,147 @@ func TestEmptySnapshotGroupJSON(t *testing.T) {
 		rtest.Equals(t, "[]", strings.TrimSpace(w.String()))
 	}
 }
+
+func TestPrintSnapshotsGroupPaths(t *testing.T) {
+	var list restic.Snapshots
+	for i, paths := range [][]string{{"/home"}, {"/etc"}, {"/home"}, {"/etc"}} {
+		sn, err := restic.NewSnapshot(paths, nil, "host", time.Unix(int64(i)*3600, 0))
+		rtest.OK(t, err)
+		list = append(list, sn)
+	}
+
+	var w strings.Builder
+	PrintSnapshots(&w, list, nil, false, true, nil, 0)
+	out := w.String()
+
+	rtest.Equals(t, 1, strings.Count(out, "paths [/home]:"))
+	rtest.Equals(t, 1, strings.Count(out, "paths [/etc]:"))
+	rtest.Assert(t, strings.Index(out, "paths [/etc]:") < strings.Index(out, "paths [/home]:"),
+		"expected groups to be sorted by paths, got:\n%s", out)
+	rtest.Assert(t, strings.Contains(out, "4 snapshots"), "missing footer in output:\n%s", out)
+}
+
+func TestPrintSnapshotsColumns(t *testing.T) {
+	sn, err := restic.NewSnapshot([]string{"/home", "/etc"}, []string{"foo"}, "myhost", time.Unix(0, 0))
+	rtest.OK(t, err)
+	list := restic.Snapshots{sn}
+
+	var w strings.Builder
+	PrintSnapshots(&w, list, nil, false, false, []string{"time", "id"}, 0)
+	header := strings.Fields(strings.SplitN(w.String(), "\n", 2)[0])
+	rtest.Equals(t, []string{"Time", "ID"}, header)
+	rtest.Assert(t, !strings.Contains(w.String(), "myhost"), "unexpected host column in output:\n%s", w.String())
+
+	w.Reset()
+	PrintSnapshots(&w, list, nil, true, false, []string{"id", "paths"}, 0)
+	rtest.Assert(t, strings.Contains(w.String(), "/home,/etc"), "expected paths on a single row, got:\n%s", w.String())
+}
+
+func TestPrintSnapshotsLong(t *testing.T) {
+	old, err := restic.NewSnapshot([]string{"/home"}, nil, "myhost", time.Unix(0, 0))
+	rtest.OK(t, err)
+	sn, err := restic.NewSnapshot([]string{"/home"}, nil, "myhost", time.Unix(3600, 0))
+	rtest.OK(t, err)
+	sn.Summary = &restic.SnapshotSummary{
+		BackupStart:         time.Unix(3600, 0),
+		BackupEnd:           time.Unix(3600+95, 0),
+		TotalFilesProcessed: 42,
+		TotalBytesProcessed: 3 * 1024 * 1024,
+		OS:                  "linux",
+		Arch:                "amd64",
+	}
+
+	var w strings.Builder
+	PrintSnapshots(&w, restic.Snapshots{old, sn}, nil, false, false, longSnapshotColumns, 0)
+	lines := strings.Split(w.String(), "\n")
+	rtest.Equals(t, []string{"ID", "Time", "Host", "Tags", "Duration", "Files", "Size", "OS", "Paths"}, strings.Fields(lines[0]))
+	// the snapshot without summary only shows the other columns
+	rtest.Equals(t, 5, len(strings.Fields(lines[2])))
+	rtest.Equals(t, []string{"1:35", "42", "3.000", "MiB", "linux/amd64", "/home"}, strings.Fields(lines[3])[4:])
+}
+
+func TestPrintSnapshotsCompactReasons(t *testing.T) {
+	sn, err := restic.NewSnapshot([]string{"/home"}, nil, "myhost", time.Unix(0, 0))
+	rtest.OK(t, err)
+	restic.TestSetSnapshotID(t, sn, restic.NewRandomID())
+	list := restic.Snapshots{sn}
+	reasons := []restic.KeepReason{{Snapshot: sn, Matches: []string{"last snapshot"}}}
+
+	var w strings.Builder
+	PrintSnapshots(&w, list, nil, true, false, nil, 0)
+	rtest.Assert(t, !strings.Contains(w.String(), "Reasons"), "unexpected reasons in output:\n%s", w.String())
+
+	w.Reset()
+	PrintSnapshots(&w, list, reasons, true, false, nil, 0)
+	rtest.Assert(t, strings.Contains(w.String(), "last snapshot"), "missing reasons in output:\n%s", w.String())
+}
+
+func TestCheckSnapshotFreshness(t *testing.T) {
+	now := time.Now()
+	var list restic.Snapshots
+	for _, test := range []struct {
+		host  string
+		paths []string
+		age   time.Duration
+	}{
+		{"host", []string{"/home", "/etc"}, 2 * time.Hour},
+		{"host", []string{"/home", "/etc"}, 50 * time.Hour},
+		{"host", []string{"/srv"}, 50 * time.Hour},
+		{"other", []string{"/var"}, time.Hour},
+	} {
+		sn, err := restic.NewSnapshot(test.paths, nil, test.host, now.Add(-test.age))
+		rtest.OK(t, err)
+		restic.TestSetSnapshotID(t, sn, restic.NewRandomID())
+		list = append(list, sn)
+	}
+
+	expectations, err := parseSnapshotExpectations([]string{"host:/home", "host:/srv", "host:/var", "other:/var"})
+	rtest.OK(t, err)
+
+	results, err := checkSnapshotFreshness(list, expectations, now.Add(-24*time.Hour))
+	rtest.OK(t, err)
+
+	var status []string
+	for _, res := range results {
+		status = append(status, res.Status)
+	}
+	rtest.Equals(t, []string{"ok", "stale", "missing", "ok"}, status)
+	rtest.Equals(t, *list[0].ID(), *results[0].SnapshotID)
+	rtest.Equals(t, *list[2].ID(), *results[1].SnapshotID)
+	rtest.Assert(t, results[2].SnapshotID == nil, "unexpected snapshot for missing expectation")
+	rtest.Equals(t, *list[3].ID(), *results[3].SnapshotID)
+}
+
+func TestParseSnapshotExpectations(t *testing.T) {
+	expectations, err := parseSnapshotExpectations([]string{`host:C:\Users`})
+	rtest.OK(t, err)
+	rtest.Equals(t, []snapshotExpectation{{Host: "host", Path: `C:\Users`}}, expectations)
+
+	for _, s := range []string{"host", ":/home", "host:"} {
+		_, err := parseSnapshotExpectations([]string{s})
+		rtest.Assert(t, err != nil, "expected error for %q", s)
+	}
+}
+
+func TestPrintSnapshotCounts(t *testing.T) {
+	var list restic.Snapshots
+	for _, host := range []string{"foo", "bar", "foo"} {
+		sn, err := restic.NewSnapshot([]string{"/home"}, nil, host, time.Unix(0, 0))
+		rtest.OK(t, err)
+		list = append(list, sn)
+	}
+	groups, _, err := restic.GroupSnapshots(list, restic.SnapshotGroupByOptions{Host: true})
+	rtest.OK(t, err)
+
+	var w strings.Builder
+	rtest.OK(t, printSnapshotCounts(&w, groups, false, false))
+	rtest.Equals(t, "3\n", w.String())
+
+	w.Reset()
+	rtest.OK(t, printSnapshotCounts(&w, groups, true, false))
+	rtest.Equals(t, "1 snapshots for (host [bar])\n2 snapshots for (host [foo])\n", w.String())
+
+	w.Reset()
+	rtest.OK(t, printSnapshotCounts(&w, groups, true, true))
+	rtest.Equals(t, `[{"group_key":{"hostname":"bar","paths":null,"tags":null},"count":1},{"group_key":{"hostname":"foo","paths":null,"tags":null},"count":2}]`+"\n", w.String())
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/restic/chunker"
 	"github.com/restic/restic/internal/crypto"
+	"github.com/restic/restic/internal/pack"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/restorer"
@@ -131,12 +132,24 @@ func runStats(ctx context.Context, opts StatsOptions, gopts GlobalOptions, args
 	}
 
 	if opts.countMode == countModeRawData {
+		stats.BlobSizes = &blobSizeHistograms{
+			Data: newSizeHistogram(2 * chunker.MaxSize),
+			Tree: newSizeHistogram(2 * chunker.MaxSize),
+		}
+		packs := restic.NewIDSet()
+
 		// the blob handles have been collected, but not yet counted
 		for blobHandle := range stats.blobs {
 			pbs := repo.Index().Lookup(blobHandle)
 			if len(pbs) == 0 {
 				return fmt.Errorf("blob %v not found", blobHandle)
 			}
+			packs.Insert(pbs[0].PackID)
+			if pbs[0].Type == restic.TreeBlob {
+				stats.BlobSizes.Tree.Add(uint64(pbs[0].Length))
+			} else {
+				stats.BlobSizes.Data.Add(uint64(pbs[0].Length))
+			}
 			stats.TotalSize += uint64(pbs[0].Length)
 			if repo.Config().Version >= 2 {
 				stats.TotalUncompressedSize += uint64(crypto.CiphertextLength(int(pbs[0].DataLength())))
@@ -147,6 +160,18 @@ func runStats(ctx context.Context, opts StatsOptions, gopts GlobalOptions, args
 			}
 			stats.TotalBlobCount++
 		}
+		if stats.TotalBlobCount > 0 {
+			stats.AverageBlobSize = stats.TotalSize / stats.TotalBlobCount
+		}
+
+		// the size of the packs containing the counted blobs
+		stats.PackSizes = newSizeHistogram(2 * repository.MaxPackSize)
+		for id, size := range pack.Size(ctx, repo.Index(), false) {
+			if packs.Has(id) {
+				stats.PackSizes.Add(uint64(size))
+			}
+		}
+
 		if stats.TotalCompressedBlobsSize > 0 {
 			stats.CompressionRatio = float64(stats.TotalCompressedBlobsUncompressedSize) / float64(stats.TotalCompressedBlobsSize)
 		}
@@ -185,6 +210,12 @@ func runStats(ctx context.Context, opts StatsOptions, gopts GlobalOptions, args
 	if stats.CompressionSpaceSaving > 0 {
 		Printf("Compression Space Saving:  %.2f%%\n", stats.CompressionSpaceSaving)
 	}
+	if stats.AverageBlobSize > 0 {
+		Printf("       Average Blob Size:  %-5s\n", ui.FormatBytes(stats.AverageBlobSize))
+	}
+	if stats.PackSizes != nil {
+		Verbosef("\nPack Sizes:\n%v\nData Blob Sizes:\n%v\nTree Blob Sizes:\n%v", stats.PackSizes, stats.BlobSizes.Data, stats.BlobSizes.Tree)
+	}
 
 	return nil
 }
@@ -320,6 +351,10 @@ type statsContainer struct {
 	CompressionSpaceSaving               float64 `json:"compression_space_saving,omitempty"`
 	TotalFileCount                       uint64  `json:"total_file_count,omitempty"`
 	TotalBlobCount                       uint64  `json:"total_blob_count,omitempty"`
+	AverageBlobSize                      uint64  `json:"average_blob_size,omitempty"`
+	// size distributions, only collected in raw-data mode
+	PackSizes *sizeHistogram      `json:"pack_size_histogram,omitempty"`
+	BlobSizes *blobSizeHistograms `json:"blob_size_histogram,omitempty"`
 	// holds count of all considered snapshots
 	SnapshotsCount int `json:"snapshots_count"`
 
@@ -336,6 +371,12 @@ type statsContainer struct {
 	blobs restic.BlobSet
 }
 
+// blobSizeHistograms holds the size distribution for each blob type.
+type blobSizeHistograms struct {
+	Data *sizeHistogram `json:"data"`
+	Tree *sizeHistogram `json:"tree"`
+}
+
 // fileID is a 256-bit hash that distinguishes unique files.
 type fileID [32]byte
 
@@ -433,6 +474,32 @@ func (s *sizeHistogram) Add(size uint64) {
 	s.oversized = append(s.oversized, size)
 }
 
+// MarshalJSON returns the histogram with all size classes as JSON.
+func (s sizeHistogram) MarshalJSON() ([]byte, error) {
+	type sizeClassJSON struct {
+		Lower uint64 `json:"lower"`
+		Upper uint64 `json:"upper"`
+		Count int64  `json:"count"`
+	}
+	type sizeHistogramJSON struct {
+		Count     int64           `json:"count"`
+		TotalSize uint64          `json:"total_size"`
+		Buckets   []sizeClassJSON `json:"buckets"`
+		Oversized []uint64        `json:"oversized,omitempty"`
+	}
+
+	out := sizeHistogramJSON{
+		Count:     s.count,
+		TotalSize: s.totalSize,
+		Buckets:   make([]sizeClassJSON, 0, len(s.buckets)),
+		Oversized: s.oversized,
+	}
+	for _, b := range s.buckets {
+		out.Buckets = append(out.Buckets, sizeClassJSON{Lower: b.lower, Upper: b.upper, Count: b.count})
+	}
+	return json.Marshal(out)
+}
+
 func (s sizeHistogram) String() string {
 	var out strings.Builder
 
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"testing"
 
 	rtest "github.com/restic/restic/internal/test"
@@ -60,3 +61,14 @@ func TestSizeHistogramString(t *testing.T) {
 		rtest.Equals(t, "Count: 3\nTotal Size: 11 B\nSize          Count\n-------------------\n  0 - 0 Byte  1\n  1 - 9 Byte  1\n10 - 42 Byte  1\n-------------------\n", h.String())
 	})
 }
+
+func TestSizeHistogramJSON(t *testing.T) {
+	h := newSizeHistogram(42)
+	for _, size := range []uint64{0, 5, 12, 50} {
+		h.Add(size)
+	}
+
+	buf, err := json.Marshal(h)
+	rtest.OK(t, err)
+	rtest.Equals(t, `{"count":4,"total_size":67,"buckets":[{"lower":0,"upper":0,"count":1},{"lower":1,"upper":9,"count":1},{"lower":10,"upper":42,"count":1}],"oversized":[50]}`, string(buf))
+}
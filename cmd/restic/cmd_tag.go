@@ -54,9 +54,9 @@ func init() {
 	initMultiSnapshotFilter(tagFlags, &tagOptions.SnapshotFilter, true)
 }
 
-func changeTags(ctx context.Context, repo *repository.Repository, sn *restic.Snapshot, setTags, addTags, removeTags []string) (bool, error) {
-	var changed bool
-
+// changeTags modifies the tags of sn. If they changed, the snapshot is saved
+// with a new ID, which is returned, and the old snapshot is removed.
+func changeTags(ctx context.Context, repo *repository.Repository, sn *restic.Snapshot, setTags, addTags, removeTags []string) (changed bool, newID restic.ID, err error) {
 	if len(setTags) != 0 {
 		// Setting the tag to an empty string really means no tags.
 		if len(setTags) == 1 && setTags[0] == "" {
@@ -78,22 +78,22 @@ func changeTags(ctx context.Context, repo *repository.Repository, sn *restic.Sna
 		}
 
 		// Save the new snapshot.
-		id, err := restic.SaveSnapshot(ctx, repo, sn)
+		newID, err = restic.SaveSnapshot(ctx, repo, sn)
 		if err != nil {
-			return false, err
+			return false, restic.ID{}, err
 		}
 
-		debug.Log("new snapshot saved as %v", id)
+		debug.Log("new snapshot saved as %v", newID)
 
 		// Remove the old snapshot.
 		h := backend.Handle{Type: restic.SnapshotFile, Name: sn.ID().String()}
 		if err = repo.Backend().Remove(ctx, h); err != nil {
-			return false, err
+			return false, restic.ID{}, err
 		}
 
 		debug.Log("old snapshot %v removed", sn.ID())
 	}
-	return changed, nil
+	return changed, newID, nil
 }
 
 func runTag(ctx context.Context, opts TagOptions, gopts GlobalOptions, args []string) error {
@@ -119,21 +119,31 @@ func runTag(ctx context.Context, opts TagOptions, gopts GlobalOptions, args []st
 		}
 	}
 
+	snapshotLister, err := restic.MemorizeList(ctx, repo, restic.SnapshotFile)
+	if err != nil {
+		return err
+	}
+
 	changeCnt := 0
-	for sn := range FindFilteredSnapshots(ctx, repo, repo, &opts.SnapshotFilter, args) {
-		changed, err := changeTags(ctx, repo, sn, opts.SetTags.Flatten(), opts.AddTags.Flatten(), opts.RemoveTags.Flatten())
+	var added restic.IDs
+	removed := restic.NewIDSet()
+	for sn := range FindFilteredSnapshots(ctx, snapshotLister, repo, &opts.SnapshotFilter, args) {
+		changed, newID, err := changeTags(ctx, repo, sn, opts.SetTags.Flatten(), opts.AddTags.Flatten(), opts.RemoveTags.Flatten())
 		if err != nil {
 			Warnf("unable to modify the tags for snapshot ID %q, ignoring: %v\n", sn.ID(), err)
 			continue
 		}
 		if changed {
 			changeCnt++
+			added = append(added, newID)
+			removed.Insert(*sn.ID())
 		}
 	}
 	if changeCnt == 0 {
 		Verbosef("no snapshots were modified\n")
 	} else {
 		Verbosef("modified tags on %v snapshots\n", changeCnt)
+		updateSnapshotIndex(ctx, gopts, repo, snapshotLister, added, removed)
 	}
 	return nil
 }
@@ -48,6 +48,14 @@ func deleteFiles(ctx context.Context, gopts GlobalOptions, ignoreError bool, rep
 			for id := range fileChan {
 				h := backend.Handle{Type: fileType, Name: id.String()}
 				err := repo.Backend().Remove(ctx, h)
+				if err != nil && repo.Backend().IsNotExist(err) {
+					// the file was removed concurrently, e.g. by another
+					// client running forget, the result is the same
+					if !gopts.JSON {
+						Warnf("%v has already been removed from the repository\n", h)
+					}
+					err = nil
+				}
 				if err != nil {
 					if !gopts.JSON {
 						Warnf("unable to remove %v from the repository\n", h)
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
@@ -108,10 +109,11 @@ func rejectByInsensitivePattern(patterns []string) RejectByNameFunc {
 // rejectIfPresent returns a RejectByNameFunc which itself returns whether a path
 // should be excluded. The RejectByNameFunc considers a file to be excluded when
 // it resides in a directory with an exclusion file, that is specified by
-// excludeFileSpec in the form "filename[:content]". The returned error is
-// non-nil if the filename component of excludeFileSpec is empty. If rc is
-// non-nil, it is going to be used in the RejectByNameFunc to expedite the evaluation
-// of a directory based on previous visits.
+// excludeFileSpec in the form "filename[:content]". If content is given, the
+// exclusion file must start with it or contain a line equal to it. The returned
+// error is non-nil if the filename component of excludeFileSpec is empty. If rc
+// is non-nil, it is going to be used in the RejectByNameFunc to expedite the
+// evaluation of a directory based on previous visits.
 func rejectIfPresent(excludeFileSpec string) (RejectByNameFunc, error) {
 	if excludeFileSpec == "" {
 		return nil, errors.New("name for exclusion tagfile is empty")
@@ -137,8 +139,8 @@ func rejectIfPresent(excludeFileSpec string) (RejectByNameFunc, error) {
 
 // isExcludedByFile interprets filename as a path and returns true if that file
 // is in an excluded directory. A directory is identified as excluded if it contains a
-// tagfile which bears the name specified in tagFilename and starts with
-// header. If rc is non-nil, it is used to expedite the evaluation of a
+// tagfile which bears the name specified in tagFilename and either starts with
+// header or contains a line equal to header. If rc is non-nil, it is used to expedite the evaluation of a
 // directory based on previous visits.
 func isExcludedByFile(filename, tagFilename, header string, rc *rejectionCache) bool {
 	if tagFilename == "" {
@@ -160,6 +162,18 @@ func isExcludedByFile(filename, tagFilename, header string, rc *rejectionCache)
 	return rejected
 }
 
+// cacheDirTagFilename and cacheDirTagSignature identify cache directories
+// according to the Cache Directory Tagging Standard, see
+// https://bford.info/cachedir/.
+const (
+	cacheDirTagFilename  = "CACHEDIR.TAG"
+	cacheDirTagSignature = "Signature: 8a477f597d28d172789f06886806bc55"
+)
+
+// maxTagfileRead is the number of bytes read from an exclusion tagfile when
+// looking for the required content.
+const maxTagfileRead = 64 * 1024
+
 func isDirExcludedByFile(dir, tagFilename, header string) bool {
 	tf := filepath.Join(dir, tagFilename)
 	_, err := fs.Lstat(tf)
@@ -175,7 +189,7 @@ func isDirExcludedByFile(dir, tagFilename, header string) bool {
 	if len(header) == 0 {
 		return true
 	}
-	// From this stage, errors mean tagFilename exists but it is malformed.
+	// From this stage, errors mean tagFilename exists but cannot be read.
 	// Warnings will be generated so that the user is informed that the
 	// indented ignore-action is not performed.
 	f, err := os.Open(tf)
@@ -186,22 +200,46 @@ func isDirExcludedByFile(dir, tagFilename, header string) bool {
 	defer func() {
 		_ = f.Close()
 	}()
-	buf := make([]byte, len(header))
-	_, err = io.ReadFull(f, buf)
-	// EOF is handled with a dedicated message, otherwise the warning were too cryptic
-	if err == io.EOF {
-		Warnf("invalid (too short) signature in exclusion tagfile %q\n", tf)
-		return false
-	}
+	buf, err := io.ReadAll(io.LimitReader(f, maxTagfileRead))
 	if err != nil {
 		Warnf("could not read signature from exclusion tagfile %q: %v\n", tf, err)
 		return false
 	}
-	if !bytes.Equal(buf, []byte(header)) {
-		Warnf("invalid signature in exclusion tagfile %q\n", tf)
+	if tagfileContains(buf, []byte(header), len(buf) == maxTagfileRead) {
+		return true
+	}
+	if tagFilename != cacheDirTagFilename || header != cacheDirTagSignature {
+		// the content may be changed on purpose to toggle the exclusion
+		debug.Log("exclusion tagfile %q does not contain %q", tf, header)
 		return false
 	}
-	return true
+	// a dedicated message for a short file, otherwise the warning were too cryptic
+	if len(buf) < len(header) {
+		Warnf("invalid (too short) signature in exclusion tagfile %q\n", tf)
+		return false
+	}
+	Warnf("invalid signature in exclusion tagfile %q\n", tf)
+	return false
+}
+
+// tagfileContains returns true if buf starts with header or contains a line
+// equal to header. If truncated is set, the last line of buf is incomplete and
+// ignored.
+func tagfileContains(buf, header []byte, truncated bool) bool {
+	if bytes.HasPrefix(buf, header) {
+		return true
+	}
+
+	lines := bytes.Split(buf, []byte("\n"))
+	if truncated {
+		lines = lines[:len(lines)-1]
+	}
+	for _, line := range lines {
+		if bytes.Equal(bytes.TrimSuffix(line, []byte("\r")), header) {
+			return true
+		}
+	}
+	return false
 }
 
 // DeviceMap is used to track allowed source devices for backup. This is used to
@@ -339,6 +377,133 @@ func rejectByDevice(samples []string) (RejectFunc, error) {
 	}, nil
 }
 
+// isMountPoint reports whether the directory dir resides on a different device
+// than its parent directory.
+func isMountPoint(dir string, fi os.FileInfo) (bool, error) {
+	id, err := fs.DeviceID(fi)
+	if err != nil {
+		return false, err
+	}
+
+	parentFI, err := fs.Lstat(filepath.Dir(dir))
+	if err != nil {
+		return false, err
+	}
+
+	parentID, err := fs.DeviceID(parentFI)
+	if err != nil {
+		return false, err
+	}
+
+	return id != parentID, nil
+}
+
+// rejectMountPoints returns a RejectFunc that rejects the directories in
+// paths, provided that a different file system is mounted there. A warning is
+// printed for each path which is currently not a mount point.
+func rejectMountPoints(paths []string) (RejectFunc, error) {
+	mounts := make(map[string]struct{}, len(paths))
+	for _, p := range paths {
+		p, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+
+		fi, err := fs.Lstat(p)
+		if err != nil {
+			Warnf("--exclude-mount: %v\n", err)
+		} else if !fi.IsDir() {
+			Warnf("--exclude-mount: %v is not a directory\n", p)
+		} else {
+			mount, err := isMountPoint(p, fi)
+			if err != nil {
+				return nil, errors.Fatalf("--exclude-mount: %v", err)
+			}
+			if !mount {
+				Warnf("--exclude-mount: %v is not a mount point, it will not be excluded\n", p)
+			}
+		}
+
+		mounts[p] = struct{}{}
+	}
+	debug.Log("excluded mount points: %v", mounts)
+
+	// both the scanner and the archiver check each directory
+	var mu sync.Mutex
+	skipped := make(map[string]struct{})
+
+	return func(item string, fi os.FileInfo) bool {
+		if !fi.IsDir() {
+			return false
+		}
+
+		item = filepath.Clean(item)
+		if _, ok := mounts[item]; !ok {
+			return false
+		}
+
+		mount, err := isMountPoint(item, fi)
+		if err != nil {
+			debug.Log("item %v: error checking for mount point: %v", item, err)
+			return false
+		}
+		if !mount {
+			return false
+		}
+
+		mu.Lock()
+		_, seen := skipped[item]
+		skipped[item] = struct{}{}
+		mu.Unlock()
+		if !seen && !globalOptions.JSON {
+			Verboseff("skipping mount point %v\n", item)
+		}
+		return true
+	}, nil
+}
+
+// commonCacheDirs are the names of directories excluded by
+// --exclude-common-caches. They contain caches and build output of common
+// tools, which can be recreated.
+var commonCacheDirs = []string{
+	".cache",
+	".gradle",
+	".mypy_cache",
+	".pytest_cache",
+	".tox",
+	"__pycache__",
+	"node_modules",
+	"target",
+}
+
+// rejectCommonCaches returns a RejectFunc that rejects directories named like
+// one of commonCacheDirs or add, unless the name is contained in keep.
+func rejectCommonCaches(add, keep []string) RejectFunc {
+	names := make(map[string]struct{}, len(commonCacheDirs)+len(add))
+	for _, name := range commonCacheDirs {
+		names[name] = struct{}{}
+	}
+	for _, name := range add {
+		names[name] = struct{}{}
+	}
+	for _, name := range keep {
+		delete(names, name)
+	}
+
+	return func(item string, fi os.FileInfo) bool {
+		if !fi.IsDir() {
+			return false
+		}
+
+		if _, ok := names[filepath.Base(item)]; !ok {
+			return false
+		}
+
+		debug.Log("rejecting common cache directory %v", item)
+		return true
+	}
+}
+
 // rejectResticCache returns a RejectByNameFunc that rejects the restic cache
 // directory (if set).
 func rejectResticCache(repo *repository.Repository) (RejectByNameFunc, error) {
@@ -364,25 +529,203 @@ func rejectResticCache(repo *repository.Repository) (RejectByNameFunc, error) {
 }
 
 func rejectBySize(maxSizeStr string) (RejectFunc, error) {
-	maxSize, err := ui.ParseBytes(maxSizeStr)
+	l, err := newSizeLimiter(maxSizeStr, nil)
 	if err != nil {
 		return nil, err
 	}
+	return l.reject, nil
+}
+
+// noSizeLimit is the limit of files whose size is not restricted.
+const noSizeLimit = -1
+
+// sizeLimitRule sets the maximum size of the files below path.
+type sizeLimitRule struct {
+	path    string
+	maxSize int64
+}
+
+// sizeLimiter rejects files which are larger than the limit of the most
+// specific rule for their path, that is the rule with the longest path. Files
+// not covered by any rule use the default limit. The rejected files are
+// recorded for the summary of the backup.
+type sizeLimiter struct {
+	defaultSize int64
+	rules       []sizeLimitRule
+
+	mu       sync.Mutex
+	excluded map[string]int64
+}
+
+// newSizeLimiter returns a sizeLimiter with the default limit maxSizeStr,
+// which may be empty for no limit.
+func newSizeLimiter(maxSizeStr string, rules []sizeLimitRule) (*sizeLimiter, error) {
+	l := &sizeLimiter{
+		defaultSize: noSizeLimit,
+		rules:       rules,
+		excluded:    make(map[string]int64),
+	}
+
+	if maxSizeStr != "" {
+		maxSize, err := ui.ParseBytes(maxSizeStr)
+		if err != nil {
+			return nil, err
+		}
+		l.defaultSize = maxSize
+	}
+
+	return l, nil
+}
 
+// limit returns the maximum size of the file item.
+func (l *sizeLimiter) limit(item string) int64 {
+	if len(l.rules) == 0 {
+		return l.defaultSize
+	}
+
+	// rules use absolute paths
+	if abs, err := filepath.Abs(item); err == nil {
+		item = abs
+	}
+
+	limit, matched := l.defaultSize, -1
+	for _, rule := range l.rules {
+		if len(rule.path) > matched && fs.HasPathPrefix(rule.path, item) {
+			limit, matched = rule.maxSize, len(rule.path)
+		}
+	}
+	return limit
+}
+
+func (l *sizeLimiter) reject(item string, fi os.FileInfo) bool {
+	// directory will be ignored
+	if fi.IsDir() {
+		return false
+	}
+
+	maxSize := l.limit(item)
+	filesize := fi.Size()
+	if maxSize == noSizeLimit || filesize <= maxSize {
+		return false
+	}
+
+	debug.Log("file %s is oversize: %d", item, filesize)
+
+	// both the scanner and the archiver check each file
+	l.mu.Lock()
+	_, seen := l.excluded[item]
+	l.excluded[item] = filesize
+	l.mu.Unlock()
+	if !seen {
+		Verboseff("excluding %v, its size of %v exceeds the limit of %v\n", item,
+			ui.FormatBytes(uint64(filesize)), ui.FormatBytes(uint64(maxSize)))
+	}
+
+	return true
+}
+
+// excludedFiles returns the number and the total size of the rejected files.
+func (l *sizeLimiter) excludedFiles() (count uint, size uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, filesize := range l.excluded {
+		count++
+		size += uint64(filesize)
+	}
+	return count, size
+}
+
+// readSizeLimitRules reads the rules for --exclude-larger-than-file. Each line
+// contains a size followed by an absolute path, for example "10G /srv/media".
+// The size "unlimited" allows files of any size below the path. Empty lines and
+// lines starting with a # are ignored.
+func readSizeLimitRules(filename string) ([]sizeLimitRule, error) {
+	data, err := textfile.Read(filename)
+	if err != nil {
+		return nil, errors.Fatalf("--exclude-larger-than-file: %v", err)
+	}
+
+	var rules []sizeLimitRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		i := strings.IndexAny(line, " \t")
+		if i < 0 {
+			return nil, errors.Fatalf("%v:%d: expected a size followed by a path", filename, lineNo)
+		}
+		sizeStr, path := line[:i], strings.TrimSpace(line[i+1:])
+
+		rule := sizeLimitRule{maxSize: noSizeLimit}
+		if sizeStr != "unlimited" {
+			rule.maxSize, err = ui.ParseBytes(sizeStr)
+			if err != nil {
+				return nil, errors.Fatalf("%v:%d: %v", filename, lineNo, err)
+			}
+		}
+
+		rule.path, err = filepath.Abs(path)
+		if err != nil {
+			return nil, errors.Fatalf("%v:%d: %v", filename, lineNo, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Fatalf("--exclude-larger-than-file: %v", err)
+	}
+
+	return rules, nil
+}
+
+// rejectByModTime returns a RejectFunc which rejects all files that were last
+// modified before cutoff. Directories are never rejected, so that modified files
+// within them are still found.
+func rejectByModTime(cutoff time.Time) RejectFunc {
 	return func(item string, fi os.FileInfo) bool {
-		// directory will be ignored
 		if fi.IsDir() {
 			return false
 		}
 
-		filesize := fi.Size()
-		if filesize > maxSize {
-			debug.Log("file %s is oversize: %d", item, filesize)
+		if fi.ModTime().Before(cutoff) {
+			debug.Log("file %s was not modified since %v", item, cutoff)
 			return true
 		}
 
 		return false
-	}, nil
+	}
+}
+
+// rejectCloudFiles returns a RejectFunc which rejects placeholder files of
+// online storage providers, whose content is not available locally. Reading
+// such a file would trigger its download. On platforms which do not support
+// placeholder files, nil is returned.
+func rejectCloudFiles() RejectFunc {
+	if !fs.CloudPlaceholdersSupported {
+		return nil
+	}
+
+	// both the scanner and the archiver check each file
+	var mu sync.Mutex
+	skipped := make(map[string]struct{})
+
+	return func(item string, fi os.FileInfo) bool {
+		if fi.IsDir() || !fs.IsCloudPlaceholder(fi) {
+			return false
+		}
+
+		mu.Lock()
+		_, seen := skipped[item]
+		skipped[item] = struct{}{}
+		mu.Unlock()
+		if !seen && !globalOptions.JSON {
+			Verboseff("skipping cloud placeholder %v\n", item)
+		}
+		return true
+	}
 }
 
 // readExcludePatternsFromFiles reads all exclude files and returns the list of
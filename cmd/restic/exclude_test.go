@@ -1,10 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/test"
 )
 
@@ -81,6 +86,11 @@ func TestIsExcludedByFile(t *testing.T) {
 		{"ValidSig", tagFilename, header, true},
 		{"ValidPlusStuff", tagFilename, header + "foo", true},
 		{"ValidPlusNewlineAndStuff", tagFilename, header + "\nbar", true},
+		{"ValidLine", tagFilename, "foo\n" + header + "\r\nbar", true},
+		{"ValidLastLine", tagFilename, "foo\n" + header, true},
+		{"SigWithinLine", tagFilename, "foo " + header + "\n", false},
+		{"SigBeyondReadLimit", tagFilename, strings.Repeat("\n", maxTagfileRead) + header, false},
+		{"SigTruncatedByReadLimit", tagFilename, strings.Repeat("\n", maxTagfileRead-10) + header, false},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -109,6 +119,39 @@ func TestIsExcludedByFile(t *testing.T) {
 	}
 }
 
+func TestIsExcludedByFileWarning(t *testing.T) {
+	for _, tc := range []struct {
+		tagFilename string
+		header      string
+		content     string
+		warning     string
+	}{
+		{cacheDirTagFilename, cacheDirTagSignature, cacheDirTagSignature, ""},
+		{cacheDirTagFilename, cacheDirTagSignature, "foo\n" + cacheDirTagSignature, ""},
+		{cacheDirTagFilename, cacheDirTagSignature, cacheDirTagSignature[1:], "invalid (too short) signature"},
+		{cacheDirTagFilename, cacheDirTagSignature, "foo " + cacheDirTagSignature + "\n", "invalid signature"},
+		{".nobackup", "SKIP", "", ""},
+		{".nobackup", "SKIP", "no backup\n", ""},
+	} {
+		tempDir := test.TempDir(t)
+		foo := filepath.Join(tempDir, "foo")
+		test.OK(t, os.WriteFile(foo, []byte("foo"), 0666))
+		test.OK(t, os.WriteFile(filepath.Join(tempDir, tc.tagFilename), []byte(tc.content), 0666))
+
+		stderr := &bytes.Buffer{}
+		test.OK(t, withRestoreGlobalOptions(func() error {
+			globalOptions.stderr = stderr
+			_ = isExcludedByFile(foo, tc.tagFilename, tc.header, nil)
+			return nil
+		}))
+		if tc.warning == "" {
+			test.Equals(t, "", stderr.String())
+		} else {
+			test.Assert(t, strings.Contains(stderr.String(), tc.warning), "missing warning %q, got %q", tc.warning, stderr.String())
+		}
+	}
+}
+
 // TestMultipleIsExcludedByFile is for testing that multiple instances of
 // the --exclude-if-present parameter (or the shortcut --exclude-caches do not
 // cancel each other out. It was initially written to demonstrate a bug in
@@ -267,6 +310,205 @@ func TestIsExcludedByFileSize(t *testing.T) {
 	}
 }
 
+func TestSizeLimiterRules(t *testing.T) {
+	tempDir := test.TempDir(t)
+
+	media := filepath.Join(tempDir, "media")
+	rulesFile := filepath.Join(tempDir, "rules")
+	test.OK(t, os.WriteFile(rulesFile, []byte("# allow large media files\n"+
+		"unlimited "+media+"\n"+
+		"\n"+
+		"2k\t"+filepath.Join(media, "cache")+"\n"), 0600))
+
+	rules, err := readSizeLimitRules(rulesFile)
+	test.OK(t, err)
+	test.Equals(t, []sizeLimitRule{
+		{path: media, maxSize: noSizeLimit},
+		{path: filepath.Join(media, "cache"), maxSize: 2048},
+	}, rules)
+
+	files := []struct {
+		path     string
+		size     int64
+		rejected bool
+	}{
+		{"small", 100, false},
+		{"large", 1500, true},
+		{"media/large", 1 << 20, false},
+		{"media/cache/small", 1500, false},
+		{"media/cache/large", 3000, true},
+		{"media-other/large", 1500, true},
+	}
+
+	l, err := newSizeLimiter("1k", rules)
+	test.OK(t, err)
+	for _, f := range files {
+		p := filepath.Join(tempDir, filepath.FromSlash(f.path))
+		test.OK(t, os.MkdirAll(filepath.Dir(p), 0700))
+		test.OK(t, os.WriteFile(p, make([]byte, f.size), 0600))
+
+		fi, err := os.Lstat(p)
+		test.OK(t, err)
+		test.Assert(t, l.reject(p, fi) == f.rejected, "unexpected result for %v, want rejected=%v", f.path, f.rejected)
+		// files checked again by the archiver are only counted once
+		test.Assert(t, l.reject(p, fi) == f.rejected, "unexpected result for %v, want rejected=%v", f.path, f.rejected)
+	}
+
+	count, size := l.excludedFiles()
+	test.Equals(t, uint(3), count)
+	test.Equals(t, uint64(1500+3000+1500), size)
+
+	for _, content := range []string{"10k", "large /srv", "10x /srv"} {
+		test.OK(t, os.WriteFile(rulesFile, []byte(content), 0600))
+		_, err := readSizeLimitRules(rulesFile)
+		test.Assert(t, err != nil, "missing error for rules %q", content)
+	}
+}
+
+func TestRejectCloudFiles(t *testing.T) {
+	reject := rejectCloudFiles()
+	if !fs.CloudPlaceholdersSupported {
+		test.Assert(t, reject == nil, "expected no reject function on unsupported platform")
+		return
+	}
+
+	tempDir := test.TempDir(t)
+	p := filepath.Join(tempDir, "file")
+	test.OK(t, os.WriteFile(p, []byte("content"), 0600))
+
+	for _, name := range []string{tempDir, p} {
+		fi, err := os.Lstat(name)
+		test.OK(t, err)
+		test.Assert(t, !reject(name, fi), "local file %v was rejected", name)
+	}
+}
+
+func TestRejectByModTime(t *testing.T) {
+	tempDir := test.TempDir(t)
+	cutoff := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+
+	for name, mtime := range map[string]time.Time{
+		"old":   cutoff.Add(-time.Hour),
+		"new":   cutoff.Add(time.Hour),
+		"exact": cutoff,
+	} {
+		p := filepath.Join(tempDir, name)
+		test.OK(t, os.WriteFile(p, []byte(name), 0600))
+		test.OK(t, os.Chtimes(p, mtime, mtime))
+	}
+
+	// directories are never rejected, regardless of their mtime
+	test.OK(t, os.Chtimes(tempDir, cutoff.Add(-time.Hour), cutoff.Add(-time.Hour)))
+
+	reject := rejectByModTime(cutoff)
+	for name, want := range map[string]bool{
+		tempDir:                         false,
+		filepath.Join(tempDir, "old"):   true,
+		filepath.Join(tempDir, "new"):   false,
+		filepath.Join(tempDir, "exact"): false,
+	} {
+		fi, err := os.Lstat(name)
+		test.OK(t, err)
+		test.Assert(t, reject(name, fi) == want, "unexpected result for %v, want rejected=%v", name, want)
+	}
+}
+
+func TestRejectCommonCaches(t *testing.T) {
+	tempDir := test.TempDir(t)
+	for _, name := range []string{"node_modules", "target", "build", "src"} {
+		test.OK(t, os.Mkdir(filepath.Join(tempDir, name), 0700))
+	}
+	// only directories are rejected
+	test.OK(t, os.WriteFile(filepath.Join(tempDir, "src", ".cache"), []byte("file"), 0600))
+
+	reject := rejectCommonCaches([]string{"build"}, []string{"target"})
+	for name, want := range map[string]bool{
+		"node_modules": true,
+		"target":       false,
+		"build":        true,
+		"src":          false,
+		"src/.cache":   false,
+	} {
+		p := filepath.Join(tempDir, filepath.FromSlash(name))
+		fi, err := os.Lstat(p)
+		test.OK(t, err)
+		test.Assert(t, reject(p, fi) == want, "unexpected result for %v, want rejected=%v", name, want)
+	}
+}
+
+func TestRejectMountPoints(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("device IDs are not supported on Windows")
+	}
+
+	tempDir := test.TempDir(t)
+	dir := filepath.Join(tempDir, "dir")
+	test.OK(t, os.Mkdir(dir, 0700))
+
+	paths := []string{dir, filepath.Join(tempDir, "missing")}
+	// /proc is a separate file system on Linux
+	proc := "/proc"
+	procFI, err := os.Lstat(proc)
+	haveProc := err == nil && procFI.IsDir()
+	if haveProc {
+		mount, err := isMountPoint(proc, procFI)
+		test.OK(t, err)
+		haveProc = mount
+	}
+	if haveProc {
+		paths = append(paths, proc)
+	}
+
+	reject, err := rejectMountPoints(paths)
+	test.OK(t, err)
+
+	// directories which are not a mount point are never rejected
+	for _, name := range []string{tempDir, dir} {
+		fi, err := os.Lstat(name)
+		test.OK(t, err)
+		test.Assert(t, !reject(name, fi), "directory %v was rejected", name)
+	}
+
+	if haveProc {
+		test.Assert(t, reject(proc, procFI), "mount point %v was not rejected", proc)
+	}
+}
+
+func TestRejectMountPointsMessage(t *testing.T) {
+	const proc = "/proc"
+	procFI, err := os.Lstat(proc)
+	if err != nil {
+		t.Skipf("%v not available: %v", proc, err)
+	}
+	mount, err := isMountPoint(proc, procFI)
+	test.OK(t, err)
+	if !mount {
+		t.Skipf("%v is not a mount point", proc)
+	}
+
+	for _, json := range []bool{false, true} {
+		stdout := &bytes.Buffer{}
+		test.OK(t, withRestoreGlobalOptions(func() error {
+			globalOptions.stdout = stdout
+			globalOptions.verbosity = 2
+			globalOptions.JSON = json
+
+			reject, err := rejectMountPoints([]string{proc})
+			test.OK(t, err)
+			// the scanner and the archiver both check the directory
+			test.Assert(t, reject(proc, procFI), "mount point %v was not rejected", proc)
+			test.Assert(t, reject(proc, procFI), "mount point %v was not rejected", proc)
+			return nil
+		}))
+
+		want := "skipping mount point /proc\n"
+		if json {
+			want = ""
+		}
+		test.Equals(t, want, stdout.String())
+	}
+}
+
 func TestDeviceMap(t *testing.T) {
 	deviceMap := DeviceMap{
 		filepath.FromSlash("/"):          1,
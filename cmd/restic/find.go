@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"time"
 
 	"github.com/restic/restic/internal/restic"
 	"github.com/spf13/pflag"
@@ -14,17 +15,69 @@ func initMultiSnapshotFilter(flags *pflag.FlagSet, filt *restic.SnapshotFilter,
 	if !addHostShorthand {
 		hostShorthand = ""
 	}
-	flags.StringArrayVarP(&filt.Hosts, "host", hostShorthand, nil, "only consider snapshots for this `host` (can be specified multiple times)")
-	flags.Var(&filt.Tags, "tag", "only consider snapshots including `tag[,tag,...]` (can be specified multiple times)")
-	flags.StringArrayVar(&filt.Paths, "path", nil, "only consider snapshots including this (absolute) `path` (can be specified multiple times)")
+	initSnapshotFilter(flags, filt, hostShorthand, "", true)
 }
 
 // initSingleSnapshotFilter is used for commands that work on a single snapshot
 // MUST be combined with restic.FindFilteredSnapshot
 func initSingleSnapshotFilter(flags *pflag.FlagSet, filt *restic.SnapshotFilter) {
-	flags.StringArrayVarP(&filt.Hosts, "host", "H", nil, "only consider snapshots for this `host`, when snapshot ID \"latest\" is given (can be specified multiple times)")
-	flags.Var(&filt.Tags, "tag", "only consider snapshots including `tag[,tag,...]`, when snapshot ID \"latest\" is given (can be specified multiple times)")
-	flags.StringArrayVar(&filt.Paths, "path", nil, "only consider snapshots including this (absolute) `path`, when snapshot ID \"latest\" is given (can be specified multiple times)")
+	initSnapshotFilter(flags, filt, "H", latestFilterSuffix, true)
+}
+
+// latestFilterSuffix is appended to the flag descriptions of commands which
+// only use the filter to select the snapshot "latest".
+const latestFilterSuffix = `, when snapshot ID "latest" is given`
+
+// initSnapshotFilter adds the flags of the snapshot filter shared by all
+// commands. Commands which use the flag --path for another purpose pass
+// withPath = false.
+func initSnapshotFilter(flags *pflag.FlagSet, filt *restic.SnapshotFilter, hostShorthand, suffix string, withPath bool) {
+	flags.StringArrayVarP(&filt.Hosts, "host", hostShorthand, nil, "only consider snapshots for this `host`, which may contain wildcards"+suffix+" (can be specified multiple times)")
+	flags.Var(&filt.Tags, "tag", "only consider snapshots including `tag[,tag,...]`"+suffix+" (can be specified multiple times)")
+	if withPath {
+		flags.StringArrayVar(&filt.Paths, "path", nil, "only consider snapshots including this (absolute) `path`, which may contain wildcards"+suffix+" (can be specified multiple times)")
+	}
+	flags.Var(&snapshotTimeValue{t: &filt.Since}, "since", "only consider snapshots created at or after `time`, a date/time or a duration (eg. 1d2h) before now"+suffix)
+	flags.Var(&snapshotTimeValue{t: &filt.Until}, "until", "only consider snapshots created at or before `time`, a date/time or a duration (eg. 1d2h) before now"+suffix)
+}
+
+// snapshotTimeValue implements pflag.Value for the time range of a snapshot
+// filter. The time is given as a date/time or as a duration before now.
+type snapshotTimeValue struct {
+	t   *time.Time
+	str string
+}
+
+func (v *snapshotTimeValue) Set(s string) error {
+	t, err := parseTimeOrDuration(s, time.Now())
+	if err != nil {
+		return err
+	}
+	*v.t = t
+	v.str = s
+	return nil
+}
+
+func (v *snapshotTimeValue) String() string {
+	return v.str
+}
+
+func (v *snapshotTimeValue) Type() string {
+	return "time"
+}
+
+// updateSnapshotIndex updates the snapshot index if it is enabled. The
+// snapshot files are not listed again, instead snapshotLister, which was
+// returned by MemorizeList, is used together with the IDs of the snapshots
+// added and removed since. Failing to update the snapshot index is not fatal,
+// as snapshots missing from the index are loaded from their snapshot file.
+func updateSnapshotIndex(ctx context.Context, gopts GlobalOptions, repo restic.Repository, snapshotLister restic.Lister, added restic.IDs, removed restic.IDSet) {
+	if !gopts.SnapshotIndex {
+		return
+	}
+	if err := restic.UpdateSnapshotIndex(ctx, repo, snapshotLister, added, removed); err != nil {
+		Warnf("unable to update the snapshot index: %v\n", err)
+	}
 }
 
 // FindFilteredSnapshots yields Snapshots, either given explicitly by `snapshotIDs` or filtered from the list of all snapshots.
@@ -41,8 +41,13 @@ func formatNode(path string, n *restic.Node, long bool, human bool) string {
 		mode = os.ModeSocket
 	}
 
-	return fmt.Sprintf("%s %5d %5d %s %s %s%s",
+	var btime string
+	if !n.BirthTime.IsZero() {
+		btime = " (created " + n.BirthTime.Local().Format(TimeFormat) + ")"
+	}
+
+	return fmt.Sprintf("%s %5d %5d %s %s %s%s%s",
 		mode|n.Mode, n.UID, n.GID, size,
 		n.ModTime.Local().Format(TimeFormat), path,
-		target)
+		target, btime)
 }
@@ -54,6 +54,17 @@ func TestFormatNode(t *testing.T) {
 			human:  true,
 			expect: "----------  1000  2000 14.000 MiB 2020-01-02 03:04:05 " + testPath,
 		},
+		{
+			path: testPath,
+			Node: func() restic.Node {
+				n := node
+				n.BirthTime = time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+				return n
+			}(),
+			long:   true,
+			human:  false,
+			expect: "----------  1000  2000 14680064 2020-01-02 03:04:05 " + testPath + " (created 2019-01-02 03:04:05)",
+		},
 	} {
 		r := formatNode(c.path, &c.Node, c.long, c.human)
 		rtest.Equals(t, c.expect, r)
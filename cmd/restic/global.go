@@ -5,16 +5,20 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/backend/azure"
 	"github.com/restic/restic/internal/backend/b2"
+	"github.com/restic/restic/internal/backend/dav"
 	"github.com/restic/restic/internal/backend/gs"
 	"github.com/restic/restic/internal/backend/limiter"
 	"github.com/restic/restic/internal/backend/local"
@@ -60,13 +64,18 @@ type GlobalOptions struct {
 	Quiet           bool
 	Verbose         int
 	NoLock          bool
+	NoTTYDetection  bool
 	RetryLock       time.Duration
 	JSON            bool
 	CacheDir        string
+	TempDir         string
 	NoCache         bool
 	CleanupCache    bool
 	Compression     repository.CompressionMode
 	PackSize        uint
+	PackReadGap     string
+	IndexMemoryMode repository.IndexMemoryMode
+	SnapshotIndex   bool
 
 	backend.TransportOptions
 	limiter.Limits
@@ -95,13 +104,16 @@ var globalOptions = GlobalOptions{
 	stderr: os.Stderr,
 }
 
-var isReadingPassword bool
+// isReadingPassword is set while a password is read from the terminal. It is
+// accessed by the signal handler concurrently.
+var isReadingPassword atomic.Bool
 var internalGlobalCtx context.Context
 
 func init() {
 	backends := location.NewRegistry()
 	backends.Register(azure.NewFactory())
 	backends.Register(b2.NewFactory())
+	backends.Register(dav.NewFactory())
 	backends.Register(gs.NewFactory())
 	backends.Register(local.NewFactory())
 	backends.Register(rclone.NewFactory())
@@ -113,6 +125,7 @@ func init() {
 
 	var cancel context.CancelFunc
 	internalGlobalCtx, cancel = context.WithCancel(context.Background())
+	setShutdownFunc(cancel)
 	AddCleanupHandler(func(code int) (int, error) {
 		// Must be called before the unlock cleanup handler to ensure that the latter is
 		// not blocked due to limited number of backend connections, see #1434
@@ -130,10 +143,12 @@ func init() {
 	// use empty parameter name as `-v, --verbose n` instead of the correct `--verbose=n` is confusing
 	f.CountVarP(&globalOptions.Verbose, "verbose", "v", "be verbose (specify multiple times or a level using --verbose=n``, max level/times is 2)")
 	f.BoolVar(&globalOptions.NoLock, "no-lock", false, "do not lock the repository, this allows some operations on read-only repositories")
+	f.BoolVar(&globalOptions.NoTTYDetection, "no-tty-detection", false, "show progress updates even if the output is not a terminal")
 	f.DurationVar(&globalOptions.RetryLock, "retry-lock", 0, "retry to lock the repository if it is already locked, takes a value like 5m or 2h (default: no retries)")
 	f.BoolVarP(&globalOptions.JSON, "json", "", false, "set output mode to JSON for commands that support it")
 	f.StringVar(&globalOptions.CacheDir, "cache-dir", "", "set the cache `directory`. (default: use system default cache directory)")
 	f.BoolVar(&globalOptions.NoCache, "no-cache", false, "do not use a local cache")
+	f.StringVar(&globalOptions.TempDir, "tmp-dir", "", "set the `directory` for temporary files (default: $TMPDIR or system default)")
 	f.StringSliceVar(&globalOptions.RootCertFilenames, "cacert", nil, "`file` to load root certificates from (default: use system certificates or $RESTIC_CACERT)")
 	f.StringVar(&globalOptions.TLSClientCertKeyFilename, "tls-client-cert", "", "path to a `file` containing PEM encoded TLS client certificate and private key (default: $RESTIC_TLS_CLIENT_CERT)")
 	f.BoolVar(&globalOptions.InsecureTLS, "insecure-tls", false, "skip TLS certificate verification when connecting to the repository (insecure)")
@@ -142,7 +157,10 @@ func init() {
 	f.IntVar(&globalOptions.Limits.UploadKb, "limit-upload", 0, "limits uploads to a maximum `rate` in KiB/s. (default: unlimited)")
 	f.IntVar(&globalOptions.Limits.DownloadKb, "limit-download", 0, "limits downloads to a maximum `rate` in KiB/s. (default: unlimited)")
 	f.UintVar(&globalOptions.PackSize, "pack-size", 0, "set target pack `size` in MiB, created pack files may be larger (default: $RESTIC_PACK_SIZE)")
-	f.StringSliceVarP(&globalOptions.Options, "option", "o", []string{}, "set extended option (`key=value`, can be specified multiple times)")
+	f.StringVar(&globalOptions.PackReadGap, "pack-read-gap", "", "read up to `size` MiB of unneeded data between two blobs of a pack file instead of sending a separate request, 0 disables this (default: $RESTIC_PACK_READ_GAP or 4)")
+	f.Var(&globalOptions.IndexMemoryMode, "index-memory-mode", "in-memory representation of the index, one of (fast|compact), compact uses less memory but slows down lookups (default: $RESTIC_INDEX_MEMORY_MODE)")
+	f.BoolVar(&globalOptions.SnapshotIndex, "snapshot-index", false, "list snapshots using the snapshot index stored in the repository and update it when snapshots are added or removed (default: $RESTIC_SNAPSHOT_INDEX)")
+	f.StringSliceVarP(&globalOptions.Options, "option", "o", []string{}, "set extended option (`key=value`, can be specified multiple times) (default: $RESTIC_BACKEND_OPTIONS)")
 	// Use our "generate" command instead of the cobra provided "completion" command
 	cmdRoot.CompletionOptions.DisableDefaultCmd = true
 
@@ -160,9 +178,17 @@ func init() {
 		// ignore error as there's no good way to handle it
 		_ = globalOptions.Compression.Set(comp)
 	}
+	indexMemoryMode := os.Getenv("RESTIC_INDEX_MEMORY_MODE")
+	if indexMemoryMode != "" {
+		// ignore error as there's no good way to handle it
+		_ = globalOptions.IndexMemoryMode.Set(indexMemoryMode)
+	}
 	// parse target pack size from env, on error the default value will be used
 	targetPackSize, _ := strconv.ParseUint(os.Getenv("RESTIC_PACK_SIZE"), 10, 32)
 	globalOptions.PackSize = uint(targetPackSize)
+	globalOptions.PackReadGap = os.Getenv("RESTIC_PACK_READ_GAP")
+	// on error the snapshot index is not used
+	globalOptions.SnapshotIndex, _ = strconv.ParseBool(os.Getenv("RESTIC_SNAPSHOT_INDEX"))
 
 	restoreTerminal()
 }
@@ -206,21 +232,29 @@ func restoreTerminal() {
 		return
 	}
 
-	AddCleanupHandler(func(code int) (int, error) {
+	AddCleanupHandler(restoreTerminalHandler(func() error {
+		return term.Restore(fd, state)
+	}))
+}
+
+// restoreTerminalHandler returns a cleanup handler which calls restore if a
+// password is currently read from the terminal.
+func restoreTerminalHandler(restore func() error) func(code int) (int, error) {
+	return func(code int) (int, error) {
 		// Restoring the terminal configuration while restic runs in the
 		// background, causes restic to get stopped on unix systems with
 		// a SIGTTOU signal. Thus only restore the terminal settings if
 		// they might have been modified, which is the case while reading
 		// a password.
-		if !isReadingPassword {
+		if !isReadingPassword.Load() {
 			return code, nil
 		}
-		err := term.Restore(fd, state)
+		err := restore()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "unable to restore terminal state: %v\n", err)
 		}
 		return code, err
-	})
+	}
 }
 
 // ClearLine creates a platform dependent string to clear the current
@@ -334,9 +368,9 @@ func readPassword(in io.Reader) (password string, err error) {
 // password.
 func readPasswordTerminal(in *os.File, out io.Writer, prompt string) (password string, err error) {
 	fmt.Fprint(out, prompt)
-	isReadingPassword = true
+	isReadingPassword.Store(true)
 	buf, err := term.ReadPassword(int(in.Fd()))
-	isReadingPassword = false
+	isReadingPassword.Store(false)
 	fmt.Fprintln(out)
 	if err != nil {
 		return "", errors.Wrap(err, "ReadPassword")
@@ -424,6 +458,24 @@ func ReadRepo(opts GlobalOptions) (string, error) {
 
 const maxKeys = 20
 
+// parsePackReadGap parses the maximum size in MiB of unneeded data read
+// between two blobs of a pack file. It returns nil for an empty string, such
+// that the default is used.
+func parsePackReadGap(s string) (*uint, error) {
+	if s == "" {
+		return nil, nil
+	}
+	mib, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return nil, errors.Fatalf("invalid pack read gap %q: %v", s, err)
+	}
+	if mib > repository.MaxPackSize/(1024*1024) {
+		return nil, errors.Fatalf("pack read gap of %d MiB is larger than the maximum pack size", mib)
+	}
+	gap := uint(mib) * 1024 * 1024
+	return &gap, nil
+}
+
 // OpenRepository reads the password and opens the repository.
 func OpenRepository(ctx context.Context, opts GlobalOptions) (*repository.Repository, error) {
 	repo, err := ReadRepo(opts)
@@ -452,9 +504,17 @@ func OpenRepository(ctx context.Context, opts GlobalOptions) (*repository.Reposi
 		}
 	}
 
+	packReadGap, err := parsePackReadGap(opts.PackReadGap)
+	if err != nil {
+		return nil, err
+	}
+
 	s, err := repository.New(be, repository.Options{
-		Compression: opts.Compression,
-		PackSize:    opts.PackSize * 1024 * 1024,
+		Compression:     opts.Compression,
+		PackSize:        opts.PackSize * 1024 * 1024,
+		IndexMemoryMode: opts.IndexMemoryMode,
+		SnapshotIndex:   opts.SnapshotIndex,
+		PackReadGap:     packReadGap,
 	})
 	if err != nil {
 		return nil, errors.Fatal(err.Error())
@@ -559,6 +619,10 @@ func parseConfig(loc location.Location, opts options.Options) (interface{}, erro
 
 	// only apply options for a particular backend here
 	opts = opts.Extract(loc.Scheme)
+	for _, key := range opts.Unknown(cfg) {
+		Warnf("ignoring unknown option %v.%v\n", loc.Scheme, key)
+		delete(opts, key)
+	}
 	if err := opts.Apply(loc.Scheme, cfg); err != nil {
 		return nil, err
 	}
@@ -567,6 +631,76 @@ func parseConfig(loc location.Location, opts options.Options) (interface{}, erro
 	return cfg, nil
 }
 
+// parseExtendedOptions parses the comma-separated extended options from env,
+// usually $RESTIC_BACKEND_OPTIONS, and those passed via --option. Options on
+// the command line take precedence over those from the environment.
+func parseExtendedOptions(env string, args []string) (options.Options, error) {
+	opts, err := options.Parse(splitEnvOptions(env))
+	if err != nil {
+		return nil, errors.Fatalf("invalid RESTIC_BACKEND_OPTIONS: %v", err)
+	}
+
+	argOpts, err := options.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range argOpts {
+		opts[key] = value
+	}
+
+	return opts, nil
+}
+
+// envOptionStart matches the start of an option in $RESTIC_BACKEND_OPTIONS.
+var envOptionStart = regexp.MustCompile(`^\s*[a-zA-Z0-9._-]+=`)
+
+// splitEnvOptions splits the comma-separated options in env. A comma only
+// separates two options if it is followed by "key=", otherwise it is part of
+// the value of the preceding option, e.g. a list of hosts.
+func splitEnvOptions(env string) []string {
+	var opts []string
+	for _, part := range strings.Split(env, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		if len(opts) > 0 && !envOptionStart.MatchString(part) {
+			opts[len(opts)-1] += "," + part
+			continue
+		}
+		opts = append(opts, part)
+	}
+	return opts
+}
+
+// parseHTTPOptions returns the HTTP options from the "http" namespace of the
+// extended options, unset options keep their default value.
+func parseHTTPOptions(opts options.Options) (*backend.HTTPOptions, error) {
+	httpOpts := backend.NewHTTPOptions()
+
+	opts = opts.Extract("http")
+	for _, key := range opts.Unknown(&httpOpts) {
+		Warnf("ignoring unknown option http.%v\n", key)
+		delete(opts, key)
+	}
+	if err := opts.Apply("http", &httpOpts); err != nil {
+		return nil, err
+	}
+
+	for name, d := range map[string]time.Duration{
+		"dial-timeout":          httpOpts.DialTimeout,
+		"tls-handshake-timeout": httpOpts.TLSHandshakeTimeout,
+		"timeout":               httpOpts.Timeout,
+		"idle-timeout":          httpOpts.IdleTimeout,
+		"keep-alive":            httpOpts.KeepAlive,
+	} {
+		if d < 0 {
+			return nil, errors.Fatalf("option http.%v must not be negative", name)
+		}
+	}
+
+	return &httpOpts, nil
+}
+
 // Open the backend specified by a location config.
 func open(ctx context.Context, s string, gopts GlobalOptions, opts options.Options) (backend.Backend, error) {
 	debug.Log("parsing location %v", location.StripPassword(gopts.backends, s))
@@ -582,9 +716,9 @@ func open(ctx context.Context, s string, gopts GlobalOptions, opts options.Optio
 		return nil, err
 	}
 
-	rt, err := backend.Transport(globalOptions.TransportOptions)
+	rt, err := newTransport()
 	if err != nil {
-		return nil, errors.Fatal(err.Error())
+		return nil, err
 	}
 
 	// wrap the transport so that the throughput via HTTP is limited
@@ -625,6 +759,19 @@ func open(ctx context.Context, s string, gopts GlobalOptions, opts options.Optio
 	return be, nil
 }
 
+// newTransport returns the HTTP transport shared by all HTTP based backends.
+func newTransport() (http.RoundTripper, error) {
+	if globalOptions.InsecureTLS {
+		Warnf("WARNING: TLS certificate verification is disabled by --insecure-tls, the connection to the repository is not secure!\n")
+	}
+
+	rt, err := backend.Transport(globalOptions.TransportOptions)
+	if err != nil {
+		return nil, errors.Fatal(err.Error())
+	}
+	return rt, nil
+}
+
 // Create the backend specified by URI.
 func create(ctx context.Context, s string, gopts GlobalOptions, opts options.Options) (backend.Backend, error) {
 	debug.Log("parsing location %v", location.StripPassword(gopts.backends, s))
@@ -638,9 +785,9 @@ func create(ctx context.Context, s string, gopts GlobalOptions, opts options.Opt
 		return nil, err
 	}
 
-	rt, err := backend.Transport(globalOptions.TransportOptions)
+	rt, err := newTransport()
 	if err != nil {
-		return nil, errors.Fatal(err.Error())
+		return nil, err
 	}
 
 	factory := gopts.backends.Lookup(loc.Scheme)
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/local"
+	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/options"
 	rtest "github.com/restic/restic/internal/test"
 )
 
@@ -50,3 +56,106 @@ func TestReadRepo(t *testing.T) {
 		t.Fatal("must not read repository path from invalid file path")
 	}
 }
+
+func TestParseExtendedOptions(t *testing.T) {
+	opts, err := parseExtendedOptions("s3.region=us-east-1, s3.connections=10,", []string{"s3.connections=20", "sftp.command=ssh -s sftp"})
+	rtest.OK(t, err)
+	rtest.Equals(t, options.Options{
+		"s3.region":      "us-east-1",
+		"s3.connections": "20",
+		"sftp.command":   "ssh -s sftp",
+	}, opts)
+
+	opts, err = parseExtendedOptions("", nil)
+	rtest.OK(t, err)
+	rtest.Equals(t, options.Options{}, opts)
+
+	opts, err = parseExtendedOptions("sftp.args=-J jump1,jump2,sftp.connections=2", nil)
+	rtest.OK(t, err)
+	rtest.Equals(t, options.Options{
+		"sftp.args":        "-J jump1,jump2",
+		"sftp.connections": "2",
+	}, opts)
+
+	_, err = parseExtendedOptions("=foo", nil)
+	rtest.Assert(t, err != nil, "expected error for invalid RESTIC_BACKEND_OPTIONS")
+}
+
+func TestSplitEnvOptions(t *testing.T) {
+	for _, test := range []struct {
+		env  string
+		opts []string
+	}{
+		{"", nil},
+		{" , ", nil},
+		{"s3.region=us-east-1", []string{"s3.region=us-east-1"}},
+		{"s3.region=us-east-1, s3.connections=10,", []string{"s3.region=us-east-1", " s3.connections=10"}},
+		{"sftp.args=-J jump1,jump2,sftp.connections=2", []string{"sftp.args=-J jump1,jump2", "sftp.connections=2"}},
+		{"sftp.command=ssh -o ProxyJump=a,b -s sftp", []string{"sftp.command=ssh -o ProxyJump=a,b -s sftp"}},
+		{"rclone.args=serve restic --stdio,--b2-hard-delete", []string{"rclone.args=serve restic --stdio,--b2-hard-delete"}},
+		{"=foo,bar", []string{"=foo,bar"}},
+	} {
+		rtest.Equals(t, test.opts, splitEnvOptions(test.env))
+	}
+}
+
+func TestParseConfigUnknownOption(t *testing.T) {
+	loc, err := location.Parse(globalOptions.backends, "local:/srv/repo")
+	rtest.OK(t, err)
+
+	stderr := &bytes.Buffer{}
+	var cfg interface{}
+	err = withRestoreGlobalOptions(func() error {
+		globalOptions.stderr = stderr
+		var err error
+		cfg, err = parseConfig(loc, options.Options{"local.layout": "default", "local.foo": "bar"})
+		return err
+	})
+	rtest.OK(t, err)
+	rtest.Equals(t, "default", cfg.(*local.Config).Layout)
+	rtest.Equals(t, "ignoring unknown option local.foo\n", stderr.String())
+}
+
+func TestParseHTTPOptions(t *testing.T) {
+	httpOpts, err := parseHTTPOptions(options.Options{"s3.region": "us-east-1"})
+	rtest.OK(t, err)
+	rtest.Equals(t, backend.NewHTTPOptions(), *httpOpts)
+
+	httpOpts, err = parseHTTPOptions(options.Options{"http.timeout": "5m", "http.keep-alive": "0",
+		"http.proxy": "http://proxy:3128", "http.ip-version": "6"})
+	rtest.OK(t, err)
+	want := backend.NewHTTPOptions()
+	want.Timeout = 5 * time.Minute
+	want.KeepAlive = 0
+	want.Proxy = "http://proxy:3128"
+	want.IPVersion = 6
+	rtest.Equals(t, want, *httpOpts)
+
+	_, err = parseHTTPOptions(options.Options{"http.dial-timeout": "-1s"})
+	rtest.Assert(t, err != nil, "expected error for negative timeout")
+	_, err = parseHTTPOptions(options.Options{"http.timeout": "foo"})
+	rtest.Assert(t, err != nil, "expected error for invalid timeout")
+}
+
+func TestParsePackReadGap(t *testing.T) {
+	gap, err := parsePackReadGap("")
+	rtest.OK(t, err)
+	rtest.Assert(t, gap == nil, "expected default pack read gap, got %v", gap)
+
+	for _, test := range []struct {
+		value string
+		gap   uint
+	}{
+		{"0", 0},
+		{"8", 8 * 1024 * 1024},
+	} {
+		gap, err := parsePackReadGap(test.value)
+		rtest.OK(t, err)
+		rtest.Equals(t, test.gap, *gap)
+	}
+
+	for _, value := range []string{"-1", "1.5", "foo", "129"} {
+		_, err := parsePackReadGap(value)
+		rtest.Assert(t, err != nil, "expected error for pack read gap %q", value)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -14,7 +15,7 @@ import (
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
-	"github.com/restic/restic/internal/options"
+	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/restic"
 )
 
@@ -54,11 +55,22 @@ The full documentation can be found at https://restic.readthedocs.io/ .
 		}
 
 		// parse extended options
-		opts, err := options.Parse(globalOptions.Options)
+		opts, err := parseExtendedOptions(os.Getenv("RESTIC_BACKEND_OPTIONS"), globalOptions.Options)
 		if err != nil {
 			return err
 		}
 		globalOptions.extended = opts
+
+		globalOptions.HTTP, err = parseHTTPOptions(opts)
+		if err != nil {
+			return err
+		}
+
+		err = fs.SetTempDir(globalOptions.TempDir)
+		if err != nil {
+			return errors.Fatalf("%v", err)
+		}
+
 		if !needsPassword(c.Name()) {
 			return nil
 		}
@@ -113,6 +125,8 @@ func main() {
 		fmt.Fprintf(os.Stderr, "%v\nthe `unlock` command can be used to remove stale locks\n", err)
 	case err == ErrInvalidSourceData:
 		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	case shutdownRequested() && errors.Is(err, context.Canceled):
+		fmt.Fprintf(os.Stderr, "interrupted, exiting\n")
 	case errors.IsFatal(err):
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 	case err != nil:
@@ -136,5 +150,8 @@ func main() {
 	default:
 		exitCode = 1
 	}
+	if err != nil && shutdownRequested() {
+		exitCode = 130
+	}
 	Exit(exitCode)
 }
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/spf13/pflag"
+)
+
+// initPathMap adds the --path-map option to commands that show or restore
+// the paths stored in snapshots.
+func initPathMap(flags *pflag.FlagSet, specs *[]string) {
+	flags.StringArrayVar(specs, "path-map", nil, "replace the path prefix `old:new` in the paths of the snapshot (can be specified multiple times)")
+}
+
+// parsePathMap parses the values of --path-map.
+func parsePathMap(specs []string) (restic.PathMap, error) {
+	m, err := restic.ParsePathMap(specs)
+	if err != nil {
+		return nil, errors.Fatalf("--path-map: %v", err)
+	}
+	return m, nil
+}
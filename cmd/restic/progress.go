@@ -14,7 +14,8 @@ import (
 
 // calculateProgressInterval returns the interval configured via RESTIC_PROGRESS_FPS
 // or if unset returns an interval for 60fps on interactive terminals and 0 (=disabled)
-// for non-interactive terminals or when run using the --quiet flag
+// for non-interactive terminals or when run using the --quiet flag. With
+// --no-tty-detection, non-interactive output is updated once per second.
 func calculateProgressInterval(show bool, json bool) time.Duration {
 	interval := time.Second / 60
 	fps, err := strconv.ParseFloat(os.Getenv("RESTIC_PROGRESS_FPS"), 64)
@@ -23,14 +24,26 @@ func calculateProgressInterval(show bool, json bool) time.Duration {
 			fps = 60
 		}
 		interval = time.Duration(float64(time.Second) / fps)
-	} else if !json && !stdoutCanUpdateStatus() || !show {
+	} else if !show {
 		interval = 0
+	} else if !json && !stdoutCanUpdateStatus() {
+		interval = 0
+		if globalOptions.NoTTYDetection {
+			interval = time.Second
+		}
 	}
 	return interval
 }
 
+// showIndexProgress returns true if the progress for loading the index should
+// be shown. JSON output never includes it.
+func showIndexProgress(quiet bool, json bool) bool {
+	return !quiet && !json && (stdoutIsTerminal() || globalOptions.NoTTYDetection)
+}
+
 // newTerminalProgressMax returns a progress.Counter that prints to stdout or terminal if provided.
-func newGenericProgressMax(show bool, max uint64, description string, print func(status string)) *progress.Counter {
+// If extra is set, the string it returns is appended to the status.
+func newGenericProgressMax(show bool, max uint64, description string, print func(status string), extra func(v uint64, max uint64, d time.Duration, final bool) string) *progress.Counter {
 	if !show {
 		return nil
 	}
@@ -45,6 +58,9 @@ func newGenericProgressMax(show bool, max uint64, description string, print func
 			status = fmt.Sprintf("[%s] %s  %d / %d %s",
 				ui.FormatDuration(d), ui.FormatPercent(v, max), v, max, description)
 		}
+		if extra != nil {
+			status += extra(v, max, d, final)
+		}
 
 		print(status)
 		if final {
@@ -56,12 +72,12 @@ func newGenericProgressMax(show bool, max uint64, description string, print func
 func newTerminalProgressMax(show bool, max uint64, description string, term *termstatus.Terminal) *progress.Counter {
 	return newGenericProgressMax(show, max, description, func(status string) {
 		term.SetStatus([]string{status})
-	})
+	}, nil)
 }
 
 // newProgressMax calls newTerminalProgress without a terminal (print to stdout)
 func newProgressMax(show bool, max uint64, description string) *progress.Counter {
-	return newGenericProgressMax(show, max, description, printProgress)
+	return newGenericProgressMax(show, max, description, printProgress, nil)
 }
 
 func printProgress(status string) {
@@ -98,9 +114,9 @@ func printProgress(status string) {
 }
 
 func newIndexProgress(quiet bool, json bool) *progress.Counter {
-	return newProgressMax(!quiet && !json && stdoutIsTerminal(), 0, "index files loaded")
+	return newProgressMax(showIndexProgress(quiet, json), 0, "index files loaded")
 }
 
 func newIndexTerminalProgress(quiet bool, json bool, term *termstatus.Terminal) *progress.Counter {
-	return newTerminalProgressMax(!quiet && !json && stdoutIsTerminal(), 0, "index files loaded", term)
+	return newTerminalProgressMax(showIndexProgress(quiet, json), 0, "index files loaded", term)
 }
@@ -115,3 +115,55 @@ func fillSecondaryGlobalOpts(opts secondaryRepoOptions, gopts GlobalOptions, rep
 	}
 	return dstGopts, hasFromRepo, nil
 }
+
+// initSecondaryTargetRepoOptions adds the options for a secondary repository
+// which receives a copy of the snapshots written to the main repository.
+func initSecondaryTargetRepoOptions(f *pflag.FlagSet, opts *secondaryRepoOptions) {
+	f.StringVarP(&opts.Repo, "secondary-repo", "", "", "secondary `repository` to also save the snapshot to (default: $RESTIC_SECONDARY_REPOSITORY)")
+	f.StringVarP(&opts.RepositoryFile, "secondary-repository-file", "", "", "`file` from which to read the secondary repository location (default: $RESTIC_SECONDARY_REPOSITORY_FILE)")
+	f.StringVarP(&opts.PasswordFile, "secondary-password-file", "", "", "`file` to read the secondary repository password from (default: $RESTIC_SECONDARY_PASSWORD_FILE)")
+	f.StringVarP(&opts.KeyHint, "secondary-key-hint", "", "", "key ID of key to try decrypting the secondary repository first (default: $RESTIC_SECONDARY_KEY_HINT)")
+	f.StringVarP(&opts.PasswordCommand, "secondary-password-command", "", "", "shell `command` to obtain the secondary repository password from (default: $RESTIC_SECONDARY_PASSWORD_COMMAND)")
+
+	opts.Repo = os.Getenv("RESTIC_SECONDARY_REPOSITORY")
+	opts.RepositoryFile = os.Getenv("RESTIC_SECONDARY_REPOSITORY_FILE")
+	opts.PasswordFile = os.Getenv("RESTIC_SECONDARY_PASSWORD_FILE")
+	opts.KeyHint = os.Getenv("RESTIC_SECONDARY_KEY_HINT")
+	opts.PasswordCommand = os.Getenv("RESTIC_SECONDARY_PASSWORD_COMMAND")
+}
+
+// hasSecondaryTargetRepo returns true if a secondary repository was specified
+// using the options added by initSecondaryTargetRepoOptions.
+func hasSecondaryTargetRepo(opts secondaryRepoOptions) bool {
+	return opts.Repo != "" || opts.RepositoryFile != ""
+}
+
+// fillSecondaryTargetGlobalOpts returns the global options to access the
+// secondary repository added by initSecondaryTargetRepoOptions.
+func fillSecondaryTargetGlobalOpts(opts secondaryRepoOptions, gopts GlobalOptions) (GlobalOptions, error) {
+	if opts.Repo != "" && opts.RepositoryFile != "" {
+		return GlobalOptions{}, errors.Fatal("Options --secondary-repo and --secondary-repository-file are mutually exclusive, please specify only one")
+	}
+
+	var err error
+	dstGopts := gopts
+	dstGopts.Repo = opts.Repo
+	dstGopts.RepositoryFile = opts.RepositoryFile
+	dstGopts.PasswordFile = opts.PasswordFile
+	dstGopts.PasswordCommand = opts.PasswordCommand
+	dstGopts.KeyHint = opts.KeyHint
+
+	if opts.password != "" {
+		dstGopts.password = opts.password
+	} else {
+		dstGopts.password, err = resolvePassword(dstGopts, "RESTIC_SECONDARY_PASSWORD")
+		if err != nil {
+			return GlobalOptions{}, err
+		}
+	}
+	dstGopts.password, err = ReadPassword(dstGopts, "enter password for secondary repository: ")
+	if err != nil {
+		return GlobalOptions{}, err
+	}
+	return dstGopts, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/backup"
+	"github.com/spf13/pflag"
+)
+
+// webhookTimeout limits how long restic waits for the webhook to respond.
+var webhookTimeout = 30 * time.Second
+
+// webhookOptions bundles the options for reporting the result of a command
+// to a webhook.
+type webhookOptions struct {
+	Webhook string
+}
+
+func initWebhookOptions(f *pflag.FlagSet, opts *webhookOptions) {
+	f.StringVar(&opts.Webhook, "webhook", "", "POST a JSON summary to `URL` once the command has finished")
+}
+
+// webhookReport is the payload sent to the webhook.
+type webhookReport struct {
+	Command         string  `json:"command"`
+	RepositoryID    string  `json:"repository_id,omitempty"`
+	Success         bool    `json:"success"`
+	Error           string  `json:"error,omitempty"`
+	DryRun          bool    `json:"dry_run,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+
+	Forget *webhookForgetSummary `json:"forget,omitempty"`
+	Backup *webhookBackupSummary `json:"backup,omitempty"`
+	// Backups contains one entry per snapshot for --group-snapshots-by-tag
+	Backups []webhookBackupSummary `json:"backups,omitempty"`
+
+	url       string
+	transport backend.TransportOptions
+	start     time.Time
+}
+
+type webhookForgetSummary struct {
+	SnapshotsKept    int `json:"snapshots_kept"`
+	SnapshotsRemoved int `json:"snapshots_removed"`
+}
+
+type webhookBackupSummary struct {
+	Tag                 string `json:"tag,omitempty"`
+	SnapshotID          string `json:"snapshot_id,omitempty"`
+	FilesNew            uint   `json:"files_new"`
+	FilesChanged        uint   `json:"files_changed"`
+	FilesUnmodified     uint   `json:"files_unmodified"`
+	DataAdded           uint64 `json:"data_added"`
+	TotalBytesProcessed uint64 `json:"total_bytes_processed"`
+}
+
+// newWebhookReport starts a report for command which is sent using the
+// transport options. It returns nil if no webhook is configured, all methods
+// of webhookReport can be called on nil.
+func newWebhookReport(opts webhookOptions, transport backend.TransportOptions, command string) *webhookReport {
+	if opts.Webhook == "" {
+		return nil
+	}
+	return &webhookReport{
+		Command:   command,
+		url:       opts.Webhook,
+		transport: transport,
+		start:     time.Now(),
+	}
+}
+
+func (r *webhookReport) setRepository(repo restic.Repository) {
+	if r == nil {
+		return
+	}
+	r.RepositoryID = repo.Config().ID
+}
+
+func (r *webhookReport) setForget(kept, removed int, dryRun bool) {
+	if r == nil {
+		return
+	}
+	r.Forget = &webhookForgetSummary{SnapshotsKept: kept, SnapshotsRemoved: removed}
+	r.DryRun = dryRun
+}
+
+func (r *webhookReport) setBackup(id restic.ID, summary backup.Summary, dryRun bool) {
+	if r == nil {
+		return
+	}
+	b := newWebhookBackupSummary("", id, summary)
+	r.Backup = &b
+	r.DryRun = dryRun
+}
+
+// setBackupGroups reports the snapshots saved for the groups of
+// --group-snapshots-by-tag.
+func (r *webhookReport) setBackupGroups(groups []backup.GroupSummary, dryRun bool) {
+	if r == nil {
+		return
+	}
+	r.Backups = make([]webhookBackupSummary, 0, len(groups))
+	for _, g := range groups {
+		r.Backups = append(r.Backups, newWebhookBackupSummary(g.Tag, g.SnapshotID, g.Summary))
+	}
+	r.DryRun = dryRun
+}
+
+func newWebhookBackupSummary(tag string, id restic.ID, summary backup.Summary) webhookBackupSummary {
+	b := webhookBackupSummary{
+		Tag:                 tag,
+		FilesNew:            summary.Files.New,
+		FilesChanged:        summary.Files.Changed,
+		FilesUnmodified:     summary.Files.Unchanged,
+		DataAdded:           summary.ItemStats.DataSize + summary.ItemStats.TreeSize,
+		TotalBytesProcessed: summary.ProcessedBytes,
+	}
+	if !id.IsNull() {
+		b.SnapshotID = id.String()
+	}
+	return b
+}
+
+// send delivers the report including the result err of the command. Errors
+// are only printed as a warning, they must not change the exit status.
+func (r *webhookReport) send(err error) {
+	if r == nil {
+		return
+	}
+
+	r.Success = err == nil
+	if err != nil {
+		r.Error = err.Error()
+	}
+	r.DurationSeconds = time.Since(r.start).Seconds()
+
+	// the context of the command may already be canceled
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	if err := r.post(ctx); err != nil {
+		Warnf("unable to deliver webhook: %v\n", err)
+	}
+}
+
+func (r *webhookReport) post(ctx context.Context) error {
+	buf, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rt, err := backend.Transport(r.transport)
+	if err != nil {
+		return err
+	}
+
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	debug.Log("webhook %v returned %v", r.url, resp.Status)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("unexpected response %v", resp.Status)
+	}
+	return nil
+}
@@ -3,11 +3,13 @@ package archiver
 import (
 	"context"
 	"fmt"
+	"math"
 	"os"
 	"path"
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/restic/restic/internal/debug"
@@ -62,6 +64,10 @@ type Archiver struct {
 	fileSaver *FileSaver
 	treeSaver *TreeSaver
 
+	// summary collects the statistics stored in the snapshot
+	summaryLock sync.Mutex
+	summary     *restic.SnapshotSummary
+
 	// Error is called for all errors that occur during backup.
 	Error ErrorFunc
 
@@ -83,11 +89,31 @@ type Archiver struct {
 	// CompleteBlob is called for all saved blobs for files.
 	CompleteBlob func(bytes uint64)
 
+	// SelectChanged is called for all new or modified regular files before
+	// they are read, item is the path within the snapshot. If it returns
+	// false, the file is not read. The version of the file from the parent
+	// snapshot is kept if available, otherwise it is left out of the snapshot.
+	SelectChanged SelectFunc
+
+	// SmallFilesFirst configures if the regular files of a directory are
+	// saved in order of increasing size before its subdirectories. This
+	// allows SelectChanged to prefer small files.
+	SmallFilesFirst bool
+
 	// WithAtime configures if the access time for files and directories should
 	// be saved. Enabling it may result in much metadata, so it's off by
 	// default.
 	WithAtime bool
 
+	// WithBtime configures if the birth time (creation time) of files and
+	// directories should be saved, if the platform and filesystem provide it.
+	WithBtime bool
+
+	// OmitEmptyDirs configures if directories which do not contain any
+	// files or subdirectories after applying the filters are left out of the
+	// snapshot.
+	OmitEmptyDirs bool
+
 	// Flags controlling change detection. See doc/040_backup.rst for details.
 	ChangeIgnoreFlags uint
 }
@@ -113,6 +139,12 @@ type Options struct {
 	// SaveTreeConcurrency sets how many trees are marshalled and saved to the
 	// repo concurrently.
 	SaveTreeConcurrency uint
+
+	// ReaddirConcurrency sets how many lstat calls are run concurrently for
+	// the entries of a directory. If it's set to zero, the entries are
+	// processed one after another. Running lstat concurrently helps on
+	// network filesystems with a high latency per metadata request.
+	ReaddirConcurrency uint
 }
 
 // ApplyDefaults returns a copy of o with the default options set for all unset
@@ -140,6 +172,10 @@ func (o Options) ApplyDefaults() Options {
 		o.SaveTreeConcurrency = uint(runtime.GOMAXPROCS(0)) + o.ReadConcurrency
 	}
 
+	if o.ReaddirConcurrency == 0 {
+		o.ReaddirConcurrency = 1
+	}
+
 	return o
 }
 
@@ -155,11 +191,27 @@ func New(repo restic.Repository, fs fs.FS, opts Options) *Archiver {
 		CompleteItem: func(string, *restic.Node, *restic.Node, ItemStats, time.Duration) {},
 		StartFile:    func(string) {},
 		CompleteBlob: func(uint64) {},
+
+		SelectChanged: func(item string, fi os.FileInfo) bool { return true },
 	}
 
 	return arch
 }
 
+// trackItem updates the summary and calls CompleteItem.
+func (arch *Archiver) trackItem(item string, previous, current *restic.Node, s ItemStats, d time.Duration) {
+	if current != nil && current.Type == "file" {
+		arch.summaryLock.Lock()
+		if arch.summary != nil {
+			arch.summary.TotalFilesProcessed++
+			arch.summary.TotalBytesProcessed += current.Size
+		}
+		arch.summaryLock.Unlock()
+	}
+
+	arch.CompleteItem(item, previous, current, s, d)
+}
+
 // error calls arch.Error if it is set and the error is different from context.Canceled.
 func (arch *Archiver) error(item string, err error) error {
 	if arch.Error == nil || err == nil {
@@ -185,9 +237,14 @@ func (arch *Archiver) error(item string, err error) error {
 // nodeFromFileInfo returns the restic node from an os.FileInfo.
 func (arch *Archiver) nodeFromFileInfo(snPath, filename string, fi os.FileInfo) (*restic.Node, error) {
 	node, err := restic.NodeFromFileInfo(filename, fi)
-	if !arch.WithAtime {
+	// some platforms and filesystems do not provide a usable access time,
+	// store the modification time instead in that case
+	if !arch.WithAtime || node.AccessTime.IsZero() || node.AccessTime.Unix() == 0 {
 		node.AccessTime = node.ModTime
 	}
+	if arch.WithBtime {
+		node.FillBirthTime(fi)
+	}
 	// overwrite name to match that within the snapshot
 	node.Name = path.Base(snPath)
 	if err != nil {
@@ -240,39 +297,159 @@ func (arch *Archiver) SaveDir(ctx context.Context, snPath string, dir string, fi
 
 	nodes := make([]FutureNode, 0, len(names))
 
-	for _, name := range names {
-		// test if context has been cancelled
-		if ctx.Err() != nil {
-			debug.Log("context has been cancelled, aborting")
-			return FutureNode{}, ctx.Err()
+	for len(names) > 0 {
+		batch := names
+		if len(batch) > readdirBatchSize {
+			batch = batch[:readdirBatchSize]
 		}
+		names = names[len(batch):]
 
-		pathname := arch.FS.Join(dir, name)
-		oldNode := previous.Find(name)
-		snItem := join(snPath, name)
-		fn, excluded, err := arch.Save(ctx, snItem, pathname, oldNode)
+		var infos []*entryInfo
+		if arch.Options.ReaddirConcurrency > 1 || arch.SmallFilesFirst {
+			infos = arch.statEntries(ctx, dir, batch)
+		}
 
-		// return error early if possible
-		if err != nil {
-			err = arch.error(pathname, err)
-			if err == nil {
-				// ignore error
+		// the nodes must be passed to the tree saver sorted by name
+		batchNodes := make([]FutureNode, len(batch))
+		saved := make([]bool, len(batch))
+
+		for _, i := range arch.saveOrder(infos, len(batch)) {
+			name := batch[i]
+			// test if context has been cancelled
+			if ctx.Err() != nil {
+				debug.Log("context has been cancelled, aborting")
+				return FutureNode{}, ctx.Err()
+			}
+
+			var info *entryInfo
+			if infos != nil {
+				info = infos[i]
+			}
+
+			pathname := arch.FS.Join(dir, name)
+			oldNode := previous.Find(name)
+			snItem := join(snPath, name)
+			fn, excluded, err := arch.save(ctx, snItem, pathname, oldNode, info)
+
+			// return error early if possible
+			if err != nil {
+				err = arch.error(pathname, err)
+				if err == nil {
+					// ignore error
+					continue
+				}
+
+				return FutureNode{}, err
+			}
+
+			if excluded {
 				continue
 			}
 
-			return FutureNode{}, err
+			batchNodes[i] = fn
+			saved[i] = true
+		}
+
+		for i := range batchNodes {
+			if saved[i] {
+				nodes = append(nodes, batchNodes[i])
+			}
+		}
+	}
+
+	fn := arch.treeSaver.Save(ctx, snPath, dir, treeNode, nodes, arch.OmitEmptyDirs, complete)
+
+	return fn, nil
+}
+
+// saveOrder returns the order in which the n entries of a directory batch are
+// saved. With SmallFilesFirst, regular files are ordered by increasing size
+// and directories come last. Otherwise, the entries are saved in order.
+func (arch *Archiver) saveOrder(infos []*entryInfo, n int) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	if !arch.SmallFilesFirst || infos == nil {
+		return order
+	}
+
+	key := func(i int) uint64 {
+		info := infos[i]
+		if info == nil || info.fi == nil {
+			return 0
+		}
+		if info.fi.IsDir() {
+			return math.MaxUint64
 		}
+		if fs.IsRegularFile(info.fi) {
+			return uint64(info.fi.Size())
+		}
+		return 0
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return key(order[a]) < key(order[b])
+	})
+	return order
+}
+
+// readdirBatchSize is the number of directory entries for which lstat is run
+// in advance. This bounds the number of file infos kept in memory for large
+// directories.
+const readdirBatchSize = 1000
+
+// entryInfo contains the absolute path of a directory entry, whether it is
+// excluded by SelectByName and the result of lstat for it.
+type entryInfo struct {
+	abstarget string
+	excluded  bool
+	fi        os.FileInfo
+	err       error
+}
 
-		if excluded {
+// statEntries runs lstat for the entries names of dir using up to
+// ReaddirConcurrency goroutines. The path based exclude functions are
+// evaluated first, such that excluded entries are not accessed at all. An
+// entry is nil if it could not be prepared, Save then handles it as usual.
+func (arch *Archiver) statEntries(ctx context.Context, dir string, names []string) []*entryInfo {
+	infos := make([]*entryInfo, len(names))
+	for i, name := range names {
+		abstarget, err := arch.FS.Abs(arch.FS.Join(dir, name))
+		if err != nil {
 			continue
 		}
+		infos[i] = &entryInfo{abstarget: abstarget, excluded: !arch.SelectByName(abstarget)}
+	}
 
-		nodes = append(nodes, fn)
+	ch := make(chan int)
+	var wg sync.WaitGroup
+	for i := uint(0); i < arch.Options.ReaddirConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range ch {
+				infos[i].fi, infos[i].err = arch.FS.Lstat(arch.FS.Join(dir, names[i]))
+			}
+		}()
 	}
 
-	fn := arch.treeSaver.Save(ctx, snPath, dir, treeNode, nodes, complete)
+	for i, info := range infos {
+		if info == nil || info.excluded {
+			continue
+		}
+		select {
+		case ch <- i:
+		case <-ctx.Done():
+			// SaveDir checks the context before processing each entry
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(ch)
+	wg.Wait()
 
-	return fn, nil
+	return infos
 }
 
 // FutureNode holds a reference to a channel that returns a FutureNodeResult
@@ -342,22 +519,36 @@ func (arch *Archiver) allBlobsPresent(previous *restic.Node) bool {
 //
 // snPath is the path within the current snapshot.
 func (arch *Archiver) Save(ctx context.Context, snPath, target string, previous *restic.Node) (fn FutureNode, excluded bool, err error) {
+	return arch.save(ctx, snPath, target, previous, nil)
+}
+
+// save works like Save, but uses the results of SelectByName and lstat from
+// info if it is not nil.
+func (arch *Archiver) save(ctx context.Context, snPath, target string, previous *restic.Node, info *entryInfo) (fn FutureNode, excluded bool, err error) {
 	start := time.Now()
 
 	debug.Log("%v target %q, previous %v", snPath, target, previous)
-	abstarget, err := arch.FS.Abs(target)
-	if err != nil {
-		return FutureNode{}, false, err
+	if info == nil {
+		abstarget, err := arch.FS.Abs(target)
+		if err != nil {
+			return FutureNode{}, false, err
+		}
+
+		info = &entryInfo{abstarget: abstarget, excluded: !arch.SelectByName(abstarget)}
+		if !info.excluded {
+			info.fi, info.err = arch.FS.Lstat(target)
+		}
 	}
+	abstarget := info.abstarget
 
 	// exclude files by path before running Lstat to reduce number of lstat calls
-	if !arch.SelectByName(abstarget) {
+	if info.excluded {
 		debug.Log("%v is excluded by path", target)
 		return FutureNode{}, true, nil
 	}
 
 	// get file info and run remaining select functions that require file information
-	fi, err := arch.FS.Lstat(target)
+	fi, err := info.fi, info.err
 	if err != nil {
 		debug.Log("lstat() for %v returned error: %v", target, err)
 		err = arch.error(abstarget, err)
@@ -377,10 +568,10 @@ func (arch *Archiver) Save(ctx context.Context, snPath, target string, previous
 
 		// check if the file has not changed before performing a fopen operation (more expensive, specially
 		// in network filesystems)
-		if previous != nil && !fileChanged(fi, previous, arch.ChangeIgnoreFlags) {
+		if previous != nil && !FileChanged(fi, previous, arch.ChangeIgnoreFlags) {
 			if arch.allBlobsPresent(previous) {
 				debug.Log("%v hasn't changed, using old list of blobs", target)
-				arch.CompleteItem(snPath, previous, previous, ItemStats{}, time.Since(start))
+				arch.trackItem(snPath, previous, previous, ItemStats{}, time.Since(start))
 				arch.CompleteBlob(previous.Size)
 				node, err := arch.nodeFromFileInfo(snPath, target, fi)
 				if err != nil {
@@ -407,6 +598,22 @@ func (arch *Archiver) Save(ctx context.Context, snPath, target string, previous
 			}
 		}
 
+		if !arch.SelectChanged(snPath, fi) {
+			if previous == nil || previous.Type != "file" || !arch.allBlobsPresent(previous) {
+				debug.Log("%v is excluded by SelectChanged", target)
+				return FutureNode{}, true, nil
+			}
+
+			debug.Log("%v is not selected by SelectChanged, keeping the previous version", target)
+			node := *previous
+			fn = newFutureNodeWithResult(futureNodeResult{
+				snPath: snPath,
+				target: target,
+				node:   &node,
+			})
+			return fn, false, nil
+		}
+
 		// reopen file and do an fstat() on the open file to check it is still
 		// a file (and has not been exchanged for e.g. a symlink)
 		file, err := arch.FS.OpenFile(target, fs.O_RDONLY|fs.O_NOFOLLOW, 0)
@@ -445,9 +652,9 @@ func (arch *Archiver) Save(ctx context.Context, snPath, target string, previous
 		fn = arch.fileSaver.Save(ctx, snPath, target, file, fi, func() {
 			arch.StartFile(snPath)
 		}, func() {
-			arch.CompleteItem(snPath, nil, nil, ItemStats{}, 0)
+			arch.trackItem(snPath, nil, nil, ItemStats{}, 0)
 		}, func(node *restic.Node, stats ItemStats) {
-			arch.CompleteItem(snPath, previous, node, stats, time.Since(start))
+			arch.trackItem(snPath, previous, node, stats, time.Since(start))
 		})
 
 	case fi.IsDir():
@@ -464,7 +671,7 @@ func (arch *Archiver) Save(ctx context.Context, snPath, target string, previous
 
 		fn, err = arch.SaveDir(ctx, snPath, target, fi, oldSubtree,
 			func(node *restic.Node, stats ItemStats) {
-				arch.CompleteItem(snItem, previous, node, stats, time.Since(start))
+				arch.trackItem(snItem, previous, node, stats, time.Since(start))
 			})
 		if err != nil {
 			debug.Log("SaveDir for %v returned error: %v", snPath, err)
@@ -494,10 +701,10 @@ func (arch *Archiver) Save(ctx context.Context, snPath, target string, previous
 	return fn, false, nil
 }
 
-// fileChanged tries to detect whether a file's content has changed compared
+// FileChanged tries to detect whether a file's content has changed compared
 // to the contents of node, which describes the same path in the parent backup.
 // It should only be run for regular files.
-func fileChanged(fi os.FileInfo, node *restic.Node, ignoreFlags uint) bool {
+func FileChanged(fi os.FileInfo, node *restic.Node, ignoreFlags uint) bool {
 	switch {
 	case node == nil:
 		return true
@@ -620,7 +827,7 @@ func (arch *Archiver) SaveTree(ctx context.Context, snPath string, atree *Tree,
 
 		// not a leaf node, archive subtree
 		fn, _, err := arch.SaveTree(ctx, join(snPath, name), &subatree, oldSubtree, func(n *restic.Node, is ItemStats) {
-			arch.CompleteItem(snItem, oldNode, n, is, time.Since(start))
+			arch.trackItem(snItem, oldNode, n, is, time.Since(start))
 		})
 		if err != nil {
 			return FutureNode{}, 0, err
@@ -628,7 +835,7 @@ func (arch *Archiver) SaveTree(ctx context.Context, snPath string, atree *Tree,
 		nodes = append(nodes, fn)
 	}
 
-	fn := arch.treeSaver.Save(ctx, snPath, atree.FileInfoPath, node, nodes, complete)
+	fn := arch.treeSaver.Save(ctx, snPath, atree.FileInfoPath, node, nodes, false, complete)
 	return fn, len(nodes), nil
 }
 
@@ -691,6 +898,16 @@ type SnapshotOptions struct {
 	Time           time.Time
 	ParentSnapshot *restic.Snapshot
 	ProgramVersion string
+	// SkipIfUnchanged omits the snapshot creation if it is identical to the parent snapshot.
+	SkipIfUnchanged bool
+
+	// Paths are recorded as the paths of the snapshot instead of the
+	// targets, if set.
+	Paths []string
+
+	// ExtraTags is called once all files have been saved, the returned tags
+	// are added to the snapshot in addition to Tags.
+	ExtraTags func() restic.TagList
 }
 
 // loadParentTree loads a tree referenced by snapshot id. If id is null, nil is returned.
@@ -737,7 +954,9 @@ func (arch *Archiver) stopWorkers() {
 	arch.treeSaver = nil
 }
 
-// Snapshot saves several targets and returns a snapshot.
+// Snapshot saves several targets and returns a snapshot. If
+// opts.SkipIfUnchanged is set and the resulting tree is identical to the one
+// of the parent snapshot, no snapshot is created and nil is returned.
 func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts SnapshotOptions) (*restic.Snapshot, restic.ID, error) {
 	cleanTargets, err := resolveRelativeTargets(arch.FS, targets)
 	if err != nil {
@@ -751,6 +970,15 @@ func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts Snaps
 
 	var rootTreeID restic.ID
 
+	summary := &restic.SnapshotSummary{
+		BackupStart: time.Now(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+	}
+	arch.summaryLock.Lock()
+	arch.summary = summary
+	arch.summaryLock.Unlock()
+
 	wgUp, wgUpCtx := errgroup.WithContext(ctx)
 	arch.Repo.StartPackUploader(wgUpCtx, wgUp)
 
@@ -763,7 +991,7 @@ func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts Snaps
 
 			debug.Log("starting snapshot")
 			fn, nodeCount, err := arch.SaveTree(wgCtx, "/", atree, arch.loadParentTree(wgCtx, opts.ParentSnapshot), func(n *restic.Node, is ItemStats) {
-				arch.CompleteItem("/", nil, nil, is, time.Since(start))
+				arch.trackItem("/", nil, nil, is, time.Since(start))
 			})
 			if err != nil {
 				return err
@@ -802,7 +1030,25 @@ func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts Snaps
 		return nil, restic.ID{}, err
 	}
 
-	sn, err := restic.NewSnapshot(targets, opts.Tags, opts.Hostname, opts.Time)
+	if opts.ParentSnapshot != nil && opts.SkipIfUnchanged {
+		ps := opts.ParentSnapshot
+		if ps.Tree != nil && rootTreeID.Equal(*ps.Tree) {
+			debug.Log("tree %v is identical to parent snapshot %v, skipping snapshot", rootTreeID, ps.ID())
+			return nil, restic.ID{}, nil
+		}
+	}
+
+	tags := opts.Tags
+	if opts.ExtraTags != nil {
+		tags = append(append(restic.TagList{}, tags...), opts.ExtraTags()...)
+	}
+
+	paths := targets
+	if len(opts.Paths) > 0 {
+		paths = opts.Paths
+	}
+
+	sn, err := restic.NewSnapshot(paths, tags, opts.Hostname, opts.Time)
 	if err != nil {
 		return nil, restic.ID{}, err
 	}
@@ -814,6 +1060,12 @@ func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts Snaps
 	}
 	sn.Tree = &rootTreeID
 
+	arch.summaryLock.Lock()
+	arch.summary = nil
+	arch.summaryLock.Unlock()
+	summary.BackupEnd = time.Now()
+	sn.Summary = summary
+
 	id, err := restic.SaveSnapshot(ctx, arch.Repo, sn)
 	if err != nil {
 		return nil, restic.ID{}, err
@@ -3,6 +3,7 @@ package archiver
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -685,7 +686,7 @@ func TestFileChanged(t *testing.T) {
 			fiBefore := lstat(t, filename)
 			node := nodeFromFI(t, filename, fiBefore)
 
-			if fileChanged(fiBefore, node, 0) {
+			if FileChanged(fiBefore, node, 0) {
 				t.Fatalf("unchanged file detected as changed")
 			}
 
@@ -695,12 +696,12 @@ func TestFileChanged(t *testing.T) {
 
 			if test.SameFile {
 				// file should be detected as unchanged
-				if fileChanged(fiAfter, node, test.ChangeIgnore) {
+				if FileChanged(fiAfter, node, test.ChangeIgnore) {
 					t.Fatalf("unmodified file detected as changed")
 				}
 			} else {
 				// file should be detected as changed
-				if !fileChanged(fiAfter, node, test.ChangeIgnore) && !test.SameFile {
+				if !FileChanged(fiAfter, node, test.ChangeIgnore) && !test.SameFile {
 					t.Fatalf("modified file detected as unchanged")
 				}
 			}
@@ -717,7 +718,7 @@ func TestFilChangedSpecialCases(t *testing.T) {
 
 	t.Run("nil-node", func(t *testing.T) {
 		fi := lstat(t, filename)
-		if !fileChanged(fi, nil, 0) {
+		if !FileChanged(fi, nil, 0) {
 			t.Fatal("nil node detected as unchanged")
 		}
 	})
@@ -726,10 +727,39 @@ func TestFilChangedSpecialCases(t *testing.T) {
 		fi := lstat(t, filename)
 		node := nodeFromFI(t, filename, fi)
 		node.Type = "symlink"
-		if !fileChanged(fi, node, 0) {
+		if !FileChanged(fi, node, 0) {
 			t.Fatal("node with changed type detected as unchanged")
 		}
 	})
+
+	t.Run("ignore-flags", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("ctime and inode are not checked on Windows")
+		}
+
+		fi := lstat(t, filename)
+		for _, test := range []struct {
+			name    string
+			modify  func(node *restic.Node)
+			flags   uint
+			changed bool
+		}{
+			{"inode", func(node *restic.Node) { node.Inode++ }, 0, true},
+			{"inode", func(node *restic.Node) { node.Inode++ }, ChangeIgnoreCtime, true},
+			{"inode", func(node *restic.Node) { node.Inode++ }, ChangeIgnoreInode, false},
+			{"ctime", func(node *restic.Node) { node.ChangeTime = node.ChangeTime.Add(time.Second) }, 0, true},
+			{"ctime", func(node *restic.Node) { node.ChangeTime = node.ChangeTime.Add(time.Second) }, ChangeIgnoreInode, true},
+			{"ctime", func(node *restic.Node) { node.ChangeTime = node.ChangeTime.Add(time.Second) }, ChangeIgnoreCtime, false},
+			{"mtime", func(node *restic.Node) { node.ModTime = node.ModTime.Add(time.Second) }, ChangeIgnoreCtime | ChangeIgnoreInode, true},
+			{"size", func(node *restic.Node) { node.Size++ }, ChangeIgnoreCtime | ChangeIgnoreInode, true},
+		} {
+			node := nodeFromFI(t, filename, fi)
+			test.modify(node)
+			if FileChanged(fi, node, test.flags) != test.changed {
+				t.Errorf("%v with flags %v: expected changed=%v", test.name, test.flags, test.changed)
+			}
+		}
+	})
 }
 
 func TestArchiverSaveDir(t *testing.T) {
@@ -1425,6 +1455,63 @@ func TestArchiverSnapshot(t *testing.T) {
 	}
 }
 
+func TestArchiverSelectChanged(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	src := TestDir{
+		"dir": TestDir{
+			"big":    TestFile{Content: strings.Repeat("b", 300)},
+			"medium": TestFile{Content: strings.Repeat("m", 30)},
+			"small":  TestFile{Content: "s"},
+			"sub": TestDir{
+				"file": TestFile{Content: "file in subdir"},
+			},
+		},
+	}
+	tempdir, repo := prepareTempdirRepoSrc(t, src)
+
+	back := restictest.Chdir(t, tempdir)
+	defer back()
+
+	var order []string
+	arch := New(repo, fs.Track{FS: fs.Local{}}, Options{})
+	arch.SmallFilesFirst = true
+	arch.SelectChanged = func(item string, fi os.FileInfo) bool {
+		order = append(order, item)
+		return true
+	}
+
+	first, _, err := arch.Snapshot(ctx, []string{"dir"}, SnapshotOptions{Time: time.Now()})
+	restictest.OK(t, err)
+	restictest.Equals(t, []string{"/dir/small", "/dir/medium", "/dir/big", "/dir/sub/file"}, order)
+
+	// modify files, but do not select the big one and the new one
+	for name, content := range map[string]string{"big": "modified big file", "small": "modified"} {
+		restictest.OK(t, os.WriteFile(filepath.Join("dir", name), []byte(content), 0644))
+	}
+	restictest.OK(t, os.WriteFile(filepath.Join("dir", "new"), []byte("new file"), 0644))
+	arch.SelectChanged = func(item string, fi os.FileInfo) bool {
+		return item != "/dir/big" && item != "/dir/new"
+	}
+
+	_, id, err := arch.Snapshot(ctx, []string{"dir"}, SnapshotOptions{Time: time.Now(), ParentSnapshot: first})
+	restictest.OK(t, err)
+
+	// the version of the parent snapshot is kept for files which were not
+	// selected, new files are left out
+	TestEnsureSnapshot(t, repo, id, TestDir{
+		"dir": TestDir{
+			"big":    TestFile{Content: strings.Repeat("b", 300)},
+			"medium": TestFile{Content: strings.Repeat("m", 30)},
+			"small":  TestFile{Content: "modified"},
+			"sub": TestDir{
+				"file": TestFile{Content: "file in subdir"},
+			},
+		},
+	})
+}
+
 func TestArchiverSnapshotSelect(t *testing.T) {
 	var tests = []struct {
 		name  string
@@ -2245,3 +2332,208 @@ func TestRacyFileSwap(t *testing.T) {
 		t.Errorf("Save() excluded the node, that's unexpected")
 	}
 }
+
+func TestArchiverWithAtime(t *testing.T) {
+	tempdir := restictest.TempDir(t)
+	filename := filepath.Join(tempdir, "testfile")
+	restictest.OK(t, os.WriteFile(filename, []byte("foo bar test file"), 0644))
+
+	atime := time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC)
+	mtime := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	restictest.OK(t, os.Chtimes(filename, atime, mtime))
+
+	for _, test := range []struct {
+		withAtime bool
+		want      time.Time
+	}{
+		{false, mtime},
+		{true, atime},
+	} {
+		arch := New(nil, fs.Local{}, Options{})
+		arch.WithAtime = test.withAtime
+
+		node, err := arch.nodeFromFileInfo("testfile", filename, lstat(t, filename))
+		restictest.OK(t, err)
+		restictest.Assert(t, node.AccessTime.Equal(test.want),
+			"withAtime %v: unexpected access time %v, want %v", test.withAtime, node.AccessTime, test.want)
+	}
+}
+
+func TestArchiverOmitEmptyDirs(t *testing.T) {
+	src := TestDir{
+		"work": TestDir{
+			"foo.txt": TestFile{Content: "foo text file"},
+			"subdir": TestDir{
+				"other": TestFile{Content: "other in subdir"},
+			},
+			"empty": TestDir{
+				"nested": TestDir{},
+			},
+		},
+		"other": TestDir{
+			"bar": TestFile{Content: "bar"},
+		},
+	}
+
+	for _, test := range []struct {
+		name          string
+		omitEmptyDirs bool
+		want          TestDir
+	}{
+		{
+			name:          "keep-empty",
+			omitEmptyDirs: false,
+			want: TestDir{
+				"work": TestDir{
+					"foo.txt": TestFile{Content: "foo text file"},
+					"subdir":  TestDir{},
+					"empty": TestDir{
+						"nested": TestDir{},
+					},
+				},
+				"other": TestDir{},
+			},
+		},
+		{
+			name:          "omit-empty",
+			omitEmptyDirs: true,
+			want: TestDir{
+				"work": TestDir{
+					"foo.txt": TestFile{Content: "foo text file"},
+				},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			tempdir, repo := prepareTempdirRepoSrc(t, src)
+
+			arch := New(repo, fs.Track{FS: fs.Local{}}, Options{})
+			arch.Select = func(item string, fi os.FileInfo) bool {
+				return fi.IsDir() || filepath.Base(item) == "foo.txt"
+			}
+			arch.OmitEmptyDirs = test.omitEmptyDirs
+
+			back := restictest.Chdir(t, tempdir)
+			defer back()
+
+			_, snapshotID, err := arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now()})
+			restictest.OK(t, err)
+
+			TestEnsureSnapshot(t, repo, snapshotID, test.want)
+			checker.TestCheckRepo(t, repo)
+		})
+	}
+}
+
+// slowLstatFS delays each Lstat call to simulate a network filesystem and
+// records which paths were passed to Lstat.
+type slowLstatFS struct {
+	fs.FS
+	delay time.Duration
+
+	m        sync.Mutex
+	calls    map[string]int
+	inFlight int
+	maxLstat int
+}
+
+func (fs *slowLstatFS) Lstat(name string) (os.FileInfo, error) {
+	fs.m.Lock()
+	fs.calls[filepath.Base(name)]++
+	fs.inFlight++
+	if fs.inFlight > fs.maxLstat {
+		fs.maxLstat = fs.inFlight
+	}
+	fs.m.Unlock()
+
+	time.Sleep(fs.delay)
+	fi, err := fs.FS.Lstat(name)
+
+	fs.m.Lock()
+	fs.inFlight--
+	fs.m.Unlock()
+	return fi, err
+}
+
+func TestArchiverReaddirConcurrency(t *testing.T) {
+	src := TestDir{}
+	want := TestDir{}
+	// more entries than fit into a single batch
+	for i := 0; i < readdirBatchSize+234; i++ {
+		name := fmt.Sprintf("file%04d", i)
+		src[name] = TestFile{Content: name}
+		want[name] = TestFile{Content: name}
+		src[name+".skip"] = TestFile{Content: "excluded"}
+	}
+	src["subdir"] = TestDir{"foo": TestFile{Content: "foo"}, "foo.skip": TestFile{Content: "excluded"}}
+	want["subdir"] = TestDir{"foo": TestFile{Content: "foo"}}
+	src = TestDir{"target": src}
+	want = TestDir{"target": want}
+
+	for _, concurrency := range []uint{0, 8} {
+		t.Run(fmt.Sprintf("concurrency-%d", concurrency), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			tempdir, repo := prepareTempdirRepoSrc(t, src)
+			testFS := &slowLstatFS{FS: fs.Local{}, delay: 50 * time.Microsecond, calls: make(map[string]int)}
+
+			arch := New(repo, fs.Track{FS: testFS}, Options{ReaddirConcurrency: concurrency})
+			arch.SelectByName = func(item string) bool {
+				return !strings.HasSuffix(item, ".skip")
+			}
+
+			back := restictest.Chdir(t, tempdir)
+			defer back()
+
+			_, snapshotID, err := arch.Snapshot(ctx, []string{"target"}, SnapshotOptions{Time: time.Now()})
+			restictest.OK(t, err)
+
+			TestEnsureSnapshot(t, repo, snapshotID, want)
+			checker.TestCheckRepo(t, repo)
+
+			for name, calls := range testFS.calls {
+				restictest.Assert(t, !strings.HasSuffix(name, ".skip"), "lstat was called for excluded file %v", name)
+				restictest.Assert(t, calls == 1 || name == "target", "lstat was called %d times for %v", calls, name)
+			}
+			max := int(concurrency)
+			if max == 0 {
+				max = 1
+			}
+			restictest.Assert(t, testFS.maxLstat <= max, "%d concurrent lstat calls, expected at most %d", testFS.maxLstat, max)
+			if concurrency > 1 {
+				restictest.Assert(t, testFS.maxLstat > 1, "lstat calls were not run concurrently")
+			}
+		})
+	}
+}
+
+func BenchmarkArchiverReaddirConcurrency(b *testing.B) {
+	src := TestDir{}
+	for i := 0; i < 20; i++ {
+		dir := TestDir{}
+		for j := 0; j < 500; j++ {
+			dir[fmt.Sprintf("file%03d", j)] = TestFile{Content: "x"}
+		}
+		src[fmt.Sprintf("dir%02d", i)] = dir
+	}
+	tempdir := restictest.TempDir(b)
+	TestCreateFiles(b, tempdir, src)
+
+	for _, concurrency := range []uint{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("concurrency-%d", concurrency), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				repo := repository.TestRepository(b)
+				// simulate the latency of a network filesystem
+				testFS := &slowLstatFS{FS: fs.Local{}, delay: 200 * time.Microsecond, calls: make(map[string]int)}
+				arch := New(repo, testFS, Options{ReaddirConcurrency: concurrency})
+
+				_, _, err := arch.Snapshot(context.TODO(), []string{tempdir}, SnapshotOptions{Time: time.Now()})
+				restictest.OK(b, err)
+			}
+		})
+	}
+}
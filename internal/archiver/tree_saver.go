@@ -41,16 +41,19 @@ func (s *TreeSaver) TriggerShutdown() {
 	close(s.ch)
 }
 
-// Save stores the dir d and returns the data once it has been completed.
-func (s *TreeSaver) Save(ctx context.Context, snPath string, target string, node *restic.Node, nodes []FutureNode, complete CompleteFunc) FutureNode {
+// Save stores the dir d and returns the data once it has been completed. If
+// omitEmpty is set and the directory does not contain any nodes, it is
+// excluded instead.
+func (s *TreeSaver) Save(ctx context.Context, snPath string, target string, node *restic.Node, nodes []FutureNode, omitEmpty bool, complete CompleteFunc) FutureNode {
 	fn, ch := newFutureNode()
 	job := saveTreeJob{
-		snPath:   snPath,
-		target:   target,
-		node:     node,
-		nodes:    nodes,
-		ch:       ch,
-		complete: complete,
+		snPath:    snPath,
+		target:    target,
+		node:      node,
+		nodes:     nodes,
+		omitEmpty: omitEmpty,
+		ch:        ch,
+		complete:  complete,
 	}
 	select {
 	case s.ch <- job:
@@ -63,12 +66,13 @@ func (s *TreeSaver) Save(ctx context.Context, snPath string, target string, node
 }
 
 type saveTreeJob struct {
-	snPath   string
-	target   string
-	node     *restic.Node
-	nodes    []FutureNode
-	ch       chan<- futureNodeResult
-	complete CompleteFunc
+	snPath    string
+	target    string
+	node      *restic.Node
+	nodes     []FutureNode
+	omitEmpty bool
+	ch        chan<- futureNodeResult
+	complete  CompleteFunc
 }
 
 // save stores the nodes as a tree in the repo.
@@ -81,6 +85,7 @@ func (s *TreeSaver) save(ctx context.Context, job *saveTreeJob) (*restic.Node, I
 
 	builder := restic.NewTreeJSONBuilder()
 	var lastNode *restic.Node
+	count := 0
 
 	for i, fn := range nodes {
 		// fn is a copy, so clear the original value explicitly
@@ -117,6 +122,12 @@ func (s *TreeSaver) save(ctx context.Context, job *saveTreeJob) (*restic.Node, I
 			return nil, stats, err
 		}
 		lastNode = fnr.node
+		count++
+	}
+
+	if job.omitEmpty && count == 0 {
+		debug.Log("%v is empty, omitting it", job.snPath)
+		return nil, stats, nil
 	}
 
 	buf, err := builder.Finalize()
@@ -165,7 +176,7 @@ func (s *TreeSaver) worker(ctx context.Context, jobs <-chan saveTreeJob) error {
 			return err
 		}
 
-		if job.complete != nil {
+		if job.complete != nil && node != nil {
 			job.complete(node, stats)
 		}
 		job.ch <- futureNodeResult{
@@ -50,7 +50,7 @@ func TestTreeSaver(t *testing.T) {
 			Name: fmt.Sprintf("file-%d", i),
 		}
 
-		fb := b.Save(ctx, join("/", node.Name), node.Name, node, nil, nil)
+		fb := b.Save(ctx, join("/", node.Name), node.Name, node, nil, false, nil)
 		results = append(results, fb)
 	}
 
@@ -100,7 +100,7 @@ func TestTreeSaverError(t *testing.T) {
 					}))
 				}
 
-				fb := b.Save(ctx, join("/", node.Name), node.Name, node, nodes, nil)
+				fb := b.Save(ctx, join("/", node.Name), node.Name, node, nodes, false, nil)
 				results = append(results, fb)
 			}
 
@@ -144,7 +144,7 @@ func TestTreeSaverDuplicates(t *testing.T) {
 				}}))
 			}
 
-			fb := b.Save(ctx, join("/", node.Name), node.Name, node, nodes, nil)
+			fb := b.Save(ctx, join("/", node.Name), node.Name, node, nodes, false, nil)
 			fb.take(ctx)
 
 			err := shutdown()
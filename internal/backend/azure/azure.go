@@ -385,6 +385,9 @@ func (be *Backend) List(ctx context.Context, t backend.FileType, fn func(backend
 				Name: path.Base(m),
 				Size: *item.Properties.ContentLength,
 			}
+			if item.Properties.LastModified != nil {
+				fi.ModTime = *item.Properties.LastModified
+			}
 
 			if ctx.Err() != nil {
 				return ctx.Err()
@@ -4,8 +4,18 @@ import (
 	"context"
 	"hash"
 	"io"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
 )
 
+// ErrAlreadyExists is returned by SaveExclusive if the file already exists.
+var ErrAlreadyExists = errors.New("file already exists")
+
+// ErrExclusiveSaveUnsupported is returned by SaveExclusive if the backend
+// cannot atomically store a file only if it does not exist yet.
+var ErrExclusiveSaveUnsupported = errors.New("exclusive save is not supported by the backend")
+
 // Backend is used to store and access data.
 //
 // Backend operations that return an error will be retried when a Backend is
@@ -100,10 +110,25 @@ type FreezeBackend interface {
 	Unfreeze()
 }
 
+// ExclusiveSaveBackend is implemented by backends which can atomically store a
+// file only if it does not exist yet.
+type ExclusiveSaveBackend interface {
+	Backend
+	// SaveExclusive stores the data from rd under the given handle. If the
+	// file already exists, it is left untouched and ErrAlreadyExists is
+	// returned. Backends which cannot perform the check atomically return
+	// ErrExclusiveSaveUnsupported. Wrappers must forward the call to the
+	// wrapped backend using the SaveExclusive function.
+	SaveExclusive(ctx context.Context, h Handle, rd RewindReader) error
+}
+
 // FileInfo is contains information about a file in the backend.
 type FileInfo struct {
 	Size int64
 	Name string
+	// ModTime is the time the file was last modified. It is zero if the
+	// backend does not provide it.
+	ModTime time.Time
 }
 
 // ApplyEnvironmenter fills in a backend configuration from the environment
@@ -0,0 +1,238 @@
+package dav
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// authTransport authenticates requests using HTTP basic authentication, or
+// digest authentication once the server has sent a digest challenge.
+type authTransport struct {
+	rt                 http.RoundTripper
+	username, password string
+
+	m         sync.Mutex
+	challenge *digestChallenge
+	count     uint
+}
+
+// digestChallenge contains the parameters of a WWW-Authenticate: Digest header.
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	stale     bool
+}
+
+func newAuthTransport(rt http.RoundTripper, username, password string) *authTransport {
+	return &authTransport{rt: rt, username: username, password: password}
+}
+
+// RoundTrip implements http.RoundTripper. If the server responds with a new
+// digest challenge, the request is repeated once using digest authentication,
+// provided that its body can be sent again.
+func (t *authTransport) RoundTrip(orig *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the request
+	req := orig.Clone(orig.Context())
+	challenge, err := t.authorize(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	newChallenge := parseDigestChallenge(resp.Header.Values("WWW-Authenticate"))
+	if newChallenge == nil || (challenge != nil && !newChallenge.stale) {
+		// not a digest challenge or the credentials were rejected
+		return resp, nil
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	t.m.Lock()
+	t.challenge = newChallenge
+	t.count = 0
+	t.m.Unlock()
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	retry := orig.Clone(orig.Context())
+	if orig.GetBody != nil {
+		retry.Body, err = orig.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, err := t.authorize(retry); err != nil {
+		return nil, err
+	}
+	return t.rt.RoundTrip(retry)
+}
+
+// authorize sets the Authorization header of req and returns the digest
+// challenge that was used, if any.
+func (t *authTransport) authorize(req *http.Request) (*digestChallenge, error) {
+	t.m.Lock()
+	challenge := t.challenge
+	t.count++
+	count := t.count
+	t.m.Unlock()
+
+	if challenge == nil {
+		if t.username != "" || t.password != "" {
+			req.SetBasicAuth(t.username, t.password)
+		}
+		return nil, nil
+	}
+
+	header, err := challenge.authorization(t.username, t.password, req.Method, req.URL.RequestURI(), count)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", header)
+	return challenge, nil
+}
+
+// parseDigestChallenge returns the first digest challenge contained in the
+// WWW-Authenticate headers, or nil if there is none.
+func parseDigestChallenge(headers []string) *digestChallenge {
+	for _, header := range headers {
+		scheme, params, _ := strings.Cut(strings.TrimSpace(header), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+
+		values := parseAuthParams(params)
+		c := &digestChallenge{
+			realm:     values["realm"],
+			nonce:     values["nonce"],
+			opaque:    values["opaque"],
+			algorithm: values["algorithm"],
+			stale:     strings.EqualFold(values["stale"], "true"),
+		}
+		if values["qop"] != "" {
+			for _, qop := range strings.Split(values["qop"], ",") {
+				if strings.TrimSpace(qop) == "auth" {
+					c.qop = "auth"
+				}
+			}
+		}
+		return c
+	}
+	return nil
+}
+
+// parseAuthParams splits a comma-separated list of key=value pairs, where
+// the value may be a quoted string.
+func parseAuthParams(s string) map[string]string {
+	values := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " ,")
+		if s == "" {
+			return values
+		}
+
+		key, rest, found := strings.Cut(s, "=")
+		if !found {
+			return values
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			rest = rest[1:]
+			for len(rest) > 0 && rest[0] != '"' {
+				if rest[0] == '\\' && len(rest) > 1 {
+					rest = rest[1:]
+				}
+				value.WriteByte(rest[0])
+				rest = rest[1:]
+			}
+			rest = strings.TrimPrefix(rest, `"`)
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value.WriteString(strings.TrimSpace(rest[:end]))
+			rest = rest[end:]
+		}
+
+		values[key] = value.String()
+		s = rest
+	}
+}
+
+// authorization computes the Authorization header for a request as described
+// in RFC 7616.
+func (c *digestChallenge) authorization(username, password, method, uri string, count uint) (string, error) {
+	var newHash func() hash.Hash
+	algorithm := strings.ToUpper(c.algorithm)
+	switch algorithm {
+	case "", "MD5", "MD5-SESS":
+		newHash = md5.New
+	case "SHA-256", "SHA-256-SESS":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm %q", c.algorithm)
+	}
+
+	h := func(s string) string {
+		hash := newHash()
+		_, _ = hash.Write([]byte(s))
+		return hex.EncodeToString(hash.Sum(nil))
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(buf)
+	nc := fmt.Sprintf("%08x", count)
+
+	ha1 := h(username + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(algorithm, "-SESS") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+
+	var response string
+	if c.qop != "" {
+		response = h(strings.Join([]string{ha1, c.nonce, nc, cnonce, c.qop, ha2}, ":"))
+	} else {
+		response = h(ha1 + ":" + c.nonce + ":" + ha2)
+	}
+
+	fields := []string{
+		fmt.Sprintf("username=%q", username),
+		fmt.Sprintf("realm=%q", c.realm),
+		fmt.Sprintf("nonce=%q", c.nonce),
+		fmt.Sprintf("uri=%q", uri),
+		fmt.Sprintf("response=%q", response),
+	}
+	if c.algorithm != "" {
+		fields = append(fields, "algorithm="+c.algorithm)
+	}
+	if c.opaque != "" {
+		fields = append(fields, fmt.Sprintf("opaque=%q", c.opaque))
+	}
+	if c.qop != "" {
+		fields = append(fields, "qop="+c.qop, "nc="+nc, fmt.Sprintf("cnonce=%q", cnonce))
+	}
+	return "Digest " + strings.Join(fields, ", "), nil
+}
@@ -0,0 +1,486 @@
+package dav
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/layout"
+	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/backend/util"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+// make sure the WebDAV backend implements backend.Backend
+var _ backend.Backend = &Backend{}
+
+// Backend stores data on a WebDAV server.
+type Backend struct {
+	url         *url.URL
+	connections uint
+	client      http.Client
+	layout.Layout
+}
+
+func NewFactory() location.Factory {
+	return location.NewHTTPBackendFactory("dav", ParseConfig, StripPassword, Create, Open)
+}
+
+// Open opens the WebDAV backend with the given config.
+func Open(_ context.Context, cfg Config, rt http.RoundTripper) (*Backend, error) {
+	u := *cfg.URL
+	var username, password string
+	if u.User != nil {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+	// credentials are added by the authTransport
+	u.User = nil
+
+	be := &Backend{
+		url:         &u,
+		connections: cfg.Connections,
+		client:      http.Client{Transport: newAuthTransport(rt, username, password)},
+		Layout:      &layout.DefaultLayout{Path: strings.TrimSuffix(u.Path, "/"), Join: path.Join},
+	}
+
+	return be, nil
+}
+
+// Create creates the directories of a new repository on the WebDAV server.
+func Create(ctx context.Context, cfg Config, rt http.RoundTripper) (*Backend, error) {
+	be, err := Open(ctx, cfg, rt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = be.Stat(ctx, backend.Handle{Type: backend.ConfigFile})
+	if err == nil {
+		return nil, errors.New("config file already exists")
+	}
+	if !be.IsNotExist(err) {
+		return nil, err
+	}
+
+	// create the repository directory including its parents
+	dir := ""
+	for _, elem := range strings.Split(strings.Trim(be.url.Path, "/"), "/") {
+		if elem == "" {
+			continue
+		}
+		dir += "/" + elem
+		if err := be.mkcol(ctx, dir); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, dir := range be.Paths() {
+		if err := be.mkcol(ctx, dir); err != nil {
+			return nil, err
+		}
+	}
+
+	return be, nil
+}
+
+func (b *Backend) Connections() uint {
+	return b.connections
+}
+
+// Location returns this backend's location (the server's URL).
+func (b *Backend) Location() string {
+	return b.url.String()
+}
+
+// Hasher may return a hash function for calculating a content hash for the backend
+func (b *Backend) Hasher() hash.Hash {
+	return nil
+}
+
+// HasAtomicReplace returns whether Save() can atomically replace files
+func (b *Backend) HasAtomicReplace() bool {
+	// WebDAV does not guarantee that PUT replaces files atomically
+	return false
+}
+
+// fileURL returns the URL for the path p on the server.
+func (b *Backend) fileURL(p string) string {
+	u := *b.url
+	u.Path = p
+	u.RawPath = ""
+	return u.String()
+}
+
+func (b *Backend) request(ctx context.Context, method, p string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, b.fileURL(p), body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return b.client.Do(req)
+}
+
+// discard reads the remaining body of resp and closes it.
+func discard(resp *http.Response) error {
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// mkcol creates the directory dir, it is not an error if it already exists.
+func (b *Backend) mkcol(ctx context.Context, dir string) error {
+	resp, err := b.request(ctx, "MKCOL", strings.TrimSuffix(dir, "/")+"/", nil)
+	if err != nil {
+		return errors.Wrap(err, "MKCOL")
+	}
+	if err := discard(resp); err != nil {
+		return errors.Wrap(err, "Close")
+	}
+
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusMethodNotAllowed:
+		// the directory already exists
+		return nil
+	default:
+		return errors.Errorf("unable to create directory %v, server response: %v (%v)", dir, resp.Status, resp.StatusCode)
+	}
+}
+
+// Save stores data in the backend at the handle.
+func (b *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	status, err := b.put(ctx, h, rd)
+	if err == nil && status == http.StatusConflict {
+		// the parent directory is missing, create it and try again
+		debug.Log("creating missing directory %v", b.Dirname(h))
+		if err := b.mkcol(ctx, b.Dirname(h)); err != nil {
+			return err
+		}
+		if err := rd.Rewind(); err != nil {
+			return err
+		}
+		status, err = b.put(ctx, h, rd)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch status {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return nil
+	default:
+		return errors.Errorf("server response unexpected: %v (%v)", http.StatusText(status), status)
+	}
+}
+
+func (b *Backend) put(ctx context.Context, h backend.Handle, rd backend.RewindReader) (int, error) {
+	// make sure that client.Do() cannot close the reader by wrapping it
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, b.fileURL(b.Filename(h)), io.NopCloser(rd))
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	// explicitly set the content length, this prevents chunked encoding and
+	// lets the server know what's coming.
+	req.ContentLength = rd.Length()
+	// allow resending the request, e.g. for digest authentication
+	req.GetBody = func() (io.ReadCloser, error) {
+		if err := rd.Rewind(); err != nil {
+			return nil, err
+		}
+		return io.NopCloser(rd), nil
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if err := discard(resp); err != nil {
+		return 0, errors.Wrap(err, "Close")
+	}
+	return resp.StatusCode, nil
+}
+
+// notExistError is returned whenever the requested file does not exist on the
+// server.
+type notExistError struct {
+	backend.Handle
+}
+
+func (e *notExistError) Error() string {
+	return fmt.Sprintf("%v does not exist", e.Handle)
+}
+
+// IsNotExist returns true if the error was caused by a non-existing file.
+func (b *Backend) IsNotExist(err error) bool {
+	var e *notExistError
+	return errors.As(err, &e)
+}
+
+// Load runs fn with a reader that yields the contents of the file at h at the
+// given offset.
+func (b *Backend) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	return util.DefaultLoad(ctx, h, length, offset, b.openReader, fn)
+}
+
+func (b *Backend) openReader(ctx context.Context, h backend.Handle, length int, offset int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.fileURL(b.Filename(h)), nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if length > 0 || offset > 0 {
+		byteRange := fmt.Sprintf("bytes=%d-", offset)
+		if length > 0 {
+			byteRange = fmt.Sprintf("bytes=%d-%d", offset, offset+int64(length)-1)
+		}
+		req.Header.Set("Range", byteRange)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "client.Do")
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		_ = discard(resp)
+		return nil, &notExistError{h}
+	}
+
+	if resp.StatusCode == http.StatusOK && offset > 0 {
+		// the server ignored the range request
+		_ = discard(resp)
+		return nil, errors.Errorf("server does not support range requests")
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		_ = discard(resp)
+		return nil, errors.Errorf("unexpected HTTP response (%v): %v", resp.StatusCode, resp.Status)
+	}
+
+	if length > 0 {
+		// servers may return the whole file despite the range request
+		return backend.LimitReadCloser(resp.Body, int64(length)), nil
+	}
+	return resp.Body, nil
+}
+
+// Stat returns information about a blob.
+func (b *Backend) Stat(ctx context.Context, h backend.Handle) (backend.FileInfo, error) {
+	entries, status, err := b.propfind(ctx, b.Filename(h), "0")
+	if err != nil {
+		return backend.FileInfo{}, err
+	}
+	if status == http.StatusNotFound {
+		return backend.FileInfo{}, &notExistError{h}
+	}
+	if status != http.StatusMultiStatus {
+		return backend.FileInfo{}, errors.Errorf("unexpected HTTP response (%v): %v", status, http.StatusText(status))
+	}
+
+	for _, entry := range entries {
+		if entry.dir {
+			continue
+		}
+		return backend.FileInfo{Size: entry.size, Name: h.Name}, nil
+	}
+	return backend.FileInfo{}, &notExistError{h}
+}
+
+// Remove removes the blob with the given name and type.
+func (b *Backend) Remove(ctx context.Context, h backend.Handle) error {
+	resp, err := b.request(ctx, http.MethodDelete, b.Filename(h), nil)
+	if err != nil {
+		return errors.Wrap(err, "client.Do")
+	}
+	if err := discard(resp); err != nil {
+		return errors.Wrap(err, "Close")
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusAccepted:
+		return nil
+	case http.StatusNotFound:
+		return &notExistError{h}
+	default:
+		return errors.Errorf("blob not removed, server response: %v (%v)", resp.Status, resp.StatusCode)
+	}
+}
+
+// davEntry is a file or directory returned by PROPFIND.
+type davEntry struct {
+	href string
+	dir  bool
+	size int64
+}
+
+// multistatus is the response body of a PROPFIND request.
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+				ContentLength string `xml:"DAV: getcontentlength"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/></d:prop></d:propfind>`
+
+// propfind requests the entries below p up to the given depth. The HTTP
+// status code is returned, the entries are only valid for http.StatusMultiStatus.
+func (b *Backend) propfind(ctx context.Context, p string, depth string) ([]davEntry, int, error) {
+	req, err := http.NewRequestWithContext(ctx, "PROPFIND", b.fileURL(p), strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", `application/xml; charset="utf-8"`)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "PROPFIND")
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, resp.StatusCode, errors.Wrap(discard(resp), "Close")
+	}
+
+	var ms multistatus
+	err = xml.NewDecoder(resp.Body).Decode(&ms)
+	if cerr := discard(resp); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "parse PROPFIND response")
+	}
+
+	var entries []davEntry
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstat {
+			if !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			href := r.Href
+			if u, err := url.Parse(href); err == nil {
+				href = u.Path
+			}
+			entry := davEntry{href: href, dir: ps.Prop.ResourceType.Collection != nil}
+			if !entry.dir && ps.Prop.ContentLength != "" {
+				entry.size, err = strconv.ParseInt(ps.Prop.ContentLength, 10, 64)
+				if err != nil {
+					return nil, 0, errors.Wrap(err, "parse content length")
+				}
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, resp.StatusCode, nil
+}
+
+// List runs fn for each file in the backend which has the type t. When an
+// error occurs (or fn returns an error), List stops and returns it.
+func (b *Backend) List(ctx context.Context, t backend.FileType, fn func(backend.FileInfo) error) error {
+	basedir, subdirs := b.Basedir(t)
+	basedir = strings.TrimSuffix(basedir, "/") + "/"
+
+	if subdirs {
+		entries, status, err := b.propfind(ctx, basedir, "infinity")
+		if err != nil {
+			return err
+		}
+		switch status {
+		case http.StatusMultiStatus:
+			return b.listEntries(ctx, basedir, entries, fn)
+		case http.StatusNotFound:
+			return nil
+		case http.StatusForbidden, http.StatusBadRequest, http.StatusNotImplemented:
+			// the server does not support listing with infinite depth
+			debug.Log("PROPFIND with depth infinity returned %v, listing subdirectories", status)
+		default:
+			return errors.Errorf("List failed, server response: %v (%v)", http.StatusText(status), status)
+		}
+	}
+
+	entries, status, err := b.propfind(ctx, basedir, "1")
+	if err != nil {
+		return err
+	}
+	if status == http.StatusNotFound {
+		return nil
+	}
+	if status != http.StatusMultiStatus {
+		return errors.Errorf("List failed, server response: %v (%v)", http.StatusText(status), status)
+	}
+	if !subdirs {
+		return b.listEntries(ctx, basedir, entries, fn)
+	}
+
+	for _, entry := range entries {
+		if !entry.dir || strings.TrimSuffix(entry.href, "/") == strings.TrimSuffix(basedir, "/") {
+			continue
+		}
+		subentries, status, err := b.propfind(ctx, strings.TrimSuffix(entry.href, "/")+"/", "1")
+		if err != nil {
+			return err
+		}
+		if status != http.StatusMultiStatus {
+			return errors.Errorf("List failed, server response: %v (%v)", http.StatusText(status), status)
+		}
+		if err := b.listEntries(ctx, basedir, subentries, fn); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// listEntries calls fn for all files in entries.
+func (b *Backend) listEntries(ctx context.Context, basedir string, entries []davEntry, fn func(backend.FileInfo) error) error {
+	for _, entry := range entries {
+		if entry.dir || !strings.HasPrefix(entry.href, basedir) {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		err := fn(backend.FileInfo{Name: path.Base(entry.href), Size: entry.size})
+		if err != nil {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return ctx.Err()
+}
+
+// Close closes all open files.
+func (b *Backend) Close() error {
+	// this does not need to do anything, all open files are closed within the
+	// same function.
+	return nil
+}
+
+// Delete removes all data in the backend.
+func (b *Backend) Delete(ctx context.Context) error {
+	return util.DefaultDelete(ctx, b)
+}
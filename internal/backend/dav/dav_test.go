@@ -0,0 +1,172 @@
+package dav_test
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/dav"
+	"github.com/restic/restic/internal/backend/test"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// runDAVServer starts an in-process WebDAV server storing its files in dir.
+// The handler is wrapped by wrap, if set.
+func runDAVServer(t testing.TB, dir string, wrap func(http.Handler) http.Handler) *url.URL {
+	var handler http.Handler = &webdav.Handler{
+		FileSystem: webdav.Dir(dir),
+		LockSystem: webdav.NewMemLS(),
+	}
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL + "/restic-test/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func newTestSuite(url *url.URL, minimalData bool) *test.Suite[dav.Config] {
+	return &test.Suite[dav.Config]{
+		MinimalData: minimalData,
+
+		// NewConfig returns a config for a new temporary backend that will be used in tests.
+		NewConfig: func() (*dav.Config, error) {
+			cfg := dav.NewConfig()
+			cfg.URL = url
+			return &cfg, nil
+		},
+
+		Factory: dav.NewFactory(),
+	}
+}
+
+func TestBackendDAV(t *testing.T) {
+	serverURL := runDAVServer(t, rtest.TempDir(t), nil)
+	newTestSuite(serverURL, false).RunTests(t)
+}
+
+// rejectDepthInfinity refuses PROPFIND requests with depth infinity, like many
+// WebDAV servers do.
+func rejectDepthInfinity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PROPFIND" && strings.EqualFold(r.Header.Get("Depth"), "infinity") {
+			http.Error(w, "depth infinity not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestBackendDAVNoDepthInfinity(t *testing.T) {
+	serverURL := runDAVServer(t, rtest.TempDir(t), rejectDepthInfinity)
+	newTestSuite(serverURL, true).RunTests(t)
+}
+
+func withBasicAuth(username, password string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, pass, ok := r.BasicAuth()
+			if !ok || user != username || pass != password {
+				w.Header().Set("WWW-Authenticate", `Basic realm="restic"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func md5hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// withDigestAuth only accepts requests using MD5 digest authentication with
+// qop=auth and rejects basic authentication.
+func withDigestAuth(username, password string) func(http.Handler) http.Handler {
+	const realm, nonce = "restic", "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			params := make(map[string]string)
+			scheme, rest, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+			for _, field := range strings.Split(rest, ", ") {
+				key, value, _ := strings.Cut(field, "=")
+				params[key] = strings.Trim(value, `"`)
+			}
+
+			ha1 := md5hex(username + ":" + realm + ":" + password)
+			ha2 := md5hex(r.Method + ":" + r.URL.RequestURI())
+			expected := md5hex(strings.Join([]string{ha1, nonce, params["nc"], params["cnonce"], "auth", ha2}, ":"))
+
+			if scheme != "Digest" || params["username"] != username || params["uri"] != r.URL.RequestURI() || params["response"] != expected {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm=%q, nonce=%q, qop="auth", algorithm=MD5`, realm, nonce))
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestBackendDAVBasicAuth(t *testing.T) {
+	serverURL := runDAVServer(t, rtest.TempDir(t), withBasicAuth("user", "secret"))
+	serverURL.User = url.UserPassword("user", "secret")
+	newTestSuite(serverURL, true).RunTests(t)
+}
+
+func TestBackendDAVDigestAuth(t *testing.T) {
+	serverURL := runDAVServer(t, rtest.TempDir(t), withDigestAuth("user", "secret"))
+	serverURL.User = url.UserPassword("user", "secret")
+	newTestSuite(serverURL, true).RunTests(t)
+}
+
+func TestBackendDAVWrongPassword(t *testing.T) {
+	ctx := context.TODO()
+	for _, wrap := range []func(http.Handler) http.Handler{
+		withBasicAuth("user", "secret"),
+		withDigestAuth("user", "secret"),
+	} {
+		serverURL := runDAVServer(t, rtest.TempDir(t), wrap)
+		serverURL.User = url.UserPassword("user", "wrong")
+
+		cfg := dav.NewConfig()
+		cfg.URL = serverURL
+		be, err := dav.Open(ctx, cfg, http.DefaultTransport)
+		rtest.OK(t, err)
+
+		_, err = be.Stat(ctx, backend.Handle{Type: backend.ConfigFile})
+		rtest.Assert(t, err != nil, "expected error for wrong password")
+		rtest.Assert(t, !be.IsNotExist(err), "wrong password must not be reported as missing file, got %v", err)
+	}
+}
+
+func TestBackendDAVExternalServer(t *testing.T) {
+	repostr := os.Getenv("RESTIC_TEST_DAV_REPOSITORY")
+	if repostr == "" {
+		t.Skipf("environment variable %v not set", "RESTIC_TEST_DAV_REPOSITORY")
+	}
+
+	cfg, err := dav.ParseConfig(repostr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newTestSuite(cfg.URL, true).RunTests(t)
+}
@@ -331,8 +331,9 @@ func (be *Backend) List(ctx context.Context, t backend.FileType, fn func(backend
 		}
 
 		fi := backend.FileInfo{
-			Name: path.Base(m),
-			Size: int64(attrs.Size),
+			Name:    path.Base(m),
+			Size:    int64(attrs.Size),
+			ModTime: attrs.Updated,
 		}
 
 		err = fn(fi)
@@ -1,17 +1,20 @@
 package backend
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
 )
 
 // TransportOptions collects various options which can be set for an HTTP based
@@ -25,6 +28,67 @@ type TransportOptions struct {
 
 	// Skip TLS certificate verification
 	InsecureTLS bool
+
+	// Timeouts and keep-alive settings, the defaults from NewHTTPOptions are
+	// used if unset.
+	HTTP *HTTPOptions
+}
+
+// HTTPOptions configures the timeouts of HTTP connections. A value of zero
+// disables the respective timeout. In addition, the proxy and the IP version
+// used for connections can be selected.
+type HTTPOptions struct {
+	DialTimeout         time.Duration `option:"dial-timeout" help:"timeout for establishing a connection (default: 30s)"`
+	TLSHandshakeTimeout time.Duration `option:"tls-handshake-timeout" help:"timeout for the TLS handshake (default: 10s)"`
+	Timeout             time.Duration `option:"timeout" help:"time to wait for the response after a request has been sent completely, uploads are not limited by it (default: 0, no timeout)"`
+	IdleTimeout         time.Duration `option:"idle-timeout" help:"close connections which have been idle for this long (default: 90s)"`
+	KeepAlive           time.Duration `option:"keep-alive" help:"interval between TCP keep-alive probes, 0 disables them (default: 30s)"`
+	Proxy               string        `option:"proxy" help:"URL of the proxy used for all connections, 'none' disables proxies (default: from HTTP_PROXY, HTTPS_PROXY and NO_PROXY)"`
+	IPVersion           uint          `option:"ip-version" help:"only connect using IPv4 (4) or IPv6 (6) (default: 0, both)"`
+}
+
+// NewHTTPOptions returns the default HTTP options.
+func NewHTTPOptions() HTTPOptions {
+	return HTTPOptions{
+		DialTimeout:         30 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+		IdleTimeout:         90 * time.Second,
+		KeepAlive:           30 * time.Second,
+	}
+}
+
+func init() {
+	options.Register("http", HTTPOptions{})
+}
+
+// proxyFunc returns the function used by http.Transport to select the proxy
+// for a request.
+func (o HTTPOptions) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	switch o.Proxy {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case "none":
+		return nil, nil
+	}
+
+	u, err := url.Parse(o.Proxy)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, errors.Errorf("invalid proxy URL %q", o.Proxy)
+	}
+	return http.ProxyURL(u), nil
+}
+
+// network returns the network passed to net.Dialer for the IP version.
+func (o HTTPOptions) network() (string, error) {
+	switch o.IPVersion {
+	case 0:
+		return "", nil
+	case 4:
+		return "tcp4", nil
+	case 6:
+		return "tcp6", nil
+	}
+	return "", errors.Errorf("invalid IP version %d, must be 4 or 6", o.IPVersion)
 }
 
 // readPEMCertKey reads a file and returns the PEM encoded certificate and key
@@ -65,19 +129,48 @@ func readPEMCertKey(filename string) (certs []byte, key []byte, err error) {
 // a custom rootCertFilename is non-empty, it must point to a valid PEM file,
 // otherwise the function will return an error.
 func Transport(opts TransportOptions) (http.RoundTripper, error) {
+	httpOpts := NewHTTPOptions()
+	if opts.HTTP != nil {
+		httpOpts = *opts.HTTP
+	}
+
+	keepAlive := httpOpts.KeepAlive
+	if keepAlive == 0 {
+		// a negative value disables keep-alive probes in net.Dialer
+		keepAlive = -1
+	}
+
+	proxy, err := httpOpts.proxyFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   httpOpts.DialTimeout,
+		KeepAlive: keepAlive,
+		DualStack: true,
+	}
+	dial := dialer.DialContext
+	network, err := httpOpts.network()
+	if err != nil {
+		return nil, err
+	}
+	if network != "" {
+		dial = func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+	}
+
 	// copied from net/http
 	tr := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-			DualStack: true,
-		}).DialContext,
+		Proxy:                 proxy,
+		DialContext:           dial,
 		ForceAttemptHTTP2:     true,
 		MaxIdleConns:          100,
 		MaxIdleConnsPerHost:   100,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
+		IdleConnTimeout:       httpOpts.IdleTimeout,
+		TLSHandshakeTimeout:   httpOpts.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: httpOpts.Timeout,
 		ExpectContinueTimeout: 1 * time.Second,
 		TLSClientConfig:       &tls.Config{},
 	}
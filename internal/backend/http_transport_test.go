@@ -0,0 +1,142 @@
+package backend_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func writePEM(t testing.TB, filename string, blocks ...*pem.Block) string {
+	var data []byte
+	for _, block := range blocks {
+		data = append(data, pem.EncodeToMemory(block)...)
+	}
+	rtest.OK(t, os.WriteFile(filename, data, 0600))
+	return filename
+}
+
+func get(t testing.TB, opts backend.TransportOptions, url string) error {
+	rt, err := backend.Transport(opts)
+	rtest.OK(t, err)
+
+	resp, err := (&http.Client{Transport: rt}).Get(url)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func TestTransportTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	rootCert := writePEM(t, filepath.Join(t.TempDir(), "root.pem"),
+		&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+
+	rtest.Assert(t, get(t, backend.TransportOptions{}, srv.URL) != nil,
+		"expected error for untrusted server certificate")
+	rtest.OK(t, get(t, backend.TransportOptions{InsecureTLS: true}, srv.URL))
+	rtest.OK(t, get(t, backend.TransportOptions{RootCertFilenames: []string{rootCert}}, srv.URL))
+
+	_, err := backend.Transport(backend.TransportOptions{RootCertFilenames: []string{filepath.Join(t.TempDir(), "missing.pem")}})
+	rtest.Assert(t, err != nil, "expected error for missing root certificate")
+}
+
+func TestTransportTLSClientCert(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rtest.OK(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "restic"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	rtest.OK(t, err)
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	rtest.OK(t, err)
+
+	clientCert := writePEM(t, filepath.Join(t.TempDir(), "client.pem"),
+		&pem.Block{Type: "CERTIFICATE", Bytes: cert},
+		&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes})
+
+	rtest.Assert(t, get(t, backend.TransportOptions{InsecureTLS: true}, srv.URL) != nil,
+		"expected error without client certificate")
+	rtest.OK(t, get(t, backend.TransportOptions{InsecureTLS: true, TLSClientCertKeyFilename: clientCert}, srv.URL))
+}
+
+func TestTransportTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	httpOpts := backend.NewHTTPOptions()
+	rtest.OK(t, get(t, backend.TransportOptions{HTTP: &httpOpts}, srv.URL))
+
+	httpOpts.Timeout = 10 * time.Millisecond
+	rtest.Assert(t, get(t, backend.TransportOptions{HTTP: &httpOpts}, srv.URL) != nil,
+		"expected timeout error for slow response")
+}
+
+func TestTransportProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// requests sent to a proxy contain the absolute URL
+		proxied = append(proxied, r.URL.String())
+	}))
+	defer proxy.Close()
+
+	httpOpts := backend.NewHTTPOptions()
+	httpOpts.Proxy = proxy.URL
+	rtest.OK(t, get(t, backend.TransportOptions{HTTP: &httpOpts}, "http://repo.example.invalid/config"))
+	rtest.Equals(t, []string{"http://repo.example.invalid/config"}, proxied)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	httpOpts.Proxy = "none"
+	rtest.OK(t, get(t, backend.TransportOptions{HTTP: &httpOpts}, srv.URL))
+	rtest.Equals(t, 1, len(proxied))
+
+	httpOpts.Proxy = "proxy:3128"
+	_, err := backend.Transport(backend.TransportOptions{HTTP: &httpOpts})
+	rtest.Assert(t, err != nil, "expected error for proxy without scheme")
+}
+
+func TestTransportIPVersion(t *testing.T) {
+	// httptest servers listen on 127.0.0.1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	httpOpts := backend.NewHTTPOptions()
+	httpOpts.IPVersion = 4
+	rtest.OK(t, get(t, backend.TransportOptions{HTTP: &httpOpts}, srv.URL))
+
+	httpOpts.IPVersion = 6
+	rtest.Assert(t, get(t, backend.TransportOptions{HTTP: &httpOpts}, srv.URL) != nil,
+		"expected error connecting to an IPv4 address using IPv6")
+
+	httpOpts.IPVersion = 5
+	_, err := backend.Transport(backend.TransportOptions{HTTP: &httpOpts})
+	rtest.Assert(t, err != nil, "expected error for invalid IP version")
+}
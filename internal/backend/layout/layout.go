@@ -23,6 +23,12 @@ type Layout interface {
 	Name() string
 }
 
+// isOptional returns true for file types whose directory is not created when
+// the repository is initialized. Backends create it when saving the first file.
+func isOptional(t backend.FileType) bool {
+	return t == backend.SnapshotIndexFile
+}
+
 // Filesystem is the abstraction of a file system used for a backend.
 type Filesystem interface {
 	Join(...string) string
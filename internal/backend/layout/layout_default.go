@@ -21,6 +21,8 @@ var defaultLayoutPaths = map[backend.FileType]string{
 	backend.IndexFile:    "index",
 	backend.LockFile:     "locks",
 	backend.KeyFile:      "keys",
+
+	backend.SnapshotIndexFile: "snapshot-index",
 }
 
 func (l *DefaultLayout) String() string {
@@ -55,7 +57,10 @@ func (l *DefaultLayout) Filename(h backend.Handle) string {
 
 // Paths returns all directory names needed for a repo.
 func (l *DefaultLayout) Paths() (dirs []string) {
-	for _, p := range defaultLayoutPaths {
+	for t, p := range defaultLayoutPaths {
+		if isOptional(t) {
+			continue
+		}
 		dirs = append(dirs, l.Join(l.Path, p))
 	}
 
@@ -44,7 +44,10 @@ func (l *RESTLayout) Filename(h backend.Handle) string {
 
 // Paths returns all directory names
 func (l *RESTLayout) Paths() (dirs []string) {
-	for _, p := range restLayoutPaths {
+	for t, p := range restLayoutPaths {
+		if isOptional(t) {
+			continue
+		}
 		dirs = append(dirs, l.URL+l.Join(l.Path, p))
 	}
 	return dirs
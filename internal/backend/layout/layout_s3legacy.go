@@ -18,6 +18,8 @@ var s3LayoutPaths = map[backend.FileType]string{
 	backend.IndexFile:    "index",
 	backend.LockFile:     "lock",
 	backend.KeyFile:      "key",
+
+	backend.SnapshotIndexFile: "snapshot-index",
 }
 
 func (l *S3LegacyLayout) String() string {
@@ -67,7 +69,10 @@ func (l *S3LegacyLayout) Filename(h backend.Handle) string {
 
 // Paths returns all directory names
 func (l *S3LegacyLayout) Paths() (dirs []string) {
-	for _, p := range s3LayoutPaths {
+	for t, p := range s3LayoutPaths {
+		if isOptional(t) {
+			continue
+		}
 		dirs = append(dirs, l.Join(l.Path, p))
 	}
 	return dirs
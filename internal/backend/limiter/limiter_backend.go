@@ -45,6 +45,15 @@ func (r rateLimitedBackend) Save(ctx context.Context, h backend.Handle, rd backe
 	return r.Backend.Save(ctx, h, limited)
 }
 
+func (r rateLimitedBackend) SaveExclusive(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	limited := limitedRewindReader{
+		RewindReader: rd,
+		limited:      r.limiter.Upstream(rd),
+	}
+
+	return backend.SaveExclusive(ctx, r.Backend, h, limited)
+}
+
 type limitedRewindReader struct {
 	backend.RewindReader
 
@@ -86,3 +95,4 @@ func (l *limitedReader) WriteTo(w io.Writer) (int64, error) {
 }
 
 var _ backend.Backend = (*rateLimitedBackend)(nil)
+var _ backend.ExclusiveSaveBackend = (*rateLimitedBackend)(nil)
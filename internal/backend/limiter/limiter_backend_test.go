@@ -44,6 +44,33 @@ func TestLimitBackendSave(t *testing.T) {
 	rtest.OK(t, err)
 }
 
+func TestLimitBackendSaveExclusive(t *testing.T) {
+	testHandle := backend.Handle{Type: backend.PackFile, Name: "test"}
+	data := randomBytes(t, 1234)
+
+	be := mock.NewBackend()
+	be.SaveExclusiveFn = func(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+		if _, ok := rd.(limitedRewindReader); !ok {
+			return fmt.Errorf("reader is not rate limited")
+		}
+		buf := new(bytes.Buffer)
+		_, err := io.Copy(buf, rd)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(data, buf.Bytes()) {
+			return fmt.Errorf("data mismatch")
+		}
+		return nil
+	}
+	limiter := NewStaticLimiter(Limits{42 * 1024, 42 * 1024})
+	limbe := LimitBackend(be, limiter)
+
+	rd := backend.NewByteReader(data, nil)
+	err := backend.SaveExclusive(context.TODO(), limbe, testHandle, rd)
+	rtest.OK(t, err)
+}
+
 type tracedReadWriteToCloser struct {
 	io.Reader
 	io.WriterTo
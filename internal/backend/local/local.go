@@ -29,6 +29,7 @@ type Local struct {
 
 // ensure statically that *Local implements backend.Backend.
 var _ backend.Backend = &Local{}
+var _ backend.ExclusiveSaveBackend = &Local{}
 
 func NewFactory() location.Factory {
 	return location.NewLimitedBackendFactory("local", ParseConfig, location.NoPassword, limiter.WrapBackendConstructor(Create), limiter.WrapBackendConstructor(Open))
@@ -112,6 +113,16 @@ func (b *Local) IsNotExist(err error) bool {
 
 // Save stores data in the backend at the handle.
 func (b *Local) Save(_ context.Context, h backend.Handle, rd backend.RewindReader) (err error) {
+	return b.save(h, rd, false)
+}
+
+// SaveExclusive stores data in the backend at the handle, unless the file
+// already exists.
+func (b *Local) SaveExclusive(_ context.Context, h backend.Handle, rd backend.RewindReader) (err error) {
+	return b.save(h, rd, true)
+}
+
+func (b *Local) save(h backend.Handle, rd backend.RewindReader, exclusive bool) (err error) {
 	finalname := b.Filename(h)
 	dir := filepath.Dir(finalname)
 
@@ -182,7 +193,20 @@ func (b *Local) Save(_ context.Context, h backend.Handle, rd backend.RewindReade
 	if err = f.Close(); err != nil {
 		return errors.WithStack(err)
 	}
-	if err = os.Rename(f.Name(), finalname); err != nil {
+	if exclusive {
+		// in contrast to rename, creating a hard link fails if the target
+		// already exists
+		err = os.Link(f.Name(), finalname)
+		if errors.Is(err, os.ErrExist) {
+			return backoff.Permanent(backend.ErrAlreadyExists)
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err = fs.Remove(f.Name()); err != nil {
+			return errors.WithStack(err)
+		}
+	} else if err = os.Rename(f.Name(), finalname); err != nil {
 		return errors.WithStack(err)
 	}
 
@@ -341,8 +365,9 @@ func visitFiles(ctx context.Context, dir string, fn func(backend.FileInfo) error
 		}
 
 		err := fn(backend.FileInfo{
-			Name: fi.Name(),
-			Size: fi.Size(),
+			Name:    fi.Name(),
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
 		})
 		if err != nil {
 			return err
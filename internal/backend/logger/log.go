@@ -14,6 +14,7 @@ type Backend struct {
 
 // statically ensure that Backend implements backend.Backend.
 var _ backend.Backend = &Backend{}
+var _ backend.ExclusiveSaveBackend = &Backend{}
 
 func New(be backend.Backend) *Backend {
 	return &Backend{Backend: be}
@@ -33,6 +34,14 @@ func (be *Backend) Save(ctx context.Context, h backend.Handle, rd backend.Rewind
 	return err
 }
 
+// SaveExclusive adds new Data to the backend, unless the file already exists.
+func (be *Backend) SaveExclusive(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	debug.Log("SaveExclusive(%v, %v)", h, rd.Length())
+	err := backend.SaveExclusive(ctx, be.Backend, h, rd)
+	debug.Log("  save err %v", err)
+	return err
+}
+
 // Remove deletes a file from the backend.
 func (be *Backend) Remove(ctx context.Context, h backend.Handle) error {
 	debug.Log("Remove(%v)", h)
@@ -21,6 +21,7 @@ type memMap map[backend.Handle][]byte
 
 // make sure that MemoryBackend implements backend.Backend
 var _ backend.Backend = &MemoryBackend{}
+var _ backend.ExclusiveSaveBackend = &MemoryBackend{}
 
 // NewFactory creates a persistent mem backend
 func NewFactory() location.Factory {
@@ -68,6 +69,12 @@ func (be *MemoryBackend) IsNotExist(err error) bool {
 	return errors.Is(err, errNotFound)
 }
 
+// SaveExclusive adds new Data to the backend. As Save never overwrites
+// existing files, it is identical to Save.
+func (be *MemoryBackend) SaveExclusive(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	return be.Save(ctx, h, rd)
+}
+
 // Save adds new Data to the backend.
 func (be *MemoryBackend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
 	be.m.Lock()
@@ -79,7 +86,7 @@ func (be *MemoryBackend) Save(ctx context.Context, h backend.Handle, rd backend.
 	}
 
 	if _, ok := be.data[h]; ok {
-		return errors.New("file already exists")
+		return backend.ErrAlreadyExists
 	}
 
 	buf, err := io.ReadAll(rd)
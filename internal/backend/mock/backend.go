@@ -14,6 +14,7 @@ type Backend struct {
 	CloseFn            func() error
 	IsNotExistFn       func(err error) bool
 	SaveFn             func(ctx context.Context, h backend.Handle, rd backend.RewindReader) error
+	SaveExclusiveFn    func(ctx context.Context, h backend.Handle, rd backend.RewindReader) error
 	OpenReaderFn       func(ctx context.Context, h backend.Handle, length int, offset int64) (io.ReadCloser, error)
 	StatFn             func(ctx context.Context, h backend.Handle) (backend.FileInfo, error)
 	ListFn             func(ctx context.Context, t backend.FileType, fn func(backend.FileInfo) error) error
@@ -92,6 +93,16 @@ func (m *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindR
 	return m.SaveFn(ctx, h, rd)
 }
 
+// SaveExclusive saves data in the backend, unless the file already exists.
+// Without SaveExclusiveFn, it is reported as unsupported.
+func (m *Backend) SaveExclusive(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	if m.SaveExclusiveFn == nil {
+		return backend.ErrExclusiveSaveUnsupported
+	}
+
+	return m.SaveExclusiveFn(ctx, h, rd)
+}
+
 // Load runs fn with a reader that yields the contents of the file at h at the
 // given offset.
 func (m *Backend) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
@@ -11,6 +11,7 @@ import (
 	"path"
 	"strings"
 
+	"github.com/cenkalti/backoff/v4"
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/backend/layout"
 	"github.com/restic/restic/internal/backend/location"
@@ -21,6 +22,7 @@ import (
 
 // make sure the rest backend implements backend.Backend
 var _ backend.Backend = &Backend{}
+var _ backend.ExclusiveSaveBackend = &Backend{}
 
 // Backend uses the REST protocol to access data stored on a server.
 type Backend struct {
@@ -119,6 +121,24 @@ func (b *Backend) HasAtomicReplace() bool {
 
 // Save stores data in the backend at the handle.
 func (b *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	return b.save(ctx, h, rd, false)
+}
+
+// SaveExclusive stores data in the backend at the handle, unless the file
+// already exists. The request carries an `If-None-Match: *` precondition,
+// servers which ignore it are covered by checking for the file beforehand.
+func (b *Backend) SaveExclusive(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	_, err := b.Stat(ctx, h)
+	if err == nil {
+		return backoff.Permanent(backend.ErrAlreadyExists)
+	}
+	if !b.IsNotExist(err) {
+		return err
+	}
+	return b.save(ctx, h, rd, true)
+}
+
+func (b *Backend) save(ctx context.Context, h backend.Handle, rd backend.RewindReader, exclusive bool) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -130,6 +150,9 @@ func (b *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindR
 	}
 	req.Header.Set("Content-Type", "application/octet-stream")
 	req.Header.Set("Accept", ContentTypeV2)
+	if exclusive {
+		req.Header.Set("If-None-Match", "*")
+	}
 
 	// explicitly set the content length, this prevents chunked encoding and
 	// let's the server know what's coming.
@@ -147,6 +170,10 @@ func (b *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindR
 		return errors.WithStack(err)
 	}
 
+	if exclusive && resp.StatusCode == http.StatusPreconditionFailed {
+		return backoff.Permanent(backend.ErrAlreadyExists)
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return errors.Errorf("server response unexpected: %v (%v)", resp.Status, resp.StatusCode)
 	}
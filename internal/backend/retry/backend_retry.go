@@ -9,6 +9,7 @@ import (
 	"github.com/cenkalti/backoff/v4"
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
 )
 
 // Backend retries operations on the backend in case of an error with a
@@ -22,6 +23,7 @@ type Backend struct {
 
 // statically ensure that RetryBackend implements backend.Backend.
 var _ backend.Backend = &Backend{}
+var _ backend.ExclusiveSaveBackend = &Backend{}
 
 // New wraps be with a backend that retries operations after a
 // backoff. report is called with a description and the error, if one occurred.
@@ -121,6 +123,24 @@ func (be *Backend) Save(ctx context.Context, h backend.Handle, rd backend.Rewind
 	})
 }
 
+// SaveExclusive stores the data in the backend under the given handle, unless
+// the file already exists. A file left over by a failed attempt is not
+// removed, as it cannot be distinguished from a file saved by someone else.
+func (be *Backend) SaveExclusive(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	return be.retry(ctx, fmt.Sprintf("SaveExclusive(%v)", h), func() error {
+		err := rd.Rewind()
+		if err != nil {
+			return err
+		}
+
+		err = backend.SaveExclusive(ctx, be.Backend, h, rd)
+		if errors.Is(err, backend.ErrAlreadyExists) {
+			return backoff.Permanent(err)
+		}
+		return err
+	})
+}
+
 // Load returns a reader that yields the contents of the file at h at the
 // given offset. If length is larger than zero, only a portion of the file
 // is returned. rd must be closed after use. If an error is returned, the
@@ -155,7 +175,12 @@ func (be *Backend) Stat(ctx context.Context, h backend.Handle) (fi backend.FileI
 // Remove removes a File with type t and name.
 func (be *Backend) Remove(ctx context.Context, h backend.Handle) (err error) {
 	return be.retry(ctx, fmt.Sprintf("Remove(%v)", h), func() error {
-		return be.Backend.Remove(ctx, h)
+		err := be.Backend.Remove(ctx, h)
+		if be.Backend.IsNotExist(err) {
+			// the file is already gone, retrying won't change that
+			return backoff.Permanent(err)
+		}
+		return err
 	})
 }
 
@@ -84,6 +84,36 @@ func TestBackendSaveRetryAtomic(t *testing.T) {
 	}
 }
 
+func TestBackendSaveExclusiveRetry(t *testing.T) {
+	calls := 0
+	be := &mock.Backend{
+		SaveFn: func(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+			t.Fatal("Save called instead of SaveExclusive")
+			return nil
+		},
+		SaveExclusiveFn: func(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+			calls++
+			if calls == 1 {
+				return errors.New("injected error")
+			}
+			return backend.ErrAlreadyExists
+		},
+		RemoveFn: func(ctx context.Context, h backend.Handle) error {
+			t.Fatal("Remove called for an exclusively saved file")
+			return nil
+		},
+	}
+
+	TestFastRetries(t)
+	retryBackend := New(be, 10, nil, nil)
+
+	data := test.Random(23, 5*1024)
+	err := retryBackend.SaveExclusive(context.TODO(), backend.Handle{}, backend.NewByteReader(data, be.Hasher()))
+	test.Assert(t, errors.Is(err, backend.ErrAlreadyExists), "expected ErrAlreadyExists, got %v", err)
+	// the first error is retried, ErrAlreadyExists is not
+	test.Equals(t, 2, calls)
+}
+
 func TestBackendListRetry(t *testing.T) {
 	const (
 		ID1 = "id1"
@@ -329,6 +359,32 @@ func TestBackendStatNotExists(t *testing.T) {
 	test.Equals(t, 1, attempt)
 }
 
+func TestBackendRemoveNotExists(t *testing.T) {
+	// remove should not retry if the error matches IsNotExist
+	notFound := errors.New("not found")
+	attempt := 0
+
+	be := mock.NewBackend()
+	be.RemoveFn = func(ctx context.Context, h backend.Handle) error {
+		attempt++
+		if attempt > 1 {
+			t.Fail()
+			return errors.New("must not retry")
+		}
+		return notFound
+	}
+	be.IsNotExistFn = func(err error) bool {
+		return errors.Is(err, notFound)
+	}
+
+	TestFastRetries(t)
+	retryBackend := New(be, 10, nil, nil)
+
+	err := retryBackend.Remove(context.TODO(), backend.Handle{})
+	test.Assert(t, be.IsNotExistFn(err), "unexpected error %v", err)
+	test.Equals(t, 1, attempt)
+}
+
 func assertIsCanceled(t *testing.T, err error) {
 	test.Assert(t, err == context.Canceled, "got unexpected err %v", err)
 }
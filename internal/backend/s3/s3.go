@@ -31,6 +31,7 @@ type Backend struct {
 
 // make sure that *Backend implements backend.Backend
 var _ backend.Backend = &Backend{}
+var _ backend.ExclusiveSaveBackend = &Backend{}
 
 func NewFactory() location.Factory {
 	return location.NewHTTPBackendFactory("s3", ParseConfig, location.NoPassword, Create, Open)
@@ -346,6 +347,13 @@ func (be *Backend) Save(ctx context.Context, h backend.Handle, rd backend.Rewind
 	return errors.Wrap(err, "client.PutObject")
 }
 
+// SaveExclusive always returns backend.ErrExclusiveSaveUnsupported. A
+// conditional PUT requires an unquoted `If-None-Match: *` header, which cannot
+// be set using minio-go, and is not supported by all S3-compatible servers.
+func (be *Backend) SaveExclusive(_ context.Context, _ backend.Handle, _ backend.RewindReader) error {
+	return backend.ErrExclusiveSaveUnsupported
+}
+
 // Load runs fn with a reader that yields the contents of the file at h at the
 // given offset.
 func (be *Backend) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
@@ -455,8 +463,9 @@ func (be *Backend) List(ctx context.Context, t backend.FileType, fn func(backend
 		}
 
 		fi := backend.FileInfo{
-			Name: path.Base(m),
-			Size: obj.Size,
+			Name:    path.Base(m),
+			Size:    obj.Size,
+			ModTime: obj.LastModified,
 		}
 
 		if ctx.Err() != nil {
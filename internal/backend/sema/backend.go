@@ -12,6 +12,7 @@ import (
 
 // make sure that connectionLimitedBackend implements backend.Backend
 var _ backend.Backend = &connectionLimitedBackend{}
+var _ backend.ExclusiveSaveBackend = &connectionLimitedBackend{}
 
 // connectionLimitedBackend limits the number of concurrent operations.
 type connectionLimitedBackend struct {
@@ -73,6 +74,21 @@ func (be *connectionLimitedBackend) Save(ctx context.Context, h backend.Handle,
 	return be.Backend.Save(ctx, h, rd)
 }
 
+// SaveExclusive adds new Data to the backend, unless the file already exists.
+func (be *connectionLimitedBackend) SaveExclusive(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	if err := h.Valid(); err != nil {
+		return backoff.Permanent(err)
+	}
+
+	defer be.typeDependentLimit(h.Type)()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return backend.SaveExclusive(ctx, be.Backend, h, rd)
+}
+
 // Load runs fn with a reader that yields the contents of the file at h at the
 // given offset.
 func (be *connectionLimitedBackend) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
@@ -26,6 +26,17 @@ func TestParameterValidationSave(t *testing.T) {
 	test.Assert(t, err != nil, "Save() with invalid handle did not return an error")
 }
 
+func TestParameterValidationSaveExclusive(t *testing.T) {
+	m := mock.NewBackend()
+	m.SaveExclusiveFn = func(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+		return nil
+	}
+	be := sema.NewBackend(m)
+
+	err := backend.SaveExclusive(context.TODO(), be, backend.Handle{}, nil)
+	test.Assert(t, err != nil, "SaveExclusive() with invalid handle did not return an error")
+}
+
 func TestParameterValidationLoad(t *testing.T) {
 	m := mock.NewBackend()
 	m.OpenReaderFn = func(ctx context.Context, h backend.Handle, length int, offset int64) (io.ReadCloser, error) {
@@ -137,6 +148,21 @@ func TestConcurrencyLimitSave(t *testing.T) {
 	}, unblock, false)
 }
 
+func TestConcurrencyLimitSaveExclusive(t *testing.T) {
+	wait, unblock := countingBlocker()
+	concurrencyTester(t, func(m *mock.Backend) {
+		m.SaveExclusiveFn = func(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+			wait()
+			return nil
+		}
+	}, func(be backend.Backend) func() error {
+		return func() error {
+			h := backend.Handle{Type: backend.PackFile, Name: "foobar"}
+			return backend.SaveExclusive(context.TODO(), be, h, nil)
+		}
+	}, unblock, false)
+}
+
 func TestConcurrencyLimitLoad(t *testing.T) {
 	wait, unblock := countingBlocker()
 	concurrencyTester(t, func(m *mock.Backend) {
@@ -13,9 +13,10 @@ import (
 type Config struct {
 	User, Host, Port, Path string
 
-	Layout  string `option:"layout"  help:"use this backend directory layout (default: auto-detect)"`
-	Command string `option:"command" help:"specify command to create sftp connection"`
-	Args    string `option:"args"    help:"specify arguments for ssh"`
+	Layout    string `option:"layout"     help:"use this backend directory layout (default: auto-detect)"`
+	Command   string `option:"command"    help:"specify command to create sftp connection"`
+	Args      string `option:"args"       help:"specify arguments for ssh"`
+	ProxyJump string `option:"proxy-jump" help:"connect via the given bastion hosts, like ssh -J [user@]host[:port][,...]"`
 
 	Connections uint `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
 }
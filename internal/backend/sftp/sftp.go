@@ -216,6 +216,9 @@ func buildSSHCommand(cfg Config) (cmd string, args []string, err error) {
 		if cfg.Args != "" {
 			return "", nil, errors.New("cannot specify both sftp.command and sftp.args options")
 		}
+		if cfg.ProxyJump != "" {
+			return "", nil, errors.New("cannot specify both sftp.command and sftp.proxy-jump options")
+		}
 
 		return args[0], args[1:], nil
 	}
@@ -231,6 +234,9 @@ func buildSSHCommand(cfg Config) (cmd string, args []string, err error) {
 	if cfg.User != "" {
 		args = append(args, "-l", cfg.User)
 	}
+	if cfg.ProxyJump != "" {
+		args = append(args, "-J", cfg.ProxyJump)
+	}
 
 	if cfg.Args != "" {
 		a, err := backend.SplitShellStrings(cfg.Args)
@@ -499,8 +505,9 @@ func (r *SFTP) List(ctx context.Context, t backend.FileType, fn func(backend.Fil
 		debug.Log("send %v\n", path.Base(walker.Path()))
 
 		rfi := backend.FileInfo{
-			Name: path.Base(walker.Path()),
-			Size: fi.Size(),
+			Name:    path.Base(walker.Path()),
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
 		}
 
 		if ctx.Err() != nil {
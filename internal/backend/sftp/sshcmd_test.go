@@ -41,6 +41,18 @@ var sshcmdTests = []struct {
 		[]string{"host", "-p", "10022", "-l", "user", "-i", "/path/to/id_rsa", "-s", "sftp"},
 		"",
 	},
+	{
+		Config{User: "user", Host: "host", Path: "dir", ProxyJump: "jump@bastion:2222", Args: "-i /path/to/id_rsa"},
+		"ssh",
+		[]string{"host", "-l", "user", "-J", "jump@bastion:2222", "-i", "/path/to/id_rsa", "-s", "sftp"},
+		"",
+	},
+	{
+		Config{Command: "ssh something", ProxyJump: "bastion"},
+		"",
+		nil,
+		"cannot specify both sftp.command and sftp.proxy-jump options",
+	},
 	{
 		Config{Command: "ssh something", Args: "-i /path/to/id_rsa"},
 		"",
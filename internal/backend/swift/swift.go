@@ -185,11 +185,16 @@ func (be *beSwift) Stat(ctx context.Context, h backend.Handle) (bi backend.FileI
 	return backend.FileInfo{Size: obj.Bytes, Name: h.Name}, nil
 }
 
-// Remove removes the blob with the given name and type.
+// Remove removes the blob with the given name and type. Removing a file which
+// does not exist succeeds.
 func (be *beSwift) Remove(ctx context.Context, h backend.Handle) error {
 	objName := be.Filename(h)
 
 	err := be.conn.ObjectDelete(ctx, be.container, objName)
+	// removing a file that does not exist is not an error
+	if errors.Is(err, swift.ObjectNotFound) {
+		err = nil
+	}
 	return errors.Wrap(err, "conn.ObjectDelete")
 }
 
@@ -213,8 +218,9 @@ func (be *beSwift) List(ctx context.Context, t backend.FileType, fn func(backend
 				}
 
 				fi := backend.FileInfo{
-					Name: m,
-					Size: obj.Bytes,
+					Name:    m,
+					Size:    obj.Bytes,
+					ModTime: obj.LastModified,
 				}
 
 				err := fn(fi)
@@ -1,7 +1,12 @@
 package swift_test
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
 	"os"
 	"testing"
 	"time"
@@ -10,9 +15,23 @@ import (
 	"github.com/restic/restic/internal/backend/swift"
 	"github.com/restic/restic/internal/backend/test"
 	rtest "github.com/restic/restic/internal/test"
+
+	"github.com/ncw/swift/v2/swifttest"
 )
 
 func newSwiftTestSuite(t testing.TB) *test.Suite[swift.Config] {
+	return newSwiftTestSuiteWithConfig(t, func() (*swift.Config, error) {
+		cfg, err := swift.ParseConfig(os.Getenv("RESTIC_TEST_SWIFT"))
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.ApplyEnvironment("RESTIC_TEST_")
+		return cfg, nil
+	})
+}
+
+func newSwiftTestSuiteWithConfig(t testing.TB, newConfig func() (*swift.Config, error)) *test.Suite[swift.Config] {
 	return &test.Suite[swift.Config]{
 		// do not use excessive data
 		MinimalData: true,
@@ -35,12 +54,11 @@ func newSwiftTestSuite(t testing.TB) *test.Suite[swift.Config] {
 
 		// NewConfig returns a config for a new temporary backend that will be used in tests.
 		NewConfig: func() (*swift.Config, error) {
-			cfg, err := swift.ParseConfig(os.Getenv("RESTIC_TEST_SWIFT"))
+			cfg, err := newConfig()
 			if err != nil {
 				return nil, err
 			}
 
-			cfg.ApplyEnvironment("RESTIC_TEST_")
 			cfg.Prefix += fmt.Sprintf("/test-%d", time.Now().UnixNano())
 			t.Logf("using prefix %v", cfg.Prefix)
 			return cfg, nil
@@ -66,6 +84,80 @@ func TestBackendSwift(t *testing.T) {
 	newSwiftTestSuite(t).RunTests(t)
 }
 
+// newFakeSwiftConfig starts an in-memory swift server and returns a function
+// which creates configs for it.
+func newFakeSwiftConfig(t testing.TB) func() (*swift.Config, error) {
+	srv, err := swifttest.NewSwiftServer("localhost")
+	rtest.OK(t, err)
+	t.Cleanup(srv.Close)
+
+	upstream, err := url.Parse("http://" + srv.Listener.Addr().String())
+	rtest.OK(t, err)
+	proxy := httputil.NewSingleHostReverseProxy(upstream)
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			clampRange(upstream, r)
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(front.Close)
+	// let the server return the proxy as storage URL
+	srv.URL = front.URL + "/v1"
+
+	return func() (*swift.Config, error) {
+		cfg, err := swift.ParseConfig("swift:restic-test:/restic")
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.AuthURL = front.URL + "/v1.0"
+		cfg.UserName = swifttest.TEST_ACCOUNT
+		cfg.APIKey = swifttest.TEST_ACCOUNT
+		return cfg, nil
+	}
+}
+
+// clampRange limits the range requested by r to the size of the object. The
+// fake server fails for ranges which extend past the end of the object, while
+// real swift servers return the remaining data.
+func clampRange(upstream *url.URL, r *http.Request) {
+	var start, end int64
+	if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err != nil {
+		return
+	}
+
+	u := *upstream
+	u.Path = r.URL.Path
+	req, err := http.NewRequest(http.MethodHead, u.String(), nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("X-Auth-Token", r.Header.Get("X-Auth-Token"))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK && end >= resp.ContentLength {
+		r.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, resp.ContentLength-1))
+	}
+}
+
+func TestBackendSwiftFake(t *testing.T) {
+	newSwiftTestSuiteWithConfig(t, newFakeSwiftConfig(t)).RunTests(t)
+}
+
+func TestBackendSwiftRemoveMissing(t *testing.T) {
+	cfg, err := newFakeSwiftConfig(t)()
+	rtest.OK(t, err)
+	be, err := swift.Open(context.TODO(), *cfg, http.DefaultTransport)
+	rtest.OK(t, err)
+
+	h := backend.Handle{Type: backend.PackFile, Name: "0123456789abcdef"}
+	rtest.OK(t, be.Remove(context.TODO(), h))
+}
+
 func BenchmarkBackendSwift(t *testing.B) {
 	if os.Getenv("RESTIC_TEST_SWIFT") == "" {
 		t.Skip("RESTIC_TEST_SWIFT unset, skipping test")
@@ -664,6 +664,35 @@ func (s *Suite[C]) TestSaveWrongHash(t *testing.T) {
 	}
 }
 
+// TestSaveExclusive tests that SaveExclusive never overwrites existing files.
+func (s *Suite[C]) TestSaveExclusive(t *testing.T) {
+	seedRand(t)
+
+	b := s.open(t)
+	defer s.close(t, b)
+
+	data := test.Random(26, rand.Intn(1<<16)+1000)
+	id := restic.Hash(data)
+	h := backend.Handle{Type: backend.PackFile, Name: id.String()}
+
+	err := backend.SaveExclusive(context.TODO(), b, h, backend.NewByteReader(data, b.Hasher()))
+	test.OK(t, err)
+
+	other := test.Random(27, len(data))
+	err = backend.SaveExclusive(context.TODO(), b, h, backend.NewByteReader(other, b.Hasher()))
+	if !errors.Is(err, backend.ErrAlreadyExists) {
+		t.Fatalf("expected ErrAlreadyExists, got %v", err)
+	}
+
+	buf, err := backend.LoadAll(context.TODO(), nil, b, h)
+	test.OK(t, err)
+	if !bytes.Equal(buf, data) {
+		t.Fatal("existing file was overwritten")
+	}
+
+	test.OK(t, s.delayedRemove(t, b, h))
+}
+
 var testStrings = []struct {
 	id   string
 	data string
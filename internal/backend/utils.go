@@ -29,6 +29,30 @@ func verifyContentMatchesName(s string, data []byte) (bool, error) {
 	return id == hashed, nil
 }
 
+// SaveExclusive stores the data from rd under the given handle, but only if the
+// file does not exist yet. Otherwise ErrAlreadyExists is returned. Backends
+// implementing ExclusiveSaveBackend perform the check atomically, for all
+// other backends or if ErrExclusiveSaveUnsupported is returned, it is emulated
+// by calling Stat before Save, which leaves a small window for a race.
+func SaveExclusive(ctx context.Context, be Backend, h Handle, rd RewindReader) error {
+	if ebe, ok := be.(ExclusiveSaveBackend); ok {
+		err := ebe.SaveExclusive(ctx, h, rd)
+		if !errors.Is(err, ErrExclusiveSaveUnsupported) {
+			return err
+		}
+		debug.Log("SaveExclusive(%v) not supported by %T, checking for the file first", h, be)
+	}
+
+	_, err := be.Stat(ctx, h)
+	if err == nil {
+		return ErrAlreadyExists
+	}
+	if !be.IsNotExist(err) {
+		return err
+	}
+	return be.Save(ctx, h, rd)
+}
+
 // LoadAll reads all data stored in the backend for the handle into the given
 // buffer, which is truncated. If the buffer is not large enough or nil, a new
 // one is allocated.
@@ -10,6 +10,7 @@ import (
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/backend/mem"
 	"github.com/restic/restic/internal/backend/mock"
+	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 )
@@ -147,3 +148,30 @@ func TestLoadAllAppend(t *testing.T) {
 		})
 	}
 }
+
+func TestSaveExclusiveUnsupported(t *testing.T) {
+	errNotFound := errors.New("not found")
+	stored := make(map[backend.Handle]struct{})
+	b := mock.NewBackend()
+	b.StatFn = func(ctx context.Context, h backend.Handle) (backend.FileInfo, error) {
+		if _, ok := stored[h]; ok {
+			return backend.FileInfo{Name: h.Name}, nil
+		}
+		return backend.FileInfo{}, errNotFound
+	}
+	b.IsNotExistFn = func(err error) bool {
+		return err == errNotFound
+	}
+	b.SaveFn = func(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+		stored[h] = struct{}{}
+		return nil
+	}
+
+	// without SaveExclusiveFn, the mock backend reports SaveExclusive as
+	// unsupported, which is emulated using Stat and Save
+	h := backend.Handle{Type: backend.ConfigFile}
+	data := rtest.Random(23, 100)
+	rtest.OK(t, backend.SaveExclusive(context.TODO(), b, h, backend.NewByteReader(data, nil)))
+	err := backend.SaveExclusive(context.TODO(), b, h, backend.NewByteReader(data, nil))
+	rtest.Assert(t, errors.Is(err, backend.ErrAlreadyExists), "expected ErrAlreadyExists, got %v", err)
+}
@@ -23,6 +23,7 @@ type Backend struct {
 
 // ensure Backend implements backend.Backend
 var _ backend.Backend = &Backend{}
+var _ backend.ExclusiveSaveBackend = &Backend{}
 
 func newBackend(be backend.Backend, c *Cache) *Backend {
 	return &Backend{
@@ -33,14 +34,20 @@ func newBackend(be backend.Backend, c *Cache) *Backend {
 }
 
 // Remove deletes a file from the backend and the cache if it has been cached.
+// If the file does not exist in the backend, for example because it was
+// removed by another client, it is removed from the cache as well.
 func (b *Backend) Remove(ctx context.Context, h backend.Handle) error {
 	debug.Log("cache Remove(%v)", h)
 	err := b.Backend.Remove(ctx, h)
-	if err != nil {
+	if err != nil && !b.Backend.IsNotExist(err) {
 		return err
 	}
 
-	return b.Cache.remove(h)
+	cacheErr := b.Cache.remove(h)
+	if err != nil {
+		return err
+	}
+	return cacheErr
 }
 
 func autoCacheTypes(h backend.Handle) bool {
@@ -55,8 +62,22 @@ func autoCacheTypes(h backend.Handle) bool {
 
 // Save stores a new file in the backend and the cache.
 func (b *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	return b.save(ctx, h, rd, b.Backend.Save)
+}
+
+// SaveExclusive stores a new file in the backend and the cache, unless the
+// file already exists in the backend.
+func (b *Backend) SaveExclusive(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	return b.save(ctx, h, rd, func(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+		return backend.SaveExclusive(ctx, b.Backend, h, rd)
+	})
+}
+
+func (b *Backend) save(ctx context.Context, h backend.Handle, rd backend.RewindReader,
+	saveFn func(ctx context.Context, h backend.Handle, rd backend.RewindReader) error) error {
+
 	if !autoCacheTypes(h) {
-		return b.Backend.Save(ctx, h, rd)
+		return saveFn(ctx, h, rd)
 	}
 
 	debug.Log("Save(%v): auto-store in the cache", h)
@@ -68,7 +89,7 @@ func (b *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindR
 	}
 
 	// first, save in the backend
-	err = b.Backend.Save(ctx, h, rd)
+	err = saveFn(ctx, h, rd)
 	if err != nil {
 		return err
 	}
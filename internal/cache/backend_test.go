@@ -200,3 +200,60 @@ func TestBackendRemoveBroken(t *testing.T) {
 		t.Fatalf("wrong data cache")
 	}
 }
+
+func TestBackendRemoveMissing(t *testing.T) {
+	be := mem.New()
+	c := TestNewCache(t)
+	wbe := c.Wrap(be)
+
+	h, data := randomData(5234)
+	save(t, wbe, h, data)
+	test.Assert(t, c.Has(h), "file not cached after save")
+
+	// the file was removed by another client, the cached copy must not be
+	// used any longer
+	remove(t, be, h)
+	err := wbe.Remove(context.TODO(), h)
+	test.Assert(t, wbe.IsNotExist(err), "unexpected error %v", err)
+	test.Assert(t, !c.Has(h), "removed file still in cache")
+}
+
+func TestVerify(t *testing.T) {
+	be := mem.New()
+	c := TestNewCache(t)
+
+	// intact file
+	hOK, dataOK := randomData(5234142)
+	save(t, be, hOK, dataOK)
+	test.OK(t, c.Save(hOK, bytes.NewReader(dataOK)))
+
+	// file which was removed from the repository
+	hStale, dataStale := randomData(4321)
+	test.OK(t, c.Save(hStale, bytes.NewReader(dataStale)))
+
+	// damaged cached file of the same size
+	hDamaged, dataDamaged := randomData(12345)
+	save(t, be, hDamaged, dataDamaged)
+	damaged := append([]byte{}, dataDamaged...)
+	damaged[100] ^= 0xff
+	test.OK(t, c.Save(hDamaged, bytes.NewReader(damaged)))
+
+	// truncated cached file
+	hShort, dataShort := randomData(54321)
+	save(t, be, hShort, dataShort)
+	test.OK(t, c.Save(hShort, bytes.NewReader(dataShort[:1000])))
+
+	removed := make(map[backend.Handle]string)
+	res, err := c.Verify(context.TODO(), be, func(h backend.Handle, reason string) {
+		removed[h] = reason
+	})
+	test.OK(t, err)
+	test.Equals(t, VerifyResult{Checked: 4, Removed: 3}, res)
+
+	for _, h := range []backend.Handle{hStale, hDamaged, hShort} {
+		_, ok := removed[h]
+		test.Assert(t, ok, "damaged file %v was not reported", h)
+		test.Assert(t, !c.Has(h), "damaged file %v was not removed", h)
+	}
+	test.Assert(t, c.Has(hOK), "intact file was removed")
+}
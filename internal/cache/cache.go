@@ -243,3 +243,8 @@ func (c *Cache) Wrap(be backend.Backend) backend.Backend {
 func (c *Cache) BaseDir() string {
 	return c.Base
 }
+
+// RepoDir returns the directory of the cache for the repository.
+func (c *Cache) RepoDir() string {
+	return c.path
+}
@@ -1,6 +1,9 @@
 package cache
 
 import (
+	"context"
+	"crypto/sha256"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -173,6 +176,23 @@ func (c *Cache) Clear(t restic.FileType, valid restic.IDSet) error {
 	return nil
 }
 
+// Purge removes the files of type t with the given IDs from the cache and
+// returns how many of them were cached.
+func (c *Cache) Purge(t restic.FileType, ids restic.IDSet) (int, error) {
+	removed := 0
+	for id := range ids {
+		h := backend.Handle{Type: t, Name: id.String()}
+		if !c.Has(h) {
+			continue
+		}
+		if err := c.remove(h); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
 func isFile(fi os.FileInfo) bool {
 	return fi.Mode()&(os.ModeType|os.ModeCharDevice) == 0
 }
@@ -215,3 +235,83 @@ func (c *Cache) Has(h backend.Handle) bool {
 	_, err := fs.Stat(c.filename(h))
 	return err == nil
 }
+
+// VerifyResult summarizes the result of Verify.
+type VerifyResult struct {
+	Checked int
+	Removed int
+}
+
+// Verify compares all cached files with the backend. A cached file is removed
+// if it does not exist in the backend, if its size differs from the file in
+// the backend or if its content does not match its ID. For each removed file,
+// report is called with the reason.
+func (c *Cache) Verify(ctx context.Context, be backend.Backend, report func(h backend.Handle, reason string)) (VerifyResult, error) {
+	var res VerifyResult
+	for _, t := range []restic.FileType{restic.SnapshotFile, restic.IndexFile, restic.PackFile} {
+		list, err := c.list(t)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return res, err
+		}
+
+		for id := range list {
+			if ctx.Err() != nil {
+				return res, ctx.Err()
+			}
+
+			h := backend.Handle{Type: t, Name: id.String()}
+			reason, err := c.verifyFile(ctx, be, h, id)
+			if err != nil {
+				return res, err
+			}
+			res.Checked++
+
+			if reason == "" {
+				continue
+			}
+			if err := c.remove(h); err != nil {
+				return res, err
+			}
+			res.Removed++
+			report(h, reason)
+		}
+	}
+	return res, nil
+}
+
+// verifyFile returns why the cached file h must be removed, or the empty
+// string if it is intact.
+func (c *Cache) verifyFile(ctx context.Context, be backend.Backend, h backend.Handle, id restic.ID) (string, error) {
+	fi, err := be.Stat(ctx, h)
+	if err != nil {
+		if be.IsNotExist(err) {
+			return "file no longer exists in the repository", nil
+		}
+		return "", err
+	}
+
+	f, err := fs.Open(c.filename(h))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	if size != fi.Size {
+		return fmt.Sprintf("size %d differs from size %d in the repository", size, fi.Size), nil
+	}
+	if !id.Equal(restic.IDFromHash(hash.Sum(nil))) {
+		return "content does not match the file in the repository", nil
+	}
+	return "", nil
+}
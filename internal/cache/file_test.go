@@ -136,6 +136,21 @@ func TestFiles(t *testing.T) {
 	}
 }
 
+func TestFilesPurge(t *testing.T) {
+	c := TestNewCache(t)
+
+	ids := generateRandomFiles(t, restic.PackFile, c)
+	keep := randomID(ids)
+	purge := ids.Sub(restic.NewIDSet(keep))
+	// IDs which are not cached are ignored
+	purge.Insert(restic.NewRandomID())
+
+	removed, err := c.Purge(restic.PackFile, purge)
+	test.OK(t, err)
+	test.Equals(t, len(ids)-1, removed)
+	test.Equals(t, restic.NewIDSet(keep), listFiles(t, c, restic.PackFile))
+}
+
 func TestFileLoad(t *testing.T) {
 	seed := time.Now().Unix()
 	t.Logf("seed is %v", seed)
@@ -9,6 +9,7 @@ import (
 	"runtime"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/minio/sha256-simd"
 	"github.com/restic/restic/internal/backend"
@@ -38,6 +39,10 @@ type Checker struct {
 	}
 	trackUnused bool
 
+	// ReadConcurrency is the number of pack files read concurrently by
+	// ReadPacks. If zero, the number of backend connections is used.
+	ReadConcurrency uint
+
 	masterIndex *index.MasterIndex
 	snapshots   restic.Lister
 
@@ -226,6 +231,11 @@ type PackError struct {
 	ID       restic.ID
 	Orphaned bool
 	Err      error
+
+	// Size and ModTime of the pack file, only set for orphaned packs. ModTime
+	// is zero if the backend does not provide it.
+	Size    int64
+	ModTime time.Time
 }
 
 func (e *PackError) Error() string {
@@ -266,10 +276,15 @@ func (c *Checker) Packs(ctx context.Context, errChan chan<- error) {
 	debug.Log("checking for %d packs", len(c.packs))
 
 	debug.Log("listing repository packs")
-	repoPacks := make(map[restic.ID]int64)
+	repoPacks := make(map[restic.ID]backend.FileInfo)
 
-	err := c.repo.List(ctx, restic.PackFile, func(id restic.ID, size int64) error {
-		repoPacks[id] = size
+	err := c.repo.Backend().List(ctx, restic.PackFile, func(fi backend.FileInfo) error {
+		id, err := restic.ParseID(fi.Name)
+		if err != nil {
+			debug.Log("unable to parse %v as an ID", fi.Name)
+			return nil
+		}
+		repoPacks[id] = fi
 		return nil
 	})
 
@@ -278,7 +293,8 @@ func (c *Checker) Packs(ctx context.Context, errChan chan<- error) {
 	}
 
 	for id, size := range c.packs {
-		reposize, ok := repoPacks[id]
+		fi, ok := repoPacks[id]
+		reposize := fi.Size
 		// remove from repoPacks so we can find orphaned packs
 		delete(repoPacks, id)
 
@@ -303,11 +319,11 @@ func (c *Checker) Packs(ctx context.Context, errChan chan<- error) {
 	}
 
 	// orphaned: present in the repo but not in c.packs
-	for orphanID := range repoPacks {
+	for orphanID, fi := range repoPacks {
 		select {
 		case <-ctx.Done():
 			return
-		case errChan <- &PackError{ID: orphanID, Orphaned: true, Err: errors.New("not referenced in any index")}:
+		case errChan <- &PackError{ID: orphanID, Orphaned: true, Err: errors.New("not referenced in any index"), Size: fi.Size, ModTime: fi.ModTime}:
 		}
 	}
 }
@@ -515,6 +531,88 @@ func (c *Checker) UnusedBlobs(ctx context.Context) (blobs restic.BlobHandles) {
 	return blobs
 }
 
+// PackFragmentation describes how much of a pack file is still referenced.
+type PackFragmentation struct {
+	ID       restic.ID
+	Size     uint64
+	LiveSize uint64
+}
+
+// LiveRatio returns the fraction of the pack that is still referenced.
+func (p PackFragmentation) LiveRatio() float64 {
+	if p.Size == 0 {
+		return 0
+	}
+	return float64(p.LiveSize) / float64(p.Size)
+}
+
+// Fragmentation summarizes the ratio of referenced to total data for all
+// packs in the repository.
+type Fragmentation struct {
+	Packs     []PackFragmentation
+	TotalSize uint64
+	LiveSize  uint64
+}
+
+// Reclaimable returns the number of bytes that are not referenced by any
+// snapshot, including the pack headers of unused blobs.
+func (f *Fragmentation) Reclaimable() uint64 {
+	return f.TotalSize - f.LiveSize
+}
+
+// PacksBelow returns the number of packs whose live ratio is below ratio.
+func (f *Fragmentation) PacksBelow(ratio float64) int {
+	count := 0
+	for _, p := range f.Packs {
+		if p.LiveRatio() < ratio {
+			count++
+		}
+	}
+	return count
+}
+
+// Fragmentation computes how much of each pack is referenced by the
+// snapshots. It must be called after Structure has completed. If a blob is
+// stored in several packs, only one copy is considered live.
+func (c *Checker) Fragmentation(ctx context.Context) *Fragmentation {
+	if !c.trackUnused {
+		panic("only works when tracking blob references")
+	}
+	c.blobRefs.Lock()
+	defer c.blobRefs.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	live := make(map[restic.ID]uint64)
+	seen := restic.NewBlobSet()
+	c.repo.Index().Each(ctx, func(blob restic.PackedBlob) {
+		h := blob.BlobHandle
+		if !c.blobRefs.M.Has(h) || seen.Has(h) {
+			return
+		}
+		seen.Insert(h)
+		live[blob.PackID] += uint64(blob.Length) + uint64(pack.CalculateEntrySize(blob.Blob))
+	})
+
+	f := &Fragmentation{Packs: make([]PackFragmentation, 0, len(c.packs))}
+	for id, size := range c.packs {
+		p := PackFragmentation{ID: id, Size: uint64(size), LiveSize: live[id]}
+		if p.LiveSize > 0 {
+			// the pack header must be kept as long as the pack is in use
+			p.LiveSize += uint64(pack.CalculateHeaderSize(nil))
+		}
+		f.Packs = append(f.Packs, p)
+		f.TotalSize += p.Size
+		f.LiveSize += p.LiveSize
+	}
+	sort.Slice(f.Packs, func(i, j int) bool {
+		return f.Packs[i].LiveRatio() < f.Packs[j].LiveRatio()
+	})
+
+	return f
+}
+
 // CountPacks returns the number of packs in the repository.
 func (c *Checker) CountPacks() uint64 {
 	return uint64(len(c.packs))
@@ -594,7 +692,7 @@ func checkPack(ctx context.Context, r restic.Repository, id restic.ID, blobs []r
 		})
 	}
 
-	err := repository.StreamPack(ctx, hashingLoader, r.Key(), id, blobs, func(blob restic.BlobHandle, buf []byte, err error) error {
+	err := repository.StreamPack(ctx, hashingLoader, r.Key(), id, blobs, r.PackReadGap(), func(blob restic.BlobHandle, buf []byte, err error) error {
 		debug.Log("  check blob %v: %v", blob.ID, blob)
 		if err != nil {
 			debug.Log("  error verifying blob %v: %v", blob.ID, err)
@@ -609,7 +707,8 @@ func checkPack(ctx context.Context, r restic.Repository, id restic.ID, blobs []r
 	}
 	if !hash.Equal(id) {
 		debug.Log("Pack ID does not match, want %v, got %v", id, hash)
-		return errors.Errorf("Pack ID does not match, want %v, got %v", id, hash)
+		errs = append(errs, errors.Errorf("Pack ID does not match, want %v, got %v", id, hash))
+		return &ErrPackData{PackID: id, errs: errs}
 	}
 
 	blobs, hdrSize, err := pack.List(r.Key(), bytes.NewReader(hdrBuf), int64(len(hdrBuf)))
@@ -664,6 +763,9 @@ func (c *Checker) ReadPacks(ctx context.Context, packs map[restic.ID]int64, p *p
 
 	// as packs are streamed the concurrency is limited by IO
 	workerCount := int(c.repo.Connections())
+	if c.ReadConcurrency > 0 {
+		workerCount = int(c.ReadConcurrency)
+	}
 	// run workers
 	for i := 0; i < workerCount; i++ {
 		g.Go(func() error {
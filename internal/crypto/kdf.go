@@ -2,21 +2,37 @@ package crypto
 
 import (
 	"crypto/rand"
+	"fmt"
 	"time"
 
 	"github.com/restic/restic/internal/errors"
 
 	sscrypt "github.com/elithrar/simple-scrypt"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/scrypt"
 )
 
 const saltLength = 64
 
+// Names of the supported key derivation functions.
+const (
+	KDFScrypt   = "scrypt"
+	KDFArgon2id = "argon2id"
+)
+
 // Params are the default parameters used for the key derivation function KDF().
+// N, R and P are only used for scrypt, Time, Memory and Threads only for argon2id.
 type Params struct {
+	// KDF is the name of the key derivation function, an empty string selects scrypt.
+	KDF string
+
 	N int
 	R int
 	P int
+
+	Time    uint32
+	Memory  uint32 // in KiB
+	Threads uint8
 }
 
 // DefaultKDFParams are the default parameters used for Calibrate and KDF().
@@ -26,6 +42,88 @@ var DefaultKDFParams = Params{
 	P: sscrypt.DefaultParams.P,
 }
 
+// DefaultArgon2idParams are the default parameters for argon2id, as
+// recommended by RFC 9106 for memory-constrained environments.
+var DefaultArgon2idParams = Params{
+	KDF:     KDFArgon2id,
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 4,
+}
+
+// Upper limits for the argon2id parameters. Keys using larger values are
+// rejected, similar to the checks for scrypt, as deriving the key would
+// require excessive amounts of time or memory.
+const (
+	maxArgon2idTime    = 1000
+	maxArgon2idMemory  = 4 * 1024 * 1024 // 4 GiB in KiB
+	maxArgon2idThreads = 64
+)
+
+// Name returns the name of the key derivation function.
+func (p Params) Name() string {
+	if p.KDF == "" {
+		return KDFScrypt
+	}
+	return p.KDF
+}
+
+// Cost returns a measure for the computational cost of deriving a key with
+// the parameters. Only costs for the same KDF are comparable.
+func (p Params) Cost() uint64 {
+	if p.Name() == KDFArgon2id {
+		return uint64(p.Time) * uint64(p.Memory)
+	}
+	return uint64(p.N) * uint64(p.R) * uint64(p.P)
+}
+
+func (p Params) String() string {
+	if p.Name() == KDFArgon2id {
+		return fmt.Sprintf("argon2id time=%d, memory=%d KiB, threads=%d", p.Time, p.Memory, p.Threads)
+	}
+	return fmt.Sprintf("scrypt N=%d, r=%d, p=%d", p.N, p.R, p.P)
+}
+
+// CalibrateKDF determines new parameters for the named KDF for the current
+// hardware, such that deriving a key takes about timeout and uses at most
+// memory MiB. For argon2id, the number of passes is never lower than the one
+// in DefaultArgon2idParams.
+func CalibrateKDF(kdf string, timeout time.Duration, memory int) (Params, error) {
+	switch kdf {
+	case "", KDFScrypt:
+		return Calibrate(timeout, memory)
+	case KDFArgon2id:
+		return calibrateArgon2id(timeout, memory)
+	default:
+		return Params{}, errors.Errorf("unknown KDF %q", kdf)
+	}
+}
+
+func calibrateArgon2id(timeout time.Duration, memory int) (Params, error) {
+	params := DefaultArgon2idParams
+	if memory > 0 && uint32(memory)*1024 < params.Memory {
+		params.Memory = uint32(memory) * 1024
+	}
+	if params.Memory < 8*uint32(params.Threads) {
+		return Params{}, errors.Errorf("argon2id requires at least %d KiB of memory", 8*uint32(params.Threads))
+	}
+
+	// measure the runtime of a single pass
+	salt := make([]byte, saltLength)
+	start := time.Now()
+	_ = argon2.IDKey([]byte("calibration"), salt, 1, params.Memory, params.Threads, macKeySize+aesKeySize)
+	d := time.Since(start)
+
+	// never go below the recommended number of passes
+	if d > 0 && timeout/d > time.Duration(params.Time) {
+		params.Time = uint32(timeout / d)
+		if timeout/d > maxArgon2idTime {
+			params.Time = maxArgon2idTime
+		}
+	}
+	return params, nil
+}
+
 // Calibrate determines new KDF parameters for the current hardware.
 func Calibrate(timeout time.Duration, memory int) (Params, error) {
 	defaultParams := sscrypt.Params{
@@ -49,12 +147,42 @@ func Calibrate(timeout time.Duration, memory int) (Params, error) {
 }
 
 // KDF derives encryption and message authentication keys from the password
-// using the supplied parameters N, R and P and the Salt.
+// using the supplied parameters and the Salt.
 func KDF(p Params, salt []byte, password string) (*Key, error) {
 	if len(salt) != saltLength {
-		return nil, errors.Errorf("scrypt() called with invalid salt bytes (len %d)", len(salt))
+		return nil, errors.Errorf("KDF called with invalid salt bytes (len %d)", len(salt))
 	}
 
+	var keys []byte
+	var err error
+	switch p.Name() {
+	case KDFScrypt:
+		keys, err = scryptKey(p, salt, password)
+	case KDFArgon2id:
+		keys, err = argon2idKey(p, salt, password)
+	default:
+		err = errors.Errorf("unknown KDF %q", p.KDF)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) != macKeySize+aesKeySize {
+		return nil, errors.Errorf("invalid numbers of bytes expanded from %v: %d", p.Name(), len(keys))
+	}
+
+	derKeys := &Key{}
+
+	// first 32 byte of the KDF output is the encryption key
+	copy(derKeys.EncryptionKey[:], keys[:aesKeySize])
+
+	// next 32 byte of the KDF output is the mac key, in the form k||r
+	macKeyFromSlice(&derKeys.MACKey, keys[aesKeySize:])
+
+	return derKeys, nil
+}
+
+func scryptKey(p Params, salt []byte, password string) ([]byte, error) {
 	// make sure we have valid parameters
 	params := sscrypt.Params{
 		N:       p.N,
@@ -68,25 +196,31 @@ func KDF(p Params, salt []byte, password string) (*Key, error) {
 		return nil, errors.Wrap(err, "Check")
 	}
 
-	derKeys := &Key{}
-
-	keybytes := macKeySize + aesKeySize
-	scryptKeys, err := scrypt.Key([]byte(password), salt, p.N, p.R, p.P, keybytes)
+	keys, err := scrypt.Key([]byte(password), salt, p.N, p.R, p.P, macKeySize+aesKeySize)
 	if err != nil {
 		return nil, errors.Wrap(err, "scrypt.Key")
 	}
+	return keys, nil
+}
 
-	if len(scryptKeys) != keybytes {
-		return nil, errors.Errorf("invalid numbers of bytes expanded from scrypt(): %d", len(scryptKeys))
+func argon2idKey(p Params, salt []byte, password string) ([]byte, error) {
+	// argon2.IDKey panics for invalid parameters
+	switch {
+	case p.Time < 1:
+		return nil, errors.New("invalid argon2id parameters: time must be at least 1")
+	case p.Threads < 1:
+		return nil, errors.New("invalid argon2id parameters: threads must be at least 1")
+	case p.Memory < 8*uint32(p.Threads):
+		return nil, errors.New("invalid argon2id parameters: memory must be at least 8 KiB per thread")
+	case p.Time > maxArgon2idTime:
+		return nil, errors.Errorf("invalid argon2id parameters: time must be at most %d", maxArgon2idTime)
+	case p.Memory > maxArgon2idMemory:
+		return nil, errors.Errorf("invalid argon2id parameters: memory must be at most %d KiB", maxArgon2idMemory)
+	case p.Threads > maxArgon2idThreads:
+		return nil, errors.Errorf("invalid argon2id parameters: threads must be at most %d", maxArgon2idThreads)
 	}
 
-	// first 32 byte of scrypt output is the encryption key
-	copy(derKeys.EncryptionKey[:], scryptKeys[:aesKeySize])
-
-	// next 32 byte of scrypt output is the mac key, in the form k||r
-	macKeyFromSlice(&derKeys.MACKey, scryptKeys[aesKeySize:])
-
-	return derKeys, nil
+	return argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, macKeySize+aesKeySize), nil
 }
 
 // NewSalt returns new random salt bytes to use with KDF(). If NewSalt returns
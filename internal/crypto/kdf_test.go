@@ -12,3 +12,60 @@ func TestCalibrate(t *testing.T) {
 	}
 	t.Logf("testing calibrate, params after: %v", params)
 }
+
+func TestCalibrateArgon2id(t *testing.T) {
+	params, err := CalibrateKDF(KDFArgon2id, 10*time.Millisecond, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("testing calibrate, params after: %v", params)
+
+	if params.Memory != 8*1024 {
+		t.Fatalf("wrong memory limit, want %d, got %d", 8*1024, params.Memory)
+	}
+	if params.Time < DefaultArgon2idParams.Time {
+		t.Fatalf("number of passes %d is below the default %d", params.Time, DefaultArgon2idParams.Time)
+	}
+}
+
+func TestKDFArgon2id(t *testing.T) {
+	salt, err := NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := Params{KDF: KDFArgon2id, Time: 1, Memory: 64, Threads: 2}
+
+	k1, err := KDF(params, salt, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := KDF(params, salt, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !k1.Valid() || k1.EncryptionKey != k2.EncryptionKey || k1.MACKey != k2.MACKey {
+		t.Fatal("argon2id did not derive the same valid key twice")
+	}
+
+	k3, err := KDF(params, salt, "other password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k1.EncryptionKey == k3.EncryptionKey {
+		t.Fatal("argon2id derived the same key for different passwords")
+	}
+
+	for _, p := range []Params{
+		{KDF: KDFArgon2id, Time: 0, Memory: 64, Threads: 1},
+		{KDF: KDFArgon2id, Time: 1, Memory: 64, Threads: 0},
+		{KDF: KDFArgon2id, Time: 1, Memory: 8, Threads: 2},
+		{KDF: KDFArgon2id, Time: maxArgon2idTime + 1, Memory: 64, Threads: 1},
+		{KDF: KDFArgon2id, Time: 1, Memory: maxArgon2idMemory + 1, Threads: 1},
+		{KDF: KDFArgon2id, Time: 1, Memory: 1024 * 1024, Threads: maxArgon2idThreads + 1},
+		{KDF: "bcrypt", N: 128, R: 1, P: 1},
+	} {
+		if _, err := KDF(p, salt, "password"); err == nil {
+			t.Errorf("expected error for invalid parameters %v", p)
+		}
+	}
+}
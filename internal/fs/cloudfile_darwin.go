@@ -0,0 +1,23 @@
+package fs
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// CloudPlaceholdersSupported is true if IsCloudPlaceholder can detect
+// placeholder files on this platform.
+const CloudPlaceholdersSupported = true
+
+// IsCloudPlaceholder returns true if fi describes a file whose content is not
+// available locally, for example a dataless file evicted by iCloud Drive.
+// Reading such a file triggers a download.
+func IsCloudPlaceholder(fi os.FileInfo) bool {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false
+	}
+	return stat.Flags&unix.SF_DATALESS != 0
+}
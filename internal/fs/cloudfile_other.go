@@ -0,0 +1,16 @@
+//go:build !darwin && !windows
+// +build !darwin,!windows
+
+package fs
+
+import "os"
+
+// CloudPlaceholdersSupported is true if IsCloudPlaceholder can detect
+// placeholder files on this platform.
+const CloudPlaceholdersSupported = false
+
+// IsCloudPlaceholder always returns false, as this platform has no concept of
+// placeholder files for online storage.
+func IsCloudPlaceholder(_ os.FileInfo) bool {
+	return false
+}
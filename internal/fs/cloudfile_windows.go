@@ -0,0 +1,23 @@
+package fs
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// CloudPlaceholdersSupported is true if IsCloudPlaceholder can detect
+// placeholder files on this platform.
+const CloudPlaceholdersSupported = true
+
+// IsCloudPlaceholder returns true if fi describes a file whose content is not
+// available locally, for example an online-only file of OneDrive. Reading such
+// a file triggers a download.
+func IsCloudPlaceholder(fi os.FileInfo) bool {
+	attrs, ok := fi.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return false
+	}
+	return attrs.FileAttributes&(windows.FILE_ATTRIBUTE_RECALL_ON_DATA_ACCESS|windows.FILE_ATTRIBUTE_RECALL_ON_OPEN|windows.FILE_ATTRIBUTE_OFFLINE) != 0
+}
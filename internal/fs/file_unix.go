@@ -15,8 +15,12 @@ func fixpath(name string) string {
 }
 
 // TempFile creates a temporary file which has already been deleted (on
-// supported platforms)
+// supported platforms). If dir is empty, the file is created in TempDir().
 func TempFile(dir, prefix string) (f *os.File, err error) {
+	if dir == "" {
+		dir = TempDir()
+	}
+
 	f, err = os.CreateTemp(dir, prefix)
 	if err != nil {
 		return nil, err
@@ -34,7 +34,8 @@ func fixpath(name string) string {
 	return name
 }
 
-// TempFile creates a temporary file which is marked as delete-on-close
+// TempFile creates a temporary file which is marked as delete-on-close. If dir
+// is empty, the file is created in TempDir().
 func TempFile(dir, prefix string) (f *os.File, err error) {
 	// slightly modified implementation of os.CreateTemp(dir, prefix) to allow us to add
 	// the FILE_ATTRIBUTE_TEMPORARY | FILE_FLAG_DELETE_ON_CLOSE flags.
@@ -45,7 +46,7 @@ func TempFile(dir, prefix string) (f *os.File, err error) {
 	// all file descriptors are closed.
 
 	if dir == "" {
-		dir = os.TempDir()
+		dir = TempDir()
 	}
 
 	access := uint32(windows.GENERIC_READ | windows.GENERIC_WRITE)
@@ -0,0 +1,45 @@
+package fs
+
+import (
+	"os"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// tempDir is the directory used for temporary files, if empty the system
+// default is used.
+var tempDir string
+
+// SetTempDir configures the directory in which temporary files are created.
+// An empty dir selects the system default, which honors $TMPDIR.
+func SetTempDir(dir string) error {
+	if dir != "" {
+		fi, err := os.Stat(dir)
+		if err != nil {
+			return errors.Wrap(err, "temporary directory")
+		}
+		if !fi.IsDir() {
+			return errors.Errorf("temporary directory %v is not a directory", dir)
+		}
+	}
+
+	tempDir = dir
+	return nil
+}
+
+// TempDir returns the directory in which temporary files are created.
+func TempDir() string {
+	if tempDir != "" {
+		return tempDir
+	}
+	return os.TempDir()
+}
+
+// MkdirTemp creates a new temporary directory. If dir is empty, the directory
+// is created in TempDir().
+func MkdirTemp(dir, pattern string) (string, error) {
+	if dir == "" {
+		dir = TempDir()
+	}
+	return os.MkdirTemp(dir, pattern)
+}
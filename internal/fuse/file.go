@@ -9,6 +9,7 @@ import (
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 
 	"github.com/anacrolix/fuse"
@@ -18,6 +19,10 @@ import (
 // The default block size to report in stat
 const blockSize = 512
 
+// readAheadSize is the amount of data of a file that is loaded when a blob of
+// the file is not cached.
+const readAheadSize = 8 << 20
+
 // Statically ensure that *file and *openFile implement the given interfaces
 var _ = fs.HandleReader(&openFile{})
 var _ = fs.NodeListxattrer(&file{})
@@ -102,6 +107,11 @@ func (f *openFile) getBlobAt(ctx context.Context, i int) (blob []byte, err error
 		return blob, nil
 	}
 
+	blob = f.readAhead(ctx, i)
+	if blob != nil {
+		return blob, nil
+	}
+
 	blob, err = f.root.repo.LoadBlob(ctx, restic.DataBlob, f.node.Content[i], nil)
 	if err != nil {
 		debug.Log("LoadBlob(%v, %v) failed: %v", f.node.Name, f.node.Content[i], err)
@@ -113,6 +123,39 @@ func (f *openFile) getBlobAt(ctx context.Context, i int) (blob []byte, err error
 	return blob, nil
 }
 
+// readAhead loads the blob at index i together with the following blobs of
+// the file, up to readAheadSize bytes, and adds them to the blob cache. This
+// allows reading blobs stored in the same pack file using a single request.
+// It returns nil if blob i could not be loaded.
+func (f *openFile) readAhead(ctx context.Context, i int) (blob []byte) {
+	blobs := []restic.BlobHandle{{ID: f.node.Content[i], Type: restic.DataBlob}}
+	for j := i + 1; j < len(f.node.Content) && f.cumsize[j+1]-f.cumsize[i] <= readAheadSize; j++ {
+		if _, ok := f.root.blobCache.Get(f.node.Content[j]); !ok {
+			blobs = append(blobs, restic.BlobHandle{ID: f.node.Content[j], Type: restic.DataBlob})
+		}
+	}
+
+	err := repository.StreamBlobs(ctx, f.root.repo, blobs, 0, func(h restic.BlobHandle, buf []byte, err error) error {
+		if err != nil {
+			debug.Log("loading blob %v of %v failed: %v", h, f.node.Name, err)
+			return nil
+		}
+
+		// buf is reused by StreamBlobs
+		buf = append([]byte(nil), buf...)
+		if h.ID == f.node.Content[i] {
+			blob = buf
+		}
+		f.root.blobCache.Add(h.ID, buf)
+		return nil
+	})
+	if err != nil {
+		debug.Log("read ahead for %v failed: %v", f.node.Name, err)
+	}
+
+	return blob
+}
+
 func (f *openFile) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
 	debug.Log("Read(%v, %v, %v), file size %v", f.node.Name, req.Size, req.Offset, f.node.Size)
 	offset := uint64(req.Offset)
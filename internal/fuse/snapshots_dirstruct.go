@@ -59,7 +59,7 @@ func NewSnapshotsDirStructure(root *Root, pathTemplates []string, timeTemplate s
 // where the variables are replaced by the snapshot data.
 // The time is given as suffix if the pathTemplate ends with "%T".
 func pathsFromSn(pathTemplate string, timeTemplate string, sn *restic.Snapshot) (paths []string, timeSuffix string) {
-	timeformat := sn.Time.Format(timeTemplate)
+	timeformat := formatTime(timeTemplate, sn)
 
 	inVerb := false
 	writeTime := false
@@ -142,6 +142,34 @@ func pathsFromSn(pathTemplate string, timeTemplate string, sn *restic.Snapshot)
 	return paths, timeSuffix
 }
 
+// formatTime formats the time of sn using the Go time layout timeTemplate.
+// The placeholders "%host" and "%tag" are replaced by the hostname and the
+// comma-separated tags of the snapshot.
+func formatTime(timeTemplate string, sn *restic.Snapshot) string {
+	var out strings.Builder
+	for {
+		idx := strings.Index(timeTemplate, "%")
+		if idx < 0 {
+			out.WriteString(sn.Time.Format(timeTemplate))
+			return out.String()
+		}
+
+		out.WriteString(sn.Time.Format(timeTemplate[:idx]))
+		rest := timeTemplate[idx:]
+		switch {
+		case strings.HasPrefix(rest, "%host"):
+			out.WriteString(sn.Hostname)
+			timeTemplate = rest[len("%host"):]
+		case strings.HasPrefix(rest, "%tag"):
+			out.WriteString(filenameFromTag(strings.Join(sn.Tags, ",")))
+			timeTemplate = rest[len("%tag"):]
+		default:
+			out.WriteString("%")
+			timeTemplate = rest[1:]
+		}
+	}
+}
+
 // Some tags are problematic when used as filenames:
 //
 //	""
@@ -193,8 +221,14 @@ outer:
 }
 
 // uniqueName returns a unique name to be used for prefix+name.
-// It appends -number to make the name unique.
-func uniqueName(entries map[string]*MetaDirData, prefix, name string) string {
+// It appends the short ID of the snapshot to make the name unique, or
+// -number if that is not sufficient.
+func uniqueName(entries map[string]*MetaDirData, prefix, name string, sn *restic.Snapshot) string {
+	if _, ok := entries[prefix+name]; !ok {
+		return name
+	}
+
+	name = fmt.Sprintf("%s-%s", name, sn.ID().Str())
 	newname := name
 	for i := 1; ; i++ {
 		if _, ok := entries[prefix+newname]; !ok {
@@ -266,7 +300,7 @@ func (d *SnapshotsDirStructure) makeDirs(snapshots restic.Snapshots) {
 				if p != "" {
 					p = "/" + p
 				}
-				suffix := uniqueName(entries, p, timeSuffix)
+				suffix := uniqueName(entries, p, timeSuffix, sn)
 				mount(path.Clean(p+suffix), mountData{sn: sn})
 				if timeSuffix != "" {
 					lt, ok := latestTime[p]
@@ -52,6 +52,24 @@ func TestPathsFromSn(t *testing.T) {
 	p, s = pathsFromSn("%T/%i", "2006/01", sn1)
 	test.Equals(t, []string{"2021/01/12345678"}, p)
 	test.Equals(t, "", s)
+
+	p, s = pathsFromSn("snapshots/%T", "%host_2006-01-02_%tag", sn1)
+	test.Equals(t, []string{"snapshots/"}, p)
+	test.Equals(t, "host_2021-01-01_tag1,tag2", s)
+}
+
+func TestFormatTime(t *testing.T) {
+	sn := &restic.Snapshot{
+		Hostname: "Monday",
+		Time:     time.Date(2021, 1, 1, 0, 0, 1, 0, time.UTC),
+	}
+
+	// the placeholders are not interpreted as time layout
+	test.Equals(t, "2021-01-01-Monday", formatTime("2006-01-02-%host", sn))
+	test.Equals(t, "_ x%", formatTime("%tag x%", sn))
+
+	sn.Tags = []string{"a/b", "c"}
+	test.Equals(t, "a_b,c@00:00:01", formatTime("%tag@15:04:05", sn))
 }
 
 func TestMakeDirs(t *testing.T) {
@@ -114,9 +132,9 @@ func TestMakeDirs(t *testing.T) {
 
 	// entries for sn2
 	expNames["/ids/87654321"] = sn2
-	expNames["/snapshots/2021/01/01-1"] = sn2 // sn1 and sn2 have same time string
+	expNames["/snapshots/2021/01/01-87654321"] = sn2 // sn1 and sn2 have same time string
 	expNames["/hosts/host2/2021/01/01"] = sn2
-	expNames["/tags/tag2/2021/01/01-1"] = sn2 // sn1 and sn2 have same time string
+	expNames["/tags/tag2/2021/01/01-87654321"] = sn2 // sn1 and sn2 have same time string
 	expNames["/tags/tag3/2021/01/01"] = sn2
 	expNames["/tags/tag4/2021/01/01"] = sn2
 	expNames["/users/user2/2021/01/01"] = sn2
@@ -126,11 +144,11 @@ func TestMakeDirs(t *testing.T) {
 
 	// entries for sn3
 	expNames["/ids/aaaaaaaa"] = sn3
-	expNames["/snapshots/2021/01/01-2"] = sn3   // sn1 - sn3 have same time string
-	expNames["/hosts/host/2021/01/01-1"] = sn3  // sn1 and sn3 have same time string
-	expNames["/users/user2/2021/01/01-1"] = sn3 // sn2 and sn3 have same time string
+	expNames["/snapshots/2021/01/01-aaaaaaaa"] = sn3   // sn1 - sn3 have same time string
+	expNames["/hosts/host/2021/01/01-aaaaaaaa"] = sn3  // sn1 and sn3 have same time string
+	expNames["/users/user2/2021/01/01-aaaaaaaa"] = sn3 // sn2 and sn3 have same time string
 	expNames["/longids/aaaaaaaa12345678123456781234567812345678123456781234567812345678"] = sn3
-	expNames["/2021/01/01/host-1"] = sn3 // sn1 and sn3 have same time string and identical host
+	expNames["/2021/01/01/host-aaaaaaaa"] = sn3 // sn1 and sn3 have same time string and identical host
 	expNames["/2021/01/01/aaaaaaaa"] = sn3
 
 	// intermediate directories
@@ -201,15 +219,15 @@ func TestMakeDirs(t *testing.T) {
 	expNames["/users/user2/latest"] = sn3 // sn2 and sn3 have same time string
 
 	// latest links
-	expLatest["/snapshots/latest"] = "2021/01/01-2" // sn1 - sn3 have same time string
-	expLatest["/hosts/host/latest"] = "2021/01/01-1"
+	expLatest["/snapshots/latest"] = "2021/01/01-aaaaaaaa" // sn1 - sn3 have same time string
+	expLatest["/hosts/host/latest"] = "2021/01/01-aaaaaaaa"
 	expLatest["/hosts/host2/latest"] = "2021/01/01"
 	expLatest["/tags/tag1/latest"] = "2021/01/01"
-	expLatest["/tags/tag2/latest"] = "2021/01/01-1" // sn1 and sn2 have same time string
+	expLatest["/tags/tag2/latest"] = "2021/01/01-87654321" // sn1 and sn2 have same time string
 	expLatest["/tags/tag3/latest"] = "2021/01/01"
 	expLatest["/tags/tag4/latest"] = "2021/01/01"
 	expLatest["/users/user/latest"] = "2021/01/01"
-	expLatest["/users/user2/latest"] = "2021/01/01-1" // sn2 and sn3 have same time string
+	expLatest["/users/user2/latest"] = "2021/01/01-aaaaaaaa" // sn2 and sn3 have same time string
 
 	verifyEntries(t, expNames, expLatest, sds.entries)
 }
@@ -20,10 +20,11 @@ import (
 // Hence the index data structure defined here is one of the main contributions
 // to the total memory requirements of restic.
 //
-// We store the index entries in indexMaps. In these maps, entries take 56
-// bytes each, plus 8/4 = 2 bytes of unused pointers on average, not counting
+// We store the index entries in indexMaps. In these maps, entries take 52
+// bytes each, plus 4/4 = 1 byte of unused pointers on average, not counting
 // malloc and header struct overhead and ignoring duplicates (those are only
-// present in edge cases and are also removed by prune runs).
+// present in edge cases and are also removed by prune runs). Compacted
+// indexMaps only need 48 bytes per entry, but lookups are slower.
 //
 // In the index entries, we need to reference the packID. As one pack may
 // contain many blobs the packIDs are saved in a separate array and only the index
@@ -34,11 +35,11 @@ import (
 // size is 1.5 MB and the minimum pack size is 4 MB)
 //
 // We have the following sizes:
-// indexEntry:  56 bytes  (on amd64)
+// indexEntry:  52 bytes  (48 bytes if compacted)
 // each packID: 32 bytes
 //
 // To save N index entries, we therefore need:
-// N * (56 + 2) bytes + N * 32 bytes / BP = N * 62 bytes,
+// N * (52 + 1) bytes + N * 32 bytes / BP = N * 57 bytes,
 // i.e., fewer than 64 bytes per blob in an index.
 
 // Index holds lookup tables for id -> pack.
@@ -484,9 +485,10 @@ func (idx *Index) merge(idx2 *Index) error {
 		}
 
 		m2.foreach(func(e2 *indexEntry) bool {
-			if !hasIdenticalEntry(e2) {
+			// compacted maps remove identical entries in compact()
+			if m.compacted || !hasIdenticalEntry(e2) {
 				// packIndex needs to be changed as idx2.pack was appended to idx.pack, see above
-				m.add(e2.id, e2.packIndex+packlen, e2.offset, e2.length, e2.uncompressedLength)
+				m.add(e2.id, int(e2.packIndex)+packlen, e2.offset, e2.length, e2.uncompressedLength)
 			}
 			return true
 		})
@@ -498,6 +500,18 @@ func (idx *Index) merge(idx2 *Index) error {
 	return nil
 }
 
+// compact converts the index into the compact representation, see
+// indexMap.compact. Entries merged into a compacted index are only available
+// for lookups after compact was called again.
+func (idx *Index) compact() {
+	idx.m.Lock()
+	defer idx.m.Unlock()
+
+	for typ := range idx.byType {
+		idx.byType[typ].compact(idx.packs)
+	}
+}
+
 // isErrOldIndex returns true if the error may be caused by an old index
 // format.
 func isErrOldIndex(err error) bool {
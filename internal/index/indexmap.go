@@ -1,7 +1,9 @@
 package index
 
 import (
+	"bytes"
 	"hash/maphash"
+	"sort"
 
 	"github.com/restic/restic/internal/restic"
 )
@@ -15,14 +17,21 @@ import (
 // The buckets in this hash table contain only pointers, rather than inlined
 // key-value pairs like the standard Go map. This way, only a pointer array
 // needs to be resized when the table grows, preventing memory usage spikes.
+//
+// After calling compact, the entries are instead stored in an array sorted by
+// blob ID, which is searched using binary search. This saves the memory for
+// the buckets and the chaining pointers at the cost of slower lookups.
 type indexMap struct {
 	// The number of buckets is always a power of two and never zero.
-	buckets    []uint
+	buckets    []uint32
 	numentries uint
 
 	mh maphash.Hash
 
 	blockList hashedArrayTree
+
+	compacted bool
+	sorted    []indexEntry
 }
 
 const (
@@ -33,6 +42,23 @@ const (
 // add inserts an indexEntry for the given arguments into the map,
 // using id as the key.
 func (m *indexMap) add(id restic.ID, packIdx int, offset, length uint32, uncompressedLength uint32) {
+	if uint64(packIdx) > maxuint32 {
+		panic("too many packs in index")
+	}
+
+	if m.compacted {
+		// entries are only sorted again by the next call to compact
+		m.sorted = append(m.sorted, indexEntry{
+			id:                 id,
+			packIndex:          uint32(packIdx),
+			offset:             offset,
+			length:             length,
+			uncompressedLength: uncompressedLength,
+		})
+		m.numentries++
+		return
+	}
+
 	switch {
 	case m.numentries == 0: // Lazy initialization.
 		m.init()
@@ -44,7 +70,7 @@ func (m *indexMap) add(id restic.ID, packIdx int, offset, length uint32, uncompr
 	e, idx := m.newEntry()
 	e.id = id
 	e.next = m.buckets[h] // Prepend to existing chain.
-	e.packIndex = packIdx
+	e.packIndex = uint32(packIdx)
 	e.offset = offset
 	e.length = length
 	e.uncompressedLength = uncompressedLength
@@ -55,9 +81,18 @@ func (m *indexMap) add(id restic.ID, packIdx int, offset, length uint32, uncompr
 
 // foreach calls fn for all entries in the map, until fn returns false.
 func (m *indexMap) foreach(fn func(*indexEntry) bool) {
+	if m.compacted {
+		for i := range m.sorted {
+			if !fn(&m.sorted[i]) {
+				return
+			}
+		}
+		return
+	}
+
 	blockCount := m.blockList.Size()
 	for i := uint(1); i < blockCount; i++ {
-		if !fn(m.resolve(i)) {
+		if !fn(&m.resolve(i).indexEntry) {
 			return
 		}
 	}
@@ -65,6 +100,13 @@ func (m *indexMap) foreach(fn func(*indexEntry) bool) {
 
 // foreachWithID calls fn for all entries with the given id.
 func (m *indexMap) foreachWithID(id restic.ID, fn func(*indexEntry)) {
+	if m.compacted {
+		for i := m.search(id); i < len(m.sorted) && m.sorted[i].id == id; i++ {
+			fn(&m.sorted[i])
+		}
+		return
+	}
+
 	if len(m.buckets) == 0 {
 		return
 	}
@@ -72,17 +114,25 @@ func (m *indexMap) foreachWithID(id restic.ID, fn func(*indexEntry)) {
 	h := m.hash(id)
 	ei := m.buckets[h]
 	for ei != 0 {
-		e := m.resolve(ei)
+		e := m.resolve(uint(ei))
 		ei = e.next
 		if e.id != id {
 			continue
 		}
-		fn(e)
+		fn(&e.indexEntry)
 	}
 }
 
 // get returns the first entry for the given id.
 func (m *indexMap) get(id restic.ID) *indexEntry {
+	if m.compacted {
+		i := m.search(id)
+		if i < len(m.sorted) && m.sorted[i].id == id {
+			return &m.sorted[i]
+		}
+		return nil
+	}
+
 	if len(m.buckets) == 0 {
 		return nil
 	}
@@ -90,17 +140,82 @@ func (m *indexMap) get(id restic.ID) *indexEntry {
 	h := m.hash(id)
 	ei := m.buckets[h]
 	for ei != 0 {
-		e := m.resolve(ei)
+		e := m.resolve(uint(ei))
 		if e.id == id {
-			return e
+			return &e.indexEntry
 		}
 		ei = e.next
 	}
 	return nil
 }
 
+// search returns the position of the first entry with the given id in the
+// sorted array or the position at which such an entry would be inserted.
+func (m *indexMap) search(id restic.ID) int {
+	return sort.Search(len(m.sorted), func(i int) bool {
+		return bytes.Compare(m.sorted[i].id[:], id[:]) >= 0
+	})
+}
+
+// compact converts the map into an array sorted by blob ID and sorts entries
+// that were added since the last call. Identical entries, that is entries with
+// the same blob ID, offset, length and referring to the same pack according to
+// packs, are only kept once.
+func (m *indexMap) compact(packs restic.IDs) {
+	if !m.compacted {
+		sorted := make([]indexEntry, 0, m.numentries)
+		m.foreach(func(e *indexEntry) bool {
+			sorted = append(sorted, *e)
+			return true
+		})
+		m.buckets = nil
+		m.blockList = hashedArrayTree{}
+		m.sorted = sorted
+		m.compacted = true
+	}
+
+	less := func(a, b *indexEntry) bool {
+		if c := bytes.Compare(a.id[:], b.id[:]); c != 0 {
+			return c < 0
+		}
+		if c := bytes.Compare(packs[a.packIndex][:], packs[b.packIndex][:]); c != 0 {
+			return c < 0
+		}
+		return a.offset < b.offset
+	}
+	sort.Slice(m.sorted, func(i, j int) bool {
+		return less(&m.sorted[i], &m.sorted[j])
+	})
+
+	// remove identical entries
+	n := 0
+	for i := range m.sorted {
+		e := &m.sorted[i]
+		if n > 0 {
+			last := &m.sorted[n-1]
+			if last.id == e.id && packs[last.packIndex] == packs[e.packIndex] &&
+				last.offset == e.offset && last.length == e.length &&
+				last.uncompressedLength == e.uncompressedLength {
+				continue
+			}
+		}
+		m.sorted[n] = *e
+		n++
+	}
+
+	if cap(m.sorted) > n+n/8 {
+		// release unused capacity
+		sorted := make([]indexEntry, n)
+		copy(sorted, m.sorted)
+		m.sorted = sorted
+	} else {
+		m.sorted = m.sorted[:n]
+	}
+	m.numentries = uint(n)
+}
+
 func (m *indexMap) grow() {
-	m.buckets = make([]uint, growthFactor*len(m.buckets))
+	m.buckets = make([]uint32, growthFactor*len(m.buckets))
 
 	blockCount := m.blockList.Size()
 	for i := uint(1); i < blockCount; i++ {
@@ -108,7 +223,7 @@ func (m *indexMap) grow() {
 
 		h := m.hash(e.id)
 		e.next = m.buckets[h]
-		m.buckets[h] = i
+		m.buckets[h] = uint32(i)
 	}
 }
 
@@ -126,7 +241,7 @@ func (m *indexMap) hash(id restic.ID) uint {
 
 func (m *indexMap) init() {
 	const initialBuckets = 64
-	m.buckets = make([]uint, initialBuckets)
+	m.buckets = make([]uint32, initialBuckets)
 	// first entry in blockList serves as null byte
 	m.blockList = *newHAT()
 	m.newEntry()
@@ -134,30 +249,39 @@ func (m *indexMap) init() {
 
 func (m *indexMap) len() uint { return m.numentries }
 
-func (m *indexMap) newEntry() (*indexEntry, uint) {
-	return m.blockList.Alloc()
+func (m *indexMap) newEntry() (*hashEntry, uint32) {
+	e, idx := m.blockList.Alloc()
+	if uint64(idx) > maxuint32 {
+		panic("too many entries in index")
+	}
+	return e, uint32(idx)
 }
 
-func (m *indexMap) resolve(idx uint) *indexEntry {
+func (m *indexMap) resolve(idx uint) *hashEntry {
 	return m.blockList.Ref(idx)
 }
 
 type indexEntry struct {
 	id                 restic.ID
-	next               uint
-	packIndex          int // Position in containing Index's packs field.
+	packIndex          uint32 // Position in containing Index's packs field.
 	offset             uint32
 	length             uint32
 	uncompressedLength uint32
 }
 
+// hashEntry is an indexEntry stored in the hash table.
+type hashEntry struct {
+	indexEntry
+	next uint32
+}
+
 type hashedArrayTree struct {
 	mask      uint
 	maskShift uint
 	blockSize uint
 
 	size      uint
-	blockList [][]indexEntry
+	blockList [][]hashEntry
 }
 
 func newHAT() *hashedArrayTree {
@@ -170,11 +294,11 @@ func newHAT() *hashedArrayTree {
 		maskShift: blockSizePower,
 		blockSize: blockSize,
 		size:      0,
-		blockList: make([][]indexEntry, blockSize),
+		blockList: make([][]hashEntry, blockSize),
 	}
 }
 
-func (h *hashedArrayTree) Alloc() (*indexEntry, uint) {
+func (h *hashedArrayTree) Alloc() (*hashEntry, uint) {
 	h.grow()
 	size := h.size
 	idx, subIdx := h.index(size)
@@ -188,7 +312,7 @@ func (h *hashedArrayTree) index(pos uint) (idx uint, subIdx uint) {
 	return
 }
 
-func (h *hashedArrayTree) Ref(pos uint) *indexEntry {
+func (h *hashedArrayTree) Ref(pos uint) *hashEntry {
 	if pos >= h.size {
 		panic("array index out of bounds")
 	}
@@ -211,11 +335,11 @@ func (h *hashedArrayTree) grow() {
 		idx = idx / 2
 
 		oldBlocks := h.blockList
-		h.blockList = make([][]indexEntry, h.blockSize)
+		h.blockList = make([][]hashEntry, h.blockSize)
 
 		// pairwise merging of blocks
 		for i := 0; i < len(oldBlocks); i += 2 {
-			block := make([]indexEntry, 0, h.blockSize)
+			block := make([]hashEntry, 0, h.blockSize)
 			block = append(block, oldBlocks[i]...)
 			block = append(block, oldBlocks[i+1]...)
 			h.blockList[i/2] = block
@@ -226,6 +350,6 @@ func (h *hashedArrayTree) grow() {
 	}
 	if subIdx == 0 {
 		// new index entry batch
-		h.blockList[idx] = make([]indexEntry, h.blockSize)
+		h.blockList[idx] = make([]hashEntry, h.blockSize)
 	}
 }
@@ -2,6 +2,7 @@ package index
 
 import (
 	"math/rand"
+	"runtime"
 	"testing"
 	"time"
 
@@ -48,7 +49,7 @@ func TestIndexMapForeach(t *testing.T) {
 	m.foreach(func(e *indexEntry) bool {
 		i := int(e.id[0])
 		rtest.Assert(t, i < N, "unknown id %v in indexMap", e.id)
-		rtest.Equals(t, i, e.packIndex)
+		rtest.Equals(t, i, int(e.packIndex))
 		rtest.Equals(t, i, int(e.length))
 		rtest.Equals(t, i, int(e.offset))
 		rtest.Equals(t, i/2, int(e.uncompressedLength))
@@ -92,7 +93,7 @@ func TestIndexMapForeachWithID(t *testing.T) {
 	for i := 0; i < 100; i++ {
 		var otherid restic.ID
 		r.Read(otherid[:])
-		m.add(otherid, -1, 0, 0, 0)
+		m.add(otherid, ndups, 0, 0, 0)
 	}
 
 	n = 0
@@ -108,6 +109,103 @@ func TestIndexMapForeachWithID(t *testing.T) {
 	}
 }
 
+func TestIndexMapCompact(t *testing.T) {
+	t.Parallel()
+
+	const N = 1000
+
+	var (
+		m     indexMap
+		ids   = make([]restic.ID, N)
+		packs = make(restic.IDs, 2)
+		r     = rand.New(rand.NewSource(424242))
+	)
+	r.Read(packs[0][:])
+	r.Read(packs[1][:])
+
+	for i := range ids {
+		r.Read(ids[i][:])
+		m.add(ids[i], 0, uint32(i), uint32(i), 0)
+	}
+	// duplicate in another pack
+	m.add(ids[0], 1, 0, 0, 0)
+
+	m.compact(packs)
+	rtest.Equals(t, uint(N+1), m.len())
+
+	for i, id := range ids {
+		e := m.get(id)
+		rtest.Assert(t, e != nil, "%v missing after compact", id)
+		rtest.Equals(t, uint32(i), e.offset)
+	}
+
+	var unknown restic.ID
+	r.Read(unknown[:])
+	rtest.Assert(t, m.get(unknown) == nil, "unknown id %v found", unknown)
+
+	n := 0
+	m.foreachWithID(ids[0], func(*indexEntry) { n++ })
+	rtest.Equals(t, 2, n)
+
+	// entries that refer to the same pack and location are only kept once
+	packs = append(packs, packs[0])
+	m.add(ids[1], 2, 1, 1, 0)
+	m.add(ids[1], 1, 1, 1, 0)
+	m.compact(packs)
+	rtest.Equals(t, uint(N+2), m.len())
+
+	n = 0
+	m.foreachWithID(ids[1], func(*indexEntry) { n++ })
+	rtest.Equals(t, 2, n)
+
+	n = 0
+	m.foreach(func(*indexEntry) bool {
+		n++
+		return true
+	})
+	rtest.Equals(t, N+2, n)
+}
+
+func TestIndexMapCompactMemory(t *testing.T) {
+	// not parallel, as this would distort the measured memory usage
+	const N = 200000
+
+	ids := make([]restic.ID, N)
+	r := rand.New(rand.NewSource(1337))
+	for i := range ids {
+		r.Read(ids[i][:])
+	}
+
+	heapSize := func() uint64 {
+		var stats runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&stats)
+		return stats.HeapAlloc
+	}
+
+	measure := func(compact bool) uint64 {
+		before := heapSize()
+		m := &indexMap{}
+		for i, id := range ids {
+			m.add(id, 0, uint32(i), 0, 0)
+		}
+		if compact {
+			m.compact(restic.IDs{{}})
+		}
+		size := heapSize() - before
+		runtime.KeepAlive(m)
+		return size
+	}
+
+	hashed := measure(false)
+	compacted := measure(true)
+	runtime.KeepAlive(ids)
+	t.Logf("memory per entry: %d bytes hashed, %d bytes compacted", hashed/N, compacted/N)
+
+	rtest.Assert(t, compacted <= 50*N, "compacted index uses %d bytes per entry", compacted/N)
+	rtest.Assert(t, compacted < hashed, "compacted index uses %d bytes, not less than hashed index with %d bytes", compacted, hashed)
+}
+
 func TestHashedArrayTree(t *testing.T) {
 	hat := newHAT()
 	const testSize = 1024
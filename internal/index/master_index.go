@@ -19,6 +19,7 @@ type MasterIndex struct {
 	pendingBlobs restic.BlobSet
 	idxMutex     sync.RWMutex
 	compress     bool
+	compact      bool
 }
 
 // NewMasterIndex creates a new master index.
@@ -35,6 +36,15 @@ func (mi *MasterIndex) MarkCompressed() {
 	mi.compress = true
 }
 
+// MarkCompact configures the master index to store final indexes in a more
+// compact representation. This reduces the memory usage of the index, but
+// makes lookups slower. Indexes are compacted once they are inserted, such
+// that the index files loaded from a repository are never all kept in the
+// larger representation at the same time.
+func (mi *MasterIndex) MarkCompact() {
+	mi.compact = true
+}
+
 // Lookup queries all known Indexes for the ID and returns all matches.
 func (mi *MasterIndex) Lookup(bh restic.BlobHandle) (pbs []restic.PackedBlob) {
 	mi.idxMutex.RLock()
@@ -152,6 +162,9 @@ func (mi *MasterIndex) Insert(idx *Index) {
 	mi.idxMutex.Lock()
 	defer mi.idxMutex.Unlock()
 
+	if mi.compact && idx.Final() {
+		idx.compact()
+	}
 	mi.idx = append(mi.idx, idx)
 }
 
@@ -243,6 +256,11 @@ func (mi *MasterIndex) MergeFinalIndexes() error {
 	mi.idxMutex.Lock()
 	defer mi.idxMutex.Unlock()
 
+	if mi.compact {
+		// merge the compacted indexes without converting them back
+		mi.idx[0].compact()
+	}
+
 	// The first index is always final and the one to merge into
 	newIdx := mi.idx[:1]
 	for i := 1; i < len(mi.idx); i++ {
@@ -262,6 +280,11 @@ func (mi *MasterIndex) MergeFinalIndexes() error {
 	}
 	mi.idx = newIdx
 
+	if mi.compact {
+		// sort the merged entries
+		mi.idx[0].compact()
+	}
+
 	return nil
 }
 
@@ -0,0 +1,78 @@
+package index
+
+import (
+	"math/rand"
+	"testing"
+	"unsafe"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// indexMapSize returns the number of bytes allocated for the entries of m.
+func indexMapSize(m *indexMap) uintptr {
+	size := uintptr(cap(m.buckets))*unsafe.Sizeof(uint32(0)) +
+		uintptr(cap(m.sorted))*unsafe.Sizeof(indexEntry{})
+	for _, block := range m.blockList.blockList {
+		size += uintptr(cap(block)) * unsafe.Sizeof(hashEntry{})
+	}
+	return size
+}
+
+func TestMasterIndexCompactInsert(t *testing.T) {
+	t.Parallel()
+
+	load := func(compact bool) (*MasterIndex, []restic.ID) {
+		rng := rand.New(rand.NewSource(0))
+		mi := NewMasterIndex()
+		if compact {
+			mi.MarkCompact()
+		}
+
+		var ids []restic.ID
+		for i := 0; i < 20; i++ {
+			idx := NewIndex()
+			for j := 0; j < 100; j++ {
+				var packID restic.ID
+				rng.Read(packID[:])
+				blobs := make([]restic.Blob, 50)
+				for k := range blobs {
+					blobs[k].Type = restic.DataBlob
+					rng.Read(blobs[k].ID[:])
+					blobs[k].Offset = uint(k)
+					blobs[k].Length = 1
+					ids = append(ids, blobs[k].ID)
+				}
+				idx.StorePack(packID, blobs)
+			}
+			idx.Finalize()
+			rtest.OK(t, idx.SetID(restic.NewRandomID()))
+			mi.Insert(idx)
+		}
+		return mi, ids
+	}
+
+	size := func(mi *MasterIndex) (size uintptr) {
+		for _, idx := range mi.idx {
+			for typ := range idx.byType {
+				size += indexMapSize(&idx.byType[typ])
+			}
+		}
+		return size
+	}
+
+	hashed, _ := load(false)
+	compacted, ids := load(true)
+
+	// the index files are compacted while they are loaded
+	t.Logf("memory for loaded indexes: %d bytes hashed, %d bytes compacted", size(hashed), size(compacted))
+	rtest.Assert(t, size(compacted) < size(hashed), "compacted indexes use %d bytes, not less than hashed indexes with %d bytes",
+		size(compacted), size(hashed))
+
+	rtest.OK(t, compacted.MergeFinalIndexes())
+	rtest.Equals(t, 1, len(compacted.idx))
+	rtest.Assert(t, compacted.idx[0].byType[restic.DataBlob].compacted, "merged index is not compacted")
+	for _, id := range ids {
+		rtest.Equals(t, 1, len(compacted.Lookup(restic.BlobHandle{ID: id, Type: restic.DataBlob})))
+	}
+}
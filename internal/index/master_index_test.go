@@ -130,6 +130,15 @@ func TestMasterIndex(t *testing.T) {
 }
 
 func TestMasterMergeFinalIndexes(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		testMasterMergeFinalIndexes(t, false)
+	})
+	t.Run("compact", func(t *testing.T) {
+		testMasterMergeFinalIndexes(t, true)
+	})
+}
+
+func testMasterMergeFinalIndexes(t *testing.T, compact bool) {
 	bhInIdx1 := restic.NewRandomBlobHandle()
 	bhInIdx2 := restic.NewRandomBlobHandle()
 
@@ -159,6 +168,9 @@ func TestMasterMergeFinalIndexes(t *testing.T) {
 	idx2.StorePack(blob2.PackID, []restic.Blob{blob2.Blob})
 
 	mIdx := index.NewMasterIndex()
+	if compact {
+		mIdx.MarkCompact()
+	}
 	mIdx.Insert(idx1)
 	mIdx.Insert(idx2)
 
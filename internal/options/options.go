@@ -143,6 +143,29 @@ func (o Options) Extract(ns string) Options {
 	return opts
 }
 
+// Unknown returns the sorted list of keys in o for which dst has no field with
+// a matching struct tag `option`.
+func (o Options) Unknown(dst interface{}) []string {
+	t := reflect.ValueOf(dst).Elem().Type()
+
+	known := make(map[string]struct{})
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("option"); tag != "" {
+			known[tag] = struct{}{}
+		}
+	}
+
+	var unknown []string
+	for key := range o {
+		if _, ok := known[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+
+	return unknown
+}
+
 // Apply sets the options on dst via reflection, using the struct tag `option`.
 // The namespace argument (ns) is only used for error messages.
 func (o Options) Apply(ns string, dst interface{}) error {
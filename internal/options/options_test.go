@@ -241,6 +241,26 @@ func TestOptionsApplyInvalid(t *testing.T) {
 	}
 }
 
+func TestOptionsUnknown(t *testing.T) {
+	opts := Options{
+		"name":       "foobar",
+		"unknown":    "xxx",
+		"first_name": "foobar",
+		"timeout":    "10s",
+	}
+
+	var dst Target
+	unknown := opts.Unknown(&dst)
+	want := []string{"first_name", "unknown"}
+	if !reflect.DeepEqual(unknown, want) {
+		t.Fatalf("wrong unknown options, want %v, got %v", want, unknown)
+	}
+
+	if unknown := (Options{"name": "foobar"}).Unknown(&dst); len(unknown) != 0 {
+		t.Fatalf("expected no unknown options, got %v", unknown)
+	}
+}
+
 func TestListOptions(t *testing.T) {
 	teststruct := struct {
 		Foo string `option:"foo" help:"bar text help"`
@@ -30,12 +30,15 @@ type Key struct {
 	Username string    `json:"username"`
 	Hostname string    `json:"hostname"`
 
-	KDF  string `json:"kdf"`
-	N    int    `json:"N"`
-	R    int    `json:"r"`
-	P    int    `json:"p"`
-	Salt []byte `json:"salt"`
-	Data []byte `json:"data"`
+	KDF     string `json:"kdf"`
+	N       int    `json:"N,omitempty"`
+	R       int    `json:"r,omitempty"`
+	P       int    `json:"p,omitempty"`
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+	Salt    []byte `json:"salt"`
+	Data    []byte `json:"data"`
 
 	user   *crypto.Key
 	master *crypto.Key
@@ -70,17 +73,12 @@ func OpenKey(ctx context.Context, s *Repository, id restic.ID, password string)
 	}
 
 	// check KDF
-	if k.KDF != "scrypt" {
-		return nil, errors.New("only supported KDF is scrypt()")
+	if k.KDF != crypto.KDFScrypt && k.KDF != crypto.KDFArgon2id {
+		return nil, errors.Errorf("unsupported KDF %q", k.KDF)
 	}
 
 	// derive user key
-	params := crypto.Params{
-		N: k.N,
-		R: k.R,
-		P: k.P,
-	}
-	k.user, err = crypto.KDF(params, k.Salt, password)
+	k.user, err = crypto.KDF(k.Params(), k.Salt, password)
 	if err != nil {
 		return nil, errors.Wrap(err, "crypto.KDF")
 	}
@@ -108,6 +106,19 @@ func OpenKey(ctx context.Context, s *Repository, id restic.ID, password string)
 	return k, nil
 }
 
+// Params returns the KDF parameters stored in the key.
+func (k *Key) Params() crypto.Params {
+	return crypto.Params{
+		KDF:     k.KDF,
+		N:       k.N,
+		R:       k.R,
+		P:       k.P,
+		Time:    k.Time,
+		Memory:  k.Memory,
+		Threads: k.Threads,
+	}
+}
+
 // SearchKey tries to decrypt at most maxKeys keys in the backend with the
 // given password. If none could be found, ErrNoKeyFound is returned. When
 // maxKeys is reached, ErrMaxKeysReached is returned. When setting maxKeys to
@@ -193,29 +204,64 @@ func LoadKey(ctx context.Context, s *Repository, id restic.ID) (k *Key, err erro
 	return k, nil
 }
 
-// AddKey adds a new key to an already existing repository.
-func AddKey(ctx context.Context, s *Repository, password, username, hostname string, template *crypto.Key) (*Key, error) {
-	// make sure we have valid KDF parameters
+// KDFParams returns the KDF parameters used for new keys. If Params is not
+// set, the parameters are calibrated for scrypt first.
+func KDFParams() (crypto.Params, error) {
 	if Params == nil {
 		p, err := crypto.Calibrate(KDFTimeout, KDFMemory)
 		if err != nil {
-			return nil, errors.Wrap(err, "Calibrate")
+			return crypto.Params{}, errors.Wrap(err, "Calibrate")
 		}
 
 		Params = &p
 		debug.Log("calibrated KDF parameters are %v", p)
 	}
 
+	return *Params, nil
+}
+
+// KDFParamsFor returns the KDF parameters for new keys using the given KDF.
+// If Params selects the same KDF, they are returned, otherwise parameters for
+// the KDF are calibrated. Params is not modified.
+func KDFParamsFor(kdf string) (crypto.Params, error) {
+	if Params != nil && Params.Name() == kdf {
+		return *Params, nil
+	}
+
+	p, err := crypto.CalibrateKDF(kdf, KDFTimeout, KDFMemory)
+	if err != nil {
+		return crypto.Params{}, errors.Wrap(err, "Calibrate")
+	}
+	debug.Log("calibrated KDF parameters are %v", p)
+	return p, nil
+}
+
+// AddKey adds a new key to an already existing repository.
+func AddKey(ctx context.Context, s *Repository, password, username, hostname string, template *crypto.Key) (*Key, error) {
+	// make sure we have valid KDF parameters
+	params, err := KDFParams()
+	if err != nil {
+		return nil, err
+	}
+
+	return AddKeyWithParams(ctx, s, params, password, username, hostname, template)
+}
+
+// AddKeyWithParams works like AddKey, but derives the key using params.
+func AddKeyWithParams(ctx context.Context, s *Repository, params crypto.Params, password, username, hostname string, template *crypto.Key) (*Key, error) {
 	// fill meta data about key
 	newkey := &Key{
 		Created:  time.Now(),
 		Username: username,
 		Hostname: hostname,
 
-		KDF: "scrypt",
-		N:   Params.N,
-		R:   Params.R,
-		P:   Params.P,
+		KDF:     params.Name(),
+		N:       params.N,
+		R:       params.R,
+		P:       params.P,
+		Time:    params.Time,
+		Memory:  params.Memory,
+		Threads: params.Threads,
 	}
 
 	if newkey.Hostname == "" {
@@ -237,7 +283,7 @@ func AddKey(ctx context.Context, s *Repository, password, username, hostname str
 	}
 
 	// call KDF to derive user key
-	newkey.user, err = crypto.KDF(*Params, newkey.Salt, password)
+	newkey.user, err = crypto.KDF(params, newkey.Salt, password)
 	if err != nil {
 		return nil, err
 	}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -59,8 +60,15 @@ type Repository struct {
 }
 
 type Options struct {
-	Compression CompressionMode
-	PackSize    uint
+	Compression     CompressionMode
+	PackSize        uint
+	IndexMemoryMode IndexMemoryMode
+	// SnapshotIndex enables reading and updating the snapshot index.
+	SnapshotIndex bool
+	// PackReadGap is the maximum size of unneeded data between two blobs of
+	// a pack file that is read instead of sending a separate request. If nil,
+	// DefaultPackReadGap is used.
+	PackReadGap *uint
 }
 
 // CompressionMode configures if data should be compressed.
@@ -108,11 +116,54 @@ func (c *CompressionMode) Type() string {
 	return "mode"
 }
 
+// IndexMemoryMode configures how the index is stored in memory.
+type IndexMemoryMode uint
+
+// Constants for the different index memory modes.
+const (
+	IndexMemoryFast    IndexMemoryMode = 0
+	IndexMemoryCompact IndexMemoryMode = 1
+	IndexMemoryInvalid IndexMemoryMode = 2
+)
+
+// Set implements the method needed for pflag command flag parsing.
+func (m *IndexMemoryMode) Set(s string) error {
+	switch s {
+	case "fast":
+		*m = IndexMemoryFast
+	case "compact":
+		*m = IndexMemoryCompact
+	default:
+		*m = IndexMemoryInvalid
+		return fmt.Errorf("invalid index memory mode %q, must be one of (fast|compact)", s)
+	}
+
+	return nil
+}
+
+func (m *IndexMemoryMode) String() string {
+	switch *m {
+	case IndexMemoryFast:
+		return "fast"
+	case IndexMemoryCompact:
+		return "compact"
+	default:
+		return "invalid"
+	}
+}
+
+func (m *IndexMemoryMode) Type() string {
+	return "mode"
+}
+
 // New returns a new repository with backend be.
 func New(be backend.Backend, opts Options) (*Repository, error) {
 	if opts.Compression == CompressionInvalid {
 		return nil, errors.New("invalid compression mode")
 	}
+	if opts.IndexMemoryMode == IndexMemoryInvalid {
+		return nil, errors.New("invalid index memory mode")
+	}
 
 	if opts.PackSize == 0 {
 		opts.PackSize = DefaultPackSize
@@ -126,12 +177,20 @@ func New(be backend.Backend, opts Options) (*Repository, error) {
 	repo := &Repository{
 		be:   be,
 		opts: opts,
-		idx:  index.NewMasterIndex(),
 	}
+	repo.setIndex(index.NewMasterIndex())
 
 	return repo, nil
 }
 
+// setIndex assigns the given index and configures it according to the options.
+func (r *Repository) setIndex(idx *index.MasterIndex) {
+	r.idx = idx
+	if r.opts.IndexMemoryMode == IndexMemoryCompact {
+		r.idx.MarkCompact()
+	}
+}
+
 // DisableAutoIndexUpdate deactives the automatic finalization and upload of new
 // indexes once these are full
 func (r *Repository) DisableAutoIndexUpdate() {
@@ -156,6 +215,21 @@ func (r *Repository) PackSize() uint {
 	return r.opts.PackSize
 }
 
+// PackReadGap returns the maximum size of unneeded data between two blobs of
+// a pack file that is read instead of sending a separate request.
+func (r *Repository) PackReadGap() uint {
+	if r.opts.PackReadGap == nil {
+		return DefaultPackReadGap
+	}
+	return *r.opts.PackReadGap
+}
+
+// SnapshotIndexEnabled returns true if snapshots should be listed using the
+// snapshot index.
+func (r *Repository) SnapshotIndexEnabled() bool {
+	return r.opts.SnapshotIndex
+}
+
 // UseCache replaces the backend with the wrapped cache.
 func (r *Repository) UseCache(c *cache.Cache) {
 	if c == nil {
@@ -350,25 +424,41 @@ func (r *Repository) LookupBlobSize(id restic.ID, tpe restic.BlobType) (uint, bo
 	return r.idx.LookupSize(restic.BlobHandle{ID: id, Type: tpe})
 }
 
+// NewZstdEncoder returns an encoder configured like the one used to compress
+// data blobs with the given compression mode. As data blobs are stored
+// uncompressed for CompressionOff, nil is returned in that case.
+func NewZstdEncoder(mode CompressionMode) (*zstd.Encoder, error) {
+	if mode == CompressionOff {
+		return nil, nil
+	}
+
+	level := zstd.SpeedDefault
+	if mode == CompressionMax {
+		level = zstd.SpeedBestCompression
+	}
+
+	opts := []zstd.EOption{
+		// Set the compression level configured.
+		zstd.WithEncoderLevel(level),
+		// Disable CRC, we have enough checks in place, makes the
+		// compressed data four bytes shorter.
+		zstd.WithEncoderCRC(false),
+		// Set a window of 512kbyte, so we have good lookbehind for usual
+		// blob sizes.
+		zstd.WithWindowSize(512 * 1024),
+	}
+
+	return zstd.NewWriter(nil, opts...)
+}
+
 func (r *Repository) getZstdEncoder() *zstd.Encoder {
 	r.allocEnc.Do(func() {
-		level := zstd.SpeedDefault
-		if r.opts.Compression == CompressionMax {
-			level = zstd.SpeedBestCompression
+		mode := r.opts.Compression
+		if mode == CompressionOff {
+			// tree blobs and unpacked files are always compressed
+			mode = CompressionAuto
 		}
-
-		opts := []zstd.EOption{
-			// Set the compression level configured.
-			zstd.WithEncoderLevel(level),
-			// Disable CRC, we have enough checks in place, makes the
-			// compressed data four bytes shorter.
-			zstd.WithEncoderCRC(false),
-			// Set a window of 512kbyte, so we have good lookbehind for usual
-			// blob sizes.
-			zstd.WithWindowSize(512 * 1024),
-		}
-
-		enc, err := zstd.NewWriter(nil, opts...)
+		enc, err := NewZstdEncoder(mode)
 		if err != nil {
 			panic(err)
 		}
@@ -475,6 +565,17 @@ func (r *Repository) decompressUnpacked(p []byte) ([]byte, error) {
 // SaveUnpacked encrypts data and stores it in the backend. Returned is the
 // storage hash.
 func (r *Repository) SaveUnpacked(ctx context.Context, t restic.FileType, p []byte) (id restic.ID, err error) {
+	return r.saveUnpacked(ctx, t, p, false)
+}
+
+// CreateExclusive encrypts data and stores it to the backend as type t, like
+// SaveUnpacked. It fails with backend.ErrAlreadyExists if the file already
+// exists instead of overwriting it.
+func (r *Repository) CreateExclusive(ctx context.Context, t restic.FileType, p []byte) (id restic.ID, err error) {
+	return r.saveUnpacked(ctx, t, p, true)
+}
+
+func (r *Repository) saveUnpacked(ctx context.Context, t restic.FileType, p []byte, exclusive bool) (id restic.ID, err error) {
 	if t != restic.ConfigFile {
 		p, err = r.compressUnpacked(p)
 		if err != nil {
@@ -496,7 +597,12 @@ func (r *Repository) SaveUnpacked(ctx context.Context, t restic.FileType, p []by
 	}
 	h := backend.Handle{Type: t, Name: id.String()}
 
-	err = r.be.Save(ctx, h, backend.NewByteReader(ciphertext, r.be.Hasher()))
+	rd := backend.NewByteReader(ciphertext, r.be.Hasher())
+	if exclusive {
+		err = backend.SaveExclusive(ctx, r.be, h, rd)
+	} else {
+		err = r.be.Save(ctx, h, rd)
+	}
 	if err != nil {
 		debug.Log("error saving blob %v: %v", h, err)
 		return restic.ID{}, err
@@ -576,7 +682,7 @@ func (r *Repository) Index() restic.MasterIndex {
 
 // SetIndex instructs the repository to use the given index.
 func (r *Repository) SetIndex(i restic.MasterIndex) error {
-	r.idx = i.(*index.MasterIndex)
+	r.setIndex(i.(*index.MasterIndex))
 	return r.prepareCache()
 }
 
@@ -797,7 +903,20 @@ func (r *Repository) init(ctx context.Context, password string, cfg restic.Confi
 	r.key = key.master
 	r.keyID = key.ID()
 	r.setConfig(cfg)
-	return restic.SaveConfig(ctx, r, cfg)
+
+	buf, err := json.Marshal(cfg)
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+
+	// refuse to overwrite a config written concurrently by another client
+	_, err = r.CreateExclusive(ctx, restic.ConfigFile, buf)
+	if errors.Is(err, backend.ErrAlreadyExists) {
+		// the key is useless without a matching config
+		_ = r.be.Remove(ctx, backend.Handle{Type: restic.KeyFile, Name: key.ID().String()})
+		return errors.New("repository master key and config already initialized")
+	}
+	return err
 }
 
 // Key returns the current master key.
@@ -877,14 +996,16 @@ func (r *Repository) SaveBlob(ctx context.Context, t restic.BlobType, buf []byte
 
 type BackendLoadFn func(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error
 
-// Skip sections with more than 4MB unused blobs
-const maxUnusedRange = 4 * 1024 * 1024
+// DefaultPackReadGap is the default maximum size of unneeded data between two
+// blobs of a pack file that is read instead of sending a separate request.
+const DefaultPackReadGap = 4 * 1024 * 1024
 
 // StreamPack loads the listed blobs from the specified pack file. The plaintext blob is passed to
 // the handleBlobFn callback or an error if decryption failed or the blob hash does not match.
 // handleBlobFn is never called multiple times for the same blob. If the callback returns an error,
-// then StreamPack will abort and not retry it.
-func StreamPack(ctx context.Context, beLoad BackendLoadFn, key *crypto.Key, packID restic.ID, blobs []restic.Blob, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
+// then StreamPack will abort and not retry it. Gaps between blobs of at most maxGap bytes are read
+// as part of the same request, larger gaps are skipped.
+func StreamPack(ctx context.Context, beLoad BackendLoadFn, key *crypto.Key, packID restic.ID, blobs []restic.Blob, maxGap uint, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
 	if len(blobs) == 0 {
 		// nothing to do
 		return nil
@@ -901,7 +1022,7 @@ func StreamPack(ctx context.Context, beLoad BackendLoadFn, key *crypto.Key, pack
 			// don't wait for streamPackPart to fail
 			return errors.Errorf("overlapping blobs in pack %v", packID)
 		}
-		if blobs[i].Offset-lastPos > maxUnusedRange {
+		if blobs[i].Offset-lastPos > maxGap {
 			// load everything up to the skipped file section
 			err := streamPackPart(ctx, beLoad, key, packID, blobs[lowerIdx:i], handleBlobFn)
 			if err != nil {
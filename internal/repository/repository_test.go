@@ -12,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -622,7 +623,7 @@ func testStreamPack(t *testing.T, version uint) {
 
 				loadCalls = 0
 				shortFirstLoad = test.shortFirstLoad
-				err = repository.StreamPack(ctx, load, &key, restic.ID{}, test.blobs, handleBlob)
+				err = repository.StreamPack(ctx, load, &key, restic.ID{}, test.blobs, repository.DefaultPackReadGap, handleBlob)
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -636,6 +637,35 @@ func testStreamPack(t *testing.T, version uint) {
 	})
 	shortFirstLoad = false
 
+	// gaps up to maxGap are read using a single request
+	t.Run("gap", func(t *testing.T) {
+		tests := []struct {
+			blobs  []restic.Blob
+			maxGap uint
+			calls  int
+		}{
+			{packfileBlobs[2:5], 0, 1},
+			{[]restic.Blob{packfileBlobs[2], packfileBlobs[4]}, 0, 2},
+			{[]restic.Blob{packfileBlobs[2], packfileBlobs[4]}, packfileBlobs[3].Length, 1},
+			{[]restic.Blob{packfileBlobs[2], packfileBlobs[4]}, packfileBlobs[3].Length - 1, 2},
+			{[]restic.Blob{packfileBlobs[0], packfileBlobs[len(packfileBlobs)-1]}, 64 * 1024 * 1024, 1},
+		}
+
+		for _, test := range tests {
+			t.Run("", func(t *testing.T) {
+				handleBlob := func(blob restic.BlobHandle, buf []byte, err error) error {
+					return err
+				}
+
+				loadCalls = 0
+				blobs := append([]restic.Blob(nil), test.blobs...)
+				err = repository.StreamPack(context.TODO(), load, &key, restic.ID{}, blobs, test.maxGap, handleBlob)
+				rtest.OK(t, err)
+				rtest.Equals(t, test.calls, loadCalls)
+			})
+		}
+	})
+
 	// next, test invalid uses, which should return an error
 	t.Run("invalid", func(t *testing.T) {
 		tests := []struct {
@@ -685,7 +715,7 @@ func testStreamPack(t *testing.T, version uint) {
 					return err
 				}
 
-				err = repository.StreamPack(ctx, load, &key, restic.ID{}, test.blobs, handleBlob)
+				err = repository.StreamPack(ctx, load, &key, restic.ID{}, test.blobs, repository.DefaultPackReadGap, handleBlob)
 				if err == nil {
 					t.Fatalf("wanted error %v, got nil", test.err)
 				}
@@ -698,6 +728,123 @@ func testStreamPack(t *testing.T, version uint) {
 	})
 }
 
+func TestStreamBlobs(t *testing.T) {
+	repository.TestAllVersions(t, testStreamBlobs)
+}
+
+func testStreamBlobs(t *testing.T, version uint) {
+	repo := repository.TestRepositoryWithVersion(t, version)
+
+	var wg errgroup.Group
+	repo.StartPackUploader(context.TODO(), &wg)
+
+	data := make(map[restic.BlobHandle][]byte)
+	var blobs []restic.BlobHandle
+	for i := 0; i < 50; i++ {
+		buf := make([]byte, rnd.Intn(256*1024))
+		_, err := io.ReadFull(rnd, buf)
+		rtest.OK(t, err)
+
+		id, _, _, err := repo.SaveBlob(context.TODO(), restic.DataBlob, buf, restic.ID{}, false)
+		rtest.OK(t, err)
+		h := restic.BlobHandle{ID: id, Type: restic.DataBlob}
+		data[h] = buf
+		// request every other blob twice to leave gaps in the pack files
+		blobs = append(blobs, h)
+		if i%2 == 0 {
+			blobs = append(blobs, h)
+		}
+	}
+	rtest.OK(t, repo.Flush(context.Background()))
+
+	missing := restic.BlobHandle{ID: restic.NewRandomID(), Type: restic.DataBlob}
+	blobs = append(blobs, missing)
+
+	var m sync.Mutex
+	seen := restic.NewBlobSet()
+	err := repository.StreamBlobs(context.TODO(), repo, blobs, 2, func(h restic.BlobHandle, buf []byte, err error) error {
+		m.Lock()
+		defer m.Unlock()
+
+		if seen.Has(h) {
+			return fmt.Errorf("blob %v handled twice", h)
+		}
+		seen.Insert(h)
+		if h == missing {
+			if err == nil {
+				return fmt.Errorf("expected error for missing blob")
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(data[h], buf) {
+			return fmt.Errorf("wrong data for blob %v", h)
+		}
+		return nil
+	})
+	rtest.OK(t, err)
+	rtest.Equals(t, len(data)+1, len(seen))
+}
+
+type countingLoadBackend struct {
+	backend.Backend
+	m     sync.Mutex
+	loads map[string]int
+}
+
+func (be *countingLoadBackend) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	be.m.Lock()
+	be.loads[h.Name]++
+	be.m.Unlock()
+	return be.Backend.Load(ctx, h, length, offset, fn)
+}
+
+func TestStreamBlobsDuplicate(t *testing.T) {
+	first := rtest.Random(1, 1000)
+	second := rtest.Random(2, 1000)
+
+	// store the first blob in two pack files and the second one only in
+	// either the first or the last pack file
+	for _, packs := range [][][][]byte{
+		{{first, second}, {first}},
+		{{first}, {second, first}},
+	} {
+		t.Run("", func(t *testing.T) {
+			be := &countingLoadBackend{Backend: repository.TestBackend(t)}
+			repo := repository.TestRepositoryWithBackend(t, be, 0)
+
+			for _, bufs := range packs {
+				var wg errgroup.Group
+				repo.StartPackUploader(context.TODO(), &wg)
+				for _, buf := range bufs {
+					_, _, _, err := repo.SaveBlob(context.TODO(), restic.DataBlob, buf, restic.ID{}, true)
+					rtest.OK(t, err)
+				}
+				rtest.OK(t, repo.Flush(context.Background()))
+			}
+
+			blobs := []restic.BlobHandle{
+				{ID: restic.Hash(second), Type: restic.DataBlob},
+				{ID: restic.Hash(first), Type: restic.DataBlob},
+			}
+			rtest.Equals(t, 2, len(repo.Index().Lookup(blobs[1])))
+
+			be.loads = make(map[string]int)
+			err := repository.StreamBlobs(context.TODO(), repo, blobs, 1, func(h restic.BlobHandle, buf []byte, err error) error {
+				return err
+			})
+			rtest.OK(t, err)
+			// both blobs are read from the same pack file using a single request
+			rtest.Equals(t, 1, len(be.loads))
+			for _, calls := range be.loads {
+				rtest.Equals(t, 1, calls)
+			}
+		})
+	}
+}
+
 func TestInvalidCompression(t *testing.T) {
 	var comp repository.CompressionMode
 	err := comp.Set("nope")
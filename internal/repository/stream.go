@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"golang.org/x/sync/errgroup"
+)
+
+// StreamBlobs loads the listed blobs and passes them to handleBlobFn, in no
+// particular order. The blobs are grouped by the pack file containing them,
+// such that each pack file is read using as few ranged requests as possible,
+// see StreamPack. Gaps between blobs are read up to the PackReadGap of the
+// repository. A blob stored in several pack files is read from the pack file
+// of a previously listed blob, if possible. Up to concurrency pack files are
+// read in parallel, if it is zero the number of backend connections is used.
+// handleBlobFn may be called concurrently, but never multiple times for the
+// same blob. Blobs which are not contained in the index are reported to
+// handleBlobFn with an error.
+func StreamBlobs(ctx context.Context, repo restic.Repository, blobs []restic.BlobHandle, concurrency uint,
+	handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
+
+	if concurrency == 0 {
+		concurrency = repo.Connections()
+	}
+
+	packs := make(map[restic.ID][]restic.Blob)
+	var packOrder restic.IDs
+	seen := restic.NewBlobSet()
+	for _, h := range blobs {
+		if seen.Has(h) {
+			continue
+		}
+		seen.Insert(h)
+
+		pbs := repo.Index().Lookup(h)
+		if len(pbs) == 0 {
+			err := handleBlobFn(h, nil, errors.Errorf("blob %v not found in index", h))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		pb := pbs[0]
+		for _, candidate := range pbs {
+			if _, ok := packs[candidate.PackID]; ok {
+				pb = candidate
+				break
+			}
+		}
+		if _, ok := packs[pb.PackID]; !ok {
+			packOrder = append(packOrder, pb.PackID)
+		}
+		packs[pb.PackID] = append(packs[pb.PackID], pb.Blob)
+	}
+
+	wg, ctx := errgroup.WithContext(ctx)
+	ch := make(chan restic.ID)
+
+	wg.Go(func() error {
+		defer close(ch)
+		for _, id := range packOrder {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case ch <- id:
+			}
+		}
+		return nil
+	})
+
+	for i := uint(0); i < concurrency; i++ {
+		wg.Go(func() error {
+			for id := range ch {
+				err := StreamPack(ctx, repo.Backend().Load, repo.Key(), id, packs[id], repo.PackReadGap(), handleBlobFn)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	return wg.Wait()
+}
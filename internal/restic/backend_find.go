@@ -3,14 +3,30 @@ package restic
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 )
 
 // A MultipleIDMatchesError is returned by Find() when multiple IDs with a
 // given prefix are found.
-type MultipleIDMatchesError struct{ prefix string }
+type MultipleIDMatchesError struct {
+	prefix  string
+	matches IDs
+}
 
 func (e *MultipleIDMatchesError) Error() string {
-	return fmt.Sprintf("multiple IDs with prefix %q found", e.prefix)
+	// print the matches with enough characters to distinguish them
+	n := UniquePrefixLength(e.matches)
+	ids := make([]string, 0, len(e.matches))
+	for _, id := range e.matches {
+		ids = append(ids, id.StrN(n))
+	}
+	return fmt.Sprintf("multiple IDs with prefix %q found: %s", e.prefix, strings.Join(ids, ", "))
+}
+
+// Matches returns the IDs which start with the prefix, sorted by ID.
+func (e *MultipleIDMatchesError) Matches() IDs {
+	return e.matches
 }
 
 // A NoIDByPrefixError is returned by Find() when no ID for a given prefix
@@ -23,21 +39,15 @@ func (e *NoIDByPrefixError) Error() string {
 
 // Find loads the list of all files of type t and searches for names which
 // start with prefix. If none is found, nil and ErrNoIDPrefixFound is returned.
-// If more than one is found, nil and ErrMultipleIDMatches is returned.
+// If more than one is found, nil and a MultipleIDMatchesError listing all
+// matches is returned.
 func Find(ctx context.Context, be Lister, t FileType, prefix string) (ID, error) {
-	match := ID{}
-
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+	var matches IDs
 
 	err := be.List(ctx, t, func(id ID, size int64) error {
 		name := id.String()
 		if len(name) >= len(prefix) && prefix == name[:len(prefix)] {
-			if match.IsNull() {
-				match = id
-			} else {
-				return &MultipleIDMatchesError{prefix}
-			}
+			matches = append(matches, id)
 		}
 
 		return nil
@@ -47,9 +57,13 @@ func Find(ctx context.Context, be Lister, t FileType, prefix string) (ID, error)
 		return ID{}, err
 	}
 
-	if !match.IsNull() {
-		return match, nil
+	switch len(matches) {
+	case 0:
+		return ID{}, &NoIDByPrefixError{prefix}
+	case 1:
+		return matches[0], nil
+	default:
+		sort.Sort(matches)
+		return ID{}, &MultipleIDMatchesError{prefix: prefix, matches: matches}
 	}
-
-	return ID{}, &NoIDByPrefixError{prefix}
 }
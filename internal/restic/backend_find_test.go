@@ -65,6 +65,12 @@ func TestFind(t *testing.T) {
 	if _, ok := err.(*restic.MultipleIDMatchesError); !ok {
 		t.Errorf("Wrong error %v for multiple snapshots", err)
 	}
+	// the matches are listed with enough characters to distinguish them
+	for _, match := range []string{"20bdc1402a6fc9b633a,", "20bdc1402a6fc9b633c,", "20bdc1402a6fc9b633f"} {
+		if !strings.Contains(err.Error(), match) {
+			t.Errorf("error %q does not list match %v", err, match)
+		}
+	}
 	if !f.IsNull() {
 		t.Errorf("Find should not return a match on error.")
 	}
@@ -67,6 +67,18 @@ func (id *ID) Str() string {
 	return hex.EncodeToString(id[:shortStr])
 }
 
+// StrN returns the first n hex characters of id. If n is below the length
+// used by Str, the result of Str is returned instead.
+func (id *ID) StrN(n int) string {
+	if id == nil || id.IsNull() || n <= 2*shortStr {
+		return id.Str()
+	}
+	if n > 2*len(id) {
+		n = 2 * len(id)
+	}
+	return id.String()[:n]
+}
+
 // IsNull returns true iff id only consists of null bytes.
 func (id ID) IsNull() bool {
 	var nullID ID
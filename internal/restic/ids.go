@@ -2,6 +2,7 @@ package restic
 
 import (
 	"encoding/hex"
+	"sort"
 	"strings"
 )
 
@@ -38,3 +39,39 @@ func (ids IDs) String() string {
 
 	return sb.String()
 }
+
+// UniquePrefixLength returns the number of hex characters which is required to
+// distinguish all ids, such that no two IDs share the same prefix. The result
+// is never below the length used by ID.Str.
+func UniquePrefixLength(ids IDs) int {
+	sorted := make(IDs, len(ids))
+	copy(sorted, ids)
+	sort.Sort(sorted)
+
+	length := 2 * shortStr
+	for i := 1; i < len(sorted); i++ {
+		a, b := sorted[i-1], sorted[i]
+		if a == b {
+			continue
+		}
+
+		// count the hex characters both IDs have in common
+		common := 0
+		for j := range a {
+			if a[j] == b[j] {
+				common += 2
+				continue
+			}
+			if a[j]>>4 == b[j]>>4 {
+				common++
+			}
+			break
+		}
+
+		if common+1 > length {
+			length = common + 1
+		}
+	}
+
+	return length
+}
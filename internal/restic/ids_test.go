@@ -15,3 +15,34 @@ func TestIDsString(t *testing.T) {
 
 	rtest.Equals(t, "[7bb086db 1285b303 7bb086db]", ids.String())
 }
+
+func TestUniquePrefixLength(t *testing.T) {
+	for _, test := range []struct {
+		ids    IDs
+		length int
+	}{
+		{nil, 8},
+		{IDs{TestParseID("7bb086db0d06285d831485da8031281e28336a56baa313539eaea1c73a2a1a40")}, 8},
+		{IDs{
+			TestParseID("7bb086db0d06285d831485da8031281e28336a56baa313539eaea1c73a2a1a40"),
+			TestParseID("1285b30394f3b74693cc29a758d9624996ae643157776fce8154aabd2f01515f"),
+			// duplicates are ignored
+			TestParseID("7bb086db0d06285d831485da8031281e28336a56baa313539eaea1c73a2a1a40"),
+		}, 8},
+		{IDs{
+			TestParseID("7bb086db0d06285d831485da8031281e28336a56baa313539eaea1c73a2a1a40"),
+			TestParseID("7bb086db0d1fffffffffffffffffffffffffffffffffffffffffffffffffffff"),
+		}, 11},
+		{IDs{
+			TestParseID("7bb086db0d06285d831485da8031281e28336a56baa313539eaea1c73a2a1a40"),
+			TestParseID("7bb086db0d0fffffffffffffffffffffffffffffffffffffffffffffffffffff"),
+		}, 12},
+	} {
+		rtest.Equals(t, test.length, UniquePrefixLength(test.ids))
+	}
+
+	id := TestParseID("7bb086db0d06285d831485da8031281e28336a56baa313539eaea1c73a2a1a40")
+	rtest.Equals(t, "7bb086db", id.StrN(4))
+	rtest.Equals(t, "7bb086db0d0", id.StrN(11))
+	rtest.Equals(t, id.String(), id.StrN(100))
+}
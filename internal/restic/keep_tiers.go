@@ -0,0 +1,134 @@
+package restic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// KeepTiers describes a tiered retention policy like `7d8w12m`, which keeps
+// daily snapshots for 7 days, weekly snapshots for 8 weeks and monthly
+// snapshots for 12 months. All tiers are relative to the latest snapshot.
+type KeepTiers struct {
+	Hours, Days, Weeks, Months, Years int
+}
+
+// ParseKeepTiers parses a tiered retention policy from a string. The format
+// is similar to durations, e.g. `24h7d8w12m5y`, but also supports weeks.
+func ParseKeepTiers(s string) (KeepTiers, error) {
+	var (
+		t   KeepTiers
+		num int
+		err error
+	)
+
+	s = strings.TrimSpace(s)
+	seen := make(map[byte]struct{})
+
+	for s != "" {
+		num, s, err = nextNumber(s)
+		if err != nil {
+			return KeepTiers{}, err
+		}
+
+		if len(s) == 0 {
+			return KeepTiers{}, errors.Errorf("no unit found after number %d", num)
+		}
+
+		if num <= 0 {
+			return KeepTiers{}, errors.Errorf("invalid number %d for unit %q, must be positive", num, s[0])
+		}
+
+		if _, ok := seen[s[0]]; ok {
+			return KeepTiers{}, errors.Errorf("unit %q specified more than once", s[0])
+		}
+		seen[s[0]] = struct{}{}
+
+		switch s[0] {
+		case 'h':
+			t.Hours = num
+		case 'd':
+			t.Days = num
+		case 'w':
+			t.Weeks = num
+		case 'm':
+			t.Months = num
+		case 'y':
+			t.Years = num
+		default:
+			return KeepTiers{}, errors.Errorf("invalid unit %q found after number %d", s[0], num)
+		}
+
+		s = s[1:]
+	}
+
+	return t, nil
+}
+
+func (t KeepTiers) String() string {
+	var s string
+	for _, tier := range []struct {
+		num  int
+		unit string
+	}{
+		{t.Hours, "h"},
+		{t.Days, "d"},
+		{t.Weeks, "w"},
+		{t.Months, "m"},
+		{t.Years, "y"},
+	} {
+		if tier.num != 0 {
+			s += fmt.Sprintf("%d%s", tier.num, tier.unit)
+		}
+	}
+	return s
+}
+
+// Set calls ParseKeepTiers and updates t.
+func (t *KeepTiers) Set(s string) error {
+	v, err := ParseKeepTiers(s)
+	if err != nil {
+		return err
+	}
+
+	*t = v
+	return nil
+}
+
+// Type returns the type of KeepTiers, usable within github.com/spf13/pflag
+// and in help texts.
+func (t KeepTiers) Type() string {
+	return "tiers"
+}
+
+// Zero returns true if no tier is set.
+func (t KeepTiers) Zero() bool {
+	return t == KeepTiers{}
+}
+
+// Apply sets the bucket durations of the policy according to the tiers. It
+// returns an error if a duration for one of the tiers is already set.
+func (t KeepTiers) Apply(p *ExpirePolicy) error {
+	for _, tier := range []struct {
+		within *Duration
+		d      Duration
+		descr  string
+	}{
+		{&p.WithinHourly, Duration{Hours: t.Hours}, "hourly"},
+		{&p.WithinDaily, Duration{Days: t.Days}, "daily"},
+		{&p.WithinWeekly, Duration{Days: 7 * t.Weeks}, "weekly"},
+		{&p.WithinMonthly, Duration{Months: t.Months}, "monthly"},
+		{&p.WithinYearly, Duration{Years: t.Years}, "yearly"},
+	} {
+		if tier.d.Zero() {
+			continue
+		}
+		if !tier.within.Zero() {
+			return errors.Errorf("duration for %s snapshots is specified more than once", tier.descr)
+		}
+		*tier.within = tier.d
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package restic_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/restic/restic/internal/restic"
+)
+
+func TestParseKeepTiers(t *testing.T) {
+	var tests = []struct {
+		input  string
+		tiers  restic.KeepTiers
+		output string
+		err    bool
+	}{
+		{input: "7d", tiers: restic.KeepTiers{Days: 7}, output: "7d"},
+		{input: "7d8w12m", tiers: restic.KeepTiers{Days: 7, Weeks: 8, Months: 12}, output: "7d8w12m"},
+		{input: "5y12m8w7d24h", tiers: restic.KeepTiers{Hours: 24, Days: 7, Weeks: 8, Months: 12, Years: 5}, output: "24h7d8w12m5y"},
+		{input: "", tiers: restic.KeepTiers{}, output: ""},
+		{input: "7d7d", err: true},
+		{input: "-7d", err: true},
+		{input: "0w", err: true},
+		{input: "7", err: true},
+		{input: "7s", err: true},
+		{input: "d", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			tiers, err := restic.ParseKeepTiers(test.input)
+			if test.err {
+				if err == nil {
+					t.Fatalf("missing error for %v", test.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !cmp.Equal(tiers, test.tiers) {
+				t.Error(cmp.Diff(test.tiers, tiers))
+			}
+
+			if s := tiers.String(); s != test.output {
+				t.Errorf("unexpected return of String(), want %q, got %q", test.output, s)
+			}
+		})
+	}
+}
+
+func TestKeepTiersApply(t *testing.T) {
+	tiers := restic.KeepTiers{Days: 7, Weeks: 8, Months: 12}
+
+	var p restic.ExpirePolicy
+	if err := tiers.Apply(&p); err != nil {
+		t.Fatal(err)
+	}
+
+	// the tiers are equivalent to the individual options
+	equivalent := restic.ExpirePolicy{
+		WithinDaily:   restic.ParseDurationOrPanic("7d"),
+		WithinWeekly:  restic.ParseDurationOrPanic("56d"),
+		WithinMonthly: restic.ParseDurationOrPanic("12m"),
+	}
+	if !cmp.Equal(p, equivalent) {
+		t.Error(cmp.Diff(equivalent, p))
+	}
+
+	// On a range of the test snapshots which contains a snapshot for each
+	// bucket within the time window, the tiers keep the same snapshots as
+	// the policy keeping the corresponding number of snapshots.
+	for _, test := range []struct {
+		until  string
+		tiers  string
+		policy restic.ExpirePolicy
+	}{
+		{"2016-01-09 21:02:03", "6d", restic.ExpirePolicy{Daily: 6}},
+		{"2016-01-09 21:02:03", "3d", restic.ExpirePolicy{Daily: 3}},
+		{"2015-11-22 10:20:30", "4m", restic.ExpirePolicy{Monthly: 4}},
+		{"2015-11-22 10:20:30", "2d4m", restic.ExpirePolicy{Daily: 2, Monthly: 4}},
+		{"2016-01-18 12:02:03", "10y", restic.ExpirePolicy{Yearly: 10}},
+	} {
+		t.Run(test.until+"/"+test.tiers, func(t *testing.T) {
+			var snapshots restic.Snapshots
+			until := parseTimeUTC(test.until)
+			for _, sn := range testExpireSnapshots {
+				if !sn.Time.After(until) {
+					snapshots = append(snapshots, sn)
+				}
+			}
+
+			tiers, err := restic.ParseKeepTiers(test.tiers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var p restic.ExpirePolicy
+			if err := tiers.Apply(&p); err != nil {
+				t.Fatal(err)
+			}
+
+			keep, _, _ := restic.ApplyPolicy(snapshots, p)
+			want, _, _ := restic.ApplyPolicy(snapshots, test.policy)
+			if !cmp.Equal(snapshotTimes(keep), snapshotTimes(want)) {
+				t.Error(cmp.Diff(snapshotTimes(want), snapshotTimes(keep)))
+			}
+		})
+	}
+
+	// combining the tiers with other options for the same bucket fails
+	p = restic.ExpirePolicy{WithinWeekly: restic.ParseDurationOrPanic("1m")}
+	if err := tiers.Apply(&p); err == nil {
+		t.Error("expected error for duplicate weekly duration")
+	}
+
+	// other options are kept
+	p = restic.ExpirePolicy{Last: 3, WithinHourly: restic.ParseDurationOrPanic("1d")}
+	if err := tiers.Apply(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Last != 3 || p.WithinHourly != restic.ParseDurationOrPanic("1d") || p.WithinDaily.Zero() {
+		t.Errorf("unexpected policy %v", p)
+	}
+}
+
+func snapshotTimes(list restic.Snapshots) (times []time.Time) {
+	for _, sn := range list {
+		times = append(times, sn.Time)
+	}
+	return times
+}
@@ -13,6 +13,9 @@ type fileInfo struct {
 type memorizedLister struct {
 	fileInfos []fileInfo
 	tpe       FileType
+	// snapshotIndex is set for a listing of the snapshot files if the
+	// snapshot index is enabled
+	snapshotIndex *memorizedSnapshotIndex
 }
 
 func (m *memorizedLister) List(ctx context.Context, t FileType, fn func(ID, int64) error) error {
@@ -31,6 +34,9 @@ func (m *memorizedLister) List(ctx context.Context, t FileType, fn func(ID, int6
 	return ctx.Err()
 }
 
+// MemorizeList lists the files of type t once and returns a Lister which
+// yields the same files again. For the snapshot files of a repository which
+// uses the snapshot index, the snapshot index is loaded as well.
 func MemorizeList(ctx context.Context, be Lister, t FileType) (Lister, error) {
 	if _, ok := be.(*memorizedLister); ok {
 		return be, nil
@@ -45,8 +51,12 @@ func MemorizeList(ctx context.Context, be Lister, t FileType) (Lister, error) {
 		return nil, err
 	}
 
-	return &memorizedLister{
+	ml := &memorizedLister{
 		fileInfos: fileInfos,
 		tpe:       t,
-	}, nil
+	}
+	if t == SnapshotFile {
+		ml.snapshotIndex = memorizeSnapshotIndex(ctx, be)
+	}
+	return ml, nil
 }
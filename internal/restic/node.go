@@ -34,6 +34,7 @@ type Node struct {
 	ModTime    time.Time   `json:"mtime,omitempty"`
 	AccessTime time.Time   `json:"atime,omitempty"`
 	ChangeTime time.Time   `json:"ctime,omitempty"`
+	BirthTime  time.Time   `json:"-"` // creation time of the file, only stored if set, see MarshalJSON
 	UID        uint32      `json:"uid"`
 	GID        uint32      `json:"gid"`
 	User       string      `json:"user,omitempty"`
@@ -218,6 +219,14 @@ func (node Node) restoreMetadata(path string) error {
 		}
 	}
 
+	if !node.BirthTime.IsZero() {
+		// the birth time can only be set on some platforms and filesystems,
+		// thus it is restored on a best-effort basis
+		if err := node.restoreBirthTime(path); err != nil {
+			debug.Log("error restoring birth time for %v: %v", path, err)
+		}
+	}
+
 	if err := node.restoreExtendedAttributes(path); err != nil {
 		debug.Log("error restoring extended attributes for %v: %v", path, err)
 		if firsterr != nil {
@@ -239,8 +248,15 @@ func (node Node) restoreExtendedAttributes(path string) error {
 }
 
 func (node Node) RestoreTimestamps(path string) error {
+	atime := node.AccessTime
+	if atime.IsZero() {
+		// the access time is unknown, e.g. because the platform the snapshot
+		// was created on does not provide it
+		atime = node.ModTime
+	}
+
 	var utimes = [...]syscall.Timespec{
-		syscall.NsecToTimespec(node.AccessTime.UnixNano()),
+		syscall.NsecToTimespec(atime.UnixNano()),
 		syscall.NsecToTimespec(node.ModTime.UnixNano()),
 	}
 
@@ -349,7 +365,16 @@ func (node Node) MarshalJSON() ([]byte, error) {
 	node.ChangeTime = FixTime(node.ChangeTime)
 
 	type nodeJSON Node
-	nj := nodeJSON(node)
+	nj := struct {
+		nodeJSON
+		// the birth time is omitted if it is unknown, so that the
+		// serialization of existing nodes does not change
+		BirthTime *time.Time `json:"btime,omitempty"`
+	}{nodeJSON: nodeJSON(node)}
+	if !node.BirthTime.IsZero() {
+		btime := FixTime(node.BirthTime)
+		nj.BirthTime = &btime
+	}
 	name := strconv.Quote(node.Name)
 	nj.Name = name[1 : len(name)-1]
 	if nj.LinkTargetRaw != nil {
@@ -366,11 +391,18 @@ func (node Node) MarshalJSON() ([]byte, error) {
 func (node *Node) UnmarshalJSON(data []byte) error {
 	type nodeJSON Node
 	nj := (*nodeJSON)(node)
+	aux := struct {
+		*nodeJSON
+		BirthTime *time.Time `json:"btime,omitempty"`
+	}{nodeJSON: nj}
 
-	err := json.Unmarshal(data, nj)
+	err := json.Unmarshal(data, &aux)
 	if err != nil {
 		return errors.Wrap(err, "Unmarshal")
 	}
+	if aux.BirthTime != nil {
+		node.BirthTime = *aux.BirthTime
+	}
 
 	nj.Name, err = strconv.Unquote(`"` + nj.Name + `"`)
 	if err != nil {
@@ -402,6 +434,9 @@ func (node Node) Equals(other Node) bool {
 	if !node.ChangeTime.Equal(other.ChangeTime) {
 		return false
 	}
+	if !node.BirthTime.Equal(other.BirthTime) {
+		return false
+	}
 	if node.UID != other.UID {
 		return false
 	}
@@ -659,6 +694,17 @@ func mkfifo(path string, mode uint32) (err error) {
 	return mknod(path, mode|syscall.S_IFIFO, 0)
 }
 
+// FillBirthTime sets the birth time of the node to the creation time of the
+// file at node.Path. The birth time is left unset if the platform or the
+// filesystem does not record it.
+func (node *Node) FillBirthTime(fi os.FileInfo) {
+	stat, ok := toStatT(fi.Sys())
+	if !ok {
+		return
+	}
+	node.BirthTime = birthTime(node.Path, stat)
+}
+
 func (node *Node) fillTimes(stat *statT) {
 	ctim := stat.ctim()
 	atim := stat.atim()
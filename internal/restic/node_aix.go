@@ -3,7 +3,10 @@
 
 package restic
 
-import "syscall"
+import (
+	"syscall"
+	"time"
+)
 
 func (node Node) restoreSymlinkTimestamps(path string, utimes [2]syscall.Timespec) error {
 	return nil
@@ -34,3 +37,12 @@ func Listxattr(path string) ([]string, error) {
 func Setxattr(path, name string, data []byte) error {
 	return nil
 }
+
+// birthTime is not supported on AIX.
+func birthTime(_ string, _ *statT) time.Time {
+	return time.Time{}
+}
+
+func (node Node) restoreBirthTime(_ string) error {
+	return nil
+}
@@ -1,6 +1,14 @@
 package restic
 
-import "syscall"
+import (
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/restic/restic/internal/errors"
+)
 
 func (node Node) restoreSymlinkTimestamps(path string, utimes [2]syscall.Timespec) error {
 	return nil
@@ -9,3 +17,19 @@ func (node Node) restoreSymlinkTimestamps(path string, utimes [2]syscall.Timespe
 func (s statT) atim() syscall.Timespec { return s.Atimespec }
 func (s statT) mtim() syscall.Timespec { return s.Mtimespec }
 func (s statT) ctim() syscall.Timespec { return s.Ctimespec }
+
+func birthTime(_ string, stat *statT) time.Time {
+	return time.Unix(stat.Birthtimespec.Unix())
+}
+
+func (node Node) restoreBirthTime(path string) error {
+	attrs := unix.Attrlist{
+		Bitmapcount: unix.ATTR_BIT_MAP_COUNT,
+		Commonattr:  unix.ATTR_CMN_CRTIME,
+	}
+	ts := unix.NsecToTimespec(node.BirthTime.UnixNano())
+	buf := (*[unsafe.Sizeof(ts)]byte)(unsafe.Pointer(&ts))[:]
+
+	err := unix.Setattrlist(path, &attrs, buf, unix.FSOPT_NOFOLLOW)
+	return errors.Wrap(err, "Setattrlist")
+}
@@ -3,7 +3,10 @@
 
 package restic
 
-import "syscall"
+import (
+	"syscall"
+	"time"
+)
 
 func (node Node) restoreSymlinkTimestamps(path string, utimes [2]syscall.Timespec) error {
 	return nil
@@ -16,3 +19,13 @@ func mknod(path string, mode uint32, dev uint64) (err error) {
 func (s statT) atim() syscall.Timespec { return s.Atimespec }
 func (s statT) mtim() syscall.Timespec { return s.Mtimespec }
 func (s statT) ctim() syscall.Timespec { return s.Ctimespec }
+
+func birthTime(_ string, stat *statT) time.Time {
+	return time.Unix(stat.Birthtimespec.Unix())
+}
+
+// restoreBirthTime is not supported on FreeBSD, the birth time is set
+// automatically when the file is created.
+func (node Node) restoreBirthTime(_ string) error {
+	return nil
+}
@@ -3,6 +3,7 @@ package restic
 import (
 	"path/filepath"
 	"syscall"
+	"time"
 
 	"golang.org/x/sys/unix"
 
@@ -35,3 +36,20 @@ func (node Node) restoreSymlinkTimestamps(path string, utimes [2]syscall.Timespe
 func (s statT) atim() syscall.Timespec { return s.Atim }
 func (s statT) mtim() syscall.Timespec { return s.Mtim }
 func (s statT) ctim() syscall.Timespec { return s.Ctim }
+
+// birthTime queries the birth time using statx, as it is not part of the
+// result of stat. Older kernels and some filesystems do not provide it.
+func birthTime(path string, _ *statT) time.Time {
+	var stx unix.Statx_t
+	err := unix.Statx(unix.AT_FDCWD, path, unix.AT_SYMLINK_NOFOLLOW, unix.STATX_BTIME, &stx)
+	if err != nil || stx.Mask&unix.STATX_BTIME == 0 {
+		return time.Time{}
+	}
+	return time.Unix(stx.Btime.Sec, int64(stx.Btime.Nsec))
+}
+
+// restoreBirthTime is not supported on Linux, there is no way to change the
+// birth time of a file.
+func (node Node) restoreBirthTime(_ string) error {
+	return nil
+}
@@ -1,6 +1,9 @@
 package restic
 
-import "syscall"
+import (
+	"syscall"
+	"time"
+)
 
 func (node Node) restoreSymlinkTimestamps(path string, utimes [2]syscall.Timespec) error {
 	return nil
@@ -25,3 +28,13 @@ func Listxattr(path string) ([]string, error) {
 func Setxattr(path, name string, data []byte) error {
 	return nil
 }
+
+func birthTime(_ string, stat *statT) time.Time {
+	return time.Unix(stat.Birthtimespec.Unix())
+}
+
+// restoreBirthTime is not supported on NetBSD, the birth time is set
+// automatically when the file is created.
+func (node Node) restoreBirthTime(_ string) error {
+	return nil
+}
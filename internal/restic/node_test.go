@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -372,3 +373,58 @@ func TestSymlinkSerializationFormat(t *testing.T) {
 		test.Assert(t, n2.LinkTargetRaw == nil, "quoted link target is just a helper field and must be unset after decoding")
 	}
 }
+
+func TestNodeRestoreTimestampsWithoutAtime(t *testing.T) {
+	nodePath := filepath.Join(t.TempDir(), "testfile")
+	rtest.OK(t, os.WriteFile(nodePath, []byte("foo"), 0600))
+
+	node := restic.Node{
+		Name:    "testfile",
+		Type:    "file",
+		ModTime: parseTimeNano(t, "2005-05-14T21:07:03.111Z"),
+	}
+	rtest.OK(t, node.RestoreTimestamps(nodePath))
+
+	fi, err := os.Lstat(nodePath)
+	rtest.OK(t, err)
+	n2, err := restic.NodeFromFileInfo(nodePath, fi)
+	rtest.OK(t, err)
+
+	// the modification time is used as access time
+	AssertFsTimeEqual(t, "AccessTime", node.Type, node.ModTime, n2.AccessTime)
+	AssertFsTimeEqual(t, "ModTime", node.Type, node.ModTime, n2.ModTime)
+}
+
+func TestBirthTimeSerialization(t *testing.T) {
+	ser, err := json.Marshal(restic.Node{Name: "foo"})
+	rtest.OK(t, err)
+	rtest.Assert(t, !strings.Contains(string(ser), "btime"), "unset birth time was serialized: %s", ser)
+
+	n := restic.Node{Name: "foo", BirthTime: parseTimeNano(t, "2005-05-14T21:07:03.111Z")}
+	ser, err = json.Marshal(n)
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(string(ser), `"btime":"2005-05-14T21:07:03.111Z"`), "birth time missing: %s", ser)
+
+	var n2 restic.Node
+	rtest.OK(t, json.Unmarshal(ser, &n2))
+	rtest.Assert(t, n.Equals(n2), "nodes differ after serialization: %v and %v", n, n2)
+}
+
+func TestNodeFillBirthTime(t *testing.T) {
+	nodePath := filepath.Join(t.TempDir(), "testfile")
+	start := time.Now().Add(-time.Minute)
+	rtest.OK(t, os.WriteFile(nodePath, []byte("foo"), 0600))
+
+	fi, err := os.Lstat(nodePath)
+	rtest.OK(t, err)
+	node, err := restic.NodeFromFileInfo(nodePath, fi)
+	rtest.OK(t, err)
+	rtest.Assert(t, node.BirthTime.IsZero(), "birth time must only be set by FillBirthTime")
+
+	node.FillBirthTime(fi)
+	if node.BirthTime.IsZero() {
+		t.Skip("birth time is not supported by the platform or filesystem")
+	}
+	rtest.Assert(t, node.BirthTime.After(start) && node.BirthTime.Before(time.Now().Add(time.Minute)),
+		"unexpected birth time %v", node.BirthTime)
+}
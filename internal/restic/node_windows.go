@@ -2,6 +2,7 @@ package restic
 
 import (
 	"syscall"
+	"time"
 
 	"github.com/restic/restic/internal/errors"
 )
@@ -83,3 +84,23 @@ func (s statT) ctim() syscall.Timespec {
 	// Windows does not have the concept of a "change time" in the sense Unix uses it, so we're using the LastWriteTime here.
 	return syscall.NsecToTimespec(s.LastWriteTime.Nanoseconds())
 }
+
+func birthTime(_ string, stat *statT) time.Time {
+	return time.Unix(0, stat.CreationTime.Nanoseconds())
+}
+
+func (node Node) restoreBirthTime(path string) error {
+	pathp, e := syscall.UTF16PtrFromString(path)
+	if e != nil {
+		return e
+	}
+	h, e := syscall.CreateFile(pathp,
+		syscall.FILE_WRITE_ATTRIBUTES, syscall.FILE_SHARE_WRITE, nil, syscall.OPEN_EXISTING,
+		syscall.FILE_FLAG_BACKUP_SEMANTICS|syscall.FILE_FLAG_OPEN_REPARSE_POINT, 0)
+	if e != nil {
+		return e
+	}
+	defer syscall.Close(h)
+	c := syscall.NsecToFiletime(node.BirthTime.UnixNano())
+	return syscall.SetFileTime(h, &c, nil, nil)
+}
@@ -0,0 +1,105 @@
+package restic
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+)
+
+// PathMapping replaces the path prefix Old with New.
+type PathMapping struct {
+	Old, New string
+}
+
+// PathMap maps the paths recorded in snapshots to different paths, for
+// example after the data was moved to another location. For each path, the
+// mapping with the longest matching prefix is applied.
+type PathMap []PathMapping
+
+// ParsePathMap parses mappings in the format "old:new". Both paths must be
+// absolute and must not contain "..".
+func ParsePathMap(specs []string) (PathMap, error) {
+	m := make(PathMap, 0, len(specs))
+	for _, spec := range specs {
+		i := splitPathMapping(spec)
+		if i < 0 {
+			return nil, errors.Errorf("invalid path mapping %q, expected old:new", spec)
+		}
+
+		oldPath, newPath := spec[:i], spec[i+1:]
+		if oldPath == "" || newPath == "" {
+			return nil, errors.Errorf("invalid path mapping %q, expected old:new", spec)
+		}
+		for _, p := range []string{oldPath, newPath} {
+			if err := checkMappedPath(p); err != nil {
+				return nil, errors.Errorf("invalid path mapping %q: %v", spec, err)
+			}
+		}
+		m = append(m, PathMapping{Old: filepath.Clean(oldPath), New: filepath.Clean(newPath)})
+	}
+	return m, nil
+}
+
+// checkMappedPath returns an error unless p is an absolute path without "..".
+// As the paths within a snapshot do not include a volume name, a path which
+// starts with a separator is also accepted on Windows.
+func checkMappedPath(p string) error {
+	for _, elem := range strings.Split(filepath.ToSlash(p), "/") {
+		if elem == ".." {
+			return errors.Errorf("path %q must not contain \"..\"", p)
+		}
+	}
+	if !filepath.IsAbs(p) && !strings.HasPrefix(filepath.ToSlash(p), "/") {
+		return errors.Errorf("path %q is not absolute", p)
+	}
+	return nil
+}
+
+// splitPathMapping returns the index of the colon which separates the old and
+// the new path in spec, or -1. The colon of a drive letter at the start of the
+// old path is not treated as separator.
+func splitPathMapping(spec string) int {
+	start := len(filepath.VolumeName(spec))
+	i := strings.Index(spec[start:], ":")
+	if i < 0 {
+		return -1
+	}
+	return start + i
+}
+
+// Apply returns p with the prefix of the best matching mapping replaced. If
+// no mapping matches, p is returned unchanged.
+func (m PathMap) Apply(p string) string {
+	best := -1
+	for i, mapping := range m {
+		if !fs.HasPathPrefix(mapping.Old, p) {
+			continue
+		}
+		if best < 0 || len(mapping.Old) > len(m[best].Old) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return p
+	}
+
+	rel, err := filepath.Rel(m[best].Old, p)
+	if err != nil {
+		return p
+	}
+	return filepath.Join(m[best].New, rel)
+}
+
+// ApplyAll returns a copy of paths with the mapping applied to each path.
+func (m PathMap) ApplyAll(paths []string) []string {
+	if len(m) == 0 {
+		return paths
+	}
+	res := make([]string, 0, len(paths))
+	for _, p := range paths {
+		res = append(res, m.Apply(p))
+	}
+	return res
+}
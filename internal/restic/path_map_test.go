@@ -0,0 +1,48 @@
+package restic
+
+import (
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestParsePathMap(t *testing.T) {
+	m, err := ParsePathMap([]string{"/home/user:/srv/user", "/data/:/mnt/data/"})
+	rtest.OK(t, err)
+	rtest.Equals(t, PathMap{
+		{Old: filepath.FromSlash("/home/user"), New: filepath.FromSlash("/srv/user")},
+		{Old: filepath.FromSlash("/data"), New: filepath.FromSlash("/mnt/data")},
+	}, m)
+
+	for _, spec := range []string{"", "/home/user", ":/srv", "/home:",
+		"home/user:/srv/user", "/home/user:srv/user", "./home:/srv",
+		"/home/../etc:/srv", "/home:/srv/..", "/home:/srv/../etc"} {
+		_, err := ParsePathMap([]string{spec})
+		rtest.Assert(t, err != nil, "expected error for %q", spec)
+	}
+}
+
+func TestPathMapApply(t *testing.T) {
+	m, err := ParsePathMap([]string{"/home:/srv/home", "/home/user/work:/work", "/:/old-root"})
+	rtest.OK(t, err)
+
+	for _, test := range []struct {
+		path, want string
+	}{
+		{"/home", "/srv/home"},
+		{"/home/other/file", "/srv/home/other/file"},
+		{"/home/user/work", "/work"},
+		{"/home/user/work/src/main.go", "/work/src/main.go"},
+		{"/home/user/workspace", "/srv/home/user/workspace"},
+		{"/etc/passwd", "/old-root/etc/passwd"},
+		{"relative/path", "relative/path"},
+	} {
+		rtest.Equals(t, filepath.FromSlash(test.want), m.Apply(filepath.FromSlash(test.path)))
+	}
+
+	var empty PathMap
+	rtest.Equals(t, "/home/user", empty.Apply("/home/user"))
+	rtest.Equals(t, []string{filepath.FromSlash("/srv/home/a"), filepath.FromSlash("/work")},
+		m.ApplyAll([]string{filepath.FromSlash("/home/a"), filepath.FromSlash("/home/user/work")}))
+}
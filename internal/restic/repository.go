@@ -31,6 +31,9 @@ type Repository interface {
 
 	Config() Config
 	PackSize() uint
+	// PackReadGap returns the maximum size of unneeded data between two blobs
+	// of a pack file that is read instead of sending a separate request
+	PackReadGap() uint
 
 	// List calls the function fn for each file of type t in the repository.
 	// When an error is returned by fn, processing stops and List() returns the
@@ -67,6 +70,8 @@ const (
 	SnapshotFile FileType = backend.SnapshotFile
 	IndexFile    FileType = backend.IndexFile
 	ConfigFile   FileType = backend.ConfigFile
+
+	SnapshotIndexFile FileType = backend.SnapshotIndexFile
 )
 
 // LoaderUnpacked allows loading a blob not stored in a pack file
@@ -1,9 +1,11 @@
 package restic
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os/user"
+	"path"
 	"path/filepath"
 	"sync"
 	"time"
@@ -25,11 +27,31 @@ type Snapshot struct {
 	Tags     []string  `json:"tags,omitempty"`
 	Original *ID       `json:"original,omitempty"`
 
-	ProgramVersion string `json:"program_version,omitempty"`
+	ProgramVersion string           `json:"program_version,omitempty"`
+	Summary        *SnapshotSummary `json:"summary,omitempty"`
 
 	id *ID // plaintext ID, used during restore
 }
 
+// SnapshotSummary contains statistics about the backup run which created a
+// snapshot. It is not set for snapshots created by older versions of restic.
+type SnapshotSummary struct {
+	BackupStart time.Time `json:"backup_start"`
+	BackupEnd   time.Time `json:"backup_end"`
+
+	TotalFilesProcessed uint   `json:"total_files_processed"`
+	TotalBytesProcessed uint64 `json:"total_bytes_processed"`
+
+	// operating system and architecture of the host running the backup
+	OS   string `json:"os,omitempty"`
+	Arch string `json:"arch,omitempty"`
+}
+
+// Duration returns how long the backup took.
+func (s *SnapshotSummary) Duration() time.Duration {
+	return s.BackupEnd.Sub(s.BackupStart)
+}
+
 // NewSnapshot returns an initialized snapshot struct for the current user and
 // time.
 func NewSnapshot(paths []string, tags []string, hostname string, time time.Time) (*Snapshot, error) {
@@ -80,6 +102,14 @@ func SaveSnapshot(ctx context.Context, repo SaverUnpacked, sn *Snapshot) (ID, er
 // also returns this error.
 // If a snapshot ID is in excludeIDs, it will be ignored.
 func ForAllSnapshots(ctx context.Context, be Lister, loader LoaderUnpacked, excludeIDs IDSet, fn func(ID, *Snapshot, error) error) error {
+	if repo, ok := loader.(snapshotIndexRepository); ok && repo.SnapshotIndexEnabled() {
+		indexed, _, err := snapshotIndexFor(ctx, be, repo, loader)
+		if err == nil {
+			return forAllSnapshotsIndexed(ctx, be, loader, indexed, excludeIDs, fn)
+		}
+		debug.Log("unable to load snapshot index, loading all snapshot files: %v", err)
+	}
+
 	var m sync.Mutex
 
 	// For most snapshots decoding is nearly for free, thus just assume were only limited by IO
@@ -211,8 +241,35 @@ func (sn *Snapshot) HasPaths(paths []string) bool {
 	return true
 }
 
+// HasPathPatterns returns true if each of the patterns matches at least one
+// path of the snapshot. A pattern matches a path if both are equal or if the
+// path matches the pattern according to filepath.Match.
+func (sn *Snapshot) HasPathPatterns(patterns []string) bool {
+	for _, pattern := range patterns {
+		if !sn.hasPathPattern(pattern) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (sn *Snapshot) hasPathPattern(pattern string) bool {
+	for _, snPath := range sn.Paths {
+		if snPath == pattern {
+			return true
+		}
+		// an invalid pattern can only match literally
+		if ok, err := filepath.Match(pattern, snPath); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
 // HasHostname returns true if either
 // - the snapshot hostname is in the list of the given hostnames, or
+// - the snapshot hostname matches one of the given patterns, or
 // - the list of given hostnames is empty
 func (sn *Snapshot) HasHostname(hostnames []string) bool {
 	if len(hostnames) == 0 {
@@ -223,6 +280,9 @@ func (sn *Snapshot) HasHostname(hostnames []string) bool {
 		if sn.Hostname == hostname {
 			return true
 		}
+		if ok, err := path.Match(hostname, sn.Hostname); err == nil && ok {
+			return true
+		}
 	}
 
 	return false
@@ -237,7 +297,12 @@ func (sn Snapshots) Len() int {
 }
 
 // Less returns true iff the ith snapshot has been made after the jth.
+// Snapshots with the same timestamp are ordered by their ID, such that the
+// order does not depend on the order in which the snapshots were loaded.
 func (sn Snapshots) Less(i, j int) bool {
+	if sn[i].Time.Equal(sn[j].Time) && sn[i].id != nil && sn[j].id != nil {
+		return bytes.Compare(sn[i].id[:], sn[j].id[:]) < 0
+	}
 	return sn[i].Time.After(sn[j].Time)
 }
 
@@ -20,16 +20,26 @@ type SnapshotFilter struct {
 	Hosts []string
 	Tags  TagLists
 	Paths []string
+	// Match snapshots created in the time range from Since to Until, both
+	// inclusive. Zero for no limit.
+	Since, Until time.Time
 	// Match snapshots from before this timestamp. Zero for no limit.
 	TimestampLimit time.Time
 }
 
-func (f *SnapshotFilter) empty() bool {
-	return len(f.Hosts)+len(f.Tags)+len(f.Paths) == 0
+// Empty returns true if the filter matches all snapshots.
+func (f *SnapshotFilter) Empty() bool {
+	return len(f.Hosts)+len(f.Tags)+len(f.Paths) == 0 && f.Since.IsZero() && f.Until.IsZero()
 }
 
 func (f *SnapshotFilter) matches(sn *Snapshot) bool {
-	return sn.HasHostname(f.Hosts) && sn.HasTagList(f.Tags) && sn.HasPaths(f.Paths)
+	if !f.Since.IsZero() && sn.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && sn.Time.After(f.Until) {
+		return false
+	}
+	return sn.HasHostname(f.Hosts) && sn.HasTagList(f.Tags) && sn.HasPathPatterns(f.Paths)
 }
 
 // findLatest finds the latest snapshot with optional target/directory,
@@ -83,6 +93,54 @@ func (f *SnapshotFilter) findLatest(ctx context.Context, be Lister, loader Loade
 	return latest, nil
 }
 
+// FindLatestInGroup returns the latest snapshot which belongs to the same group
+// as sn according to groupBy and which is not newer than timestampLimit. The
+// groups are determined in the same way as by GroupSnapshots, that is hostname,
+// paths and tags must match exactly. If timestampLimit is zero, snapshots are
+// not filtered by time.
+func FindLatestInGroup(ctx context.Context, be Lister, loader LoaderUnpacked, sn *Snapshot, groupBy SnapshotGroupByOptions, timestampLimit time.Time) (*Snapshot, error) {
+	key, err := snapshotGroupKey(sn, groupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *Snapshot
+	err = ForAllSnapshots(ctx, be, loader, nil, func(id ID, snapshot *Snapshot, err error) error {
+		if err != nil {
+			return errors.Errorf("Error loading snapshot %v: %v", id.Str(), err)
+		}
+
+		if !timestampLimit.IsZero() && snapshot.Time.After(timestampLimit) {
+			return nil
+		}
+
+		if latest != nil && snapshot.Time.Before(latest.Time) {
+			return nil
+		}
+
+		k, err := snapshotGroupKey(snapshot, groupBy)
+		if err != nil {
+			return err
+		}
+		if k != key {
+			return nil
+		}
+
+		latest = snapshot
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if latest == nil {
+		return nil, ErrNoSnapshotFound
+	}
+
+	return latest, nil
+}
+
 func splitSnapshotID(s string) (id, subfolder string) {
 	id, subfolder, _ = strings.Cut(s, ":")
 	return
@@ -173,7 +231,7 @@ func (f *SnapshotFilter) FindAll(ctx context.Context, be Lister, loader LoaderUn
 		}
 
 		// Give the user some indication their filters are not used.
-		if !usedFilter && !f.empty() {
+		if !usedFilter && !f.Empty() {
 			return fn("filters", nil, errors.Errorf("explicit snapshot ids are given"))
 		}
 		return nil
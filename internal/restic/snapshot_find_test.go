@@ -45,6 +45,27 @@ func TestFindLatestSnapshotWithMaxTimestamp(t *testing.T) {
 	}
 }
 
+func TestFindAllTimeRange(t *testing.T) {
+	repo := repository.TestRepository(t)
+	restic.TestCreateSnapshot(t, repo, parseTimeUTC("2015-05-05 05:05:05"), 1)
+	desiredSnapshot := restic.TestCreateSnapshot(t, repo, parseTimeUTC("2017-07-07 07:07:07"), 1)
+	restic.TestCreateSnapshot(t, repo, parseTimeUTC("2019-09-09 09:09:09"), 1)
+
+	var found restic.IDs
+	err := (&restic.SnapshotFilter{
+		Since: parseTimeUTC("2016-06-06 06:06:06"),
+		Until: parseTimeUTC("2017-07-07 07:07:07"),
+	}).FindAll(context.TODO(), repo, repo, nil, func(id string, sn *restic.Snapshot, err error) error {
+		if err != nil {
+			return err
+		}
+		found = append(found, *sn.ID())
+		return nil
+	})
+	test.OK(t, err)
+	test.Equals(t, restic.IDs{*desiredSnapshot.ID()}, found)
+}
+
 func TestFindLatestWithSubpath(t *testing.T) {
 	repo := repository.TestRepository(t)
 	restic.TestCreateSnapshot(t, repo, parseTimeUTC("2015-05-05 05:05:05"), 1)
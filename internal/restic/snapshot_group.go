@@ -66,6 +66,38 @@ type SnapshotGroupKey struct {
 	Tags     []string `json:"tags"`
 }
 
+// snapshotGroupKey returns the key of the group the snapshot belongs to.
+func snapshotGroupKey(sn *Snapshot, groupBy SnapshotGroupByOptions) (string, error) {
+	// Determining grouping-keys
+	var tags []string
+	var hostname string
+	var paths []string
+
+	if groupBy.Tag {
+		tags = sn.Tags
+		sort.Strings(tags)
+	}
+	if groupBy.Host {
+		hostname = sn.Hostname
+	}
+	if groupBy.Path {
+		paths = sn.Paths
+	}
+
+	sort.Strings(sn.Paths)
+
+	// snapshots without tags or paths store either null or an empty list
+	if len(tags) == 0 {
+		tags = nil
+	}
+	if len(paths) == 0 {
+		paths = nil
+	}
+
+	k, err := json.Marshal(SnapshotGroupKey{Tags: tags, Hostname: hostname, Paths: paths})
+	return string(k), err
+}
+
 // GroupSnapshots takes a list of snapshots and a grouping criteria and creates
 // a grouped list of snapshots.
 func GroupSnapshots(snapshots Snapshots, groupBy SnapshotGroupByOptions) (map[string]Snapshots, bool, error) {
@@ -73,33 +105,23 @@ func GroupSnapshots(snapshots Snapshots, groupBy SnapshotGroupByOptions) (map[st
 	snapshotGroups := make(map[string]Snapshots)
 
 	for _, sn := range snapshots {
-		// Determining grouping-keys
-		var tags []string
-		var hostname string
-		var paths []string
-
-		if groupBy.Tag {
-			tags = sn.Tags
-			sort.Strings(tags)
-		}
-		if groupBy.Host {
-			hostname = sn.Hostname
-		}
-		if groupBy.Path {
-			paths = sn.Paths
-		}
-
-		sort.Strings(sn.Paths)
-		var k []byte
-		var err error
-
-		k, err = json.Marshal(SnapshotGroupKey{Tags: tags, Hostname: hostname, Paths: paths})
-
+		k, err := snapshotGroupKey(sn, groupBy)
 		if err != nil {
 			return nil, false, err
 		}
-		snapshotGroups[string(k)] = append(snapshotGroups[string(k)], sn)
+		snapshotGroups[k] = append(snapshotGroups[k], sn)
 	}
 
 	return snapshotGroups, groupBy.Tag || groupBy.Host || groupBy.Path, nil
 }
+
+// SortedGroupKeys returns the keys of the groups returned by GroupSnapshots in
+// a stable order, such that all commands list the groups in the same order.
+func SortedGroupKeys(groups map[string]Snapshots) []string {
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -48,3 +48,20 @@ func TestGroupByOptions(t *testing.T) {
 	test.Assert(t, err != nil, "missing error on invalid tags")
 	test.Assert(t, !opts.Host && !opts.Path && !opts.Tag, "unexpected opts %s %s %s", opts.Host, opts.Path, opts.Tag)
 }
+
+func TestSortedGroupKeys(t *testing.T) {
+	var snapshots restic.Snapshots
+	for _, host := range []string{"foo", "bar", "baz", "bar"} {
+		snapshots = append(snapshots, &restic.Snapshot{Hostname: host})
+	}
+
+	groups, grouped, err := restic.GroupSnapshots(snapshots, restic.SnapshotGroupByOptions{Host: true})
+	test.OK(t, err)
+	test.Assert(t, grouped, "snapshots are not grouped")
+
+	var hosts []string
+	for _, k := range restic.SortedGroupKeys(groups) {
+		hosts = append(hosts, groups[k][0].Hostname)
+	}
+	test.Equals(t, []string{"bar", "baz", "foo"}, hosts)
+}
@@ -0,0 +1,208 @@
+package restic
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+)
+
+// A snapshot index file contains the snapshots of a repository at the time it
+// was written. It allows listing the snapshots without loading each snapshot
+// file. Snapshot index files are never modified: each update adds a new file
+// containing all snapshots and afterwards removes the files it replaces. Thus
+// concurrent updates never lose snapshots, at worst several snapshot index
+// files exist until the next update.
+//
+// Readers merge all snapshot index files and only use the entries of snapshots
+// which are still listed in the repository. Snapshots which are not contained
+// in any snapshot index file are loaded from their snapshot file.
+type snapshotIndex struct {
+	Snapshots []snapshotIndexEntry `json:"snapshots"`
+}
+
+type snapshotIndexEntry struct {
+	ID       ID        `json:"id"`
+	Snapshot *Snapshot `json:"snapshot"`
+}
+
+// snapshotIndexRepository is implemented by repositories which can be
+// configured to use the snapshot index.
+type snapshotIndexRepository interface {
+	Lister
+	LoaderUnpacked
+	SnapshotIndexEnabled() bool
+}
+
+// memorizedSnapshotIndex is the snapshot index loaded along with a memorized
+// listing of the snapshot files.
+type memorizedSnapshotIndex struct {
+	snapshots map[ID]*Snapshot
+	files     IDs
+	err       error
+}
+
+// memorizeSnapshotIndex loads the snapshot index if be is a repository which
+// uses it. Otherwise nil is returned.
+func memorizeSnapshotIndex(ctx context.Context, be Lister) *memorizedSnapshotIndex {
+	repo, ok := be.(snapshotIndexRepository)
+	if !ok || !repo.SnapshotIndexEnabled() {
+		return nil
+	}
+	snapshots, files, err := loadSnapshotIndex(ctx, repo, repo)
+	return &memorizedSnapshotIndex{snapshots: snapshots, files: files, err: err}
+}
+
+// snapshotIndexFor returns the snapshot index memorized along with the
+// snapshot listing be, such that the snapshot index files are not listed
+// again. Otherwise the snapshot index files are listed using repo.
+func snapshotIndexFor(ctx context.Context, be Lister, repo Lister, loader LoaderUnpacked) (map[ID]*Snapshot, IDs, error) {
+	if ml, ok := be.(*memorizedLister); ok && ml.snapshotIndex != nil {
+		return ml.snapshotIndex.snapshots, ml.snapshotIndex.files, ml.snapshotIndex.err
+	}
+	return loadSnapshotIndex(ctx, repo, loader)
+}
+
+// loadSnapshotIndex loads all snapshot index files and returns the contained
+// snapshots along with the IDs of the snapshot index files. Damaged snapshot
+// index files are ignored.
+func loadSnapshotIndex(ctx context.Context, be Lister, loader LoaderUnpacked) (map[ID]*Snapshot, IDs, error) {
+	snapshots := make(map[ID]*Snapshot)
+	var files IDs
+	var m sync.Mutex
+
+	err := ParallelList(ctx, be, SnapshotIndexFile, loader.Connections(), func(ctx context.Context, id ID, _ int64) error {
+		var idx snapshotIndex
+		err := LoadJSONUnpacked(ctx, loader, SnapshotIndexFile, id, &idx)
+
+		m.Lock()
+		defer m.Unlock()
+		files = append(files, id)
+		if err != nil {
+			debug.Log("ignoring snapshot index %v: %v", id, err)
+			return nil
+		}
+		for _, entry := range idx.Snapshots {
+			if entry.Snapshot != nil {
+				snapshots[entry.ID] = entry.Snapshot
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return snapshots, files, nil
+}
+
+// forAllSnapshotsIndexed is like ForAllSnapshots, but uses the snapshots from
+// indexed instead of loading their snapshot file.
+func forAllSnapshotsIndexed(ctx context.Context, be Lister, loader LoaderUnpacked, indexed map[ID]*Snapshot, excludeIDs IDSet, fn func(ID, *Snapshot, error) error) error {
+	var m sync.Mutex
+
+	return ParallelList(ctx, be, SnapshotFile, loader.Connections(), func(ctx context.Context, id ID, size int64) error {
+		if excludeIDs.Has(id) {
+			return nil
+		}
+
+		sn, ok := indexed[id]
+		var err error
+		if ok {
+			// the caller may modify the snapshot
+			indexedSn := *sn
+			indexedSn.id = &id
+			sn = &indexedSn
+		} else {
+			debug.Log("snapshot %v is not indexed", id)
+			sn, err = LoadSnapshot(ctx, loader, id)
+		}
+
+		m.Lock()
+		defer m.Unlock()
+		return fn(id, sn, err)
+	})
+}
+
+// UpdateSnapshotIndex replaces all snapshot index files with a single one
+// which contains all snapshots of the repository. be lists the snapshot files,
+// it can be a listing returned by MemorizeList before the snapshots listed in
+// added were saved and those in removed were deleted, null IDs in added are
+// ignored. Snapshots missing from
+// the existing snapshot index files are loaded from their snapshot file,
+// damaged snapshots are not added. If the snapshot index is missing, it is
+// created.
+func UpdateSnapshotIndex(ctx context.Context, repo Repository, be Lister, added IDs, removed IDSet) error {
+	indexed, files, err := snapshotIndexFor(ctx, be, repo, repo)
+	if err != nil {
+		return err
+	}
+
+	var idx snapshotIndex
+	upToDate := len(files) == 1
+	seen := NewIDSet()
+	addSnapshot := func(id ID, sn *Snapshot, err error) error {
+		if seen.Has(id) {
+			return nil
+		}
+		seen.Insert(id)
+		if err != nil {
+			debug.Log("not adding damaged snapshot %v to the snapshot index: %v", id, err)
+			upToDate = false
+			return nil
+		}
+		if _, ok := indexed[id]; !ok {
+			upToDate = false
+		}
+		idx.Snapshots = append(idx.Snapshots, snapshotIndexEntry{ID: id, Snapshot: sn})
+		return nil
+	}
+
+	err = forAllSnapshotsIndexed(ctx, be, repo, indexed, removed, addSnapshot)
+	if err != nil {
+		return err
+	}
+	for _, id := range added {
+		if id.IsNull() || removed.Has(id) || seen.Has(id) {
+			continue
+		}
+		sn, ok := indexed[id]
+		if !ok {
+			sn, err = LoadSnapshot(ctx, repo, id)
+		}
+		err = addSnapshot(id, sn, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	if upToDate && len(idx.Snapshots) == len(indexed) {
+		debug.Log("snapshot index %v is up to date", files[0])
+		return nil
+	}
+
+	sort.Slice(idx.Snapshots, func(i, j int) bool {
+		return bytes.Compare(idx.Snapshots[i].ID[:], idx.Snapshots[j].ID[:]) < 0
+	})
+
+	id, err := SaveJSONUnpacked(ctx, repo, SnapshotIndexFile, &idx)
+	if err != nil {
+		return err
+	}
+	debug.Log("saved snapshot index %v with %d snapshots", id, len(idx.Snapshots))
+
+	for _, file := range files {
+		if file.Equal(id) {
+			continue
+		}
+		err := repo.Backend().Remove(ctx, backend.Handle{Type: SnapshotIndexFile, Name: file.String()})
+		if err != nil && !repo.Backend().IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
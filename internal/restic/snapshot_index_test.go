@@ -0,0 +1,166 @@
+package restic_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// snapshotIndexRepo enables the snapshot index and counts how many snapshot
+// files are loaded. If listed is set, listing files fails.
+type snapshotIndexRepo struct {
+	restic.Repository
+
+	m               sync.Mutex
+	loadedSnapshots int
+	listed          bool
+}
+
+func (r *snapshotIndexRepo) List(ctx context.Context, t restic.FileType, fn func(restic.ID, int64) error) error {
+	if r.listed {
+		return errors.Errorf("tried listing type %v again", t)
+	}
+	return r.Repository.List(ctx, t, fn)
+}
+
+func (r *snapshotIndexRepo) SnapshotIndexEnabled() bool {
+	return true
+}
+
+func (r *snapshotIndexRepo) LoadUnpacked(ctx context.Context, t restic.FileType, id restic.ID) ([]byte, error) {
+	if t == restic.SnapshotFile {
+		r.m.Lock()
+		r.loadedSnapshots++
+		r.m.Unlock()
+	}
+	return r.Repository.LoadUnpacked(ctx, t, id)
+}
+
+func listSnapshotsIndexed(t *testing.T, repo *snapshotIndexRepo) (restic.IDSet, int) {
+	repo.loadedSnapshots = 0
+	ids := restic.NewIDSet()
+	rtest.OK(t, restic.ForAllSnapshots(context.TODO(), repo, repo, nil, func(id restic.ID, sn *restic.Snapshot, err error) error {
+		rtest.OK(t, err)
+		rtest.Equals(t, id, *sn.ID())
+		ids.Insert(id)
+		return nil
+	}))
+	return ids, repo.loadedSnapshots
+}
+
+func listSnapshotIndexFiles(t *testing.T, repo restic.Repository) restic.IDs {
+	var ids restic.IDs
+	rtest.OK(t, repo.List(context.TODO(), restic.SnapshotIndexFile, func(id restic.ID, _ int64) error {
+		ids = append(ids, id)
+		return nil
+	}))
+	return ids
+}
+
+func TestSnapshotIndex(t *testing.T) {
+	repo := &snapshotIndexRepo{Repository: repository.TestRepository(t)}
+	want := restic.NewIDSet()
+	for _, ts := range []string{"2015-05-05 05:05:05", "2017-07-07 07:07:07", "2019-09-09 09:09:09"} {
+		want.Insert(*restic.TestCreateSnapshot(t, repo, parseTimeUTC(ts), 1).ID())
+	}
+
+	// without a snapshot index, all snapshot files are loaded
+	ids, loaded := listSnapshotsIndexed(t, repo)
+	rtest.Equals(t, want, ids)
+	rtest.Equals(t, 3, loaded)
+
+	rtest.OK(t, restic.UpdateSnapshotIndex(context.TODO(), repo, repo, nil, nil))
+	indexFiles := listSnapshotIndexFiles(t, repo)
+	rtest.Equals(t, 1, len(indexFiles))
+	ids, loaded = listSnapshotsIndexed(t, repo)
+	rtest.Equals(t, want, ids)
+	rtest.Equals(t, 0, loaded)
+
+	// an up to date snapshot index is not rewritten
+	rtest.OK(t, restic.UpdateSnapshotIndex(context.TODO(), repo, repo, nil, nil))
+	rtest.Equals(t, indexFiles, listSnapshotIndexFiles(t, repo))
+
+	// snapshots missing from the index are loaded from their file, removed
+	// snapshots are ignored
+	added := restic.TestCreateSnapshot(t, repo, parseTimeUTC("2021-01-01 01:01:01"), 1)
+	want.Insert(*added.ID())
+	for id := range want {
+		if !id.Equal(*added.ID()) {
+			rtest.OK(t, repo.Backend().Remove(context.TODO(), backend.Handle{Type: restic.SnapshotFile, Name: id.String()}))
+			want.Delete(id)
+			break
+		}
+	}
+	ids, loaded = listSnapshotsIndexed(t, repo)
+	rtest.Equals(t, want, ids)
+	rtest.Equals(t, 1, loaded)
+
+	// the update replaces the old snapshot index
+	rtest.OK(t, restic.UpdateSnapshotIndex(context.TODO(), repo, repo, nil, nil))
+	newIndexFiles := listSnapshotIndexFiles(t, repo)
+	rtest.Equals(t, 1, len(newIndexFiles))
+	rtest.Assert(t, !newIndexFiles[0].Equal(indexFiles[0]), "snapshot index was not replaced")
+	ids, loaded = listSnapshotsIndexed(t, repo)
+	rtest.Equals(t, want, ids)
+	rtest.Equals(t, 0, loaded)
+}
+
+func TestSnapshotIndexDamaged(t *testing.T) {
+	repo := &snapshotIndexRepo{Repository: repository.TestRepository(t)}
+	want := restic.NewIDSet(*restic.TestCreateSnapshot(t, repo, parseTimeUTC("2015-05-05 05:05:05"), 1).ID())
+
+	// a damaged snapshot index is ignored and replaced by the next update
+	h := backend.Handle{Type: restic.SnapshotIndexFile, Name: restic.NewRandomID().String()}
+	rtest.OK(t, repo.Backend().Save(context.TODO(), h, backend.NewByteReader([]byte("damaged"), repo.Backend().Hasher())))
+
+	ids, loaded := listSnapshotsIndexed(t, repo)
+	rtest.Equals(t, want, ids)
+	rtest.Equals(t, 1, loaded)
+
+	rtest.OK(t, restic.UpdateSnapshotIndex(context.TODO(), repo, repo, nil, nil))
+	indexFiles := listSnapshotIndexFiles(t, repo)
+	rtest.Equals(t, 1, len(indexFiles))
+	rtest.Assert(t, indexFiles[0].String() != h.Name, "damaged snapshot index was not removed")
+
+	ids, loaded = listSnapshotsIndexed(t, repo)
+	rtest.Equals(t, want, ids)
+	rtest.Equals(t, 0, loaded)
+}
+
+func TestSnapshotIndexMemorizedList(t *testing.T) {
+	repo := &snapshotIndexRepo{Repository: repository.TestRepository(t)}
+	removed := restic.TestCreateSnapshot(t, repo, parseTimeUTC("2015-05-05 05:05:05"), 1)
+	kept := restic.TestCreateSnapshot(t, repo, parseTimeUTC("2017-07-07 07:07:07"), 1)
+	rtest.OK(t, restic.UpdateSnapshotIndex(context.TODO(), repo, repo, nil, nil))
+
+	// the snapshot index is loaded along with the memorized listing
+	snapshotLister, err := restic.MemorizeList(context.TODO(), repo, restic.SnapshotFile)
+	rtest.OK(t, err)
+	repo.listed = true
+
+	repo.loadedSnapshots = 0
+	ids := restic.NewIDSet()
+	rtest.OK(t, restic.ForAllSnapshots(context.TODO(), snapshotLister, repo, nil, func(id restic.ID, sn *restic.Snapshot, err error) error {
+		ids.Insert(id)
+		return err
+	}))
+	rtest.Equals(t, restic.NewIDSet(*removed.ID(), *kept.ID()), ids)
+	rtest.Equals(t, 0, repo.loadedSnapshots)
+
+	// the update reuses the listing and applies the changes made since
+	added := restic.TestCreateSnapshot(t, repo, parseTimeUTC("2019-09-09 09:09:09"), 1)
+	rtest.OK(t, repo.Backend().Remove(context.TODO(), backend.Handle{Type: restic.SnapshotFile, Name: removed.ID().String()}))
+	rtest.OK(t, restic.UpdateSnapshotIndex(context.TODO(), repo, snapshotLister, restic.IDs{*added.ID()}, restic.NewIDSet(*removed.ID())))
+
+	repo.listed = false
+	ids, loaded := listSnapshotsIndexed(t, repo)
+	rtest.Equals(t, restic.NewIDSet(*kept.ID(), *added.ID()), ids)
+	rtest.Equals(t, 0, loaded)
+	rtest.Equals(t, 1, len(listSnapshotIndexFiles(t, repo)))
+}
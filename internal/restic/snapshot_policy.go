@@ -25,6 +25,17 @@ type ExpirePolicy struct {
 	WithinMonthly Duration  // keep monthly snapshots made within this duration
 	WithinYearly  Duration  // keep yearly snapshots made within this duration
 	Tags          []TagList // keep all snapshots that include at least one of the tag lists.
+	LastPerTag    int       // keep the last n snapshots for each tag
+
+	// RemoveTags lists snapshots which are removed regardless of the count
+	// and duration based rules, as long as they are not kept by Tags or Last.
+	RemoveTags []TagList
+	// ForceRemove also removes snapshots matching RemoveTags that are kept by Last.
+	ForceRemove bool
+
+	// MinInterval thins out the kept snapshots such that they are at least
+	// this far apart. Snapshots kept by Last or Tags are never removed.
+	MinInterval Duration
 }
 
 func (e ExpirePolicy) String() (s string) {
@@ -80,6 +91,18 @@ func (e ExpirePolicy) String() (s string) {
 		s += strings.Join(keepw, ", ")
 	}
 
+	if e.LastPerTag > 0 {
+		if s != "" {
+			s += ", "
+		}
+		s += fmt.Sprintf("%d latest snapshots per tag", e.LastPerTag)
+	} else if e.LastPerTag == -1 {
+		if s != "" {
+			s += ", "
+		}
+		s += "all tagged snapshots"
+	}
+
 	if len(e.Tags) > 0 {
 		if s != "" {
 			s += " and "
@@ -94,18 +117,40 @@ func (e ExpirePolicy) String() (s string) {
 		s += fmt.Sprintf("all snapshots within %s of the newest", e.Within)
 	}
 
+	if s == "" {
+		s = "all snapshots"
+	}
+
 	s = "keep " + s
 
+	if !e.MinInterval.Zero() {
+		s += fmt.Sprintf(" at least %v apart", e.MinInterval)
+	}
+
+	if len(e.RemoveTags) > 0 {
+		s += fmt.Sprintf(", remove all snapshots with tags %s", e.RemoveTags)
+	}
+
 	return s
 }
 
 // Empty returns true if no policy has been configured (all values zero).
 func (e ExpirePolicy) Empty() bool {
+	if len(e.RemoveTags) != 0 || !e.MinInterval.Zero() {
+		return false
+	}
+
+	return e.keepRulesEmpty()
+}
+
+// keepRulesEmpty returns true if no rules to keep snapshots have been
+// configured, ignoring RemoveTags and MinInterval.
+func (e ExpirePolicy) keepRulesEmpty() bool {
 	if len(e.Tags) != 0 {
 		return false
 	}
 
-	empty := ExpirePolicy{Tags: e.Tags}
+	empty := ExpirePolicy{Tags: e.Tags, RemoveTags: e.RemoveTags, ForceRemove: e.ForceRemove, MinInterval: e.MinInterval}
 	return reflect.DeepEqual(e, empty)
 }
 
@@ -230,6 +275,11 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 	}
 
 	latest := findLatestTimestamp(list)
+	keepUnmatched := p.keepRulesEmpty()
+	// number of snapshots kept so far for each tag by LastPerTag
+	keptPerTag := make(map[string]int)
+	// snapshots which must not be removed by MinInterval
+	pinned := make(map[*Snapshot]struct{})
 
 	for nr, cur := range list {
 		var keepSnap bool
@@ -242,9 +292,27 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 				keepSnapReasons = append(keepSnapReasons, fmt.Sprintf("has tags %v", l))
 			}
 		}
+		pinnedSnap := keepSnap
+
+		// Snapshots with one of the remove tags are skipped by all other rules
+		// except for keep-last, unless ForceRemove is set. Snapshots which are
+		// kept by their tags are never removed.
+		var removeSnap bool
+		if !keepSnap {
+			for _, l := range p.RemoveTags {
+				if cur.HasTags(l) {
+					removeSnap = true
+				}
+			}
+		}
+
+		if keepUnmatched && !removeSnap {
+			keepSnap = true
+			keepSnapReasons = append(keepSnapReasons, "not tagged for removal")
+		}
 
 		// If the timestamp of the snapshot is within the range, then keep it.
-		if !p.Within.Zero() {
+		if !p.Within.Zero() && !removeSnap {
 			t := latest.AddDate(-p.Within.Years, -p.Within.Months, -p.Within.Days).Add(time.Hour * time.Duration(-p.Within.Hours))
 			if cur.Time.After(t) {
 				keepSnap = true
@@ -254,6 +322,9 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 
 		// Now update the other buckets and see if they have some counts left.
 		for i, b := range buckets {
+			if removeSnap && (i > 0 || p.ForceRemove) {
+				continue
+			}
 			// -1 means "keep all"
 			if b.Count > 0 || b.Count == -1 {
 				val := b.bucker(cur.Time, nr)
@@ -267,13 +338,28 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 						buckets[i].Count--
 					}
 					keepSnapReasons = append(keepSnapReasons, b.reason)
+					if i == 0 {
+						pinnedSnap = true
+					}
+				}
+			}
+		}
+
+		// Keep the newest snapshots for each of the tags. In contrast to Tags,
+		// this only keeps a limited number of snapshots per tag.
+		if p.LastPerTag != 0 && !removeSnap {
+			for _, tag := range TagList(cur.Tags).Unique() {
+				if p.LastPerTag == -1 || keptPerTag[tag] < p.LastPerTag {
+					keptPerTag[tag]++
+					keepSnap = true
+					keepSnapReasons = append(keepSnapReasons, fmt.Sprintf("last snapshot for tag %v", tag))
 				}
 			}
 		}
 
 		// If the timestamp is within range, and the snapshot is an hourly/daily/weekly/monthly/yearly snapshot, then keep it
 		for i, b := range bucketsWithin {
-			if !b.Within.Zero() {
+			if !b.Within.Zero() && !removeSnap {
 				t := latest.AddDate(-b.Within.Years, -b.Within.Months, -b.Within.Days).Add(time.Hour * time.Duration(-b.Within.Hours))
 
 				if cur.Time.After(t) {
@@ -289,6 +375,9 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 		}
 
 		if keepSnap {
+			if pinnedSnap {
+				pinned[cur] = struct{}{}
+			}
 			keep = append(keep, cur)
 			kr := KeepReason{
 				Snapshot: cur,
@@ -306,5 +395,42 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 		}
 	}
 
+	if !p.MinInterval.Zero() {
+		keep, remove, reasons = applyMinInterval(keep, remove, reasons, p.MinInterval, pinned)
+	}
+
 	return keep, remove, reasons
 }
+
+// applyMinInterval removes snapshots from keep which were created less than
+// interval after the previous kept snapshot, starting with the oldest one.
+// Thus the earliest snapshot in each interval is kept. Snapshots in pinned are
+// always kept. keep and reasons must be sorted newest first.
+func applyMinInterval(keep, remove Snapshots, reasons []KeepReason, interval Duration, pinned map[*Snapshot]struct{}) (Snapshots, Snapshots, []KeepReason) {
+	var newKeep Snapshots
+	var newReasons []KeepReason
+	var last time.Time
+
+	for i := len(keep) - 1; i >= 0; i-- {
+		sn := keep[i]
+		next := last.AddDate(interval.Years, interval.Months, interval.Days).Add(time.Hour * time.Duration(interval.Hours))
+		_, isPinned := pinned[sn]
+		if last.IsZero() || !sn.Time.Before(next) || isPinned {
+			last = sn.Time
+			newKeep = append(newKeep, sn)
+			newReasons = append(newReasons, reasons[i])
+			continue
+		}
+		debug.Log("remove %v %v, less than %v after %v", sn.Time, sn.id.Str(), interval, last)
+		remove = append(remove, sn)
+	}
+
+	// restore the order newest first
+	for i, j := 0, len(newKeep)-1; i < j; i, j = i+1, j-1 {
+		newKeep[i], newKeep[j] = newKeep[j], newKeep[i]
+		newReasons[i], newReasons[j] = newReasons[j], newReasons[i]
+	}
+	sort.Stable(remove)
+
+	return newKeep, remove, newReasons
+}
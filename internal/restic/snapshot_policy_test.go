@@ -91,113 +91,114 @@ func saveGoldenFile(t testing.TB, filename string, keep restic.Snapshots, reason
 	}
 }
 
-func TestApplyPolicy(t *testing.T) {
-	var testExpireSnapshots = restic.Snapshots{
-		{Time: parseTimeUTC("2014-09-01 10:20:30")},
-		{Time: parseTimeUTC("2014-09-02 10:20:30")},
-		{Time: parseTimeUTC("2014-09-05 10:20:30")},
-		{Time: parseTimeUTC("2014-09-06 10:20:30")},
-		{Time: parseTimeUTC("2014-09-08 10:20:30")},
-		{Time: parseTimeUTC("2014-09-09 10:20:30")},
-		{Time: parseTimeUTC("2014-09-10 10:20:30")},
-		{Time: parseTimeUTC("2014-09-11 10:20:30")},
-		{Time: parseTimeUTC("2014-09-20 10:20:30")},
-		{Time: parseTimeUTC("2014-09-22 10:20:30")},
-		{Time: parseTimeUTC("2014-08-08 10:20:30")},
-		{Time: parseTimeUTC("2014-08-10 10:20:30")},
-		{Time: parseTimeUTC("2014-08-12 10:20:30")},
-		{Time: parseTimeUTC("2014-08-13 10:20:30")},
-		{Time: parseTimeUTC("2014-08-13 10:20:30.1")},
-		{Time: parseTimeUTC("2014-08-15 10:20:30")},
-		{Time: parseTimeUTC("2014-08-18 10:20:30")},
-		{Time: parseTimeUTC("2014-08-20 10:20:30")},
-		{Time: parseTimeUTC("2014-08-21 10:20:30")},
-		{Time: parseTimeUTC("2014-08-22 10:20:30")},
-		{Time: parseTimeUTC("2014-10-01 10:20:30"), Tags: []string{"foo"}},
-		{Time: parseTimeUTC("2014-10-02 10:20:30"), Tags: []string{"foo"}},
-		{Time: parseTimeUTC("2014-10-05 10:20:30"), Tags: []string{"foo"}},
-		{Time: parseTimeUTC("2014-10-06 10:20:30"), Tags: []string{"foo"}},
-		{Time: parseTimeUTC("2014-10-08 10:20:30"), Tags: []string{"foo"}},
-		{Time: parseTimeUTC("2014-10-09 10:20:30"), Tags: []string{"foo"}},
-		{Time: parseTimeUTC("2014-10-10 10:20:30"), Tags: []string{"foo"}},
-		{Time: parseTimeUTC("2014-10-11 10:20:30"), Tags: []string{"foo"}},
-		{Time: parseTimeUTC("2014-10-20 10:20:30"), Tags: []string{"foo"}},
-		{Time: parseTimeUTC("2014-10-22 10:20:30"), Tags: []string{"foo"}},
-		{Time: parseTimeUTC("2014-11-08 10:20:30"), Tags: []string{"foo"}},
-		{Time: parseTimeUTC("2014-11-10 10:20:30"), Tags: []string{"foo"}},
-		{Time: parseTimeUTC("2014-11-12 10:20:30"), Tags: []string{"foo"}},
-		{Time: parseTimeUTC("2014-11-13 10:20:30"), Tags: []string{"foo"}},
-		{Time: parseTimeUTC("2014-11-13 10:20:30.1"), Tags: []string{"bar"}},
-		{Time: parseTimeUTC("2014-11-15 10:20:30"), Tags: []string{"foo", "bar"}},
-		{Time: parseTimeUTC("2014-11-18 10:20:30")},
-		{Time: parseTimeUTC("2014-11-20 10:20:30")},
-		{Time: parseTimeUTC("2014-11-21 10:20:30")},
-		{Time: parseTimeUTC("2014-11-22 10:20:30")},
-		{Time: parseTimeUTC("2015-09-01 10:20:30")},
-		{Time: parseTimeUTC("2015-09-02 10:20:30")},
-		{Time: parseTimeUTC("2015-09-05 10:20:30")},
-		{Time: parseTimeUTC("2015-09-06 10:20:30")},
-		{Time: parseTimeUTC("2015-09-08 10:20:30")},
-		{Time: parseTimeUTC("2015-09-09 10:20:30")},
-		{Time: parseTimeUTC("2015-09-10 10:20:30")},
-		{Time: parseTimeUTC("2015-09-11 10:20:30")},
-		{Time: parseTimeUTC("2015-09-20 10:20:30")},
-		{Time: parseTimeUTC("2015-09-22 10:20:30")},
-		{Time: parseTimeUTC("2015-08-08 10:20:30")},
-		{Time: parseTimeUTC("2015-08-10 10:20:30")},
-		{Time: parseTimeUTC("2015-08-12 10:20:30")},
-		{Time: parseTimeUTC("2015-08-13 10:20:30")},
-		{Time: parseTimeUTC("2015-08-13 10:20:30.1")},
-		{Time: parseTimeUTC("2015-08-15 10:20:30")},
-		{Time: parseTimeUTC("2015-08-18 10:20:30")},
-		{Time: parseTimeUTC("2015-08-20 10:20:30")},
-		{Time: parseTimeUTC("2015-08-21 10:20:30")},
-		{Time: parseTimeUTC("2015-08-22 10:20:30")},
-		{Time: parseTimeUTC("2015-10-01 10:20:30")},
-		{Time: parseTimeUTC("2015-10-02 10:20:30")},
-		{Time: parseTimeUTC("2015-10-05 10:20:30")},
-		{Time: parseTimeUTC("2015-10-06 10:20:30")},
-		{Time: parseTimeUTC("2015-10-08 10:20:30")},
-		{Time: parseTimeUTC("2015-10-09 10:20:30")},
-		{Time: parseTimeUTC("2015-10-10 10:20:30")},
-		{Time: parseTimeUTC("2015-10-11 10:20:30")},
-		{Time: parseTimeUTC("2015-10-20 10:20:30")},
-		{Time: parseTimeUTC("2015-10-22 10:20:30")},
-		{Time: parseTimeUTC("2015-10-22 10:20:30")},
-		{Time: parseTimeUTC("2015-10-22 10:20:30"), Tags: []string{"foo", "bar"}},
-		{Time: parseTimeUTC("2015-10-22 10:20:30"), Tags: []string{"foo", "bar"}},
-		{Time: parseTimeUTC("2015-10-22 10:20:30"), Tags: []string{"foo", "bar"}, Paths: []string{"path1", "path2"}},
-		{Time: parseTimeUTC("2015-11-08 10:20:30")},
-		{Time: parseTimeUTC("2015-11-10 10:20:30")},
-		{Time: parseTimeUTC("2015-11-12 10:20:30")},
-		{Time: parseTimeUTC("2015-11-13 10:20:30")},
-		{Time: parseTimeUTC("2015-11-13 10:20:30.1")},
-		{Time: parseTimeUTC("2015-11-15 10:20:30")},
-		{Time: parseTimeUTC("2015-11-18 10:20:30")},
-		{Time: parseTimeUTC("2015-11-20 10:20:30")},
-		{Time: parseTimeUTC("2015-11-21 10:20:30")},
-		{Time: parseTimeUTC("2015-11-22 10:20:30")},
-		{Time: parseTimeUTC("2016-01-01 01:02:03")},
-		{Time: parseTimeUTC("2016-01-01 01:03:03")},
-		{Time: parseTimeUTC("2016-01-01 07:08:03")},
-		{Time: parseTimeUTC("2016-01-03 07:02:03")},
-		{Time: parseTimeUTC("2016-01-04 10:23:03")},
-		{Time: parseTimeUTC("2016-01-04 11:23:03")},
-		{Time: parseTimeUTC("2016-01-04 12:23:03")},
-		{Time: parseTimeUTC("2016-01-04 12:24:03")},
-		{Time: parseTimeUTC("2016-01-04 12:28:03")},
-		{Time: parseTimeUTC("2016-01-04 12:30:03")},
-		{Time: parseTimeUTC("2016-01-04 16:23:03")},
-		{Time: parseTimeUTC("2016-01-05 09:02:03")},
-		{Time: parseTimeUTC("2016-01-06 08:02:03")},
-		{Time: parseTimeUTC("2016-01-07 10:02:03")},
-		{Time: parseTimeUTC("2016-01-08 20:02:03")},
-		{Time: parseTimeUTC("2016-01-09 21:02:03")},
-		{Time: parseTimeUTC("2016-01-12 21:02:03")},
-		{Time: parseTimeUTC("2016-01-12 21:08:03")},
-		{Time: parseTimeUTC("2016-01-18 12:02:03")},
-	}
+// testExpireSnapshots is the snapshot list used to test the expire policies.
+var testExpireSnapshots = restic.Snapshots{
+	{Time: parseTimeUTC("2014-09-01 10:20:30")},
+	{Time: parseTimeUTC("2014-09-02 10:20:30")},
+	{Time: parseTimeUTC("2014-09-05 10:20:30")},
+	{Time: parseTimeUTC("2014-09-06 10:20:30")},
+	{Time: parseTimeUTC("2014-09-08 10:20:30")},
+	{Time: parseTimeUTC("2014-09-09 10:20:30")},
+	{Time: parseTimeUTC("2014-09-10 10:20:30")},
+	{Time: parseTimeUTC("2014-09-11 10:20:30")},
+	{Time: parseTimeUTC("2014-09-20 10:20:30")},
+	{Time: parseTimeUTC("2014-09-22 10:20:30")},
+	{Time: parseTimeUTC("2014-08-08 10:20:30")},
+	{Time: parseTimeUTC("2014-08-10 10:20:30")},
+	{Time: parseTimeUTC("2014-08-12 10:20:30")},
+	{Time: parseTimeUTC("2014-08-13 10:20:30")},
+	{Time: parseTimeUTC("2014-08-13 10:20:30.1")},
+	{Time: parseTimeUTC("2014-08-15 10:20:30")},
+	{Time: parseTimeUTC("2014-08-18 10:20:30")},
+	{Time: parseTimeUTC("2014-08-20 10:20:30")},
+	{Time: parseTimeUTC("2014-08-21 10:20:30")},
+	{Time: parseTimeUTC("2014-08-22 10:20:30")},
+	{Time: parseTimeUTC("2014-10-01 10:20:30"), Tags: []string{"foo"}},
+	{Time: parseTimeUTC("2014-10-02 10:20:30"), Tags: []string{"foo"}},
+	{Time: parseTimeUTC("2014-10-05 10:20:30"), Tags: []string{"foo"}},
+	{Time: parseTimeUTC("2014-10-06 10:20:30"), Tags: []string{"foo"}},
+	{Time: parseTimeUTC("2014-10-08 10:20:30"), Tags: []string{"foo"}},
+	{Time: parseTimeUTC("2014-10-09 10:20:30"), Tags: []string{"foo"}},
+	{Time: parseTimeUTC("2014-10-10 10:20:30"), Tags: []string{"foo"}},
+	{Time: parseTimeUTC("2014-10-11 10:20:30"), Tags: []string{"foo"}},
+	{Time: parseTimeUTC("2014-10-20 10:20:30"), Tags: []string{"foo"}},
+	{Time: parseTimeUTC("2014-10-22 10:20:30"), Tags: []string{"foo"}},
+	{Time: parseTimeUTC("2014-11-08 10:20:30"), Tags: []string{"foo"}},
+	{Time: parseTimeUTC("2014-11-10 10:20:30"), Tags: []string{"foo"}},
+	{Time: parseTimeUTC("2014-11-12 10:20:30"), Tags: []string{"foo"}},
+	{Time: parseTimeUTC("2014-11-13 10:20:30"), Tags: []string{"foo"}},
+	{Time: parseTimeUTC("2014-11-13 10:20:30.1"), Tags: []string{"bar"}},
+	{Time: parseTimeUTC("2014-11-15 10:20:30"), Tags: []string{"foo", "bar"}},
+	{Time: parseTimeUTC("2014-11-18 10:20:30")},
+	{Time: parseTimeUTC("2014-11-20 10:20:30")},
+	{Time: parseTimeUTC("2014-11-21 10:20:30")},
+	{Time: parseTimeUTC("2014-11-22 10:20:30")},
+	{Time: parseTimeUTC("2015-09-01 10:20:30")},
+	{Time: parseTimeUTC("2015-09-02 10:20:30")},
+	{Time: parseTimeUTC("2015-09-05 10:20:30")},
+	{Time: parseTimeUTC("2015-09-06 10:20:30")},
+	{Time: parseTimeUTC("2015-09-08 10:20:30")},
+	{Time: parseTimeUTC("2015-09-09 10:20:30")},
+	{Time: parseTimeUTC("2015-09-10 10:20:30")},
+	{Time: parseTimeUTC("2015-09-11 10:20:30")},
+	{Time: parseTimeUTC("2015-09-20 10:20:30")},
+	{Time: parseTimeUTC("2015-09-22 10:20:30")},
+	{Time: parseTimeUTC("2015-08-08 10:20:30")},
+	{Time: parseTimeUTC("2015-08-10 10:20:30")},
+	{Time: parseTimeUTC("2015-08-12 10:20:30")},
+	{Time: parseTimeUTC("2015-08-13 10:20:30")},
+	{Time: parseTimeUTC("2015-08-13 10:20:30.1")},
+	{Time: parseTimeUTC("2015-08-15 10:20:30")},
+	{Time: parseTimeUTC("2015-08-18 10:20:30")},
+	{Time: parseTimeUTC("2015-08-20 10:20:30")},
+	{Time: parseTimeUTC("2015-08-21 10:20:30")},
+	{Time: parseTimeUTC("2015-08-22 10:20:30")},
+	{Time: parseTimeUTC("2015-10-01 10:20:30")},
+	{Time: parseTimeUTC("2015-10-02 10:20:30")},
+	{Time: parseTimeUTC("2015-10-05 10:20:30")},
+	{Time: parseTimeUTC("2015-10-06 10:20:30")},
+	{Time: parseTimeUTC("2015-10-08 10:20:30")},
+	{Time: parseTimeUTC("2015-10-09 10:20:30")},
+	{Time: parseTimeUTC("2015-10-10 10:20:30")},
+	{Time: parseTimeUTC("2015-10-11 10:20:30")},
+	{Time: parseTimeUTC("2015-10-20 10:20:30")},
+	{Time: parseTimeUTC("2015-10-22 10:20:30")},
+	{Time: parseTimeUTC("2015-10-22 10:20:30")},
+	{Time: parseTimeUTC("2015-10-22 10:20:30"), Tags: []string{"foo", "bar"}},
+	{Time: parseTimeUTC("2015-10-22 10:20:30"), Tags: []string{"foo", "bar"}},
+	{Time: parseTimeUTC("2015-10-22 10:20:30"), Tags: []string{"foo", "bar"}, Paths: []string{"path1", "path2"}},
+	{Time: parseTimeUTC("2015-11-08 10:20:30")},
+	{Time: parseTimeUTC("2015-11-10 10:20:30")},
+	{Time: parseTimeUTC("2015-11-12 10:20:30")},
+	{Time: parseTimeUTC("2015-11-13 10:20:30")},
+	{Time: parseTimeUTC("2015-11-13 10:20:30.1")},
+	{Time: parseTimeUTC("2015-11-15 10:20:30")},
+	{Time: parseTimeUTC("2015-11-18 10:20:30")},
+	{Time: parseTimeUTC("2015-11-20 10:20:30")},
+	{Time: parseTimeUTC("2015-11-21 10:20:30")},
+	{Time: parseTimeUTC("2015-11-22 10:20:30")},
+	{Time: parseTimeUTC("2016-01-01 01:02:03")},
+	{Time: parseTimeUTC("2016-01-01 01:03:03")},
+	{Time: parseTimeUTC("2016-01-01 07:08:03")},
+	{Time: parseTimeUTC("2016-01-03 07:02:03")},
+	{Time: parseTimeUTC("2016-01-04 10:23:03")},
+	{Time: parseTimeUTC("2016-01-04 11:23:03")},
+	{Time: parseTimeUTC("2016-01-04 12:23:03")},
+	{Time: parseTimeUTC("2016-01-04 12:24:03")},
+	{Time: parseTimeUTC("2016-01-04 12:28:03")},
+	{Time: parseTimeUTC("2016-01-04 12:30:03")},
+	{Time: parseTimeUTC("2016-01-04 16:23:03")},
+	{Time: parseTimeUTC("2016-01-05 09:02:03")},
+	{Time: parseTimeUTC("2016-01-06 08:02:03")},
+	{Time: parseTimeUTC("2016-01-07 10:02:03")},
+	{Time: parseTimeUTC("2016-01-08 20:02:03")},
+	{Time: parseTimeUTC("2016-01-09 21:02:03")},
+	{Time: parseTimeUTC("2016-01-12 21:02:03")},
+	{Time: parseTimeUTC("2016-01-12 21:08:03")},
+	{Time: parseTimeUTC("2016-01-18 12:02:03")},
+}
 
+func TestApplyPolicy(t *testing.T) {
 	var tests = []restic.ExpirePolicy{
 		{},
 		{Last: 10},
@@ -285,3 +286,137 @@ func TestApplyPolicy(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyPolicyRemoveTags(t *testing.T) {
+	var list restic.Snapshots
+	for i, tags := range [][]string{{"temp"}, {"temp", "keep"}, nil, {"temp"}, nil} {
+		list = append(list, &restic.Snapshot{
+			Time: parseTimeUTC(fmt.Sprintf("2023-01-0%d 12:00:00", 5-i)),
+			Tags: tags,
+		})
+	}
+
+	var tests = []struct {
+		p      restic.ExpirePolicy
+		remove []int
+	}{
+		// only remove tags, everything else is kept
+		{restic.ExpirePolicy{RemoveTags: []restic.TagList{{"temp"}}}, []int{0, 1, 3}},
+		// count based rules skip the tagged snapshots
+		{restic.ExpirePolicy{Daily: 2, RemoveTags: []restic.TagList{{"temp"}}}, []int{0, 1, 3}},
+		// keep-tag takes precedence
+		{restic.ExpirePolicy{Tags: []restic.TagList{{"keep"}}, RemoveTags: []restic.TagList{{"temp"}}}, []int{0, 2, 3, 4}},
+		// keep-last still protects the newest snapshot
+		{restic.ExpirePolicy{Last: 1, RemoveTags: []restic.TagList{{"temp"}}}, []int{1, 2, 3, 4}},
+		{restic.ExpirePolicy{Last: 1, RemoveTags: []restic.TagList{{"temp"}}, ForceRemove: true}, []int{0, 1, 3, 4}},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			input := append(restic.Snapshots{}, list...)
+			keep, remove, _ := restic.ApplyPolicy(input, test.p)
+
+			var want, got []time.Time
+			for _, idx := range test.remove {
+				want = append(want, list[idx].Time)
+			}
+			for _, sn := range remove {
+				got = append(got, sn.Time)
+			}
+			if !cmp.Equal(want, got) {
+				t.Error(cmp.Diff(want, got))
+			}
+			if len(keep)+len(remove) != len(list) {
+				t.Errorf("expected %d snapshots in total, got %d", len(list), len(keep)+len(remove))
+			}
+		})
+	}
+}
+
+func TestApplyPolicyLastPerTag(t *testing.T) {
+	var list restic.Snapshots
+	for i, tags := range [][]string{{"a"}, {"a", "b"}, {"b"}, {"a"}, nil, {"b", "c"}} {
+		list = append(list, &restic.Snapshot{
+			Time: parseTimeUTC(fmt.Sprintf("2023-01-0%d 12:00:00", 6-i)),
+			Tags: tags,
+		})
+	}
+
+	var tests = []struct {
+		p      restic.ExpirePolicy
+		remove []int
+	}{
+		// a snapshot with several tags is kept for each of them
+		{restic.ExpirePolicy{LastPerTag: 1}, []int{2, 3, 4}},
+		{restic.ExpirePolicy{LastPerTag: 2}, []int{3, 4}},
+		{restic.ExpirePolicy{LastPerTag: -1}, []int{4}},
+		// the rules are combined
+		{restic.ExpirePolicy{LastPerTag: 1, Last: 1}, []int{2, 3, 4}},
+		{restic.ExpirePolicy{LastPerTag: 1, Tags: []restic.TagList{{"a"}}}, []int{2, 4}},
+		// snapshots tagged for removal are not counted
+		{restic.ExpirePolicy{LastPerTag: 1, RemoveTags: []restic.TagList{{"c"}}}, []int{2, 3, 4, 5}},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			input := append(restic.Snapshots{}, list...)
+			_, remove, _ := restic.ApplyPolicy(input, test.p)
+
+			var want, got []time.Time
+			for _, idx := range test.remove {
+				want = append(want, list[idx].Time)
+			}
+			for _, sn := range remove {
+				got = append(got, sn.Time)
+			}
+			if !cmp.Equal(want, got) {
+				t.Error(cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestApplyPolicyMinInterval(t *testing.T) {
+	var list restic.Snapshots
+	// newest first, 20 minutes apart
+	for i := 0; i < 7; i++ {
+		list = append(list, &restic.Snapshot{
+			Time: parseTimeUTC("2023-01-01 12:00:00").Add(time.Duration(-20*i) * time.Minute),
+		})
+	}
+	list[5].Tags = []string{"keep"}
+
+	var tests = []struct {
+		p      restic.ExpirePolicy
+		remove []int
+	}{
+		// the earliest snapshot of each hour is kept
+		{restic.ExpirePolicy{MinInterval: restic.Duration{Hours: 1}}, []int{1, 2, 4, 5}},
+		// the spacing applies to the snapshots kept by the other rules
+		{restic.ExpirePolicy{Hourly: 10, MinInterval: restic.Duration{Hours: 1}}, []int{0, 2, 3, 4, 5}},
+		// keep-last and keep-tag are never thinned out
+		{restic.ExpirePolicy{Last: 2, Hourly: 10, MinInterval: restic.Duration{Hours: 1}}, []int{2, 3, 4, 5}},
+		{restic.ExpirePolicy{Tags: []restic.TagList{{"keep"}}, Hourly: 10, MinInterval: restic.Duration{Hours: 1}}, []int{0, 2, 3, 4}},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			input := append(restic.Snapshots{}, list...)
+			keep, remove, reasons := restic.ApplyPolicy(input, test.p)
+
+			var want, got []time.Time
+			for _, idx := range test.remove {
+				want = append(want, list[idx].Time)
+			}
+			for _, sn := range remove {
+				got = append(got, sn.Time)
+			}
+			if !cmp.Equal(want, got) {
+				t.Error(cmp.Diff(want, got))
+			}
+			if len(keep)+len(remove) != len(list) || len(keep) != len(reasons) {
+				t.Errorf("unexpected number of snapshots: %d kept, %d removed, %d reasons", len(keep), len(remove), len(reasons))
+			}
+		})
+	}
+}
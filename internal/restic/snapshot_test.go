@@ -2,6 +2,8 @@ package restic_test
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -49,3 +51,100 @@ func testLoadJSONUnpacked(t *testing.T, version uint) {
 	rtest.Equals(t, sn.Hostname, sn2.Hostname)
 	rtest.Equals(t, sn.Username, sn2.Username)
 }
+
+func TestHasPathPatterns(t *testing.T) {
+	sn := &restic.Snapshot{Paths: []string{
+		filepath.FromSlash("/home/alice/Documents"),
+		filepath.FromSlash("/srv/[www]"),
+	}}
+
+	for _, test := range []struct {
+		patterns []string
+		match    bool
+	}{
+		{nil, true},
+		{[]string{"/home/alice/Documents"}, true},
+		{[]string{"/home/*/Documents"}, true},
+		{[]string{"/home/*"}, false},
+		{[]string{"/home/*/*"}, true},
+		{[]string{"/home/*/Documents", "/srv/*"}, true},
+		{[]string{"/home/*/Documents", "/var/*"}, false},
+		{[]string{"/home/bob/Documents"}, false},
+		// paths containing special characters still match literally
+		{[]string{"/srv/[www]"}, true},
+		{[]string{"/srv/["}, false},
+	} {
+		var patterns []string
+		for _, p := range test.patterns {
+			patterns = append(patterns, filepath.FromSlash(p))
+		}
+		rtest.Equals(t, test.match, sn.HasPathPatterns(patterns))
+	}
+}
+
+func TestHasHostname(t *testing.T) {
+	sn := &restic.Snapshot{Hostname: "web-01.example.com"}
+
+	for _, test := range []struct {
+		hosts []string
+		match bool
+	}{
+		{nil, true},
+		{[]string{"web-01.example.com"}, true},
+		{[]string{"web-*"}, true},
+		{[]string{"web-0?.example.com"}, true},
+		{[]string{"db-*", "web-*"}, true},
+		{[]string{"db-*"}, false},
+		{[]string{"web-01"}, false},
+		{[]string{"["}, false},
+	} {
+		rtest.Equals(t, test.match, sn.HasHostname(test.hosts))
+	}
+}
+
+func TestLoadAllSnapshotsOrder(t *testing.T) {
+	repo := repository.TestRepository(t)
+	ts := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		// every other snapshot shares its timestamp with the previous one
+		sn, err := restic.NewSnapshot([]string{"/foo", fmt.Sprint(i)}, nil, "foo", ts.Add(time.Duration(i/2)*time.Hour))
+		rtest.OK(t, err)
+		_, err = restic.SaveSnapshot(context.TODO(), repo, sn)
+		rtest.OK(t, err)
+	}
+
+	first, err := restic.TestLoadAllSnapshots(context.TODO(), repo, nil)
+	rtest.OK(t, err)
+	rtest.Equals(t, 20, len(first))
+	for i := 1; i < len(first); i++ {
+		rtest.Assert(t, !first[i].Time.After(first[i-1].Time), "snapshots are not sorted newest first")
+	}
+
+	for i := 0; i < 5; i++ {
+		list, err := restic.TestLoadAllSnapshots(context.TODO(), repo, nil)
+		rtest.OK(t, err)
+		for j := range list {
+			rtest.Equals(t, *first[j].ID(), *list[j].ID())
+		}
+	}
+}
+
+func BenchmarkLoadAllSnapshots(b *testing.B) {
+	repo := repository.TestRepository(b)
+	ts := time.Now()
+	for i := 0; i < 5000; i++ {
+		sn, err := restic.NewSnapshot([]string{"/foo"}, []string{fmt.Sprint(i)}, "foo", ts.Add(time.Duration(i)*time.Second))
+		rtest.OK(b, err)
+		_, err = restic.SaveSnapshot(context.TODO(), repo, sn)
+		rtest.OK(b, err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		list, err := restic.TestLoadAllSnapshots(context.TODO(), repo, nil)
+		rtest.OK(b, err)
+		if len(list) != 5000 {
+			b.Fatalf("expected 5000 snapshots, got %d", len(list))
+		}
+	}
+}
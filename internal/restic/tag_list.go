@@ -22,6 +22,21 @@ func (l TagList) String() string {
 	return "[" + strings.Join(l, ", ") + "]"
 }
 
+// Unique returns the tags of the list without duplicates and empty tags,
+// keeping the order of their first occurrence.
+func (l TagList) Unique() TagList {
+	tags := make(TagList, 0, len(l))
+	seen := make(map[string]struct{}, len(l))
+	for _, tag := range l {
+		if _, ok := seen[tag]; ok || tag == "" {
+			continue
+		}
+		seen[tag] = struct{}{}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
 // Set updates the TagList's value.
 func (l *TagList) Set(s string) error {
 	*l = splitTagList(s)
@@ -43,3 +43,33 @@ func TestTagLists_Flatten(t *testing.T) {
 		})
 	}
 }
+
+func TestTagList_Unique(t *testing.T) {
+	tests := []struct {
+		name string
+		l    TagList
+		want TagList
+	}{
+		{
+			name: "No duplicates",
+			l:    TagList{"tag1", "tag2"},
+			want: TagList{"tag1", "tag2"},
+		},
+		{
+			name: "Duplicates",
+			l:    TagList{"tag2", "tag1", "tag2", "", "tag1"},
+			want: TagList{"tag2", "tag1"},
+		},
+		{
+			name: "No tags",
+			l:    nil,
+			want: TagList{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.l.Unique()
+			rtest.Equals(t, got, tt.want)
+		})
+	}
+}
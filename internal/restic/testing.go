@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"sort"
 	"testing"
 	"time"
 
@@ -188,8 +189,9 @@ func ParseDurationOrPanic(s string) Duration {
 	return d
 }
 
-// TestLoadAllSnapshots returns a list of all snapshots in the repo.
-// If a snapshot ID is in excludeIDs, it will not be included in the result.
+// TestLoadAllSnapshots returns a list of all snapshots in the repo, sorted
+// newest first. If a snapshot ID is in excludeIDs, it will not be included in
+// the result.
 func TestLoadAllSnapshots(ctx context.Context, repo Repository, excludeIDs IDSet) (snapshots Snapshots, err error) {
 	err = ForAllSnapshots(ctx, repo, repo, excludeIDs, func(id ID, sn *Snapshot, err error) error {
 		if err != nil {
@@ -204,5 +206,6 @@ func TestLoadAllSnapshots(ctx context.Context, repo Repository, excludeIDs IDSet
 		return nil, err
 	}
 
+	sort.Sort(snapshots)
 	return snapshots, nil
 }
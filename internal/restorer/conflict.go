@@ -0,0 +1,167 @@
+package restorer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+)
+
+// ConflictBehavior configures how nodes are handled whose target already
+// exists and does not belong to the restore.
+type ConflictBehavior int
+
+// Constants for the different conflict behaviors.
+const (
+	ConflictOverwrite ConflictBehavior = iota
+	ConflictSkip
+	ConflictRename
+	ConflictInvalid
+)
+
+// Set implements the method needed for pflag command flag parsing.
+func (c *ConflictBehavior) Set(s string) error {
+	switch s {
+	case "overwrite":
+		*c = ConflictOverwrite
+	case "skip":
+		*c = ConflictSkip
+	case "rename":
+		*c = ConflictRename
+	default:
+		*c = ConflictInvalid
+		return fmt.Errorf("invalid conflict behavior %q, must be one of (overwrite|skip|rename)", s)
+	}
+
+	return nil
+}
+
+func (c *ConflictBehavior) String() string {
+	switch *c {
+	case ConflictOverwrite:
+		return "overwrite"
+	case ConflictSkip:
+		return "skip"
+	case ConflictRename:
+		return "rename"
+	default:
+		return "invalid"
+	}
+}
+
+func (c *ConflictBehavior) Type() string {
+	return "behavior"
+}
+
+// renameSuffix is appended to the name of a node restored next to an existing
+// file. If that name is taken as well, a counter is appended.
+const renameSuffix = ".restored"
+
+// conflictResolver keeps track of the nodes whose target already exists.
+type conflictResolver struct {
+	behavior ConflictBehavior
+	report   func(location, target string, action ConflictBehavior)
+
+	// targets of all restored nodes, only used for ConflictRename
+	planned map[string]struct{}
+	// conflicting nodes which are not renamed yet, in the order they were found
+	pending []pendingRename
+
+	skipped map[string]struct{} // locations of skipped nodes
+	renamed map[string]string   // new target of renamed nodes, by location
+}
+
+type pendingRename struct {
+	location, target string
+}
+
+func newConflictResolver(behavior ConflictBehavior, report func(location, target string, action ConflictBehavior)) *conflictResolver {
+	return &conflictResolver{
+		behavior: behavior,
+		report:   report,
+		planned:  make(map[string]struct{}),
+		skipped:  make(map[string]struct{}),
+		renamed:  make(map[string]string),
+	}
+}
+
+// plan records that target is restored. Renamed nodes never use such a target.
+func (c *conflictResolver) plan(target string) {
+	if c.behavior == ConflictRename {
+		c.planned[target] = struct{}{}
+	}
+}
+
+// check returns true if the node at location must be skipped because its
+// target already exists. In rename mode, the node is renamed by resolve.
+func (c *conflictResolver) check(target, location string) (skip bool, err error) {
+	if c.behavior == ConflictOverwrite {
+		return false, nil
+	}
+
+	_, err = fs.Lstat(target)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if c.behavior == ConflictSkip {
+		c.skipped[location] = struct{}{}
+		c.reportConflict(location, target, ConflictSkip)
+		return true, nil
+	}
+
+	c.pending = append(c.pending, pendingRename{location: location, target: target})
+	return false, nil
+}
+
+// resolve chooses a new target for all conflicting nodes found by check. The
+// name of the node is suffixed with ".restored", followed by a counter if a
+// file with that name exists or is restored as well.
+func (c *conflictResolver) resolve() error {
+	for _, p := range c.pending {
+		target := p.target + renameSuffix
+		for i := 1; ; i++ {
+			if _, ok := c.planned[target]; !ok {
+				_, err := fs.Lstat(target)
+				if errors.Is(err, os.ErrNotExist) {
+					break
+				}
+				if err != nil {
+					return err
+				}
+			}
+			target = p.target + renameSuffix + "." + strconv.Itoa(i)
+		}
+
+		c.planned[target] = struct{}{}
+		c.renamed[p.location] = target
+		c.reportConflict(p.location, target, ConflictRename)
+	}
+	c.pending = nil
+	return nil
+}
+
+func (c *conflictResolver) reportConflict(location, target string, action ConflictBehavior) {
+	if c.report != nil {
+		c.report(location, target, action)
+	}
+}
+
+// isSkipped returns true if the node at location was skipped.
+func (c *conflictResolver) isSkipped(location string) bool {
+	_, ok := c.skipped[location]
+	return ok
+}
+
+// target returns the path the node at location is restored to.
+func (c *conflictResolver) target(target, location string) string {
+	if renamed, ok := c.renamed[location]; ok {
+		return renamed
+	}
+	return target
+}
@@ -3,7 +3,10 @@ package restorer
 import (
 	"context"
 	"path/filepath"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 
@@ -30,8 +33,10 @@ type fileInfo struct {
 	inProgress bool
 	sparse     bool
 	size       int64
-	location   string      // file on local filesystem relative to restorer basedir
-	blobs      interface{} // blobs of the file
+	modTime    time.Time
+	written    atomic.Int64 // number of bytes written
+	location   string       // file on local filesystem relative to restorer basedir
+	blobs      interface{}  // blobs of the file
 }
 
 type fileBlobInfo struct {
@@ -50,12 +55,21 @@ type fileRestorer struct {
 	key        *crypto.Key
 	idx        func(restic.BlobHandle) []restic.PackedBlob
 	packLoader repository.BackendLoadFn
+	// packReadGap is the maximum size of unneeded data read between two blobs
+	packReadGap uint
 
 	workerCount int
 	filesWriter *filesWriter
 	zeroChunk   restic.ID
 	sparse      bool
 	progress    *restore.Progress
+	order       RestoreOrder
+	pathMap     restic.PathMap
+	// renamed contains the target of files which are not restored to the
+	// path in the snapshot, by location
+	renamed map[string]string
+	// fileCompleted, if set, is called once all data of a file was written
+	fileCompleted func(location string, modTime time.Time) error
 
 	dst   string
 	files []*fileInfo
@@ -66,17 +80,18 @@ func newFileRestorer(dst string,
 	packLoader repository.BackendLoadFn,
 	key *crypto.Key,
 	idx func(restic.BlobHandle) []restic.PackedBlob,
-	connections uint,
+	workers uint,
 	sparse bool,
 	progress *restore.Progress) *fileRestorer {
 
 	// as packs are streamed the concurrency is limited by IO
-	workerCount := int(connections)
+	workerCount := int(workers)
 
 	return &fileRestorer{
 		key:         key,
 		idx:         idx,
 		packLoader:  packLoader,
+		packReadGap: repository.DefaultPackReadGap,
 		filesWriter: newFilesWriter(workerCount),
 		zeroChunk:   repository.ZeroChunk(),
 		sparse:      sparse,
@@ -87,12 +102,31 @@ func newFileRestorer(dst string,
 	}
 }
 
-func (r *fileRestorer) addFile(location string, content restic.IDs, size int64) {
-	r.files = append(r.files, &fileInfo{location: location, blobs: content, size: size})
+func (r *fileRestorer) addFile(location string, content restic.IDs, size int64, modTime time.Time) {
+	r.files = append(r.files, &fileInfo{location: location, blobs: content, size: size, modTime: modTime})
+}
+
+// sortFiles sorts the files according to the restore order. As packs are
+// downloaded in the order they are first needed, this determines which files
+// are completed first.
+func (r *fileRestorer) sortFiles() {
+	switch r.order {
+	case RestoreOrderSize:
+		sort.SliceStable(r.files, func(i, j int) bool {
+			return r.files[i].size < r.files[j].size
+		})
+	case RestoreOrderMtime:
+		sort.SliceStable(r.files, func(i, j int) bool {
+			return r.files[i].modTime.After(r.files[j].modTime)
+		})
+	}
 }
 
 func (r *fileRestorer) targetPath(location string) string {
-	return filepath.Join(r.dst, location)
+	if target, ok := r.renamed[location]; ok {
+		return target
+	}
+	return filepath.Join(r.dst, r.pathMap.Apply(location))
 }
 
 func (r *fileRestorer) forEachBlob(blobIDs []restic.ID, fn func(packID restic.ID, packBlob restic.Blob)) error {
@@ -119,6 +153,8 @@ func (r *fileRestorer) restoreFiles(ctx context.Context) error {
 	// approximation to shorten restore times by up to 19% in some test.
 	var packOrder restic.IDs
 
+	r.sortFiles()
+
 	// create packInfo from fileInfo
 	for _, file := range r.files {
 		fileBlobs := file.blobs.(restic.IDs)
@@ -179,6 +215,8 @@ func (r *fileRestorer) restoreFiles(ctx context.Context) error {
 
 	// the main restore loop
 	wg.Go(func() error {
+		// also stop the workers if the restore is canceled
+		defer close(downloadCh)
 		for _, id := range packOrder {
 			pack := packs[id]
 			// allow garbage collection of packInfo
@@ -190,7 +228,6 @@ func (r *fileRestorer) restoreFiles(ctx context.Context) error {
 				debug.Log("Scheduled download pack %s", pack.id.Str())
 			}
 		}
-		close(downloadCh)
 		return nil
 	})
 
@@ -310,7 +347,7 @@ func (r *fileRestorer) downloadBlobs(ctx context.Context, packID restic.ID,
 	for _, entry := range blobs {
 		blobList = append(blobList, entry.blob)
 	}
-	return repository.StreamPack(ctx, r.packLoader, r.key, packID, blobList,
+	return repository.StreamPack(ctx, r.packLoader, r.key, packID, blobList, r.packReadGap,
 		func(h restic.BlobHandle, blobData []byte, err error) error {
 			processedBlobs.Insert(h)
 			blob := blobs[h.ID]
@@ -346,6 +383,10 @@ func (r *fileRestorer) downloadBlobs(ctx context.Context, packID restic.ID,
 							r.progress.AddProgress(file.location, uint64(len(blobData)), uint64(file.size))
 						}
 
+						if writeErr == nil && file.written.Add(int64(len(blobData))) == file.size && r.fileCompleted != nil {
+							writeErr = r.fileCompleted(file.location, file.modTime)
+						}
+
 						return writeErr
 					}
 					err := r.sanitizeError(file, writeToFile())
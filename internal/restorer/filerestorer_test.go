@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/crypto"
@@ -269,6 +271,115 @@ func TestFileRestorerFrequentBlob(t *testing.T) {
 	}
 }
 
+func TestFileRestorerReadConcurrency(t *testing.T) {
+	var content []TestFile
+	for i := 0; i < 20; i++ {
+		content = append(content, TestFile{
+			name:  fmt.Sprintf("file%d", i),
+			blobs: []TestBlob{{fmt.Sprintf("data%d", i), fmt.Sprintf("pack%d", i)}},
+		})
+	}
+
+	for _, workers := range []uint{1, 4} {
+		t.Run(fmt.Sprint(workers), func(t *testing.T) {
+			tempdir := rtest.TempDir(t)
+			repo := newTestRepo(content)
+
+			var m sync.Mutex
+			var active, maxActive uint
+			loader := repo.loader
+			repo.loader = func(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+				m.Lock()
+				active++
+				if active > maxActive {
+					maxActive = active
+				}
+				m.Unlock()
+				defer func() {
+					m.Lock()
+					active--
+					m.Unlock()
+				}()
+
+				time.Sleep(time.Millisecond)
+				return loader(ctx, h, length, offset, fn)
+			}
+
+			r := newFileRestorer(tempdir, repo.loader, repo.key, repo.Lookup, workers, false, nil)
+			r.files = repo.files
+
+			rtest.OK(t, r.restoreFiles(context.TODO()))
+			verifyRestore(t, r, repo)
+			rtest.Assert(t, maxActive >= 1 && maxActive <= workers,
+				"expected at most %d concurrent downloads, got %d", workers, maxActive)
+		})
+	}
+}
+
+func TestFileRestorerOrder(t *testing.T) {
+	content := []TestFile{
+		{name: "large", blobs: []TestBlob{{"large data", "pack1"}}},
+		{name: "small", blobs: []TestBlob{{"data", "pack2"}}},
+		{name: "medium", blobs: []TestBlob{{"some data", "pack3"}}},
+	}
+	modTimes := map[string]time.Time{
+		"large":  time.Unix(3, 0),
+		"small":  time.Unix(1, 0),
+		"medium": time.Unix(2, 0),
+	}
+
+	for _, test := range []struct {
+		order RestoreOrder
+		want  []string
+	}{
+		{RestoreOrderTree, []string{"large", "small", "medium"}},
+		{RestoreOrderSize, []string{"small", "medium", "large"}},
+		{RestoreOrderMtime, []string{"large", "medium", "small"}},
+	} {
+		t.Run(test.order.String(), func(t *testing.T) {
+			tempdir := rtest.TempDir(t)
+			repo := newTestRepo(content)
+			for _, file := range repo.files {
+				file.size = int64(len(repo.fileContent(file)))
+				file.modTime = modTimes[file.location]
+			}
+
+			var completed []string
+			r := newFileRestorer(tempdir, repo.loader, repo.key, repo.Lookup, 1, false, nil)
+			r.files = repo.files
+			r.order = test.order
+			r.fileCompleted = func(location string, modTime time.Time) error {
+				rtest.Equals(t, modTimes[location], modTime)
+				completed = append(completed, location)
+				return nil
+			}
+
+			rtest.OK(t, r.restoreFiles(context.TODO()))
+			verifyRestore(t, r, repo)
+			rtest.Equals(t, test.want, completed)
+		})
+	}
+}
+
+func TestFileRestorerCancel(t *testing.T) {
+	var content []TestFile
+	for i := 0; i < 5; i++ {
+		content = append(content, TestFile{
+			name:  fmt.Sprintf("file%d", i),
+			blobs: []TestBlob{{fmt.Sprintf("data%d", i), fmt.Sprintf("pack%d", i)}},
+		})
+	}
+	repo := newTestRepo(content)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r := newFileRestorer(rtest.TempDir(t), repo.loader, repo.key, repo.Lookup, 2, false, nil)
+	r.files = repo.files
+	err := r.restoreFiles(ctx)
+	rtest.Assert(t, errors.Is(err, context.Canceled), "expected context canceled error, got %v", err)
+}
+
 func TestErrorRestoreFiles(t *testing.T) {
 	tempdir := rtest.TempDir(t)
 	content := []TestFile{
@@ -0,0 +1,204 @@
+package restorer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+)
+
+// PlanAction describes what a restore does with a node.
+type PlanAction int
+
+// Constants for the different plan actions.
+const (
+	// PlanCreate restores a node whose target does not exist.
+	PlanCreate PlanAction = iota
+	// PlanOverwrite replaces the existing target.
+	PlanOverwrite
+	// PlanUnchanged keeps the content of the existing target and only
+	// restores the metadata.
+	PlanUnchanged
+	// PlanSkip does not restore a node whose target exists.
+	PlanSkip
+	// PlanRename restores a node next to its existing target.
+	PlanRename
+)
+
+func (a PlanAction) String() string {
+	switch a {
+	case PlanCreate:
+		return "new"
+	case PlanOverwrite:
+		return "overwrite"
+	case PlanUnchanged:
+		return "unchanged"
+	case PlanSkip:
+		return "skip"
+	case PlanRename:
+		return "rename"
+	default:
+		return "invalid"
+	}
+}
+
+// PlannedNode describes how a node other than a directory is restored.
+type PlannedNode struct {
+	Location string
+	// Target is the path the node is restored to. For PlanRename, it
+	// differs from the existing path.
+	Target string
+	Type   string
+	Size   uint64
+	Action PlanAction
+}
+
+// RestorePlan lists the nodes a restore would create along with the number
+// of bytes that would be written and downloaded.
+type RestorePlan struct {
+	Nodes []PlannedNode
+	// TotalBytes is the size of all selected files, WriteBytes the size of
+	// the file contents which are written.
+	TotalBytes uint64
+	WriteBytes uint64
+	// DownloadBytes is the size of the blobs which must be downloaded from
+	// the repository to write the file contents. Blobs used by several files
+	// are only counted once.
+	DownloadBytes uint64
+}
+
+// Plan returns what RestoreTo would do when restoring to dst without
+// modifying anything. It applies SelectFilter, PathMap, Overwrite,
+// MetadataOnly, State and OnConflict in the same way as RestoreTo.
+func (res *Restorer) Plan(ctx context.Context, dst string) (*RestorePlan, error) {
+	var err error
+	if !filepath.IsAbs(dst) {
+		dst, err = filepath.Abs(dst)
+		if err != nil {
+			return nil, errors.Wrap(err, "Abs")
+		}
+	}
+
+	res.conflicts = newConflictResolver(res.OnConflict, res.Conflict)
+
+	plan := &RestorePlan{}
+	idx := NewHardlinkIndex[struct{}]()
+	blobs := restic.NewIDSet()
+
+	add := func(node *restic.Node, target, location string, action PlanAction) {
+		plan.Nodes = append(plan.Nodes, PlannedNode{
+			Location: location,
+			Target:   target,
+			Type:     node.Type,
+			Size:     node.Size,
+			Action:   action,
+		})
+	}
+
+	// existing returns the action for a node which is restored to target
+	existing := func(target string) (PlanAction, error) {
+		_, err := fs.Lstat(target)
+		if errors.Is(err, os.ErrNotExist) {
+			return PlanCreate, nil
+		}
+		if err != nil {
+			return PlanCreate, err
+		}
+		return PlanOverwrite, nil
+	}
+
+	// check handles nodes whose content is not downloaded
+	check := func(node *restic.Node, target, location string) (written bool, err error) {
+		skip, err := res.conflicts.check(target, location)
+		if err != nil {
+			return false, err
+		}
+		if skip {
+			add(node, target, location, PlanSkip)
+			return false, nil
+		}
+		action, err := existing(target)
+		if err != nil {
+			return false, err
+		}
+		add(node, target, location, action)
+		return true, nil
+	}
+
+	_, err = res.traverseTree(ctx, dst, dst, string(filepath.Separator), *res.sn.Tree, treeVisitor{
+		enterDir: func(node *restic.Node, target, location string) error {
+			res.conflicts.plan(target)
+			return nil
+		},
+
+		visitNode: func(node *restic.Node, target, location string) error {
+			res.conflicts.plan(target)
+
+			if node.Type != "file" || node.Size == 0 {
+				_, err := check(node, target, location)
+				return err
+			}
+
+			plan.TotalBytes += node.Size
+
+			if node.Links > 1 && idx.Has(node.Inode, node.DeviceID) {
+				// a hardlinked file does not write its content again
+				_, err := check(node, target, location)
+				return err
+			}
+
+			if res.skipFile(node, target, location) {
+				debug.Log("plan: unchanged file %q", location)
+				add(node, target, location, PlanUnchanged)
+				if node.Links > 1 {
+					idx.Add(node.Inode, node.DeviceID, struct{}{})
+				}
+				return nil
+			}
+
+			written, err := check(node, target, location)
+			if err != nil || !written {
+				return err
+			}
+			if node.Links > 1 {
+				idx.Add(node.Inode, node.DeviceID, struct{}{})
+			}
+
+			plan.WriteBytes += node.Size
+			for _, id := range node.Content {
+				if blobs.Has(id) {
+					continue
+				}
+				blobs.Insert(id)
+				pbs := res.repo.Index().Lookup(restic.BlobHandle{ID: id, Type: restic.DataBlob})
+				if len(pbs) == 0 {
+					return errors.Errorf("unknown blob %s", id.Str())
+				}
+				plan.DownloadBytes += uint64(pbs[0].Length)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = res.conflicts.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range plan.Nodes {
+		n := &plan.Nodes[i]
+		if renamed, ok := res.conflicts.renamed[n.Location]; ok {
+			n.Target = renamed
+			n.Action = PlanRename
+		}
+	}
+
+	return plan, nil
+}
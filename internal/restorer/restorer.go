@@ -2,9 +2,11 @@ package restorer
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync/atomic"
+	"time"
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
@@ -23,8 +25,134 @@ type Restorer struct {
 
 	progress *restoreui.Progress
 
-	Error        func(location string, err error) error
+	// Overwrite configures how existing files in the target are handled.
+	Overwrite OverwriteBehavior
+	// MetadataOnly, if set, only restores the metadata of existing regular
+	// files whose content matches the snapshot. Other files are restored
+	// completely. If NoContentCheck is set as well, the content of existing
+	// regular files is not compared and never rewritten.
+	MetadataOnly   bool
+	NoContentCheck bool
+	// OnConflict configures how nodes are handled whose target already
+	// exists. Files which are skipped according to Overwrite are not
+	// considered a conflict. Skipping or renaming conflicting nodes must not
+	// be combined with State, as the files restored by the interrupted run
+	// would be considered a conflict.
+	OnConflict ConflictBehavior
+	// Conflict, if set, is called for each conflicting node. For
+	// ConflictRename, target is the path the node is restored to instead.
+	Conflict func(location, target string, action ConflictBehavior)
+	// State, if set, records completed files and allows resuming an
+	// interrupted restore.
+	State *State
+	// ReadConcurrency is the number of pack files downloaded concurrently. If
+	// zero, the number of backend connections is used.
+	ReadConcurrency uint
+	// Order configures which files are restored first.
+	Order RestoreOrder
+	// PathMap, if set, restores the nodes below an old path of a mapping to
+	// the new path within the target directory.
+	PathMap restic.PathMap
+	// XattrFilter, if set, decides which extended attributes are restored.
+	// A nil filter restores all extended attributes.
+	XattrFilter func(name string) bool
+	// FileMode and DirMode, if set, compute the mode of restored files and
+	// directories from the mode stored in the snapshot.
+	FileMode func(mode os.FileMode) os.FileMode
+	DirMode  func(mode os.FileMode) os.FileMode
+
+	Error func(location string, err error) error
+	// Warn is called for problems which do not fail the restore, such as
+	// extended attributes that cannot be set on the target file system.
+	Warn         func(location string, err error)
 	SelectFilter func(item string, dstpath string, node *restic.Node) (selectedForRestore bool, childMayBeSelected bool)
+
+	conflicts *conflictResolver
+}
+
+// OverwriteBehavior configures when existing files are overwritten.
+type OverwriteBehavior int
+
+// Constants for the different overwrite behaviors.
+const (
+	OverwriteAlways OverwriteBehavior = iota
+	OverwriteIfChanged
+	OverwriteInvalid
+)
+
+// Set implements the method needed for pflag command flag parsing.
+func (c *OverwriteBehavior) Set(s string) error {
+	switch s {
+	case "always":
+		*c = OverwriteAlways
+	case "if-changed":
+		*c = OverwriteIfChanged
+	default:
+		*c = OverwriteInvalid
+		return fmt.Errorf("invalid overwrite behavior %q, must be one of (always|if-changed)", s)
+	}
+
+	return nil
+}
+
+func (c *OverwriteBehavior) String() string {
+	switch *c {
+	case OverwriteAlways:
+		return "always"
+	case OverwriteIfChanged:
+		return "if-changed"
+	default:
+		return "invalid"
+	}
+}
+
+func (c *OverwriteBehavior) Type() string {
+	return "behavior"
+}
+
+// RestoreOrder configures the order in which the file contents are restored.
+type RestoreOrder int
+
+// Constants for the different restore orders.
+const (
+	RestoreOrderTree RestoreOrder = iota
+	RestoreOrderSize
+	RestoreOrderMtime
+	RestoreOrderInvalid
+)
+
+// Set implements the method needed for pflag command flag parsing.
+func (c *RestoreOrder) Set(s string) error {
+	switch s {
+	case "tree":
+		*c = RestoreOrderTree
+	case "size":
+		*c = RestoreOrderSize
+	case "mtime":
+		*c = RestoreOrderMtime
+	default:
+		*c = RestoreOrderInvalid
+		return fmt.Errorf("invalid restore order %q, must be one of (tree|size|mtime)", s)
+	}
+
+	return nil
+}
+
+func (c *RestoreOrder) String() string {
+	switch *c {
+	case RestoreOrderTree:
+		return "tree"
+	case RestoreOrderSize:
+		return "size"
+	case RestoreOrderMtime:
+		return "mtime"
+	default:
+		return "invalid"
+	}
+}
+
+func (c *RestoreOrder) Type() string {
+	return "order"
 }
 
 var restorerAbortOnAllErrors = func(location string, err error) error { return err }
@@ -39,6 +167,7 @@ func NewRestorer(repo restic.Repository, sn *restic.Snapshot, sparse bool,
 		SelectFilter: func(string, string, *restic.Node) (bool, bool) { return true, true },
 		progress:     progress,
 		sn:           sn,
+		conflicts:    newConflictResolver(ConflictOverwrite, nil),
 	}
 
 	return r
@@ -51,8 +180,9 @@ type treeVisitor struct {
 }
 
 // traverseTree traverses a tree from the repo and calls treeVisitor.
-// target is the path in the file system, location within the snapshot.
-func (res *Restorer) traverseTree(ctx context.Context, target, location string, treeID restic.ID, visitor treeVisitor) (hasRestored bool, err error) {
+// target is the path in the file system below the restore directory dst,
+// location within the snapshot.
+func (res *Restorer) traverseTree(ctx context.Context, dst, target, location string, treeID restic.ID, visitor treeVisitor) (hasRestored bool, err error) {
 	debug.Log("%v %v %v", target, location, treeID)
 	tree, err := restic.LoadTree(ctx, res.repo, treeID)
 	if err != nil {
@@ -87,6 +217,10 @@ func (res *Restorer) traverseTree(ctx context.Context, target, location string,
 			continue
 		}
 
+		if len(res.PathMap) > 0 {
+			nodeTarget = filepath.Join(dst, res.PathMap.Apply(nodeLocation))
+		}
+
 		// sockets cannot be restored
 		if node.Type == "socket" {
 			continue
@@ -126,7 +260,7 @@ func (res *Restorer) traverseTree(ctx context.Context, target, location string,
 			childHasRestored := false
 
 			if childMayBeSelected {
-				childHasRestored, err = res.traverseTree(ctx, nodeTarget, nodeLocation, *node.Subtree, visitor)
+				childHasRestored, err = res.traverseTree(ctx, dst, nodeTarget, nodeLocation, *node.Subtree, visitor)
 				err = sanitizeError(err)
 				if err != nil {
 					return hasRestored, err
@@ -178,13 +312,39 @@ func (res *Restorer) restoreNodeTo(ctx context.Context, node *restic.Node, targe
 
 func (res *Restorer) restoreNodeMetadataTo(node *restic.Node, target, location string) error {
 	debug.Log("restoreNodeMetadata %v %v %v", node.Name, target, location)
-	err := node.RestoreMetadata(target)
+	// extended attributes are restored separately, failing to set them only
+	// results in a warning
+	n := *node
+	n.ExtendedAttributes = nil
+	switch {
+	case n.Type == "file" && res.FileMode != nil:
+		n.Mode = res.FileMode(n.Mode)
+	case n.Type == "dir" && res.DirMode != nil:
+		n.Mode = res.DirMode(n.Mode)
+	}
+	err := n.RestoreMetadata(target)
 	if err != nil {
 		debug.Log("node.RestoreMetadata(%s) error %v", target, err)
 	}
+	res.restoreExtendedAttributes(node, target, location)
 	return err
 }
 
+func (res *Restorer) restoreExtendedAttributes(node *restic.Node, target, location string) {
+	for _, attr := range node.ExtendedAttributes {
+		if res.XattrFilter != nil && !res.XattrFilter(attr.Name) {
+			continue
+		}
+		err := restic.Setxattr(target, attr.Name, attr.Value)
+		if err != nil {
+			debug.Log("Setxattr(%v, %v) error %v", target, attr.Name, err)
+			if res.Warn != nil {
+				res.Warn(location, errors.Wrapf(err, "restore xattr %v", attr.Name))
+			}
+		}
+	}
+}
+
 func (res *Restorer) restoreHardlinkAt(node *restic.Node, target, path, location string) error {
 	if err := fs.Remove(path); !os.IsNotExist(err) {
 		return errors.Wrap(err, "RemoveCreateHardlink")
@@ -202,14 +362,63 @@ func (res *Restorer) restoreHardlinkAt(node *restic.Node, target, path, location
 	return res.restoreNodeMetadataTo(node, path, location)
 }
 
-func (res *Restorer) restoreEmptyFileAt(node *restic.Node, target, location string) error {
-	wr, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
-	if err != nil {
-		return err
+// skipFile returns true if the content of the file at target does not need to
+// be restored, either because it matches node or because it was completed by
+// an earlier run of the same restore. In both cases, size and modification time
+// must match, as the modification time is only set once the file is complete.
+// With MetadataOnly, the content of the file is compared instead.
+func (res *Restorer) skipFile(node *restic.Node, target, location string) bool {
+	checkModTime := res.Overwrite == OverwriteIfChanged || (res.State != nil && res.State.Completed(location))
+	if !checkModTime && !res.MetadataOnly {
+		return false
+	}
+
+	fi, err := fs.Lstat(target)
+	if err != nil || !fi.Mode().IsRegular() {
+		return false
 	}
-	err = wr.Close()
+
+	if checkModTime && fi.Size() == int64(node.Size) && fi.ModTime().Equal(node.ModTime) {
+		return true
+	}
+	return res.MetadataOnly && res.contentMatches(node, target, fi)
+}
+
+// contentMatches returns true if the existing file at target, described by fi,
+// has the content of node. The check is skipped with NoContentCheck.
+func (res *Restorer) contentMatches(node *restic.Node, target string, fi os.FileInfo) bool {
+	if res.NoContentCheck {
+		return true
+	}
+	if fi.Size() != int64(node.Size) {
+		return false
+	}
+
+	_, err := res.verifyFile(target, node, nil)
 	if err != nil {
-		return err
+		debug.Log("content of %v does not match: %v", target, err)
+		return false
+	}
+	return true
+}
+
+func (res *Restorer) restoreEmptyFileAt(node *restic.Node, target, location string) error {
+	truncate := true
+	if res.MetadataOnly && res.NoContentCheck {
+		// the content of existing files is never rewritten
+		fi, err := fs.Lstat(target)
+		truncate = err != nil || !fi.Mode().IsRegular()
+	}
+
+	if truncate {
+		wr, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		err = wr.Close()
+		if err != nil {
+			return err
+		}
 	}
 
 	if res.progress != nil {
@@ -230,20 +439,52 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
 		}
 	}
 
+	readConcurrency := res.ReadConcurrency
+	if readConcurrency == 0 {
+		readConcurrency = res.repo.Connections()
+	}
+
+	res.conflicts = newConflictResolver(res.OnConflict, res.Conflict)
+
 	idx := NewHardlinkIndex[string]()
 	filerestorer := newFileRestorer(dst, res.repo.Backend().Load, res.repo.Key(), res.repo.Index().Lookup,
-		res.repo.Connections(), res.sparse, res.progress)
+		readConcurrency, res.sparse, res.progress)
+	filerestorer.packReadGap = res.repo.PackReadGap()
 	filerestorer.Error = res.Error
+	filerestorer.order = res.Order
+	filerestorer.pathMap = res.PathMap
+	filerestorer.renamed = res.conflicts.renamed
+	if res.State != nil {
+		// record files as soon as their content is complete, such that an
+		// interrupted restore does not have to download them again
+		filerestorer.fileCompleted = func(location string, modTime time.Time) error {
+			err := fs.Chtimes(filerestorer.targetPath(location), modTime, modTime)
+			if err != nil {
+				return err
+			}
+			return res.State.MarkCompleted(location)
+		}
+	}
+
+	// checkConflict handles conflicts of nodes which are created in the second pass
+	checkConflict := func(target, location string) error {
+		skip, err := res.conflicts.check(target, location)
+		if skip && res.progress != nil {
+			res.progress.AddProgress(location, 0, 0)
+		}
+		return err
+	}
 
 	debug.Log("first pass for %q", dst)
 
 	// first tree pass: create directories and collect all files to restore
-	_, err = res.traverseTree(ctx, dst, string(filepath.Separator), *res.sn.Tree, treeVisitor{
+	_, err = res.traverseTree(ctx, dst, dst, string(filepath.Separator), *res.sn.Tree, treeVisitor{
 		enterDir: func(node *restic.Node, target, location string) error {
 			debug.Log("first pass, enterDir: mkdir %q, leaveDir should restore metadata", location)
 			if res.progress != nil {
 				res.progress.AddFile(0)
 			}
+			res.conflicts.plan(target)
 			// create dir with default permissions
 			// #leaveDir restores dir metadata after visiting all children
 			return fs.MkdirAll(target, 0700)
@@ -257,37 +498,61 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
 			if err != nil {
 				return err
 			}
+			res.conflicts.plan(target)
 
 			if node.Type != "file" {
 				if res.progress != nil {
 					res.progress.AddFile(0)
 				}
-				return nil
+				return checkConflict(target, location)
 			}
 
 			if node.Size == 0 {
 				if res.progress != nil {
 					res.progress.AddFile(node.Size)
 				}
-				return nil // deal with empty files later
+				return checkConflict(target, location) // deal with empty files later
 			}
 
-			if node.Links > 1 {
-				if idx.Has(node.Inode, node.DeviceID) {
-					if res.progress != nil {
-						// a hardlinked file does not increase the restore size
-						res.progress.AddFile(0)
-					}
-					return nil
+			if node.Links > 1 && idx.Has(node.Inode, node.DeviceID) {
+				if res.progress != nil {
+					// a hardlinked file does not increase the restore size
+					res.progress.AddFile(0)
 				}
-				idx.Add(node.Inode, node.DeviceID, location)
+				return checkConflict(target, location)
 			}
 
 			if res.progress != nil {
 				res.progress.AddFile(node.Size)
 			}
 
-			filerestorer.addFile(location, node.Content, int64(node.Size))
+			skip := res.skipFile(node, target, location)
+			if skip {
+				debug.Log("skipping content of unchanged file %q", location)
+			} else {
+				skip, err = res.conflicts.check(target, location)
+				if err != nil {
+					return err
+				}
+				if skip {
+					debug.Log("skipping conflicting file %q", location)
+				}
+			}
+			if skip {
+				if res.progress != nil {
+					res.progress.AddProgress(location, node.Size, node.Size)
+				}
+				// other links to a conflicting file are restored from the snapshot
+				if node.Links > 1 && !res.conflicts.isSkipped(location) {
+					idx.Add(node.Inode, node.DeviceID, location)
+				}
+				return nil
+			}
+
+			if node.Links > 1 {
+				idx.Add(node.Inode, node.DeviceID, location)
+			}
+			filerestorer.addFile(location, node.Content, int64(node.Size), node.ModTime)
 
 			return nil
 		},
@@ -296,6 +561,11 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
 		return err
 	}
 
+	err = res.conflicts.resolve()
+	if err != nil {
+		return err
+	}
+
 	err = filerestorer.restoreFiles(ctx)
 	if err != nil {
 		return err
@@ -304,9 +574,14 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
 	debug.Log("second pass for %q", dst)
 
 	// second tree pass: restore special files and filesystem metadata
-	_, err = res.traverseTree(ctx, dst, string(filepath.Separator), *res.sn.Tree, treeVisitor{
+	_, err = res.traverseTree(ctx, dst, dst, string(filepath.Separator), *res.sn.Tree, treeVisitor{
 		visitNode: func(node *restic.Node, target, location string) error {
 			debug.Log("second pass, visitNode: restore node %q", location)
+			if res.conflicts.isSkipped(location) {
+				return nil
+			}
+			target = res.conflicts.target(target, location)
+
 			if node.Type != "file" {
 				return res.restoreNodeTo(ctx, node, target, location)
 			}
@@ -323,7 +598,11 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
 				return res.restoreHardlinkAt(node, filerestorer.targetPath(idx.Value(node.Inode, node.DeviceID)), target, location)
 			}
 
-			return res.restoreNodeMetadataTo(node, target, location)
+			err := res.restoreNodeMetadataTo(node, target, location)
+			if err == nil && res.State != nil {
+				err = res.State.MarkCompleted(location)
+			}
+			return err
 		},
 		leaveDir: func(node *restic.Node, target, location string) error {
 			err := res.restoreNodeMetadataTo(node, target, location)
@@ -365,11 +644,12 @@ func (res *Restorer) VerifyFiles(ctx context.Context, dst string) (int, error) {
 	g.Go(func() error {
 		defer close(work)
 
-		_, err := res.traverseTree(ctx, dst, string(filepath.Separator), *res.sn.Tree, treeVisitor{
+		_, err := res.traverseTree(ctx, dst, dst, string(filepath.Separator), *res.sn.Tree, treeVisitor{
 			visitNode: func(node *restic.Node, target, location string) error {
-				if node.Type != "file" {
+				if node.Type != "file" || res.conflicts.isSkipped(location) {
 					return nil
 				}
+				target = res.conflicts.target(target, location)
 				select {
 				case <-ctx.Done():
 					return ctx.Err()
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
@@ -682,7 +683,7 @@ func TestRestorerTraverseTree(t *testing.T) {
 			// make sure we're creating a new subdir of the tempdir
 			target := filepath.Join(tempdir, "target")
 
-			_, err := res.traverseTree(ctx, target, string(filepath.Separator), *sn.Tree, test.Visitor(t))
+			_, err := res.traverseTree(ctx, target, target, string(filepath.Separator), *sn.Tree, test.Visitor(t))
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -875,3 +876,330 @@ func TestRestorerSparseFiles(t *testing.T) {
 	t.Logf("wrote %d zeros as %d blocks, %.1f%% sparse",
 		len(zeros), blocks, 100*sparsity)
 }
+
+func TestRestorerOverwriteIfChanged(t *testing.T) {
+	modtime := time.Date(2023, 4, 5, 6, 7, 8, 0, time.Local)
+	repo := repository.TestRepository(t)
+	sn, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"unchanged": File{Data: "content: unchanged\n", ModTime: modtime},
+			"modified":  File{Data: "content: modified\n", ModTime: modtime},
+			"truncated": File{Data: "content: truncated\n", ModTime: modtime},
+		},
+	})
+
+	tempdir := rtest.TempDir(t)
+	// same size and modification time, the content is not checked
+	rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "unchanged"), []byte("content: XXXXXXXXX\n"), 0644))
+	rtest.OK(t, os.Chtimes(filepath.Join(tempdir, "unchanged"), modtime, modtime))
+	rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "modified"), []byte("content: XXXXXXXX\n"), 0644))
+	rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "truncated"), []byte("content"), 0644))
+	rtest.OK(t, os.Chtimes(filepath.Join(tempdir, "truncated"), modtime, modtime))
+
+	res := NewRestorer(repo, sn, false, nil)
+	res.Overwrite = OverwriteIfChanged
+	rtest.OK(t, res.RestoreTo(context.TODO(), tempdir))
+
+	for name, data := range map[string]string{
+		"unchanged": "content: XXXXXXXXX\n",
+		"modified":  "content: modified\n",
+		"truncated": "content: truncated\n",
+	} {
+		content, err := os.ReadFile(filepath.Join(tempdir, name))
+		rtest.OK(t, err)
+		rtest.Equals(t, data, string(content))
+	}
+}
+
+func TestRestorerResume(t *testing.T) {
+	modtime := time.Date(2023, 4, 5, 6, 7, 8, 0, time.Local)
+	repo := repository.TestRepository(t)
+	sn, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"dir": Dir{
+				Nodes: map[string]Node{
+					"completed": File{Data: "content: completed\n", ModTime: modtime},
+					"partial":   File{Data: "content: partial\n", ModTime: modtime},
+				},
+			},
+		},
+	})
+
+	tempdir := rtest.TempDir(t)
+	statefile := filepath.Join(rtest.TempDir(t), "state")
+
+	// simulate an interrupted restore, which completed one file and left the
+	// second one with the final size but without restored metadata
+	rtest.OK(t, os.MkdirAll(filepath.Join(tempdir, "dir"), 0700))
+	rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "dir", "completed"), []byte("content: XXXXXXXXX\n"), 0644))
+	rtest.OK(t, os.Chtimes(filepath.Join(tempdir, "dir", "completed"), modtime, modtime))
+	rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "dir", "partial"), []byte("content: XXXXXXX\n"), 0644))
+
+	state, err := OpenState(statefile)
+	rtest.OK(t, err)
+	rtest.OK(t, state.MarkCompleted(filepath.FromSlash("/dir/completed")))
+	rtest.OK(t, state.Close())
+
+	state, err = OpenState(statefile)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, state.Len())
+
+	res := NewRestorer(repo, sn, false, nil)
+	res.State = state
+	rtest.OK(t, res.RestoreTo(context.TODO(), tempdir))
+	rtest.Equals(t, 2, state.Len())
+	rtest.OK(t, state.Remove())
+
+	for name, data := range map[string]string{
+		"completed": "content: XXXXXXXXX\n",
+		"partial":   "content: partial\n",
+	} {
+		content, err := os.ReadFile(filepath.Join(tempdir, "dir", name))
+		rtest.OK(t, err)
+		rtest.Equals(t, data, string(content))
+	}
+
+	_, err = os.Stat(statefile)
+	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "state file was not removed: %v", err)
+}
+
+func TestRestorerPathMap(t *testing.T) {
+	repo := repository.TestRepository(t)
+	sn, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"home": Dir{
+				Nodes: map[string]Node{
+					"user": Dir{
+						Nodes: map[string]Node{
+							"file":   File{Data: "content: file\n"},
+							"link":   File{Data: "content: linked\n", Links: 2, Inode: 1},
+							"link2":  File{Data: "content: linked\n", Links: 2, Inode: 1},
+							"subdir": Dir{Nodes: map[string]Node{"other": File{Data: "content: other\n"}}},
+						},
+					},
+				},
+			},
+			"etc": Dir{Nodes: map[string]Node{"config": File{Data: "content: config\n"}}},
+		},
+	})
+
+	pathMap, err := restic.ParsePathMap([]string{
+		filepath.FromSlash("/home/user") + ":" + filepath.FromSlash("/srv/data"),
+	})
+	rtest.OK(t, err)
+
+	tempdir := rtest.TempDir(t)
+	res := NewRestorer(repo, sn, false, nil)
+	res.PathMap = pathMap
+	rtest.OK(t, res.RestoreTo(context.TODO(), tempdir))
+
+	nverified, err := res.VerifyFiles(context.TODO(), tempdir)
+	rtest.OK(t, err)
+	rtest.Equals(t, 5, nverified)
+
+	for name, data := range map[string]string{
+		"srv/data/file":         "content: file\n",
+		"srv/data/link":         "content: linked\n",
+		"srv/data/link2":        "content: linked\n",
+		"srv/data/subdir/other": "content: other\n",
+		"etc/config":            "content: config\n",
+	} {
+		content, err := os.ReadFile(filepath.Join(tempdir, filepath.FromSlash(name)))
+		rtest.OK(t, err)
+		rtest.Equals(t, data, string(content))
+	}
+
+	_, err = os.Stat(filepath.Join(tempdir, "home", "user"))
+	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "mapped directory was restored at the old path: %v", err)
+}
+
+func TestRestorerOnConflict(t *testing.T) {
+	repo := repository.TestRepository(t)
+	sn, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"file":          File{Data: "content: file\n"},
+			"file.restored": File{Data: "content: file.restored\n"},
+			"empty":         File{Data: ""},
+			"new":           File{Data: "content: new\n"},
+			"link":          File{Data: "content: linked\n", Links: 2, Inode: 1},
+			"link2":         File{Data: "content: linked\n", Links: 2, Inode: 1},
+		},
+	})
+
+	for _, test := range []struct {
+		behavior  ConflictBehavior
+		files     map[string]string
+		conflicts map[string]string
+	}{
+		{
+			behavior: ConflictSkip,
+			files: map[string]string{
+				"file":          "existing: file\n",
+				"file.restored": "content: file.restored\n",
+				"empty":         "existing: empty\n",
+				"new":           "content: new\n",
+				"link":          "existing: link\n",
+				"link2":         "content: linked\n",
+			},
+			conflicts: map[string]string{
+				"/empty": "empty",
+				"/file":  "file",
+				"/link":  "link",
+			},
+		},
+		{
+			behavior: ConflictRename,
+			files: map[string]string{
+				"file":            "existing: file\n",
+				"file.restored":   "content: file.restored\n",
+				"file.restored.1": "content: file\n",
+				"empty":           "existing: empty\n",
+				"empty.restored":  "",
+				"new":             "content: new\n",
+				"link":            "existing: link\n",
+				"link.restored":   "content: linked\n",
+				"link2":           "content: linked\n",
+			},
+			conflicts: map[string]string{
+				"/empty": "empty.restored",
+				"/file":  "file.restored.1",
+				"/link":  "link.restored",
+			},
+		},
+	} {
+		t.Run(test.behavior.String(), func(t *testing.T) {
+			tempdir := rtest.TempDir(t)
+			for _, name := range []string{"file", "empty", "link"} {
+				rtest.OK(t, os.WriteFile(filepath.Join(tempdir, name), []byte("existing: "+name+"\n"), 0644))
+			}
+
+			conflicts := make(map[string]string)
+			res := NewRestorer(repo, sn, false, nil)
+			res.OnConflict = test.behavior
+			res.Conflict = func(location, target string, action ConflictBehavior) {
+				rtest.Equals(t, test.behavior, action)
+				rel, err := filepath.Rel(tempdir, target)
+				rtest.OK(t, err)
+				conflicts[filepath.ToSlash(location)] = filepath.ToSlash(rel)
+			}
+			rtest.OK(t, res.RestoreTo(context.TODO(), tempdir))
+			rtest.Equals(t, test.conflicts, conflicts)
+
+			entries, err := os.ReadDir(tempdir)
+			rtest.OK(t, err)
+			rtest.Equals(t, len(test.files), len(entries))
+			for name, data := range test.files {
+				content, err := os.ReadFile(filepath.Join(tempdir, name))
+				rtest.OK(t, err)
+				rtest.Equals(t, data, string(content))
+			}
+
+			// skipped files are not verified, renamed ones at their new path
+			_, err = res.VerifyFiles(context.TODO(), tempdir)
+			rtest.OK(t, err)
+		})
+	}
+}
+
+func TestRestorerPlan(t *testing.T) {
+	modtime := time.Date(2023, 4, 5, 6, 7, 8, 0, time.Local)
+	repo := repository.TestRepository(t)
+	sn, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"unchanged": File{Data: "content: unchanged\n", ModTime: modtime},
+			"modified":  File{Data: "content: modified\n", ModTime: modtime},
+			"dir": Dir{Nodes: map[string]Node{
+				"new":  File{Data: "content: new\n"},
+				"copy": File{Data: "content: new\n"},
+			}},
+		},
+	})
+
+	tempdir := rtest.TempDir(t)
+	rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "unchanged"), []byte("content: unchanged\n"), 0644))
+	rtest.OK(t, os.Chtimes(filepath.Join(tempdir, "unchanged"), modtime, modtime))
+	rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "modified"), []byte("existing\n"), 0644))
+
+	for _, test := range []struct {
+		behavior ConflictBehavior
+		actions  map[string]PlanAction
+		targets  map[string]string
+		write    uint64
+		download []string
+	}{
+		{
+			behavior: ConflictOverwrite,
+			actions: map[string]PlanAction{
+				"/unchanged": PlanUnchanged,
+				"/modified":  PlanOverwrite,
+				"/dir/new":   PlanCreate,
+				"/dir/copy":  PlanCreate,
+			},
+			write:    44,
+			download: []string{"content: modified\n", "content: new\n"},
+		},
+		{
+			behavior: ConflictSkip,
+			actions: map[string]PlanAction{
+				"/unchanged": PlanUnchanged,
+				"/modified":  PlanSkip,
+				"/dir/new":   PlanCreate,
+				"/dir/copy":  PlanCreate,
+			},
+			write:    26,
+			download: []string{"content: new\n"},
+		},
+		{
+			behavior: ConflictRename,
+			actions: map[string]PlanAction{
+				"/unchanged": PlanUnchanged,
+				"/modified":  PlanRename,
+				"/dir/new":   PlanCreate,
+				"/dir/copy":  PlanCreate,
+			},
+			targets: map[string]string{
+				"/modified": "modified.restored",
+			},
+			write:    44,
+			download: []string{"content: modified\n", "content: new\n"},
+		},
+	} {
+		t.Run(test.behavior.String(), func(t *testing.T) {
+			res := NewRestorer(repo, sn, false, nil)
+			res.Overwrite = OverwriteIfChanged
+			res.OnConflict = test.behavior
+			plan, err := res.Plan(context.TODO(), tempdir)
+			rtest.OK(t, err)
+
+			actions := make(map[string]PlanAction)
+			for _, node := range plan.Nodes {
+				location := filepath.ToSlash(node.Location)
+				actions[location] = node.Action
+				target, ok := test.targets[location]
+				if !ok {
+					target = location[1:]
+				}
+				rel, err := filepath.Rel(tempdir, node.Target)
+				rtest.OK(t, err)
+				rtest.Equals(t, target, filepath.ToSlash(rel))
+			}
+			rtest.Equals(t, test.actions, actions)
+			rtest.Equals(t, uint64(63), plan.TotalBytes)
+			rtest.Equals(t, test.write, plan.WriteBytes)
+
+			// the content of both new files is only downloaded once
+			var download uint64
+			for _, data := range test.download {
+				pbs := repo.Index().Lookup(restic.BlobHandle{ID: restic.Hash([]byte(data)), Type: restic.DataBlob})
+				rtest.Equals(t, 1, len(pbs))
+				download += uint64(pbs[0].Length)
+			}
+			rtest.Equals(t, download, plan.DownloadBytes)
+
+			// nothing is written
+			entries, err := os.ReadDir(tempdir)
+			rtest.OK(t, err)
+			rtest.Equals(t, 2, len(entries))
+		})
+	}
+}
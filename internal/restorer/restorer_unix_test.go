@@ -121,3 +121,105 @@ func TestRestorerProgressBar(t *testing.T) {
 	rtest.Assert(t, mock.allBytesWritten == allBytesWritten, "allBytesWritten: expected %v, got %v", allBytesWritten, mock.allBytesWritten)
 	rtest.Assert(t, mock.allBytesTotal == allBytesTotal, "allBytesTotal: expected %v, got %v", allBytesTotal, mock.allBytesTotal)
 }
+
+func TestRestorerXattrFilter(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "file")
+	rtest.OK(t, os.WriteFile(target, nil, 0600))
+	err := restic.Setxattr(target, "user.probe", []byte("probe"))
+	if value, _ := restic.Getxattr(target, "user.probe"); err != nil || value == nil {
+		t.Skip("extended attributes are not supported on this file system")
+	}
+
+	node := &restic.Node{
+		Type:    "file",
+		Mode:    0600,
+		ModTime: time.Now(),
+		UID:     uint32(os.Getuid()),
+		GID:     uint32(os.Getgid()),
+		ExtendedAttributes: []restic.ExtendedAttribute{
+			{Name: "user.foo", Value: []byte("foo")},
+			{Name: "user.bar", Value: []byte("bar")},
+			// an empty name is rejected by the kernel
+			{Name: "", Value: []byte("invalid")},
+		},
+	}
+
+	var warnings []string
+	res := &Restorer{
+		XattrFilter: func(name string) bool { return name != "user.bar" },
+		Warn: func(location string, err error) {
+			warnings = append(warnings, location)
+		},
+	}
+	rtest.OK(t, res.restoreNodeMetadataTo(node, target, "/file"))
+
+	value, err := restic.Getxattr(target, "user.foo")
+	rtest.OK(t, err)
+	rtest.Equals(t, []byte("foo"), value)
+	value, err = restic.Getxattr(target, "user.bar")
+	rtest.OK(t, err)
+	rtest.Assert(t, value == nil, "filtered xattr was restored: %q", value)
+	rtest.Equals(t, []string{"/file"}, warnings)
+}
+
+func TestRestorerMetadataOnly(t *testing.T) {
+	modtime := time.Date(2023, 4, 5, 6, 7, 8, 0, time.Local)
+	repo := repository.TestRepository(t)
+	sn, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"unchanged": File{Data: "content: unchanged\n", Mode: 0600, ModTime: modtime},
+			"modified":  File{Data: "content: modified\n", Mode: 0600, ModTime: modtime},
+			"empty":     File{Data: "", Mode: 0600, ModTime: modtime},
+			"missing":   File{Data: "content: missing\n", Mode: 0600, ModTime: modtime},
+		},
+	})
+
+	for _, test := range []struct {
+		name           string
+		noContentCheck bool
+		files          map[string]string
+	}{
+		{
+			name: "content-check",
+			files: map[string]string{
+				"unchanged": "content: unchanged\n",
+				"modified":  "content: modified\n",
+				"empty":     "",
+				"missing":   "content: missing\n",
+			},
+		},
+		{
+			name:           "no-content-check",
+			noContentCheck: true,
+			files: map[string]string{
+				"unchanged": "content: unchanged\n",
+				"modified":  "content: XXXXXXXX\n",
+				"empty":     "existing\n",
+				"missing":   "content: missing\n",
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			tempdir := rtest.TempDir(t)
+			rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "unchanged"), []byte("content: unchanged\n"), 0644))
+			rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "modified"), []byte("content: XXXXXXXX\n"), 0644))
+			rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "empty"), []byte("existing\n"), 0644))
+
+			res := NewRestorer(repo, sn, false, nil)
+			res.MetadataOnly = true
+			res.NoContentCheck = test.noContentCheck
+			rtest.OK(t, res.RestoreTo(context.TODO(), tempdir))
+
+			for name, data := range test.files {
+				content, err := os.ReadFile(filepath.Join(tempdir, name))
+				rtest.OK(t, err)
+				rtest.Equals(t, data, string(content))
+
+				fi, err := os.Stat(filepath.Join(tempdir, name))
+				rtest.OK(t, err)
+				rtest.Equals(t, os.FileMode(0600), fi.Mode().Perm())
+				rtest.Assert(t, fi.ModTime().Equal(modtime), "unexpected modification time %v for %v", fi.ModTime(), name)
+			}
+		})
+	}
+}
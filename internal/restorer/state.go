@@ -0,0 +1,127 @@
+package restorer
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// State keeps track of the files an interrupted restore has already
+// completed. Each completed file is appended as a single line with its quoted
+// location within the snapshot, so that the state survives a crash at any
+// point in time. It is safe for concurrent use.
+type State struct {
+	filename  string
+	m         sync.Mutex
+	f         *os.File
+	completed map[string]struct{}
+}
+
+// StateFilename returns the name of the state file in dir for restoring the
+// tree id to target.
+func StateFilename(dir string, id restic.ID, target string) string {
+	key := restic.Hash([]byte(id.String() + "\x00" + target))
+	return filepath.Join(dir, key.String())
+}
+
+// OpenState loads the state from filename, if it exists, and opens it for
+// recording newly completed files.
+func OpenState(filename string) (*State, error) {
+	s := &State{
+		filename:  filename,
+		completed: make(map[string]struct{}),
+	}
+
+	err := os.MkdirAll(filepath.Dir(filename), 0700)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		location, err := strconv.Unquote(sc.Text())
+		if err != nil {
+			// ignore a partially written last line
+			continue
+		}
+		s.completed[location] = struct{}{}
+	}
+	if err := sc.Err(); err != nil {
+		_ = f.Close()
+		return nil, errors.Wrap(err, "read restore state")
+	}
+
+	// terminate a partially written last line, new entries must not be
+	// appended to it
+	fi, err := f.Stat()
+	if err == nil && fi.Size() > 0 {
+		last := make([]byte, 1)
+		_, err = f.ReadAt(last, fi.Size()-1)
+		if err == nil && last[0] != '\n' {
+			_, err = f.WriteString("\n")
+		}
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, errors.Wrap(err, "read restore state")
+	}
+
+	s.f = f
+	return s, nil
+}
+
+// Len returns the number of files recorded as completed.
+func (s *State) Len() int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return len(s.completed)
+}
+
+// Completed returns true if the file at location was recorded as completed.
+func (s *State) Completed(location string) bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+	_, ok := s.completed[location]
+	return ok
+}
+
+// MarkCompleted records that the file at location has been restored.
+func (s *State) MarkCompleted(location string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if _, ok := s.completed[location]; ok {
+		return nil
+	}
+
+	_, err := s.f.WriteString(strconv.Quote(location) + "\n")
+	if err != nil {
+		return errors.Wrap(err, "write restore state")
+	}
+	s.completed[location] = struct{}{}
+	return nil
+}
+
+// Close closes the state file, it is kept for resuming the restore later.
+func (s *State) Close() error {
+	return s.f.Close()
+}
+
+// Remove closes and removes the state file, it must be called once the
+// restore has finished successfully.
+func (s *State) Remove() error {
+	err := s.f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(s.filename)
+}
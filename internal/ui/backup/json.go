@@ -146,6 +146,12 @@ func (b *JSONProgress) CompleteItem(messageType, item string, s archiver.ItemSta
 			DataSize:       s.DataSize,
 			DataSizeInRepo: s.DataSizeInRepo,
 		})
+	case "file skipped":
+		b.print(verboseUpdate{
+			MessageType: "verbose_status",
+			Action:      "skipped",
+			Item:        item,
+		})
 	}
 }
 
@@ -164,23 +170,60 @@ func (b *JSONProgress) ReportTotal(start time.Time, s archiver.ScanStats) {
 
 // Finish prints the finishing messages.
 func (b *JSONProgress) Finish(snapshotID restic.ID, start time.Time, summary *Summary, dryRun bool) {
-	b.print(summaryOutput{
-		MessageType:         "summary",
+	b.print(newSummaryOutput("summary", snapshotID, time.Since(start), summary, dryRun))
+}
+
+// FinishGroup prints the summary of the snapshot saved for a group of backup
+// targets.
+func (b *JSONProgress) FinishGroup(tag string, snapshotID restic.ID, d time.Duration, summary *Summary, dryRun bool) {
+	b.print(groupSummaryOutput{
+		summaryOutput: newSummaryOutput("group_summary", snapshotID, d, summary, dryRun),
+		Tag:           tag,
+	})
+}
+
+func newSummaryOutput(messageType string, snapshotID restic.ID, d time.Duration, summary *Summary, dryRun bool) summaryOutput {
+	id := ""
+	// empty if snapshot creation was skipped
+	if !snapshotID.IsNull() {
+		id = snapshotID.String()
+	}
+	return summaryOutput{
+		MessageType:         messageType,
 		FilesNew:            summary.Files.New,
 		FilesChanged:        summary.Files.Changed,
 		FilesUnmodified:     summary.Files.Unchanged,
+		FilesSkipped:        summary.Files.Skipped,
+		FilesExcludedBySize: summary.Files.ExcludedBySize,
+		BytesExcludedBySize: summary.BytesExcludedBySize,
 		DirsNew:             summary.Dirs.New,
 		DirsChanged:         summary.Dirs.Changed,
 		DirsUnmodified:      summary.Dirs.Unchanged,
 		DataBlobs:           summary.ItemStats.DataBlobs,
 		TreeBlobs:           summary.ItemStats.TreeBlobs,
 		DataAdded:           summary.ItemStats.DataSize + summary.ItemStats.TreeSize,
+		DataAddedPacked:     summary.ItemStats.DataSizeInRepo + summary.ItemStats.TreeSizeInRepo,
+		DataReused:          summary.reusedBytes(),
 		TotalFilesProcessed: summary.Files.New + summary.Files.Changed + summary.Files.Unchanged,
 		TotalBytesProcessed: summary.ProcessedBytes,
-		TotalDuration:       time.Since(start).Seconds(),
-		SnapshotID:          snapshotID.String(),
+		TotalDuration:       d.Seconds(),
+		SnapshotID:          id,
 		DryRun:              dryRun,
-	})
+	}
+}
+
+// FinishSecondary prints the result of copying the snapshot to a secondary repository.
+func (b *JSONProgress) FinishSecondary(repository string, snapshotID restic.ID, err error) {
+	out := secondarySummaryOutput{
+		MessageType: "secondary_summary",
+		Repository:  repository,
+	}
+	if err != nil {
+		out.Error = err.Error()
+	} else {
+		out.SnapshotID = snapshotID.String()
+	}
+	b.print(out)
 }
 
 // Reset no-op
@@ -219,20 +262,37 @@ type verboseUpdate struct {
 	TotalFiles         uint    `json:"total_files"`
 }
 
+type groupSummaryOutput struct {
+	summaryOutput        // message_type "group_summary"
+	Tag           string `json:"tag,omitempty"`
+}
+
+type secondarySummaryOutput struct {
+	MessageType string `json:"message_type"` // "secondary_summary"
+	Repository  string `json:"repository"`
+	SnapshotID  string `json:"snapshot_id,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
 type summaryOutput struct {
-	MessageType         string  `json:"message_type"` // "summary"
+	MessageType         string  `json:"message_type"` // "summary" or "group_summary"
 	FilesNew            uint    `json:"files_new"`
 	FilesChanged        uint    `json:"files_changed"`
 	FilesUnmodified     uint    `json:"files_unmodified"`
+	FilesSkipped        uint    `json:"files_skipped,omitempty"`
+	FilesExcludedBySize uint    `json:"files_excluded_by_size,omitempty"`
+	BytesExcludedBySize uint64  `json:"bytes_excluded_by_size,omitempty"`
 	DirsNew             uint    `json:"dirs_new"`
 	DirsChanged         uint    `json:"dirs_changed"`
 	DirsUnmodified      uint    `json:"dirs_unmodified"`
 	DataBlobs           int     `json:"data_blobs"`
 	TreeBlobs           int     `json:"tree_blobs"`
 	DataAdded           uint64  `json:"data_added"`
+	DataAddedPacked     uint64  `json:"data_added_packed"`
+	DataReused          uint64  `json:"data_reused"`
 	TotalFilesProcessed uint    `json:"total_files_processed"`
 	TotalBytesProcessed uint64  `json:"total_bytes_processed"`
 	TotalDuration       float64 `json:"total_duration"` // in seconds
-	SnapshotID          string  `json:"snapshot_id"`
+	SnapshotID          string  `json:"snapshot_id,omitempty"`
 	DryRun              bool    `json:"dry_run,omitempty"`
 }
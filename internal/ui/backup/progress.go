@@ -18,6 +18,10 @@ type ProgressPrinter interface {
 	CompleteItem(messageType string, item string, s archiver.ItemStats, d time.Duration)
 	ReportTotal(start time.Time, s archiver.ScanStats)
 	Finish(snapshotID restic.ID, start time.Time, summary *Summary, dryRun bool)
+	// FinishSecondary reports the result of copying the snapshot to a secondary repository.
+	FinishSecondary(repository string, snapshotID restic.ID, err error)
+	// FinishGroup reports the snapshot saved for a group of --group-snapshots-by-tag.
+	FinishGroup(tag string, snapshotID restic.ID, d time.Duration, summary *Summary, dryRun bool)
 	Reset()
 
 	P(msg string, args ...interface{})
@@ -33,11 +37,56 @@ type Summary struct {
 		New       uint
 		Changed   uint
 		Unchanged uint
+		Skipped   uint
+		// files excluded by --exclude-larger-than
+		ExcludedBySize uint
 	}
-	ProcessedBytes uint64
+	ProcessedBytes      uint64
+	BytesExcludedBySize uint64
 	archiver.ItemStats
 }
 
+// reusedBytes returns the amount of processed file data which did not have to
+// be added to the repository as it was already stored.
+func (s *Summary) reusedBytes() uint64 {
+	if s.ProcessedBytes < s.ItemStats.DataSize {
+		return 0
+	}
+	return s.ProcessedBytes - s.ItemStats.DataSize
+}
+
+// sub returns the statistics collected since prev.
+func (s Summary) sub(prev Summary) Summary {
+	s.Files.New -= prev.Files.New
+	s.Files.Changed -= prev.Files.Changed
+	s.Files.Unchanged -= prev.Files.Unchanged
+	s.Files.Skipped -= prev.Files.Skipped
+	s.Files.ExcludedBySize -= prev.Files.ExcludedBySize
+	s.Dirs.New -= prev.Dirs.New
+	s.Dirs.Changed -= prev.Dirs.Changed
+	s.Dirs.Unchanged -= prev.Dirs.Unchanged
+	s.Dirs.Skipped -= prev.Dirs.Skipped
+	s.Dirs.ExcludedBySize -= prev.Dirs.ExcludedBySize
+	s.ProcessedBytes -= prev.ProcessedBytes
+	s.BytesExcludedBySize -= prev.BytesExcludedBySize
+	s.ItemStats.DataBlobs -= prev.ItemStats.DataBlobs
+	s.ItemStats.DataSize -= prev.ItemStats.DataSize
+	s.ItemStats.DataSizeInRepo -= prev.ItemStats.DataSizeInRepo
+	s.ItemStats.TreeBlobs -= prev.ItemStats.TreeBlobs
+	s.ItemStats.TreeSize -= prev.ItemStats.TreeSize
+	s.ItemStats.TreeSizeInRepo -= prev.ItemStats.TreeSizeInRepo
+	return s
+}
+
+// GroupSummary contains the statistics of the snapshot saved for a group of
+// backup targets.
+type GroupSummary struct {
+	Tag        string
+	SnapshotID restic.ID
+	Duration   time.Duration
+	Summary
+}
+
 // Progress reports progress for the `backup` command.
 type Progress struct {
 	progress.Updater
@@ -54,11 +103,17 @@ type Progress struct {
 
 	summary Summary
 	printer ProgressPrinter
+
+	// statistics of the snapshots saved for groups of backup targets
+	groups     []GroupSummary
+	groupStart time.Time
+	groupPrev  Summary
 }
 
 func NewProgress(printer ProgressPrinter, interval time.Duration) *Progress {
 	p := &Progress{
 		start:        time.Now(),
+		groupStart:   time.Now(),
 		currentFiles: make(map[string]struct{}),
 		printer:      printer,
 		estimator:    *newRateEstimator(time.Now()),
@@ -73,6 +128,8 @@ func NewProgress(printer ProgressPrinter, interval time.Duration) *Progress {
 				return
 			}
 
+			total := reconcileTotal(p.total, p.processed)
+
 			var secondsRemaining uint64
 			if p.scanFinished {
 				rate := p.estimator.rate(time.Now())
@@ -80,17 +137,38 @@ func NewProgress(printer ProgressPrinter, interval time.Duration) *Progress {
 				if rate <= tooSlowCutoff {
 					secondsRemaining = 0
 				} else {
-					todo := float64(p.total.Bytes - p.processed.Bytes)
+					todo := float64(total.Bytes - p.processed.Bytes)
 					secondsRemaining = uint64(todo / rate)
 				}
 			}
 
-			p.printer.Update(p.total, p.processed, p.errors, p.currentFiles, p.start, secondsRemaining)
+			p.printer.Update(total, p.processed, p.errors, p.currentFiles, p.start, secondsRemaining)
 		}
 	})
 	return p
 }
 
+// reconcileTotal returns the total estimated by the scanner, raised to at least
+// the processed counts. Files which were added or have grown after they were
+// scanned would otherwise result in a progress of more than 100 percent.
+func reconcileTotal(total, processed Counter) Counter {
+	if total.Files == 0 && total.Dirs == 0 {
+		// no estimate available
+		return total
+	}
+
+	if processed.Files > total.Files {
+		total.Files = processed.Files
+	}
+	if processed.Dirs > total.Dirs {
+		total.Dirs = processed.Dirs
+	}
+	if processed.Bytes > total.Bytes {
+		total.Bytes = processed.Bytes
+	}
+	return total
+}
+
 // Error is the error callback function for the archiver, it prints the error and returns nil.
 func (p *Progress) Error(item string, err error) error {
 	p.mu.Lock()
@@ -198,6 +276,27 @@ func (p *Progress) CompleteItem(item string, previous, current *restic.Node, s a
 	}
 }
 
+// SkipFile is called for files which are not included in the snapshot,
+// because the limit for new data was reached.
+func (p *Progress) SkipFile(item string, size uint64) {
+	p.mu.Lock()
+	p.addProcessed(Counter{Files: 1, Bytes: size})
+	p.summary.Files.Skipped++
+	p.mu.Unlock()
+
+	p.printer.CompleteItem("file skipped", item, archiver.ItemStats{}, 0)
+}
+
+// ExcludedBySize records the number and the total size of the files which
+// were excluded from the backup because they were too large.
+func (p *Progress) ExcludedBySize(files uint, bytes uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.summary.Files.ExcludedBySize = files
+	p.summary.BytesExcludedBySize = bytes
+}
+
 // ReportTotal sets the total stats up to now
 func (p *Progress) ReportTotal(item string, s archiver.ScanStats) {
 	p.mu.Lock()
@@ -212,9 +311,50 @@ func (p *Progress) ReportTotal(item string, s archiver.ScanStats) {
 	}
 }
 
-// Finish prints the finishing messages.
+// EndGroup records the statistics collected since the previous group for the
+// snapshot saved for a group of backup targets.
+func (p *Progress) EndGroup(tag string, snapshotID restic.ID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	p.groups = append(p.groups, GroupSummary{
+		Tag:        tag,
+		SnapshotID: snapshotID,
+		Duration:   now.Sub(p.groupStart),
+		Summary:    p.summary.sub(p.groupPrev),
+	})
+	p.groupStart = now
+	p.groupPrev = p.summary
+}
+
+// Finish prints the finishing messages. If groups were recorded using
+// EndGroup, a summary is printed for each group instead of the total.
 func (p *Progress) Finish(snapshotID restic.ID, dryrun bool) {
 	// wait for the status update goroutine to shut down
 	p.Updater.Done()
-	p.printer.Finish(snapshotID, p.start, &p.summary, dryrun)
+	if len(p.groups) == 0 {
+		p.printer.Finish(snapshotID, p.start, &p.summary, dryrun)
+		return
+	}
+	for i := range p.groups {
+		g := &p.groups[i]
+		p.printer.FinishGroup(g.Tag, g.SnapshotID, g.Duration, &g.Summary, dryrun)
+	}
+}
+
+// Summary returns the statistics of the backup. It must only be called after
+// Finish.
+func (p *Progress) Summary() Summary {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.summary
+}
+
+// Groups returns the statistics recorded using EndGroup. It must only be
+// called after Finish.
+func (p *Progress) Groups() []GroupSummary {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.groups
 }
@@ -41,6 +41,9 @@ func (p *mockPrinter) Finish(id restic.ID, _ time.Time, summary *Summary, _ bool
 	p.id = id
 }
 
+func (p *mockPrinter) FinishSecondary(_ string, _ restic.ID, _ error) {}
+func (p *mockPrinter) FinishGroup(_ string, _ restic.ID, _ time.Duration, _ *Summary, _ bool) {
+}
 func (p *mockPrinter) Reset() {}
 
 func (p *mockPrinter) P(_ string, _ ...interface{}) {}
@@ -76,3 +79,20 @@ func TestProgress(t *testing.T) {
 		t.Errorf("id not stored (has %v)", prnt.id)
 	}
 }
+
+func TestReconcileTotal(t *testing.T) {
+	for _, test := range []struct {
+		total, processed, expected Counter
+	}{
+		// no estimate available, for example with --no-scan
+		{Counter{}, Counter{Files: 3, Bytes: 100}, Counter{}},
+		{Counter{Files: 10, Dirs: 2, Bytes: 1000}, Counter{Files: 3, Bytes: 100}, Counter{Files: 10, Dirs: 2, Bytes: 1000}},
+		// files were added or have grown after the scan
+		{Counter{Files: 10, Dirs: 2, Bytes: 1000}, Counter{Files: 12, Dirs: 1, Bytes: 1500}, Counter{Files: 12, Dirs: 2, Bytes: 1500}},
+	} {
+		total := reconcileTotal(test.total, test.processed)
+		if total != test.expected {
+			t.Errorf("reconcileTotal(%v, %v) = %v, want %v", test.total, test.processed, total, test.expected)
+		}
+	}
+}
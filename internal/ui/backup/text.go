@@ -107,6 +107,8 @@ func (b *TextProgress) CompleteItem(messageType, item string, s archiver.ItemSta
 	case "file modified":
 		b.VV("modified  %v, saved in %.3fs (%v added, %v stored)", item,
 			d.Seconds(), ui.FormatBytes(s.DataSize), ui.FormatBytes(s.DataSizeInRepo))
+	case "file skipped":
+		b.V("skipped   %v, limit for new data reached", item)
 	}
 }
 
@@ -127,9 +129,37 @@ func (b *TextProgress) Reset() {
 
 // Finish prints the finishing messages.
 func (b *TextProgress) Finish(_ restic.ID, start time.Time, summary *Summary, dryRun bool) {
+	b.printSummary(time.Since(start), summary, dryRun)
+}
+
+// FinishGroup prints the summary of the snapshot saved for a group of backup
+// targets.
+func (b *TextProgress) FinishGroup(tag string, snapshotID restic.ID, d time.Duration, summary *Summary, dryRun bool) {
+	group := "with tag " + tag
+	if tag == "" {
+		group = "for the remaining targets"
+	}
+	b.printSummary(d, summary, dryRun)
+	switch {
+	case dryRun:
+		b.P("would save snapshot %s\n", group)
+	case snapshotID.IsNull():
+		b.P("skipped creating snapshot %s\n", group)
+	default:
+		b.P("snapshot %s saved %s\n", snapshotID.Str(), group)
+	}
+}
+
+func (b *TextProgress) printSummary(d time.Duration, summary *Summary, dryRun bool) {
 	b.P("\n")
 	b.P("Files:       %5d new, %5d changed, %5d unmodified\n", summary.Files.New, summary.Files.Changed, summary.Files.Unchanged)
 	b.P("Dirs:        %5d new, %5d changed, %5d unmodified\n", summary.Dirs.New, summary.Dirs.Changed, summary.Dirs.Unchanged)
+	if summary.Files.Skipped > 0 {
+		b.P("Skipped:     %5d files, limit for new data reached\n", summary.Files.Skipped)
+	}
+	if summary.Files.ExcludedBySize > 0 {
+		b.P("Excluded:    %5d files larger than the size limit, %v in total\n", summary.Files.ExcludedBySize, ui.FormatBytes(summary.BytesExcludedBySize))
+	}
 	b.V("Data Blobs:  %5d new\n", summary.ItemStats.DataBlobs)
 	b.V("Tree Blobs:  %5d new\n", summary.ItemStats.TreeBlobs)
 	verb := "Added"
@@ -139,10 +169,22 @@ func (b *TextProgress) Finish(_ restic.ID, start time.Time, summary *Summary, dr
 	b.P("%s to the repository: %-5s (%-5s stored)\n", verb,
 		ui.FormatBytes(summary.ItemStats.DataSize+summary.ItemStats.TreeSize),
 		ui.FormatBytes(summary.ItemStats.DataSizeInRepo+summary.ItemStats.TreeSizeInRepo))
+	if dryRun {
+		b.P("Would reuse existing data: %-5s\n", ui.FormatBytes(summary.reusedBytes()))
+	}
 	b.P("\n")
 	b.P("processed %v files, %v in %s",
 		summary.Files.New+summary.Files.Changed+summary.Files.Unchanged,
 		ui.FormatBytes(summary.ProcessedBytes),
-		ui.FormatDuration(time.Since(start)),
+		ui.FormatDuration(d),
 	)
 }
+
+// FinishSecondary prints the result of copying the snapshot to a secondary repository.
+func (b *TextProgress) FinishSecondary(repository string, snapshotID restic.ID, err error) {
+	if err != nil {
+		b.E("unable to save snapshot to secondary repository %s: %v\n", repository, err)
+		return
+	}
+	b.P("snapshot %s saved to secondary repository %s\n", snapshotID.Str(), repository)
+}
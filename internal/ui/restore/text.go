@@ -43,5 +43,9 @@ func (t *textPrinter) Finish(filesFinished, filesTotal, allBytesWritten, allByte
 			filesFinished, filesTotal, formattedAllBytesWritten, formattedAllBytesTotal, timeLeft)
 	}
 
+	if duration > 0 {
+		summary += fmt.Sprintf(", %s/s", ui.FormatBytes(uint64(float64(allBytesWritten)/duration.Seconds())))
+	}
+
 	t.terminal.Print(summary)
 }